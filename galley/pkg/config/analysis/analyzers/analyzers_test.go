@@ -31,9 +31,12 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/auth"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deployment"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/deprecation"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/destinationrule"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/envoyfilter"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/gateway"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/injection"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/service"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/serviceentry"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/sidecar"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/virtualservice"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
@@ -50,12 +53,19 @@ type message struct {
 	origin      string
 }
 
+// fix is a fix suggested by a message, in the format {"<ResourceKind> <Name>.<Namespace>", patch}
+type fix struct {
+	origin string
+	patch  []diag.PatchOperation
+}
+
 type testCase struct {
 	name           string
 	inputFiles     []string
 	meshConfigFile string // Optional
 	analyzer       analysis.Analyzer
 	expected       []message
+	fixes          []fix // Optional, the fixes suggested by the expected messages
 }
 
 // Some notes on setting up tests for Analyzers:
@@ -79,6 +89,47 @@ var testGrid = []testCase{
 			{msg.MisplacedAnnotation, "Namespace staging"},
 		},
 	},
+	{
+		name:       "authorizationPolicies",
+		inputFiles: []string{"testdata/authorizationpolicies.yaml"},
+		analyzer:   &auth.AuthorizationPoliciesAnalyzer{},
+		expected: []message{
+			{msg.NoMatchingWorkloadsFound, "AuthorizationPolicy no-workload.foo"},
+			{msg.AuthorizationPolicyPrincipalNotFound, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyPrincipalNotFound, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyPrincipalNotFound, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.ReferencedResourceNotFound, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyOperationNeverApplies, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyOperationNeverApplies, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyShadowedByDeny, "AuthorizationPolicy productpage-viewer.foo"},
+			{msg.AuthorizationPolicyPrincipalNotFound, "AuthorizationPolicy dangling.foo"},
+			{msg.AuthorizationPolicyDeniesAllTraffic, "AuthorizationPolicy dangling.foo"},
+			{msg.AuthorizationPolicyOperationNeverApplies, "AuthorizationPolicy db-viewer.foo"},
+			{msg.AuthorizationPolicyDeniesAllTraffic, "AuthorizationPolicy db-deny.foo"},
+			{msg.AuthorizationPolicyDeniesAllTraffic, "AuthorizationPolicy deny-all.baz"},
+		},
+	},
+	{
+		name:       "mtls",
+		inputFiles: []string{"testdata/mtls-peerauthentication.yaml"},
+		analyzer:   &auth.MTLSAnalyzer{},
+		expected: []message{
+			{msg.MTLSPolicyConflict, "DestinationRule strict-plaintext.strict"},
+			{msg.PortLevelMTLSPortNotFound, "PeerAuthentication ports.ports"},
+			{msg.PortLevelMTLSPortNotFound, "DestinationRule ports.ports"},
+			{msg.MTLSPolicyConflict, "DestinationRule default.istio-system"},
+			{msg.DestinationRuleUsesMTLSForWorkloadWithoutSidecar, "DestinationRule default.istio-system"},
+		},
+	},
+	{
+		name:           "mtlsWithoutAutoMtls",
+		inputFiles:     []string{"testdata/mtls-peerauthentication-no-automtls.yaml"},
+		meshConfigFile: "testdata/mesh-without-automtls.yaml",
+		analyzer:       &auth.MTLSAnalyzer{},
+		expected: []message{
+			{msg.MTLSPolicyConflict, "DestinationRule reviews-subsets.default"},
+		},
+	},
 	{
 		name:       "serviceRoleBindings",
 		inputFiles: []string{"testdata/servicerolebindings.yaml"},
@@ -108,6 +159,27 @@ var testGrid = []testCase{
 			{msg.Deprecated, "ServiceRoleBinding bind-mongodb-viewer.default"},
 		},
 	},
+	{
+		name:       "destinationRuleSubsets",
+		inputFiles: []string{"testdata/destinationrule-subsets.yaml"},
+		analyzer:   &destinationrule.SubsetAnalyzer{},
+		expected: []message{
+			{msg.DestinationRuleSubsetMatchesNoPods, "DestinationRule reviews.default"},
+		},
+	},
+	{
+		name:       "envoyFilters",
+		inputFiles: []string{"testdata/envoyfilters.yaml"},
+		analyzer:   &envoyfilter.Analyzer{},
+		expected: []message{
+			{msg.NoMatchingWorkloadsFound, "EnvoyFilter no-workload.default"},
+			{msg.EnvoyFilterProxyVersionNeverMatches, "EnvoyFilter versions.default"},
+			{msg.EnvoyFilterRemovedFilter, "EnvoyFilter removed.default"},
+			{msg.EnvoyFilterDeprecatedConfig, "EnvoyFilter removed.default"},
+			{msg.EnvoyFilterOrderConflict, "EnvoyFilter ratelimit.default"},
+			{msg.EnvoyFilterOrderConflict, "EnvoyFilter lua.default"},
+		},
+	},
 	{
 		name:       "gatewayNoWorkload",
 		inputFiles: []string{"testdata/gateway-no-workload.yaml"},
@@ -116,6 +188,19 @@ var testGrid = []testCase{
 			{msg.ReferencedResourceNotFound, "Gateway httpbin-gateway"},
 		},
 	},
+	{
+		name:       "gatewayNoWorkloadFix",
+		inputFiles: []string{"testdata/gateway-selector-fix.yaml"},
+		analyzer:   &gateway.IngressGatewayPortAnalyzer{},
+		expected: []message{
+			{msg.ReferencedResourceNotFound, "Gateway httpbin-gateway.default"},
+		},
+		fixes: []fix{
+			{"Gateway httpbin-gateway.default", []diag.PatchOperation{
+				diag.Replace("/spec/selector", map[string]string{"istio": "ingressgateway"}),
+			}},
+		},
+	},
 	{
 		name:       "gatewayBadPort",
 		inputFiles: []string{"testdata/gateway-no-port.yaml"},
@@ -197,6 +282,11 @@ var testGrid = []testCase{
 			{msg.PortNameIsNotUnderNamingConvention, "Service my-service1.my-namespace1"},
 			{msg.PortNameIsNotUnderNamingConvention, "Service my-service2.my-namespace2"},
 		},
+		fixes: []fix{
+			{"Service my-service1.my-namespace1", []diag.PatchOperation{diag.Add("/spec/ports/0/name", "http-8080")}},
+			{"Service my-service1.my-namespace1", []diag.PatchOperation{diag.Add("/spec/ports/1/name", "tcp-8081")}},
+			{"Service my-service2.my-namespace2", []diag.PatchOperation{diag.Add("/spec/ports/0/name", "http-foo")}},
+		},
 	},
 	{
 		name:       "namedPort",
@@ -217,6 +307,16 @@ var testGrid = []testCase{
 		expected: []message{
 			{msg.MultipleSidecarsWithoutWorkloadSelectors, "Sidecar has-conflict-2.ns2"},
 			{msg.MultipleSidecarsWithoutWorkloadSelectors, "Sidecar has-conflict-1.ns2"},
+			{msg.MultipleSidecarsWithoutWorkloadSelectors, "Sidecar default.ns3"},
+			{msg.MultipleSidecarsWithoutWorkloadSelectors, "Sidecar reviews.ns3"},
+		},
+		fixes: []fix{
+			{"Sidecar has-conflict-2.ns2", []diag.PatchOperation{
+				diag.Add("/spec/workloadSelector", map[string]interface{}{"labels": map[string]string{"app": "has-conflict-2"}}),
+			}},
+			{"Sidecar reviews.ns3", []diag.PatchOperation{
+				diag.Add("/spec/workloadSelector", map[string]interface{}{"labels": map[string]string{"app": "reviews"}}),
+			}},
 		},
 	},
 	{
@@ -232,6 +332,19 @@ var testGrid = []testCase{
 			{msg.ConflictingSidecarWorkloadSelectors, "Sidecar overlap-2.default"},
 		},
 	},
+	{
+		name:       "sidecarSelectorFix",
+		inputFiles: []string{"testdata/sidecar-selector-fix.yaml"},
+		analyzer:   &sidecar.SelectorAnalyzer{},
+		expected: []message{
+			{msg.ReferencedResourceNotFound, "Sidecar reviews.default"},
+		},
+		fixes: []fix{
+			{"Sidecar reviews.default", []diag.PatchOperation{
+				diag.Replace("/spec/workloadSelector/labels", map[string]string{"app": "reviews"}),
+			}},
+		},
+	},
 	{
 		name:       "virtualServiceConflictingMeshGatewayHosts",
 		inputFiles: []string{"testdata/virtualservice_conflictingmeshgatewayhosts.yaml"},
@@ -274,6 +387,33 @@ var testGrid = []testCase{
 			{msg.ReferencedResourceNotFound, "VirtualService httpbin-bogus"},
 		},
 	},
+	{
+		name:       "virtualServiceUnreachableRoutes",
+		inputFiles: []string{"testdata/virtualservice_unreachableroutes.yaml"},
+		analyzer:   &virtualservice.UnreachableRouteAnalyzer{},
+		expected: []message{
+			{msg.VirtualServiceUnreachableRoute, "VirtualService catch-all-first.default"},
+			{msg.VirtualServiceUnreachableRoute, "VirtualService broader-prefix-first.default"},
+			{msg.VirtualServiceUnreachableRoute, "VirtualService header-method-authority-port.default"},
+			{msg.VirtualServiceUnreachableRoute, "VirtualService gateway-api-v2.default"},
+			{msg.VirtualServiceUnreachableRoute, "VirtualService gateway-api-v2.default"},
+		},
+	},
+	{
+		name:       "serviceEntryConflicts",
+		inputFiles: []string{"testdata/serviceentry-conflicts.yaml"},
+		analyzer:   &serviceentry.ConflictAnalyzer{},
+		expected: []message{
+			{msg.ServiceEntryConflict, "ServiceEntry reviews-tcp.default"},
+			{msg.ServiceEntryConflict, "ServiceEntry reviews-tcp.default"},
+			{msg.ServiceEntryConflict, "ServiceEntry reviews-vm.default"},
+			{msg.ServiceEntryConflict, "ServiceEntry external-dns.frontend"},
+			{msg.ServiceEntryConflict, "ServiceEntry external-static.backend"},
+			{msg.ServiceEntryConflict, "ServiceEntry wildcard-local.wild"},
+			{msg.ServiceEntryConflict, "ServiceEntry wildcard-external.wild"},
+			{msg.ServiceEntryConflict, "ServiceEntry concrete-tcp.wild"},
+		},
+	},
 	{
 		name:       "serviceMultipleDeployments",
 		inputFiles: []string{"testdata/deployment-multi-service.yaml"},
@@ -344,6 +484,7 @@ func TestAnalyzers(t *testing.T) {
 			}
 
 			g.Expect(extractFields(result.Messages)).To(ConsistOf(tc.expected), "%v", prettyPrintMessages(result.Messages))
+			g.Expect(extractFixes(result.Messages)).To(ConsistOf(tc.fixes))
 		})
 	}
 
@@ -484,6 +625,16 @@ func extractFields(msgs diag.Messages) []message {
 	return result
 }
 
+func extractFixes(msgs diag.Messages) []fix {
+	result := make([]fix, 0)
+	for _, m := range msgs {
+		for _, f := range m.Fixes {
+			result = append(result, fix{origin: m.Resource.Origin.FriendlyName(), patch: f.Patch})
+		}
+	}
+	return result
+}
+
 func prettyPrintMessages(msgs diag.Messages) string {
 	var sb strings.Builder
 	fmt.Fprintf(&sb, "Analyzer messages: %d\n", len(msgs))
@@ -0,0 +1,477 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/security/v1beta1"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// AuthorizationPoliciesAnalyzer checks that authorization policies select existing workloads and
+// refer to existing principals, namespaces, ports and paths, and that they do not deny more
+// traffic than intended.
+type AuthorizationPoliciesAnalyzer struct{}
+
+var _ analysis.Analyzer = &AuthorizationPoliciesAnalyzer{}
+
+// The trust domain workloads use by default, which is also a pointer to the trust domain of the mesh
+const defaultTrustDomain = "cluster.local"
+
+// Metadata implements Analyzer
+func (a *AuthorizationPoliciesAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "auth.AuthorizationPoliciesAnalyzer",
+		Description: "Checks the references and the effect of authorization policies",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+			collections.K8SCoreV1Namespaces.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// authzContext holds the workloads and the identities authorization policies refer to
+type authzContext struct {
+	rootNamespace resource.Namespace
+	trustDomains  map[string]bool
+	namespaces    map[resource.Namespace]bool
+	pods          map[resource.Namespace][]*resource.Instance
+	services      []*resource.Instance
+	// serviceAccounts used by the pods, as namespace/name
+	serviceAccounts map[string]bool
+}
+
+// Analyze implements Analyzer
+func (a *AuthorizationPoliciesAnalyzer) Analyze(c analysis.Context) {
+	ac := newAuthzContext(c)
+
+	var policies []*resource.Instance
+	c.ForEach(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(), func(r *resource.Instance) bool {
+		policies = append(policies, r)
+		return true
+	})
+	sort.Slice(policies, func(i, j int) bool {
+		return policies[i].Metadata.FullName.String() < policies[j].Metadata.FullName.String()
+	})
+
+	for _, r := range policies {
+		ac.analyzePolicy(c, r)
+	}
+	ac.analyzeShadowing(c, policies)
+}
+
+func newAuthzContext(c analysis.Context) *authzContext {
+	mc := util.MeshConfig(c)
+	ac := &authzContext{
+		rootNamespace:   resource.Namespace(mc.GetRootNamespace()),
+		trustDomains:    map[string]bool{defaultTrustDomain: true},
+		namespaces:      map[resource.Namespace]bool{},
+		pods:            map[resource.Namespace][]*resource.Instance{},
+		serviceAccounts: map[string]bool{},
+	}
+	if ac.rootNamespace == "" {
+		ac.rootNamespace = constants.IstioSystemNamespace
+	}
+	if td := mc.GetTrustDomain(); td != "" {
+		ac.trustDomains[td] = true
+	}
+	for _, td := range mc.GetTrustDomainAliases() {
+		ac.trustDomains[td] = true
+	}
+
+	c.ForEach(collections.K8SCoreV1Namespaces.Name(), func(r *resource.Instance) bool {
+		ac.namespaces[resource.Namespace(r.Metadata.FullName.Name)] = true
+		return true
+	})
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		ns := r.Metadata.FullName.Namespace
+		ac.namespaces[ns] = true
+		ac.pods[ns] = append(ac.pods[ns], r)
+		sa := r.Message.(*v1.Pod).Spec.ServiceAccountName
+		if sa == "" {
+			sa = "default"
+		}
+		ac.serviceAccounts[string(ns)+"/"+sa] = true
+		return true
+	})
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(r *resource.Instance) bool {
+		ac.namespaces[r.Metadata.FullName.Namespace] = true
+		ac.services = append(ac.services, r)
+		return true
+	})
+	return ac
+}
+
+// workloadsOf returns the pods the policy applies to, with a description of them
+func (ac *authzContext) workloadsOf(r *resource.Instance) ([]*resource.Instance, string) {
+	ns := r.Metadata.FullName.Namespace
+	if selector := r.Message.(*v1beta1.AuthorizationPolicy).GetSelector().GetMatchLabels(); len(selector) > 0 {
+		return selectedPods(selector, ac.pods[ns]),
+			fmt.Sprintf("the workloads with labels %s", k8s_labels.SelectorFromSet(selector))
+	}
+	if ns == ac.rootNamespace {
+		var all []*resource.Instance
+		for _, pods := range ac.pods {
+			all = append(all, pods...)
+		}
+		return all, "the mesh"
+	}
+	return ac.pods[ns], fmt.Sprintf("the namespace %s", ns)
+}
+
+func (ac *authzContext) analyzePolicy(c analysis.Context, r *resource.Instance) {
+	ap := r.Message.(*v1beta1.AuthorizationPolicy)
+	workloads, target := ac.workloadsOf(r)
+	if selector := ap.GetSelector().GetMatchLabels(); len(selector) > 0 && len(workloads) == 0 {
+		c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+			msg.NewNoMatchingWorkloadsFound(r, k8s_labels.SelectorFromSet(selector).String()))
+	}
+	ports := workloadPorts(workloads, ac.services)
+
+	deadRules := 0
+	for i, rule := range ap.Rules {
+		if ac.analyzeSources(c, r, i, rule) {
+			deadRules++
+		}
+		analyzeOperations(c, r, i, rule, ports)
+	}
+
+	switch ap.Action {
+	case v1beta1.AuthorizationPolicy_ALLOW:
+		// An ALLOW policy without rules is the way to deny all traffic on purpose
+		if len(ap.Rules) > 0 && deadRules == len(ap.Rules) {
+			c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+				msg.NewAuthorizationPolicyDeniesAllTraffic(r, target, "none of its rules matches any request"))
+		}
+	case v1beta1.AuthorizationPolicy_DENY:
+		tcpPorts := tcpPortsOf(ports)
+		for i, rule := range ap.Rules {
+			if len(rule.From) == 0 && len(rule.To) == 0 && len(rule.When) == 0 {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyDeniesAllTraffic(r, target, fmt.Sprintf("rule %d matches all requests", i)))
+			} else if len(tcpPorts) > 0 && matchesAllTCP(rule) {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyDeniesAllTraffic(r, target, fmt.Sprintf(
+						"rule %d only has HTTP conditions, which are ignored on the TCP ports %v", i, tcpPorts)))
+			}
+		}
+	}
+}
+
+// analyzeSources reports the principals and namespaces matching no workload, and returns whether
+// the rule never matches because of them.
+func (ac *authzContext) analyzeSources(c analysis.Context, r *resource.Instance, i int, rule *v1beta1.Rule) bool {
+	deadSources := 0
+	for _, from := range rule.From {
+		src := from.GetSource()
+		principalsDead := len(src.GetPrincipals()) > 0
+		for _, p := range src.GetPrincipals() {
+			if problem := ac.principalProblem(p); problem != "" {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyPrincipalNotFound(r, p, i, problem))
+			} else {
+				principalsDead = false
+			}
+		}
+		namespacesDead := len(src.GetNamespaces()) > 0
+		for _, ns := range src.GetNamespaces() {
+			if !strings.Contains(ns, "*") && !ac.namespaces[resource.Namespace(ns)] {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewReferencedResourceNotFound(r, "namespace", ns))
+			} else {
+				namespacesDead = false
+			}
+		}
+		if principalsDead || namespacesDead {
+			deadSources++
+		}
+	}
+	return len(rule.From) > 0 && deadSources == len(rule.From)
+}
+
+// principalProblem returns why the principal matches no workload, or an empty string. Only the
+// principals of the form <trust domain>/ns/<namespace>/sa/<service account> without wildcard
+// are checked.
+func (ac *authzContext) principalProblem(principal string) string {
+	if strings.Contains(principal, "*") {
+		return ""
+	}
+	parts := strings.Split(principal, "/")
+	if len(parts) != 5 || parts[1] != "ns" || parts[3] != "sa" {
+		return ""
+	}
+	td, ns, sa := parts[0], resource.Namespace(parts[2]), parts[4]
+	if !ac.trustDomains[td] {
+		return fmt.Sprintf("the trust domain %q is neither the trust domain of the mesh nor one of its aliases", td)
+	}
+	if !ac.namespaces[ns] {
+		return fmt.Sprintf("the namespace %q does not exist", ns)
+	}
+	if len(ac.pods[ns]) > 0 && !ac.serviceAccounts[string(ns)+"/"+sa] {
+		return fmt.Sprintf("no pod runs with the service account %q in the namespace %q", sa, ns)
+	}
+	return ""
+}
+
+// analyzeOperations reports the ports and the paths of the rule that never apply to the workloads
+func analyzeOperations(c analysis.Context, r *resource.Instance, i int, rule *v1beta1.Rule, ports map[uint32]protocol.Instance) {
+	for _, to := range rule.To {
+		op := to.GetOperation()
+		for _, port := range op.GetPorts() {
+			number, err := strconv.ParseUint(port, 10, 32)
+			if err != nil || len(ports) == 0 {
+				continue
+			}
+			if _, exists := ports[uint32(number)]; !exists {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyOperationNeverApplies(r, "port", port, i, "none of them exposes it"))
+			}
+		}
+		for _, path := range op.GetPaths() {
+			if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "*") {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyOperationNeverApplies(r, "path", path, i, "request paths start with /"))
+			} else if len(ports) > 0 && len(tcpPortsOf(ports)) == len(ports) {
+				c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+					msg.NewAuthorizationPolicyOperationNeverApplies(r, "path", path, i, "none of them serves HTTP"))
+			}
+		}
+	}
+}
+
+// tcpPortsOf returns the sorted ports using a TCP protocol other than HTTP
+func tcpPortsOf(ports map[uint32]protocol.Instance) []int {
+	var out []int
+	for port, p := range ports {
+		if p.IsTCP() || p.IsTLS() {
+			out = append(out, int(port))
+		}
+	}
+	sort.Ints(out)
+	return out
+}
+
+// matchesAllTCP returns whether the rule of a DENY policy matches all the connections to TCP ports.
+// The HTTP conditions do not apply to TCP connections: Pilot ignores them in DENY policies, which
+// results in denying more connections.
+func matchesAllTCP(rule *v1beta1.Rule) bool {
+	fromAll := len(rule.From) == 0
+	for _, from := range rule.From {
+		src := from.GetSource()
+		if len(src.GetPrincipals()) == 0 && len(src.GetNotPrincipals()) == 0 &&
+			len(src.GetNamespaces()) == 0 && len(src.GetNotNamespaces()) == 0 &&
+			len(src.GetIpBlocks()) == 0 && len(src.GetNotIpBlocks()) == 0 {
+			fromAll = true
+		}
+	}
+	toAll := len(rule.To) == 0
+	for _, to := range rule.To {
+		if len(to.GetOperation().GetPorts()) == 0 && len(to.GetOperation().GetNotPorts()) == 0 {
+			toAll = true
+		}
+	}
+	for _, when := range rule.When {
+		if !strings.HasPrefix(when.Key, "request.") && !strings.HasPrefix(when.Key, "experimental.") {
+			return false
+		}
+	}
+	return fromAll && toAll
+}
+
+// analyzeShadowing reports the rules of ALLOW policies matching requests all denied by a DENY policy
+func (ac *authzContext) analyzeShadowing(c analysis.Context, policies []*resource.Instance) {
+	var denies []*resource.Instance
+	for _, r := range policies {
+		if r.Message.(*v1beta1.AuthorizationPolicy).Action == v1beta1.AuthorizationPolicy_DENY {
+			denies = append(denies, r)
+		}
+	}
+
+	for _, r := range policies {
+		ap := r.Message.(*v1beta1.AuthorizationPolicy)
+		if ap.Action != v1beta1.AuthorizationPolicy_ALLOW {
+			continue
+		}
+	rules:
+		for i, rule := range ap.Rules {
+			for _, d := range denies {
+				if !ac.covers(d, r) {
+					continue
+				}
+				for _, denyRule := range d.Message.(*v1beta1.AuthorizationPolicy).Rules {
+					if ruleSubsumes(denyRule, rule) {
+						c.Report(collections.IstioSecurityV1Beta1Authorizationpolicies.Name(),
+							msg.NewAuthorizationPolicyShadowedByDeny(r, i, d.Metadata.FullName.String()))
+						continue rules
+					}
+				}
+			}
+		}
+	}
+}
+
+// covers returns whether policy a applies to all the workloads policy b applies to
+func (ac *authzContext) covers(a, b *resource.Instance) bool {
+	aSelector := a.Message.(*v1beta1.AuthorizationPolicy).GetSelector().GetMatchLabels()
+	bSelector := b.Message.(*v1beta1.AuthorizationPolicy).GetSelector().GetMatchLabels()
+	aNs, bNs := a.Metadata.FullName.Namespace, b.Metadata.FullName.Namespace
+	switch {
+	case aNs == ac.rootNamespace && len(aSelector) == 0:
+		return true
+	case aNs != bNs:
+		return false
+	case len(aSelector) == 0:
+		return true
+	case len(bSelector) == 0:
+		return false
+	}
+
+	bPods := selectedPods(bSelector, ac.pods[bNs])
+	if len(bPods) == 0 {
+		return false
+	}
+	aPods := map[*resource.Instance]bool{}
+	for _, pod := range selectedPods(aSelector, ac.pods[aNs]) {
+		aPods[pod] = true
+	}
+	for _, pod := range bPods {
+		if !aPods[pod] {
+			return false
+		}
+	}
+	return true
+}
+
+// ruleSubsumes returns whether rule a matches all the requests rule b matches. It errs on the side
+// of false when the rules are too complex to compare.
+func ruleSubsumes(a, b *v1beta1.Rule) bool {
+	if len(a.When) > 0 {
+		return false
+	}
+	if len(a.From) > 0 {
+		if len(b.From) == 0 {
+			return false
+		}
+		for _, bFrom := range b.From {
+			found := false
+			for _, aFrom := range a.From {
+				if sourceSubsumes(aFrom.GetSource(), bFrom.GetSource()) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	if len(a.To) > 0 {
+		if len(b.To) == 0 {
+			return false
+		}
+		for _, bTo := range b.To {
+			found := false
+			for _, aTo := range a.To {
+				if operationSubsumes(aTo.GetOperation(), bTo.GetOperation()) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func sourceSubsumes(a, b *v1beta1.Source) bool {
+	if len(a.GetNotPrincipals()) > 0 || len(a.GetNotRequestPrincipals()) > 0 ||
+		len(a.GetNotNamespaces()) > 0 || len(a.GetNotIpBlocks()) > 0 {
+		return false
+	}
+	return valuesSubsume(a.GetPrincipals(), b.GetPrincipals()) &&
+		valuesSubsume(a.GetRequestPrincipals(), b.GetRequestPrincipals()) &&
+		valuesSubsume(a.GetNamespaces(), b.GetNamespaces()) &&
+		valuesSubsume(a.GetIpBlocks(), b.GetIpBlocks())
+}
+
+func operationSubsumes(a, b *v1beta1.Operation) bool {
+	if len(a.GetNotHosts()) > 0 || len(a.GetNotPorts()) > 0 ||
+		len(a.GetNotMethods()) > 0 || len(a.GetNotPaths()) > 0 {
+		return false
+	}
+	return valuesSubsume(a.GetHosts(), b.GetHosts()) &&
+		valuesSubsume(a.GetPorts(), b.GetPorts()) &&
+		valuesSubsume(a.GetMethods(), b.GetMethods()) &&
+		valuesSubsume(a.GetPaths(), b.GetPaths())
+}
+
+// valuesSubsume returns whether the values of a field in a match all the values of the field in b.
+// A field without values matches any value.
+func valuesSubsume(a, b []string) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, bv := range b {
+		found := false
+		for _, av := range a {
+			if valueCovers(av, bv) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// valueCovers returns whether the value a, which may have a prefix or suffix wildcard, matches
+// all the values b matches.
+func valueCovers(a, b string) bool {
+	switch {
+	case a == "*":
+		return true
+	case strings.HasSuffix(a, "*"):
+		return strings.HasPrefix(b, strings.TrimSuffix(a, "*"))
+	case strings.HasPrefix(a, "*"):
+		return strings.HasSuffix(b, strings.TrimPrefix(a, "*")) && !strings.HasSuffix(b, "*")
+	default:
+		return a == b
+	}
+}
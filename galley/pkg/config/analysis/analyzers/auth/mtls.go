@@ -0,0 +1,325 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/networking/v1alpha3"
+	"istio.io/api/security/v1beta1"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// MTLSAnalyzer checks that the mTLS settings of clients, from destination rules and auto mTLS,
+// agree with the mTLS settings of servers, from peer authentications at the mesh, namespace,
+// workload and port levels.
+type MTLSAnalyzer struct{}
+
+var _ analysis.Analyzer = &MTLSAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *MTLSAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "auth.MTLSAnalyzer",
+		Description: "Checks for mTLS settings of clients and servers that do not agree",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+			collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// peerAuthentications indexes the peer authentications by level
+type peerAuthentications struct {
+	mesh       *resource.Instance
+	namespaces map[resource.Namespace]*resource.Instance
+	// workload level peer authentications, oldest first
+	workloads map[resource.Namespace][]*resource.Instance
+}
+
+// Analyze implements Analyzer
+func (a *MTLSAnalyzer) Analyze(c analysis.Context) {
+	mc := util.MeshConfig(c)
+	rootNamespace := resource.Namespace(mc.GetRootNamespace())
+	if rootNamespace == "" {
+		rootNamespace = constants.IstioSystemNamespace
+	}
+	autoMTLS := mc.GetEnableAutoMtls().GetValue()
+
+	pas := getPeerAuthentications(c, rootNamespace)
+	pods := map[resource.Namespace][]*resource.Instance{}
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		pods[r.Metadata.FullName.Namespace] = append(pods[r.Metadata.FullName.Namespace], r)
+		return true
+	})
+	var services []*resource.Instance
+	c.ForEach(collections.K8SCoreV1Services.Name(), func(r *resource.Instance) bool {
+		services = append(services, r)
+		return true
+	})
+
+	for _, workloads := range pas.workloads {
+		for _, r := range workloads {
+			analyzePortLevelMTLS(c, r, pods, services)
+		}
+	}
+
+	var drs []*resource.Instance
+	c.ForEach(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), func(r *resource.Instance) bool {
+		drs = append(drs, r)
+		return true
+	})
+	// A destination rule applying to several pods or ports would report the same problem again
+	reported := map[string]bool{}
+	for _, svc := range services {
+		ns := svc.Metadata.FullName.Namespace
+		if util.IsSystemNamespace(ns) || util.IsIstioControlPlane(svc) {
+			continue
+		}
+		fqdn := util.ConvertHostToFQDN(ns, string(svc.Metadata.FullName.Name))
+		backends := selectedPods(svc.Message.(*v1.ServiceSpec).Selector, pods[ns])
+		for _, dr := range destinationRulesFor(fqdn, ns, rootNamespace, drs) {
+			for _, m := range analyzeDestinationRule(dr, svc, fqdn, backends, pas, autoMTLS) {
+				if !reported[m.String()] {
+					reported[m.String()] = true
+					c.Report(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), m)
+				}
+			}
+		}
+	}
+}
+
+func getPeerAuthentications(c analysis.Context, rootNamespace resource.Namespace) *peerAuthentications {
+	pas := &peerAuthentications{
+		namespaces: map[resource.Namespace]*resource.Instance{},
+		workloads:  map[resource.Namespace][]*resource.Instance{},
+	}
+	var all []*resource.Instance
+	c.ForEach(collections.IstioSecurityV1Beta1Peerauthentications.Name(), func(r *resource.Instance) bool {
+		all = append(all, r)
+		return true
+	})
+	// When several peer authentications apply at the same level, the oldest one wins
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i].Metadata, all[j].Metadata
+		if a.CreateTime.Equal(b.CreateTime) {
+			return a.FullName.String() < b.FullName.String()
+		}
+		return a.CreateTime.Before(b.CreateTime)
+	})
+	for _, r := range all {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+		ns := r.Metadata.FullName.Namespace
+		switch {
+		case len(pa.GetSelector().GetMatchLabels()) > 0:
+			pas.workloads[ns] = append(pas.workloads[ns], r)
+		case ns == rootNamespace:
+			if pas.mesh == nil {
+				pas.mesh = r
+			}
+		default:
+			if _, exists := pas.namespaces[ns]; !exists {
+				pas.namespaces[ns] = r
+			}
+		}
+	}
+	return pas
+}
+
+// modeFor returns the mTLS mode of the pod for the port, along with the peer authentication
+// setting it. The mode defaults to PERMISSIVE when no peer authentication sets it.
+func (p *peerAuthentications) modeFor(pod *resource.Instance, port uint32) (v1beta1.PeerAuthentication_MutualTLS_Mode, *resource.Instance) {
+	ns := pod.Metadata.FullName.Namespace
+	for _, r := range p.workloads[ns] {
+		pa := r.Message.(*v1beta1.PeerAuthentication)
+		if !k8s_labels.SelectorFromSet(pa.Selector.MatchLabels).Matches(k8s_labels.Set(pod.Metadata.Labels)) {
+			continue
+		}
+		if mode := pa.PortLevelMtls[port].GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			return mode, r
+		}
+		if mode := pa.GetMtls().GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			return mode, r
+		}
+		break
+	}
+	for _, r := range []*resource.Instance{p.namespaces[ns], p.mesh} {
+		if r == nil {
+			continue
+		}
+		if mode := r.Message.(*v1beta1.PeerAuthentication).GetMtls().GetMode(); mode != v1beta1.PeerAuthentication_MutualTLS_UNSET {
+			return mode, r
+		}
+	}
+	return v1beta1.PeerAuthentication_MutualTLS_PERMISSIVE, nil
+}
+
+// analyzePortLevelMTLS reports the port-level settings of a workload peer authentication for
+// ports that neither the selected pods nor their services expose.
+func analyzePortLevelMTLS(c analysis.Context, r *resource.Instance, pods map[resource.Namespace][]*resource.Instance,
+	services []*resource.Instance) {
+	pa := r.Message.(*v1beta1.PeerAuthentication)
+	if len(pa.PortLevelMtls) == 0 {
+		return
+	}
+	ns := r.Metadata.FullName.Namespace
+	selected := selectedPods(pa.Selector.MatchLabels, pods[ns])
+	// Reporting selectors matching no pods is not the concern of this analyzer
+	if len(selected) == 0 {
+		return
+	}
+
+	ports := workloadPorts(selected, services)
+	numbers := make([]int, 0, len(pa.PortLevelMtls))
+	for port := range pa.PortLevelMtls {
+		numbers = append(numbers, int(port))
+	}
+	sort.Ints(numbers)
+	for _, port := range numbers {
+		if _, exists := ports[uint32(port)]; !exists {
+			c.Report(collections.IstioSecurityV1Beta1Peerauthentications.Name(),
+				msg.NewPortLevelMTLSPortNotFound(r, port, "the selected workloads"))
+		}
+	}
+}
+
+// destinationRulesFor returns the destination rules clients use for the host of a service. Clients
+// use the destination rule of their namespace, of the service namespace or of the root namespace,
+// picking the most specific host in a namespace. The destination rules of the root namespace
+// are overridden by a destination rule of the service namespace exported to all namespaces.
+func destinationRulesFor(fqdn string, ns, rootNamespace resource.Namespace, drs []*resource.Instance) []*resource.Instance {
+	best := map[resource.Namespace]*resource.Instance{}
+	bestHost := map[resource.Namespace]host.Name{}
+	for _, r := range drs {
+		drNs := r.Metadata.FullName.Namespace
+		h := host.Name(util.ConvertHostToFQDN(drNs, r.Message.(*v1alpha3.DestinationRule).Host))
+		if !host.Name(fqdn).SubsetOf(h) {
+			continue
+		}
+		if current, exists := bestHost[drNs]; exists && moreSpecific(current, h) {
+			continue
+		}
+		best[drNs] = r
+		bestHost[drNs] = h
+	}
+
+	if own, exists := best[ns]; exists && ns != rootNamespace &&
+		util.IsExportToAllNamespaces(own.Message.(*v1alpha3.DestinationRule).ExportTo) {
+		delete(best, rootNamespace)
+	}
+	out := make([]*resource.Instance, 0, len(best))
+	for _, r := range best {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Metadata.FullName.String() < out[j].Metadata.FullName.String() })
+	return out
+}
+
+// moreSpecific returns whether host a is at least as specific as host b
+func moreSpecific(a, b host.Name) bool {
+	if a.IsWildCarded() != b.IsWildCarded() {
+		return !a.IsWildCarded()
+	}
+	return len(a) >= len(b)
+}
+
+// analyzeDestinationRule returns the messages about the mTLS settings of the destination rule for
+// the service and the pods backing it.
+func analyzeDestinationRule(r *resource.Instance, svc *resource.Instance, fqdn string, backends []*resource.Instance,
+	pas *peerAuthentications, autoMTLS bool) []diag.Message {
+	dr := r.Message.(*v1alpha3.DestinationRule)
+	spec := svc.Message.(*v1.ServiceSpec)
+	drName := r.Metadata.FullName.String()
+	var messages []diag.Message
+
+	servicePorts := map[uint32]bool{}
+	for _, port := range spec.Ports {
+		servicePorts[uint32(port.Port)] = true
+	}
+	// Port-level settings only matter for the host of the destination rule: a wildcard host
+	// may cover services with different ports.
+	if !host.Name(dr.Host).IsWildCarded() {
+		for _, pls := range dr.GetTrafficPolicy().GetPortLevelSettings() {
+			number := pls.GetPort().GetNumber()
+			if pls.Tls != nil && number != 0 && !servicePorts[number] {
+				messages = append(messages, msg.NewPortLevelMTLSPortNotFound(r, int(number), "service "+svc.Metadata.FullName.String()))
+			}
+		}
+	}
+
+	for _, port := range spec.Ports {
+		if port.Protocol != "" && port.Protocol != v1.ProtocolTCP {
+			continue
+		}
+		mode, set := clientTLSMode(dr, uint32(port.Port))
+		if !set {
+			if autoMTLS {
+				// Clients use mTLS towards the pods with sidecars, and plain text to the other ones
+				continue
+			}
+			mode = v1alpha3.ClientTLSSettings_DISABLE
+		}
+		if mode != v1alpha3.ClientTLSSettings_DISABLE && mode != v1alpha3.ClientTLSSettings_ISTIO_MUTUAL {
+			continue
+		}
+		for _, pod := range backends {
+			if !util.HasSidecar(pod) {
+				if mode == v1alpha3.ClientTLSSettings_ISTIO_MUTUAL {
+					messages = append(messages, msg.NewDestinationRuleUsesMTLSForWorkloadWithoutSidecar(r, drName, fqdn))
+				}
+				continue
+			}
+			target, ok := targetPort(port, pod)
+			if !ok {
+				continue
+			}
+			serverMode, pa := pas.modeFor(pod, target)
+			if (mode == v1alpha3.ClientTLSSettings_DISABLE && serverMode == v1beta1.PeerAuthentication_MutualTLS_STRICT) ||
+				(mode == v1alpha3.ClientTLSSettings_ISTIO_MUTUAL && serverMode == v1beta1.PeerAuthentication_MutualTLS_DISABLE) {
+				messages = append(messages, msg.NewMTLSPolicyConflict(r, fqdn, drName, mode == v1alpha3.ClientTLSSettings_ISTIO_MUTUAL,
+					pa.Metadata.FullName.String(), serverMode.String()))
+			}
+		}
+	}
+	return messages
+}
+
+// clientTLSMode returns the TLS mode of the destination rule for the port, and whether it is set
+func clientTLSMode(dr *v1alpha3.DestinationRule, port uint32) (v1alpha3.ClientTLSSettings_TLSmode, bool) {
+	for _, pls := range dr.GetTrafficPolicy().GetPortLevelSettings() {
+		if pls.GetPort().GetNumber() == port && pls.Tls != nil {
+			return pls.Tls.Mode, true
+		}
+	}
+	if tls := dr.GetTrafficPolicy().GetTls(); tls != nil {
+		return tls.Mode, true
+	}
+	return v1alpha3.ClientTLSSettings_DISABLE, false
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auth
+
+import (
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	configKube "istio.io/istio/pkg/config/kube"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/resource"
+)
+
+// selectedPods returns the pods matching the selector. An empty selector matches no pods.
+func selectedPods(selector map[string]string, pods []*resource.Instance) []*resource.Instance {
+	if len(selector) == 0 {
+		return nil
+	}
+	s := k8s_labels.SelectorFromSet(selector)
+	var out []*resource.Instance
+	for _, pod := range pods {
+		if s.Matches(k8s_labels.Set(pod.Metadata.Labels)) {
+			out = append(out, pod)
+		}
+	}
+	return out
+}
+
+// targetPort returns the port of the pod the service port targets
+func targetPort(port v1.ServicePort, pod *resource.Instance) (uint32, bool) {
+	switch {
+	case port.TargetPort.Type == intstr.String:
+		for _, container := range pod.Message.(*v1.Pod).Spec.Containers {
+			for _, p := range container.Ports {
+				if p.Name == port.TargetPort.StrVal {
+					return uint32(p.ContainerPort), true
+				}
+			}
+		}
+		return 0, false
+	case port.TargetPort.IntVal != 0:
+		return uint32(port.TargetPort.IntVal), true
+	default:
+		return uint32(port.Port), true
+	}
+}
+
+// workloadPorts returns the ports of the pods, exposed by their containers or targeted by the
+// services selecting them, with the protocol of the service ports. The protocol of the ports only
+// exposed by containers is empty.
+func workloadPorts(pods []*resource.Instance, services []*resource.Instance) map[uint32]protocol.Instance {
+	ports := map[uint32]protocol.Instance{}
+	for _, pod := range pods {
+		for _, container := range pod.Message.(*v1.Pod).Spec.Containers {
+			for _, port := range container.Ports {
+				if _, exists := ports[uint32(port.ContainerPort)]; !exists {
+					ports[uint32(port.ContainerPort)] = ""
+				}
+			}
+		}
+		for _, svc := range services {
+			if svc.Metadata.FullName.Namespace != pod.Metadata.FullName.Namespace {
+				continue
+			}
+			spec := svc.Message.(*v1.ServiceSpec)
+			if len(selectedPods(spec.Selector, []*resource.Instance{pod})) == 0 {
+				continue
+			}
+			for _, port := range spec.Ports {
+				if target, ok := targetPort(port, pod); ok {
+					ports[target] = configKube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol)
+				}
+			}
+		}
+	}
+	return ports
+}
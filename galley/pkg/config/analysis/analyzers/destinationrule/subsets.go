@@ -0,0 +1,107 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package destinationrule
+
+import (
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// SubsetAnalyzer checks that the subsets of destination rules match pods of the services they
+// apply to. Requests routed to a subset without pods fail with no healthy upstream.
+type SubsetAnalyzer struct{}
+
+var _ analysis.Analyzer = &SubsetAnalyzer{}
+
+// Metadata implements Analyzer
+func (s *SubsetAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "destinationrule.SubsetAnalyzer",
+		Description: "Checks that the subsets of destination rules match pods of their service",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+			collections.K8SCoreV1Pods.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// Analyze implements Analyzer
+func (s *SubsetAnalyzer) Analyze(ctx analysis.Context) {
+	podsByNamespace := map[resource.Namespace][]*resource.Instance{}
+	ctx.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		ns := r.Metadata.FullName.Namespace
+		podsByNamespace[ns] = append(podsByNamespace[ns], r)
+		return true
+	})
+
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Destinationrules.Name(), func(r *resource.Instance) bool {
+		dr := r.Message.(*v1alpha3.DestinationRule)
+		if strings.HasPrefix(dr.Host, util.Wildcard) || len(dr.Subsets) == 0 {
+			return true
+		}
+
+		svcName := util.GetResourceNameFromHost(r.Metadata.FullName.Namespace, dr.Host)
+		svc := ctx.Find(collections.K8SCoreV1Services.Name(), svcName)
+		if svc == nil {
+			return true
+		}
+		// Without selector, the endpoints of the service are not managed by Kubernetes
+		selector := svc.Message.(*v1.ServiceSpec).Selector
+		if len(selector) == 0 {
+			return true
+		}
+		var backends []*resource.Instance
+		for _, pod := range podsByNamespace[svcName.Namespace] {
+			if k8s_labels.SelectorFromSet(selector).Matches(k8s_labels.Set(pod.Metadata.Labels)) {
+				backends = append(backends, pod)
+			}
+		}
+		// A service without pods is scaled down, which is not a problem of its subsets
+		if len(backends) == 0 {
+			return true
+		}
+
+		for _, subset := range dr.Subsets {
+			if len(subset.Labels) == 0 {
+				continue
+			}
+			subsetSelector := k8s_labels.SelectorFromSet(subset.Labels)
+			matched := false
+			for _, pod := range backends {
+				if subsetSelector.Matches(k8s_labels.Set(pod.Metadata.Labels)) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				ctx.Report(collections.IstioNetworkingV1Alpha3Destinationrules.Name(),
+					msg.NewDestinationRuleSubsetMatchesNoPods(r, subset.Name, svcName.String(), subsetSelector.String()))
+			}
+		}
+		return true
+	})
+}
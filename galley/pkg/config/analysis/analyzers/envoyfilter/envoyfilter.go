@@ -0,0 +1,364 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package envoyfilter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/annotation"
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/constants"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// Analyzer checks that EnvoyFilters select running workloads and proxy versions, do not use
+// filters or fields removed from the proxy, and do not depend on the order they are applied in.
+type Analyzer struct{}
+
+var _ analysis.Analyzer = &Analyzer{}
+
+// The environment variable of the proxy container holding the Istio version of the proxy
+const istioVersionEnv = "ISTIO_META_ISTIO_VERSION"
+
+// removedFilters maps the names of the filters removed from the proxy to their replacement
+var removedFilters = map[string]string{
+	"jwt-auth": "envoy.filters.http.jwt_authn",
+}
+
+// deprecatedFields maps the deprecated fields of the filter configurations to their replacement
+var deprecatedFields = map[string]string{
+	"config": "typed_config",
+}
+
+// Metadata implements Analyzer
+func (a *Analyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "envoyfilter.Analyzer",
+		Description: "Checks the workloads, the proxy versions and the filters EnvoyFilters apply to",
+		Inputs: collection.Names{
+			collections.IstioMeshV1Alpha1MeshConfig.Name(),
+			collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+			collections.K8SCoreV1Pods.Name(),
+		},
+	}
+}
+
+// envoyFilter is an EnvoyFilter with the pods it applies to
+type envoyFilter struct {
+	r    *resource.Instance
+	ef   *v1alpha3.EnvoyFilter
+	root bool
+	pods map[*resource.Instance]bool
+}
+
+// Analyze implements Analyzer
+func (a *Analyzer) Analyze(c analysis.Context) {
+	rootNamespace := resource.Namespace(util.MeshConfig(c).GetRootNamespace())
+	if rootNamespace == "" {
+		rootNamespace = constants.IstioSystemNamespace
+	}
+
+	var pods []*resource.Instance
+	c.ForEach(collections.K8SCoreV1Pods.Name(), func(r *resource.Instance) bool {
+		if util.HasSidecar(r) {
+			pods = append(pods, r)
+		}
+		return true
+	})
+
+	var filters []*envoyFilter
+	c.ForEach(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(), func(r *resource.Instance) bool {
+		f := &envoyFilter{
+			r:    r,
+			ef:   r.Message.(*v1alpha3.EnvoyFilter),
+			root: r.Metadata.FullName.Namespace == rootNamespace,
+			pods: map[*resource.Instance]bool{},
+		}
+		selector := k8s_labels.SelectorFromSet(f.ef.GetWorkloadSelector().GetLabels())
+		for _, pod := range pods {
+			if (f.root || pod.Metadata.FullName.Namespace == r.Metadata.FullName.Namespace) &&
+				selector.Matches(k8s_labels.Set(pod.Metadata.Labels)) {
+				f.pods[pod] = true
+			}
+		}
+		filters = append(filters, f)
+		return true
+	})
+
+	// Sort the filters in the order Pilot applies them: the ones of the root namespace first,
+	// then by creation time.
+	sort.SliceStable(filters, func(i, j int) bool {
+		if filters[i].root != filters[j].root {
+			return filters[i].root
+		}
+		ti, tj := filters[i].r.Metadata.CreateTime, filters[j].r.Metadata.CreateTime
+		if ti.Equal(tj) {
+			return applyKey(filters[i].r) < applyKey(filters[j].r)
+		}
+		return ti.Before(tj)
+	})
+
+	for _, f := range filters {
+		if labels := f.ef.GetWorkloadSelector().GetLabels(); len(labels) > 0 && len(f.pods) == 0 {
+			c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+				msg.NewNoMatchingWorkloadsFound(f.r, k8s_labels.SelectorFromSet(labels).String()))
+		}
+		analyzeProxyVersions(c, f)
+		analyzeFilterNames(c, f)
+	}
+	analyzeOrder(c, filters)
+}
+
+// applyKey returns the key Pilot orders the EnvoyFilters created at the same time with
+func applyKey(r *resource.Instance) string {
+	return r.Metadata.FullName.Name.String() + "." + r.Metadata.FullName.Namespace.String()
+}
+
+// proxyVersion returns the Istio version of the proxy of the pod, or an empty string if unknown.
+// The version is taken from the proxy environment if set, or from the tag of the proxy image.
+func proxyVersion(pod *resource.Instance) string {
+	image := pod.Metadata.Annotations[annotation.SidecarProxyImage.Name]
+	for _, container := range pod.Message.(*v1.Pod).Spec.Containers {
+		if container.Name != util.IstioProxyName {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == istioVersionEnv && env.Value != "" {
+				return env.Value
+			}
+		}
+		if image == "" {
+			image = container.Image
+		}
+	}
+
+	image = strings.SplitN(image, "@", 2)[0]
+	i := strings.LastIndex(image, ":")
+	if i < 0 || i < strings.LastIndex(image, "/") {
+		return ""
+	}
+	// Only the tags starting like a version are versions, "latest" is not
+	tag := image[i+1:]
+	if tag == "" || tag[0] < '0' || tag[0] > '9' {
+		return ""
+	}
+	return tag
+}
+
+func analyzeProxyVersions(c analysis.Context, f *envoyFilter) {
+	versions := map[string]bool{}
+	for pod := range f.pods {
+		if v := proxyVersion(pod); v != "" {
+			versions[v] = true
+		}
+	}
+	if len(versions) == 0 {
+		return
+	}
+	sorted := make([]string, 0, len(versions))
+	for v := range versions {
+		sorted = append(sorted, v)
+	}
+	sort.Strings(sorted)
+
+	for i, cp := range f.ef.ConfigPatches {
+		regex := cp.GetMatch().GetProxy().GetProxyVersion()
+		if regex == "" {
+			continue
+		}
+		// Invalid regexes are reported by the validation
+		re, err := regexp.Compile(regex)
+		if err != nil {
+			continue
+		}
+		matched := false
+		for _, v := range sorted {
+			if re.MatchString(v) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+				msg.NewEnvoyFilterProxyVersionNeverMatches(f.r, regex, i, strings.Join(sorted, ", ")))
+		}
+	}
+}
+
+func analyzeFilterNames(c analysis.Context, f *envoyFilter) {
+	for i, cp := range f.ef.ConfigPatches {
+		filter := cp.GetMatch().GetListener().GetFilterChain().GetFilter()
+		for _, name := range []string{filter.GetName(), filter.GetSubFilter().GetName(), insertedFilter(cp)} {
+			if replacement, removed := removedFilters[name]; removed {
+				c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+					msg.NewEnvoyFilterRemovedFilter(f.r, i, name, replacement))
+			}
+		}
+
+		if !isFilter(cp.ApplyTo) {
+			continue
+		}
+		fields := cp.GetPatch().GetValue().GetFields()
+		for field, replacement := range deprecatedFields {
+			if _, exists := fields[field]; exists {
+				c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+					msg.NewEnvoyFilterDeprecatedConfig(f.r, i, field, replacement))
+			}
+		}
+	}
+}
+
+func isFilter(applyTo v1alpha3.EnvoyFilter_ApplyTo) bool {
+	return applyTo == v1alpha3.EnvoyFilter_NETWORK_FILTER || applyTo == v1alpha3.EnvoyFilter_HTTP_FILTER
+}
+
+func isInsertion(op v1alpha3.EnvoyFilter_Patch_Operation) bool {
+	return op == v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE || op == v1alpha3.EnvoyFilter_Patch_INSERT_AFTER ||
+		op == v1alpha3.EnvoyFilter_Patch_INSERT_FIRST || op == v1alpha3.EnvoyFilter_Patch_ADD
+}
+
+// insertedFilter returns the name of the filter the patch inserts, if any
+func insertedFilter(cp *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch) string {
+	if !isFilter(cp.ApplyTo) || !isInsertion(cp.GetPatch().GetOperation()) {
+		return ""
+	}
+	return cp.GetPatch().GetValue().GetFields()["name"].GetStringValue()
+}
+
+// anchor returns the name of the filter the patch is applied relative to, if any
+func anchor(cp *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch) string {
+	filter := cp.GetMatch().GetListener().GetFilterChain().GetFilter()
+	switch cp.ApplyTo {
+	case v1alpha3.EnvoyFilter_HTTP_FILTER:
+		return filter.GetSubFilter().GetName()
+	case v1alpha3.EnvoyFilter_NETWORK_FILTER:
+		return filter.GetName()
+	}
+	return ""
+}
+
+func overlap(a, b *envoyFilter) bool {
+	for pod := range a.pods {
+		if b.pods[pod] {
+			return true
+		}
+	}
+	return false
+}
+
+func sameContext(a, b *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch) bool {
+	ac, bc := a.GetMatch().GetContext(), b.GetMatch().GetContext()
+	return ac == bc || ac == v1alpha3.EnvoyFilter_ANY || bc == v1alpha3.EnvoyFilter_ANY
+}
+
+// analyzeOrder reports the patches whose result depends on the order the filters are applied in:
+// the patches relative to a filter inserted by a filter applied later, which do not apply, and the
+// insertions at the same position, which are ordered by creation time.
+func analyzeOrder(c analysis.Context, filters []*envoyFilter) {
+	for i, f := range filters {
+		for pi, cp := range f.ef.ConfigPatches {
+			name := anchor(cp)
+			if name == "" && cp.GetPatch().GetOperation() != v1alpha3.EnvoyFilter_Patch_INSERT_FIRST {
+				continue
+			}
+
+			// The filters applied before only matter when inserting at the same position
+			for _, other := range filters[:i] {
+				if !overlap(f, other) {
+					continue
+				}
+				if reason := samePosition(cp, other.ef); reason != "" {
+					c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+						msg.NewEnvoyFilterOrderConflict(f.r, pi, other.r.Metadata.FullName.String(), reason))
+					break
+				}
+			}
+
+			if name == "" || insertedBy(name, cp, f, filters[:i+1]) {
+				continue
+			}
+			for _, other := range filters[i+1:] {
+				if !insertedBy(name, cp, f, []*envoyFilter{other}) {
+					continue
+				}
+				c.Report(collections.IstioNetworkingV1Alpha3Envoyfilters.Name(),
+					msg.NewEnvoyFilterOrderConflict(f.r, pi, other.r.Metadata.FullName.String(), fmt.Sprintf(
+						"the filter %q is inserted by the other EnvoyFilter, which is applied after this one", name)))
+				break
+			}
+		}
+	}
+}
+
+// insertedBy returns whether one of the filters inserts the named filter in the context of the
+// patch of the EnvoyFilter ef, on the same workloads.
+func insertedBy(name string, cp *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, ef *envoyFilter, filters []*envoyFilter) bool {
+	for _, f := range filters {
+		if f != ef && !overlap(f, ef) {
+			continue
+		}
+		for _, other := range f.ef.ConfigPatches {
+			if other.ApplyTo == cp.ApplyTo && sameContext(cp, other) && insertedFilter(other) == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// samePosition returns how the patch inserts a filter at the same position as a patch of the
+// EnvoyFilter, or an empty string.
+func samePosition(cp *v1alpha3.EnvoyFilter_EnvoyConfigObjectPatch, ef *v1alpha3.EnvoyFilter) string {
+	if !isFilter(cp.ApplyTo) {
+		return ""
+	}
+	op := cp.GetPatch().GetOperation()
+	if op != v1alpha3.EnvoyFilter_Patch_INSERT_BEFORE && op != v1alpha3.EnvoyFilter_Patch_INSERT_AFTER &&
+		op != v1alpha3.EnvoyFilter_Patch_INSERT_FIRST {
+		return ""
+	}
+	for _, other := range ef.ConfigPatches {
+		if other.ApplyTo != cp.ApplyTo || other.GetPatch().GetOperation() != op || !sameContext(cp, other) {
+			continue
+		}
+		kind := "an HTTP filter"
+		if cp.ApplyTo == v1alpha3.EnvoyFilter_NETWORK_FILTER {
+			kind = "a network filter"
+		}
+		switch {
+		case op == v1alpha3.EnvoyFilter_Patch_INSERT_FIRST:
+			return fmt.Sprintf("both insert %s first, in the order of their creation", kind)
+		case anchor(cp) == anchor(other):
+			position := "before"
+			if op == v1alpha3.EnvoyFilter_Patch_INSERT_AFTER {
+				position = "after"
+			}
+			return fmt.Sprintf("both insert %s %s %q, in the order of their creation", kind, position, anchor(cp))
+		}
+	}
+	return ""
+}
@@ -15,12 +15,16 @@
 package gateway
 
 import (
+	"fmt"
+
 	v1 "k8s.io/api/core/v1"
 	k8s_labels "k8s.io/apimachinery/pkg/labels"
 
 	"istio.io/api/networking/v1alpha3"
 
 	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -64,13 +68,14 @@ func (*IngressGatewayPortAnalyzer) analyzeGateway(r *resource.Instance, c analys
 	// not report a problem if *any* selecting service exposes the Gateway's port.
 	servicePorts := map[uint32]bool{}
 	gwSelectorMatches := 0
+	var podLabels []map[string]string
 
 	// For pods selected by gw.Selector, find Services that select them and remember those ports
 	gwSelector := k8s_labels.SelectorFromSet(gw.Selector)
 	c.ForEach(collections.K8SCoreV1Pods.Name(), func(rPod *resource.Instance) bool {
 		pod := rPod.Message.(*v1.Pod)
-		podLabels := k8s_labels.Set(pod.ObjectMeta.Labels)
-		if gwSelector.Matches(podLabels) {
+		podLabels = append(podLabels, pod.ObjectMeta.Labels)
+		if gwSelector.Matches(k8s_labels.Set(pod.ObjectMeta.Labels)) {
 			gwSelectorMatches++
 			c.ForEach(collections.K8SCoreV1Services.Name(), func(rSvc *resource.Instance) bool {
 				nsSvc := string(rSvc.Metadata.FullName.Namespace)
@@ -81,7 +86,7 @@ func (*IngressGatewayPortAnalyzer) analyzeGateway(r *resource.Instance, c analys
 				service := rSvc.Message.(*v1.ServiceSpec)
 				// TODO I want to match service.Namespace to pod.ObjectMeta.Namespace
 				svcSelector := k8s_labels.SelectorFromSet(service.Selector)
-				if svcSelector.Matches(podLabels) {
+				if svcSelector.Matches(k8s_labels.Set(pod.ObjectMeta.Labels)) {
 					for _, port := range service.Ports {
 						if port.Protocol == "TCP" {
 							servicePorts[uint32(port.Port)] = true
@@ -96,7 +101,12 @@ func (*IngressGatewayPortAnalyzer) analyzeGateway(r *resource.Instance, c analys
 
 	// Report if we found no pods matching this gateway's selector
 	if gwSelectorMatches == 0 {
-		c.Report(collections.IstioNetworkingV1Alpha3Gateways.Name(), msg.NewReferencedResourceNotFound(r, "selector", gwSelector.String()))
+		m := msg.NewReferencedResourceNotFound(r, "selector", gwSelector.String())
+		if suggestion := util.SuggestSelector(gw.Selector, podLabels); suggestion != nil {
+			m = m.WithFix(diag.NewFix(fmt.Sprintf("Select the workloads with labels %s", k8s_labels.SelectorFromSet(suggestion)),
+				diag.Replace("/spec/selector", suggestion)))
+		}
+		c.Report(collections.IstioNetworkingV1Alpha3Gateways.Name(), m)
 		return
 	}
 
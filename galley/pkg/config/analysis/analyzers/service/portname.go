@@ -15,8 +15,11 @@
 package service
 
 import (
+	"fmt"
+
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	configKube "istio.io/istio/pkg/config/kube"
 	"istio.io/istio/pkg/config/resource"
@@ -31,6 +34,21 @@ type PortNameAnalyzer struct{}
 
 var _ analysis.Analyzer = &PortNameAnalyzer{}
 
+// The protocols of well known ports, used to suggest port names. Other ports are assumed to be TCP.
+var wellKnownPortProtocols = map[int32]string{
+	80:    "http",
+	443:   "https",
+	3306:  "mysql",
+	6379:  "redis",
+	8080:  "http",
+	8443:  "https",
+	9080:  "http",
+	27017: "mongo",
+}
+
+// The maximum length of a port name in Kubernetes
+const maxPortNameLength = 15
+
 // Metadata implements Analyzer
 func (s *PortNameAnalyzer) Metadata() analysis.Metadata {
 	return analysis.Metadata{
@@ -64,10 +82,26 @@ func (s *PortNameAnalyzer) Analyze(c analysis.Context) {
 
 func (s *PortNameAnalyzer) analyzeService(r *resource.Instance, c analysis.Context) {
 	svc := r.Message.(*v1.ServiceSpec)
-	for _, port := range svc.Ports {
+	for i, port := range svc.Ports {
 		if instance := configKube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol); instance.IsUnsupported() {
-			c.Report(collections.K8SCoreV1Services.Name(), msg.NewPortNameIsNotUnderNamingConvention(
-				r, port.Name, int(port.Port), port.TargetPort.String()))
+			m := msg.NewPortNameIsNotUnderNamingConvention(r, port.Name, int(port.Port), port.TargetPort.String())
+			if name := suggestPortName(port); len(name) <= maxPortNameLength {
+				m = m.WithFix(diag.NewFix(fmt.Sprintf("Rename the port %d to %q", port.Port, name),
+					diag.Add(fmt.Sprintf("/spec/ports/%d/name", i), name)))
+			}
+			c.Report(collections.K8SCoreV1Services.Name(), m)
 		}
 	}
 }
+
+// suggestPortName returns the name of the port prefixed with its protocol, guessed from its number
+func suggestPortName(port v1.ServicePort) string {
+	protocol, ok := wellKnownPortProtocols[port.Port]
+	if !ok {
+		protocol = "tcp"
+	}
+	if port.Name == "" {
+		return fmt.Sprintf("%s-%d", protocol, port.Port)
+	}
+	return protocol + "-" + port.Name
+}
@@ -0,0 +1,188 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package serviceentry
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/api/annotation"
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/host"
+	configKube "istio.io/istio/pkg/config/kube"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// ConflictAnalyzer checks that service entries do not define the hosts of Kubernetes services or
+// of other service entries visible in the same namespaces with an incompatible protocol or
+// resolution, wildcard hosts being compared with the hosts they match. Only one of the definitions is
+// used, which one is undefined.
+type ConflictAnalyzer struct{}
+
+var _ analysis.Analyzer = &ConflictAnalyzer{}
+
+// Metadata implements Analyzer
+func (c *ConflictAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "serviceentry.ConflictAnalyzer",
+		Description: "Checks that service entries do not conflict with Kubernetes services or other service entries",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Serviceentries.Name(),
+			collections.K8SCoreV1Services.Name(),
+		},
+	}
+}
+
+// service is the definition of a host by a service entry or a Kubernetes service
+type service struct {
+	r          *resource.Instance
+	namespace  resource.Namespace
+	exportAll  bool
+	resolution v1alpha3.ServiceEntry_Resolution
+	ports      map[uint32]protocol.Instance
+}
+
+// visibleWith returns whether both services are visible in a same namespace
+func (s *service) visibleWith(o *service) bool {
+	return s.exportAll || o.exportAll || s.namespace == o.namespace
+}
+
+// hostService is a host defined by a service
+type hostService struct {
+	host host.Name
+	*service
+}
+
+// Analyze implements Analyzer
+func (c *ConflictAnalyzer) Analyze(ctx analysis.Context) {
+	var kubeServices []hostService
+	ctx.ForEach(collections.K8SCoreV1Services.Name(), func(r *resource.Instance) bool {
+		svc := r.Message.(*v1.ServiceSpec)
+		s := &service{
+			r:          r,
+			namespace:  r.Metadata.FullName.Namespace,
+			exportAll:  util.IsExportToAllNamespaces(exportTo(r)),
+			resolution: v1alpha3.ServiceEntry_STATIC,
+			ports:      map[uint32]protocol.Instance{},
+		}
+		// Pilot forwards the traffic of headless services to the original destination
+		if svc.ClusterIP == v1.ClusterIPNone {
+			s.resolution = v1alpha3.ServiceEntry_NONE
+		}
+		for _, port := range svc.Ports {
+			s.ports[uint32(port.Port)] = configKube.ConvertProtocol(port.Port, port.Name, port.Protocol, port.AppProtocol)
+		}
+		fqdn := util.ConvertHostToFQDN(r.Metadata.FullName.Namespace, r.Metadata.FullName.Name.String())
+		kubeServices = append(kubeServices, hostService{host.Name(fqdn), s})
+		return true
+	})
+
+	var entries []hostService
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Serviceentries.Name(), func(r *resource.Instance) bool {
+		se := r.Message.(*v1alpha3.ServiceEntry)
+		s := &service{
+			r:          r,
+			namespace:  r.Metadata.FullName.Namespace,
+			exportAll:  util.IsExportToAllNamespaces(se.ExportTo),
+			resolution: se.Resolution,
+			ports:      map[uint32]protocol.Instance{},
+		}
+		for _, port := range se.Ports {
+			s.ports[port.Number] = protocol.Parse(port.Protocol)
+		}
+		for _, h := range se.Hosts {
+			entries = append(entries, hostService{host.Name(util.ConvertHostToFQDN(s.namespace, h)), s})
+		}
+		return true
+	})
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].host < entries[j].host })
+
+	// Wildcard hosts collide with the hosts they match
+	for _, e := range entries {
+		for _, k := range kubeServices {
+			if !e.host.Matches(k.host) || !e.visibleWith(k.service) {
+				continue
+			}
+			if reason := conflict(e.service, k.service); reason != "" {
+				ctx.Report(collections.IstioNetworkingV1Alpha3Serviceentries.Name(), msg.NewServiceEntryConflict(
+					e.r, string(e.host), "the Kubernetes service "+k.r.Metadata.FullName.String(), reason))
+			}
+		}
+		reported := map[*service]bool{}
+		for _, o := range entries {
+			if o.service == e.service || reported[o.service] || !e.host.Matches(o.host) || !e.visibleWith(o.service) {
+				continue
+			}
+			reported[o.service] = true
+			if reason := conflict(e.service, o.service); reason != "" {
+				ctx.Report(collections.IstioNetworkingV1Alpha3Serviceentries.Name(), msg.NewServiceEntryConflict(
+					e.r, string(e.host), "the ServiceEntry "+o.r.Metadata.FullName.String(), reason))
+			}
+		}
+	}
+}
+
+// exportTo returns the namespaces the Kubernetes service is exported to
+func exportTo(r *resource.Instance) []string {
+	value, ok := r.Metadata.Annotations[annotation.NetworkingExportTo.Name]
+	if !ok {
+		return nil
+	}
+	var namespaces []string
+	for _, ns := range strings.Split(value, ",") {
+		namespaces = append(namespaces, strings.TrimSpace(ns))
+	}
+	return namespaces
+}
+
+// conflict returns why the service s is incompatible with the service o, or an empty string.
+// Services only collide on the ports they share.
+func conflict(s, o *service) string {
+	ports := make([]int, 0, len(s.ports))
+	for port := range s.ports {
+		if _, exists := o.ports[port]; exists {
+			ports = append(ports, int(port))
+		}
+	}
+	if len(ports) == 0 {
+		return ""
+	}
+	sort.Ints(ports)
+
+	if s.resolution != o.resolution {
+		return fmt.Sprintf("the resolution is %s, not %s", s.resolution, o.resolution)
+	}
+	for _, port := range ports {
+		p, op := s.ports[uint32(port)], o.ports[uint32(port)]
+		// Pilot detects the protocol of the ports without a known protocol
+		if p == protocol.Unsupported || op == protocol.Unsupported {
+			continue
+		}
+		if p != op {
+			return fmt.Sprintf("the port %d uses the protocol %s, not %s", port, p, op)
+		}
+	}
+	return ""
+}
@@ -14,9 +14,13 @@
 package sidecar
 
 import (
+	"fmt"
+	"sort"
+
 	"istio.io/api/networking/v1alpha3"
 
 	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -27,6 +31,10 @@ import (
 // sidecar resources that have no selector. This is distinct from
 // SelectorAnalyzer because it does not require pods, so it can run even if that
 // collection is unavailable.
+//
+// The Sidecar named "default", or else the first one by name, is kept as the
+// default of the namespace, and the others are suggested a selector of the
+// workloads labeled with their name.
 type DefaultSelectorAnalyzer struct{}
 
 var _ analysis.Analyzer = &DefaultSelectorAnalyzer{}
@@ -61,9 +69,32 @@ func (a *DefaultSelectorAnalyzer) Analyze(c analysis.Context) {
 	for ns, sList := range nsToSidecars {
 		if len(sList) > 1 {
 			sNames := getNames(sList)
+			def := defaultSidecar(sList)
 			for _, r := range sList {
-				c.Report(collections.IstioNetworkingV1Alpha3Sidecars.Name(), msg.NewMultipleSidecarsWithoutWorkloadSelectors(r, sNames, string(ns)))
+				m := msg.NewMultipleSidecarsWithoutWorkloadSelectors(r, sNames, string(ns))
+				if r != def {
+					name := string(r.Metadata.FullName.Name)
+					m = m.WithFix(diag.NewFix(fmt.Sprintf("Select the workloads labeled app=%s", name),
+						diag.Add("/spec/workloadSelector", map[string]interface{}{
+							"labels": map[string]string{"app": name},
+						})))
+				}
+				c.Report(collections.IstioNetworkingV1Alpha3Sidecars.Name(), m)
 			}
 		}
 	}
 }
+
+// defaultSidecar returns the selector-less Sidecar to keep as the default of its namespace
+func defaultSidecar(sList []*resource.Instance) *resource.Instance {
+	sorted := append([]*resource.Instance(nil), sList...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Metadata.FullName.Name < sorted[j].Metadata.FullName.Name
+	})
+	for _, r := range sorted {
+		if r.Metadata.FullName.Name == "default" {
+			return r
+		}
+	}
+	return sorted[0]
+}
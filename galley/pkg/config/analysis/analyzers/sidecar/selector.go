@@ -14,12 +14,16 @@
 package sidecar
 
 import (
+	"fmt"
+
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/labels"
 
 	"istio.io/api/networking/v1alpha3"
 
 	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/msg"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -65,6 +69,7 @@ func (a *SelectorAnalyzer) Analyze(c analysis.Context) {
 		sel := labels.SelectorFromSet(s.WorkloadSelector.Labels)
 
 		foundPod := false
+		var nsPodLabels []map[string]string
 		c.ForEach(collections.K8SCoreV1Pods.Name(), func(rp *resource.Instance) bool {
 			pod := rp.Message.(*v1.Pod)
 			pNs := rp.Metadata.FullName.Namespace
@@ -74,6 +79,7 @@ func (a *SelectorAnalyzer) Analyze(c analysis.Context) {
 			if pNs != sNs {
 				return true
 			}
+			nsPodLabels = append(nsPodLabels, pod.ObjectMeta.Labels)
 
 			if sel.Matches(podLabels) {
 				foundPod = true
@@ -84,7 +90,12 @@ func (a *SelectorAnalyzer) Analyze(c analysis.Context) {
 		})
 
 		if !foundPod {
-			c.Report(collections.IstioNetworkingV1Alpha3Sidecars.Name(), msg.NewReferencedResourceNotFound(rs, "selector", sel.String()))
+			m := msg.NewReferencedResourceNotFound(rs, "selector", sel.String())
+			if suggestion := util.SuggestSelector(s.WorkloadSelector.Labels, nsPodLabels); suggestion != nil {
+				m = m.WithFix(diag.NewFix(fmt.Sprintf("Select the workloads with labels %s", labels.SelectorFromSet(suggestion)),
+					diag.Replace("/spec/workloadSelector/labels", suggestion)))
+			}
+			c.Report(collections.IstioNetworkingV1Alpha3Sidecars.Name(), m)
 		}
 
 		return true
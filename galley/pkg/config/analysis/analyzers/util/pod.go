@@ -0,0 +1,31 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/config/resource"
+)
+
+// HasSidecar returns whether the pod has an Istio proxy container
+func HasSidecar(pod *resource.Instance) bool {
+	for _, container := range pod.Message.(*v1.Pod).Spec.Containers {
+		if container.Name == IstioProxyName {
+			return true
+		}
+	}
+	return false
+}
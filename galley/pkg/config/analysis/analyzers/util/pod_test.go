@@ -0,0 +1,40 @@
+// Copyright 2019 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	"istio.io/istio/pkg/config/resource"
+)
+
+func TestHasSidecar(t *testing.T) {
+	pod := func(containers ...string) *resource.Instance {
+		p := &v1.Pod{}
+		for _, name := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, v1.Container{Name: name})
+		}
+		return &resource.Instance{Message: p}
+	}
+
+	if !HasSidecar(pod("app", IstioProxyName)) {
+		t.Error("HasSidecar() of a pod with an Istio proxy => false, want true")
+	}
+	if HasSidecar(pod("app")) {
+		t.Error("HasSidecar() of a pod without an Istio proxy => true, want false")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"strings"
+
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SuggestSelector returns the selector a selector matching none of the given pod labels was likely
+// meant to be, or nil. The suggestion keeps the keys of the selector, and replaces the values with
+// the values of the pods they are a part of or contain, e.g. istio=ingress by istio=ingressgateway.
+// A selector is only suggested if it is the only one found.
+func SuggestSelector(selector map[string]string, podLabels []map[string]string) map[string]string {
+	candidates := map[string]map[string]string{}
+	for _, l := range podLabels {
+		suggestion := make(map[string]string, len(selector))
+		for k, v := range selector {
+			pv, ok := l[k]
+			if !ok || (!strings.Contains(pv, v) && !strings.Contains(v, pv)) {
+				suggestion = nil
+				break
+			}
+			suggestion[k] = pv
+		}
+		if suggestion != nil {
+			candidates[labels.SelectorFromSet(suggestion).String()] = suggestion
+		}
+	}
+
+	if len(candidates) != 1 {
+		return nil
+	}
+	for _, suggestion := range candidates {
+		return suggestion
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestSuggestSelector(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	pods := []map[string]string{
+		{"istio": "ingressgateway", "app": "istio-ingressgateway"},
+		{"istio": "ingressgateway", "app": "istio-ingressgateway", "pod-template-hash": "abc"},
+		{"istio": "egressgateway", "app": "istio-egressgateway"},
+	}
+
+	g.Expect(SuggestSelector(map[string]string{"istio": "ingress"}, pods)).To(
+		Equal(map[string]string{"istio": "ingressgateway"}))
+	g.Expect(SuggestSelector(map[string]string{"istio": "ingressgateway", "app": "ingressgateway"}, pods)).To(
+		Equal(map[string]string{"istio": "ingressgateway", "app": "istio-ingressgateway"}))
+	// Both gateways are candidates
+	g.Expect(SuggestSelector(map[string]string{"istio": "gateway"}, pods)).To(BeNil())
+	g.Expect(SuggestSelector(map[string]string{"istio": "typo"}, pods)).To(BeNil())
+	g.Expect(SuggestSelector(map[string]string{"version": "v1"}, pods)).To(BeNil())
+	g.Expect(SuggestSelector(map[string]string{"istio": "ingress"}, nil)).To(BeNil())
+}
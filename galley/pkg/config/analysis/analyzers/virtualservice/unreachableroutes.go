@@ -0,0 +1,425 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package virtualservice
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers/util"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// UnreachableRouteAnalyzer checks for HTTP routes of virtual services that can never be matched,
+// because the requests they match are all matched by earlier routes. Virtual services bound to
+// the same gateway host are merged by Pilot, so their routes are checked against each other too.
+type UnreachableRouteAnalyzer struct{}
+
+var _ analysis.Analyzer = &UnreachableRouteAnalyzer{}
+
+// Metadata implements Analyzer
+func (a *UnreachableRouteAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "virtualservice.UnreachableRouteAnalyzer",
+		Description: "Checks for HTTP routes shadowed by earlier routes",
+		Inputs: collection.Names{
+			collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+		},
+	}
+}
+
+// routeEntry is a match of a route, as ordered in the route table of a gateway or sidecar.
+type routeEntry struct {
+	vs    *resource.Instance
+	route int
+	// match is nil when the route has no match, which matches all requests
+	match *v1alpha3.HTTPMatchRequest
+}
+
+// routeTable is the ordered list of route entries for a host on a gateway or on the mesh.
+type routeTable struct {
+	gateway string
+	vss     []*resource.Instance
+	entries []routeEntry
+}
+
+// routeRef identifies an HTTP route of a virtual service
+type routeRef struct {
+	vs    *resource.Instance
+	route int
+}
+
+// shadowing records the tables where a route is shadowed
+type shadowing struct {
+	// by describes the routes shadowing it in the first table
+	by     []string
+	tables int
+}
+
+// Analyze implements Analyzer
+func (a *UnreachableRouteAnalyzer) Analyze(ctx analysis.Context) {
+	tables := map[string]*routeTable{}
+	ctx.ForEach(collections.IstioNetworkingV1Alpha3Virtualservices.Name(), func(r *resource.Instance) bool {
+		vs := r.Message.(*v1alpha3.VirtualService)
+		ns := r.Metadata.FullName.Namespace
+		gateways := vs.Gateways
+		// No entry in gateways imply "mesh" by default
+		if len(gateways) == 0 {
+			gateways = []string{util.MeshGateway}
+		}
+		for _, gw := range gateways {
+			gw = gatewayName(ns, gw)
+			for _, h := range vs.Hosts {
+				key := gw + "/" + util.ConvertHostToFQDN(ns, h)
+				if gw == util.MeshGateway {
+					// Sidecars do not merge virtual services sharing a host: conflicts are reported by
+					// the ConflictingMeshGatewayHostsAnalyzer, so each one is checked on its own.
+					key += "/" + r.Metadata.FullName.String()
+				}
+				t, exists := tables[key]
+				if !exists {
+					t = &routeTable{gateway: gw}
+					tables[key] = t
+				}
+				t.vss = append(t.vss, r)
+			}
+		}
+		return true
+	})
+
+	// The number of tables each route is part of
+	tablesOf := map[routeRef]int{}
+	shadowed := map[routeRef]*shadowing{}
+	keys := make([]string, 0, len(tables))
+	for key := range tables {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		t := tables[key]
+		t.build()
+		for ref := range t.routes() {
+			tablesOf[ref]++
+		}
+		for ref, by := range t.shadowedRoutes() {
+			s, exists := shadowed[ref]
+			if !exists {
+				// Only the first table explains why a route is shadowed
+				s = &shadowing{by: by}
+				shadowed[ref] = s
+			}
+			s.tables++
+		}
+	}
+
+	refs := make([]routeRef, 0, len(shadowed))
+	for ref, s := range shadowed {
+		// A route is reachable as long as it is not shadowed in one of its tables
+		if s.tables == tablesOf[ref] {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].vs != refs[j].vs {
+			return refs[i].vs.Metadata.FullName.String() < refs[j].vs.Metadata.FullName.String()
+		}
+		return refs[i].route < refs[j].route
+	})
+	for _, ref := range refs {
+		ctx.Report(collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+			msg.NewVirtualServiceUnreachableRoute(ref.vs, ref.route, strings.Join(shadowed[ref].by, " and ")))
+	}
+}
+
+// build orders the route entries of the table the way Pilot does: the routes of a single virtual
+// service are kept in order, while merging the routes of several virtual services moves the
+// catch-all routes to the end.
+func (t *routeTable) build() {
+	if len(t.vss) > 1 {
+		sort.SliceStable(t.vss, func(i, j int) bool {
+			a, b := t.vss[i].Metadata, t.vss[j].Metadata
+			if a.CreateTime.Equal(b.CreateTime) {
+				return string(a.FullName.Name)+"."+string(a.FullName.Namespace) <
+					string(b.FullName.Name)+"."+string(b.FullName.Namespace)
+			}
+			return a.CreateTime.Before(b.CreateTime)
+		})
+	}
+
+	var catchAll []routeEntry
+	for _, r := range t.vss {
+		vs := r.Message.(*v1alpha3.VirtualService)
+		for i, route := range vs.Http {
+			matches := route.Match
+			if len(matches) == 0 {
+				matches = []*v1alpha3.HTTPMatchRequest{nil}
+			}
+			for _, m := range matches {
+				if m != nil && len(m.Gateways) > 0 {
+					if !containsGateway(r.Metadata.FullName.Namespace, m.Gateways, t.gateway) {
+						continue
+					}
+					// The match applies to the gateway of the table, so its gateways are irrelevant here
+					mc := *m
+					mc.Gateways = nil
+					m = &mc
+				}
+				e := routeEntry{vs: r, route: i, match: m}
+				if len(t.vss) > 1 && isCatchAll(m) {
+					catchAll = append(catchAll, e)
+				} else {
+					t.entries = append(t.entries, e)
+				}
+			}
+		}
+	}
+	t.entries = append(t.entries, catchAll...)
+}
+
+// routes returns the routes with at least one entry in the table
+func (t *routeTable) routes() map[routeRef]struct{} {
+	out := map[routeRef]struct{}{}
+	for _, e := range t.entries {
+		out[routeRef{e.vs, e.route}] = struct{}{}
+	}
+	return out
+}
+
+// shadowedRoutes returns the routes all entries of which are matched by earlier entries, along
+// with the routes of those earlier entries.
+func (t *routeTable) shadowedRoutes() map[routeRef][]string {
+	out := map[routeRef][]string{}
+	reachable := map[routeRef]bool{}
+	for i, e := range t.entries {
+		ref := routeRef{e.vs, e.route}
+		if reachable[ref] {
+			continue
+		}
+		var by *routeEntry
+		for j := 0; j < i; j++ {
+			prev := t.entries[j]
+			if prev.vs == e.vs && prev.route == e.route {
+				continue
+			}
+			if subsumes(prev.match, e.match) {
+				by = &t.entries[j]
+				break
+			}
+		}
+		if by == nil {
+			reachable[ref] = true
+			delete(out, ref)
+			continue
+		}
+		desc := describeRoute(e.vs, by)
+		if !containsString(out[ref], desc) {
+			out[ref] = append(out[ref], desc)
+		}
+	}
+	return out
+}
+
+func describeRoute(of *resource.Instance, e *routeEntry) string {
+	desc := fmt.Sprintf("route %d", e.route)
+	if name := e.vs.Message.(*v1alpha3.VirtualService).Http[e.route].Name; name != "" {
+		desc += fmt.Sprintf(" (%s)", name)
+	}
+	if e.vs != of {
+		desc += " of VirtualService " + e.vs.Metadata.FullName.String()
+	}
+	return desc
+}
+
+// isCatchAll returns whether the match is turned into a catch-all Envoy route, i.e. a route
+// matching any path, without header or query parameter match.
+func isCatchAll(m *v1alpha3.HTTPMatchRequest) bool {
+	if m == nil {
+		return true
+	}
+	if m.Uri != nil && m.Uri.GetPrefix() != "/" {
+		return false
+	}
+	return len(m.Headers) == 0 && len(m.QueryParams) == 0 && len(m.WithoutHeaders) == 0 &&
+		m.Method == nil && m.Authority == nil && m.Scheme == nil
+}
+
+// subsumes returns whether all requests matched by b are matched by a. It errs on the side of
+// false, when the predicates are too complex to compare.
+func subsumes(a, b *v1alpha3.HTTPMatchRequest) bool {
+	if a == nil {
+		return true
+	}
+	if b == nil {
+		b = &v1alpha3.HTTPMatchRequest{}
+	}
+
+	if !matchesAllUris(a.Uri) {
+		if b.Uri == nil || (b.IgnoreUriCase && !a.IgnoreUriCase) ||
+			!stringMatchSubsumes(a.Uri, b.Uri, a.IgnoreUriCase) {
+			return false
+		}
+	}
+	if !optionalStringMatchSubsumes(a.Scheme, b.Scheme) ||
+		!optionalStringMatchSubsumes(a.Method, b.Method) ||
+		!optionalStringMatchSubsumes(a.Authority, b.Authority) {
+		return false
+	}
+
+	// Header names are case insensitive
+	headers := make(map[string]*v1alpha3.StringMatch, len(b.Headers))
+	for name, m := range b.Headers {
+		headers[strings.ToLower(name)] = m
+	}
+	for name, m := range a.Headers {
+		if !optionalStringMatchSubsumes(m, headers[strings.ToLower(name)]) {
+			return false
+		}
+	}
+	for name, m := range a.QueryParams {
+		if !optionalStringMatchSubsumes(m, b.QueryParams[name]) {
+			return false
+		}
+	}
+	for name, m := range a.WithoutHeaders {
+		if other, exists := b.WithoutHeaders[name]; !exists || !proto.Equal(m, other) {
+			return false
+		}
+	}
+
+	if a.Port != 0 && a.Port != b.Port {
+		return false
+	}
+	for k, v := range a.SourceLabels {
+		if other, exists := b.SourceLabels[k]; !exists || other != v {
+			return false
+		}
+	}
+	if a.SourceNamespace != "" && a.SourceNamespace != b.SourceNamespace {
+		return false
+	}
+	if len(a.Gateways) > 0 {
+		if len(b.Gateways) == 0 {
+			return false
+		}
+		for _, gw := range b.Gateways {
+			if !containsString(a.Gateways, gw) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesAllUris returns whether the URI match matches any request path
+func matchesAllUris(m *v1alpha3.StringMatch) bool {
+	if m == nil {
+		return true
+	}
+	switch {
+	case m.GetPrefix() == "/":
+		return true
+	case m.GetRegex() == ".*":
+		return true
+	}
+	return false
+}
+
+// optionalStringMatchSubsumes returns whether a, when set, matches all values matched by b
+func optionalStringMatchSubsumes(a, b *v1alpha3.StringMatch) bool {
+	if a == nil {
+		return true
+	}
+	return b != nil && stringMatchSubsumes(a, b, false)
+}
+
+// stringMatchSubsumes returns whether all the values matched by b are matched by a
+func stringMatchSubsumes(a, b *v1alpha3.StringMatch, ignoreCase bool) bool {
+	normalize := func(s string) string {
+		if ignoreCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+
+	switch am := a.MatchType.(type) {
+	case *v1alpha3.StringMatch_Exact:
+		if bm, ok := b.MatchType.(*v1alpha3.StringMatch_Exact); ok {
+			return normalize(am.Exact) == normalize(bm.Exact)
+		}
+	case *v1alpha3.StringMatch_Prefix:
+		prefix := normalize(am.Prefix)
+		switch bm := b.MatchType.(type) {
+		case *v1alpha3.StringMatch_Exact:
+			return strings.HasPrefix(normalize(bm.Exact), prefix)
+		case *v1alpha3.StringMatch_Prefix:
+			return strings.HasPrefix(normalize(bm.Prefix), prefix)
+		case *v1alpha3.StringMatch_Regex:
+			return prefix == ""
+		}
+	case *v1alpha3.StringMatch_Regex:
+		if am.Regex == ".*" {
+			return true
+		}
+		switch bm := b.MatchType.(type) {
+		case *v1alpha3.StringMatch_Exact:
+			// Regexes must match the whole value
+			re, err := regexp.Compile("^(?:" + am.Regex + ")$")
+			// The case variants of the value cannot all be checked
+			if err != nil || ignoreCase {
+				return false
+			}
+			return re.MatchString(bm.Exact)
+		case *v1alpha3.StringMatch_Regex:
+			return am.Regex == bm.Regex
+		}
+	}
+	return false
+}
+
+// gatewayName returns the namespace/name of the gateway, or "mesh"
+func gatewayName(ns resource.Namespace, gw string) string {
+	if gw == util.MeshGateway {
+		return gw
+	}
+	return resource.NewShortOrFullName(ns, gw).String()
+}
+
+func containsGateway(ns resource.Namespace, gateways []string, gw string) bool {
+	for _, g := range gateways {
+		if gatewayName(ns, g) == gw {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
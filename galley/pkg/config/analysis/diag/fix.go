@@ -0,0 +1,56 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package diag
+
+// Fix is a suggested change of the resource of a message that addresses the message
+type Fix struct {
+	// Description of the change, for humans
+	Description string `json:"description"`
+
+	// Patch is the change, as a JSON patch (RFC 6902) against the resource as written by users,
+	// i.e. with paths starting with /metadata or /spec.
+	Patch []PatchOperation `json:"patch"`
+}
+
+// PatchOperation is an operation of a JSON patch
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// NewFix returns a new Fix applying the given operations.
+func NewFix(description string, ops ...PatchOperation) Fix {
+	return Fix{
+		Description: description,
+		Patch:       ops,
+	}
+}
+
+// Replace returns an operation replacing the value at the given path.
+func Replace(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "replace", Path: path, Value: value}
+}
+
+// Add returns an operation adding the value at the given path.
+func Add(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "add", Path: path, Value: value}
+}
+
+// WithFix returns a copy of the message suggesting the given fix.
+func (m Message) WithFix(f Fix) Message {
+	m.Fixes = append(append([]Fix(nil), m.Fixes...), f)
+	return m
+}
@@ -58,6 +58,9 @@ type Message struct {
 
 	// DocRef is an optional reference tracker for the documentation URL
 	DocRef string
+
+	// Fixes are the suggested changes of the resource that address the message, if any
+	Fixes []Fix
 }
 
 // Unstructured returns this message as a JSON-style unstructured map
@@ -73,14 +76,21 @@ func (m *Message) Unstructured(includeOrigin bool) map[string]interface{} {
 		}
 	}
 	result["message"] = fmt.Sprintf(m.Type.Template(), m.Parameters...)
+	result["documentation_url"] = m.DocumentationURL()
+	if len(m.Fixes) > 0 {
+		result["fixes"] = m.Fixes
+	}
 
+	return result
+}
+
+// DocumentationURL returns the URL of the documentation of the message type
+func (m *Message) DocumentationURL() string {
 	docQueryString := ""
 	if m.DocRef != "" {
 		docQueryString = fmt.Sprintf("?ref=%s", m.DocRef)
 	}
-	result["documentation_url"] = fmt.Sprintf("%s/%s%s", DocPrefix, m.Type.Code(), docQueryString)
-
-	return result
+	return fmt.Sprintf("%s/%s%s", DocPrefix, m.Type.Code(), docQueryString)
 }
 
 // String implements io.Stringer
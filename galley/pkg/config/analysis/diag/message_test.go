@@ -70,3 +70,16 @@ func TestMessage_JSON(t *testing.T) {
 	g.Expect(string(j)).To(Equal(`{"code":"IST-0042","documentation_url":"https://istio.io/docs/reference/config/analysis/IST-0042"` +
 		`,"level":"Error","message":"Cheese type not found: \"Feta\"","origin":"toppings/cheese","reference":"path/to/file"}`))
 }
+
+func TestMessageWithFix_JSON(t *testing.T) {
+	g := NewGomegaWithT(t)
+	mt := NewMessageType(Error, "IST-0042", "Cheese type not found: %q")
+	m := NewMessage(mt, nil, "Feta")
+	fixed := m.WithFix(NewFix("Use Brie", Replace("/spec/cheese", "Brie")))
+
+	g.Expect(m.Fixes).To(BeEmpty())
+	j, _ := json.Marshal(&fixed)
+	g.Expect(string(j)).To(Equal(`{"code":"IST-0042","documentation_url":"https://istio.io/docs/reference/config/analysis/IST-0042"` +
+		`,"fixes":[{"description":"Use Brie","patch":[{"op":"replace","path":"/spec/cheese","value":"Brie"}]}]` +
+		`,"level":"Error","message":"Cheese type not found: \"Feta\""}`))
+}
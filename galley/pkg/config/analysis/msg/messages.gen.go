@@ -116,6 +116,58 @@ var (
 	// InvalidAnnotation defines a diag.MessageType for message "InvalidAnnotation".
 	// Description: An Istio annotation that is not valid
 	InvalidAnnotation = diag.NewMessageType(diag.Warning, "IST0125", "Invalid annotation %s: %s")
+
+	// VirtualServiceUnreachableRoute defines a diag.MessageType for message "VirtualServiceUnreachableRoute".
+	// Description: A VirtualService HTTP route can never be matched, because earlier routes match all of its requests
+	VirtualServiceUnreachableRoute = diag.NewMessageType(diag.Warning, "IST0126", "HTTP route %d is unreachable: all of its requests are matched first by %s")
+
+	// PortLevelMTLSPortNotFound defines a diag.MessageType for message "PortLevelMTLSPortNotFound".
+	// Description: A port-level mTLS setting targets a port that does not exist, so it is never applied.
+	PortLevelMTLSPortNotFound = diag.NewMessageType(diag.Warning, "IST0127", "The port-level mTLS setting for port %d is never applied, as the port does not exist on %s.")
+
+	// NoMatchingWorkloadsFound defines a diag.MessageType for message "NoMatchingWorkloadsFound".
+	// Description: There aren't workloads matching the resource labels
+	NoMatchingWorkloadsFound = diag.NewMessageType(diag.Warning, "IST0128", "No matching workloads for this resource with the following labels: %s")
+
+	// AuthorizationPolicyPrincipalNotFound defines a diag.MessageType for message "AuthorizationPolicyPrincipalNotFound".
+	// Description: A principal of an AuthorizationPolicy does not match any workload.
+	AuthorizationPolicyPrincipalNotFound = diag.NewMessageType(diag.Warning, "IST0129", "The principal %q in rule %d does not match any workload: %s.")
+
+	// AuthorizationPolicyOperationNeverApplies defines a diag.MessageType for message "AuthorizationPolicyOperationNeverApplies".
+	// Description: An operation of an AuthorizationPolicy never applies to the workloads it selects.
+	AuthorizationPolicyOperationNeverApplies = diag.NewMessageType(diag.Warning, "IST0130", "The %s %q in rule %d never applies to the selected workloads: %s.")
+
+	// AuthorizationPolicyShadowedByDeny defines a diag.MessageType for message "AuthorizationPolicyShadowedByDeny".
+	// Description: An ALLOW rule of an AuthorizationPolicy never allows a request, as a DENY policy denies all the requests it matches.
+	AuthorizationPolicyShadowedByDeny = diag.NewMessageType(diag.Warning, "IST0131", "Rule %d never allows a request: the DENY policy %s denies all the requests it matches.")
+
+	// AuthorizationPolicyDeniesAllTraffic defines a diag.MessageType for message "AuthorizationPolicyDeniesAllTraffic".
+	// Description: An AuthorizationPolicy denies all the traffic to the workloads it applies to.
+	AuthorizationPolicyDeniesAllTraffic = diag.NewMessageType(diag.Warning, "IST0132", "The policy denies all the traffic to %s: %s.")
+
+	// EnvoyFilterProxyVersionNeverMatches defines a diag.MessageType for message "EnvoyFilterProxyVersionNeverMatches".
+	// Description: The proxy version regex of an EnvoyFilter patch matches none of the proxies it selects.
+	EnvoyFilterProxyVersionNeverMatches = diag.NewMessageType(diag.Warning, "IST0133", "The proxy version regex %q of patch %d matches none of the proxy versions of the selected workloads: %s.")
+
+	// EnvoyFilterRemovedFilter defines a diag.MessageType for message "EnvoyFilterRemovedFilter".
+	// Description: An EnvoyFilter patch refers to a filter that is no longer supported by the proxy.
+	EnvoyFilterRemovedFilter = diag.NewMessageType(diag.Warning, "IST0134", "Patch %d refers to the filter %q, which is no longer supported by the proxy. Use %q instead.")
+
+	// EnvoyFilterDeprecatedConfig defines a diag.MessageType for message "EnvoyFilterDeprecatedConfig".
+	// Description: An EnvoyFilter patch uses a deprecated field of the filter configuration.
+	EnvoyFilterDeprecatedConfig = diag.NewMessageType(diag.Warning, "IST0135", "Patch %d uses the deprecated field %q of the filter configuration. Use %q instead.")
+
+	// EnvoyFilterOrderConflict defines a diag.MessageType for message "EnvoyFilterOrderConflict".
+	// Description: The result of an EnvoyFilter patch depends on the order in which EnvoyFilters are applied to a workload.
+	EnvoyFilterOrderConflict = diag.NewMessageType(diag.Warning, "IST0136", "Patch %d conflicts with the EnvoyFilter %s applied to the same workloads: %s.")
+
+	// DestinationRuleSubsetMatchesNoPods defines a diag.MessageType for message "DestinationRuleSubsetMatchesNoPods".
+	// Description: A subset of a DestinationRule matches no pod of the service it applies to, so requests routed to it fail.
+	DestinationRuleSubsetMatchesNoPods = diag.NewMessageType(diag.Warning, "IST0137", "The subset %q matches no pod of the service %s: none of them has the labels %s.")
+
+	// ServiceEntryConflict defines a diag.MessageType for message "ServiceEntryConflict".
+	// Description: A ServiceEntry defines a host visible in the same namespaces as another ServiceEntry or a Kubernetes service, with an incompatible protocol or resolution.
+	ServiceEntryConflict = diag.NewMessageType(diag.Warning, "IST0138", "The host %s conflicts with %s: %s.")
 )
 
 // All returns a list of all known message types.
@@ -148,6 +200,19 @@ func All() []*diag.MessageType {
 		NamespaceMultipleInjectionLabels,
 		NamespaceInvalidInjectorRevision,
 		InvalidAnnotation,
+		VirtualServiceUnreachableRoute,
+		PortLevelMTLSPortNotFound,
+		NoMatchingWorkloadsFound,
+		AuthorizationPolicyPrincipalNotFound,
+		AuthorizationPolicyOperationNeverApplies,
+		AuthorizationPolicyShadowedByDeny,
+		AuthorizationPolicyDeniesAllTraffic,
+		EnvoyFilterProxyVersionNeverMatches,
+		EnvoyFilterRemovedFilter,
+		EnvoyFilterDeprecatedConfig,
+		EnvoyFilterOrderConflict,
+		DestinationRuleSubsetMatchesNoPods,
+		ServiceEntryConflict,
 	}
 }
 
@@ -418,3 +483,141 @@ func NewInvalidAnnotation(r *resource.Instance, annotation string, problem strin
 		problem,
 	)
 }
+
+// NewVirtualServiceUnreachableRoute returns a new diag.Message based on VirtualServiceUnreachableRoute.
+func NewVirtualServiceUnreachableRoute(r *resource.Instance, index int, shadowedBy string) diag.Message {
+	return diag.NewMessage(
+		VirtualServiceUnreachableRoute,
+		r,
+		index,
+		shadowedBy,
+	)
+}
+
+// NewPortLevelMTLSPortNotFound returns a new diag.Message based on PortLevelMTLSPortNotFound.
+func NewPortLevelMTLSPortNotFound(r *resource.Instance, port int, target string) diag.Message {
+	return diag.NewMessage(
+		PortLevelMTLSPortNotFound,
+		r,
+		port,
+		target,
+	)
+}
+
+// NewNoMatchingWorkloadsFound returns a new diag.Message based on NoMatchingWorkloadsFound.
+func NewNoMatchingWorkloadsFound(r *resource.Instance, labels string) diag.Message {
+	return diag.NewMessage(
+		NoMatchingWorkloadsFound,
+		r,
+		labels,
+	)
+}
+
+// NewAuthorizationPolicyPrincipalNotFound returns a new diag.Message based on AuthorizationPolicyPrincipalNotFound.
+func NewAuthorizationPolicyPrincipalNotFound(r *resource.Instance, principal string, rule int, problem string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyPrincipalNotFound,
+		r,
+		principal,
+		rule,
+		problem,
+	)
+}
+
+// NewAuthorizationPolicyOperationNeverApplies returns a new diag.Message based on AuthorizationPolicyOperationNeverApplies.
+func NewAuthorizationPolicyOperationNeverApplies(r *resource.Instance, field string, value string, rule int, problem string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyOperationNeverApplies,
+		r,
+		field,
+		value,
+		rule,
+		problem,
+	)
+}
+
+// NewAuthorizationPolicyShadowedByDeny returns a new diag.Message based on AuthorizationPolicyShadowedByDeny.
+func NewAuthorizationPolicyShadowedByDeny(r *resource.Instance, rule int, denyPolicy string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyShadowedByDeny,
+		r,
+		rule,
+		denyPolicy,
+	)
+}
+
+// NewAuthorizationPolicyDeniesAllTraffic returns a new diag.Message based on AuthorizationPolicyDeniesAllTraffic.
+func NewAuthorizationPolicyDeniesAllTraffic(r *resource.Instance, target string, reason string) diag.Message {
+	return diag.NewMessage(
+		AuthorizationPolicyDeniesAllTraffic,
+		r,
+		target,
+		reason,
+	)
+}
+
+// NewEnvoyFilterProxyVersionNeverMatches returns a new diag.Message based on EnvoyFilterProxyVersionNeverMatches.
+func NewEnvoyFilterProxyVersionNeverMatches(r *resource.Instance, regex string, patch int, versions string) diag.Message {
+	return diag.NewMessage(
+		EnvoyFilterProxyVersionNeverMatches,
+		r,
+		regex,
+		patch,
+		versions,
+	)
+}
+
+// NewEnvoyFilterRemovedFilter returns a new diag.Message based on EnvoyFilterRemovedFilter.
+func NewEnvoyFilterRemovedFilter(r *resource.Instance, patch int, filter string, replacement string) diag.Message {
+	return diag.NewMessage(
+		EnvoyFilterRemovedFilter,
+		r,
+		patch,
+		filter,
+		replacement,
+	)
+}
+
+// NewEnvoyFilterDeprecatedConfig returns a new diag.Message based on EnvoyFilterDeprecatedConfig.
+func NewEnvoyFilterDeprecatedConfig(r *resource.Instance, patch int, field string, replacement string) diag.Message {
+	return diag.NewMessage(
+		EnvoyFilterDeprecatedConfig,
+		r,
+		patch,
+		field,
+		replacement,
+	)
+}
+
+// NewEnvoyFilterOrderConflict returns a new diag.Message based on EnvoyFilterOrderConflict.
+func NewEnvoyFilterOrderConflict(r *resource.Instance, patch int, envoyFilter string, reason string) diag.Message {
+	return diag.NewMessage(
+		EnvoyFilterOrderConflict,
+		r,
+		patch,
+		envoyFilter,
+		reason,
+	)
+}
+
+// NewDestinationRuleSubsetMatchesNoPods returns a new diag.Message based on DestinationRuleSubsetMatchesNoPods.
+func NewDestinationRuleSubsetMatchesNoPods(r *resource.Instance, subset string, service string, labels string) diag.Message {
+	return diag.NewMessage(
+		DestinationRuleSubsetMatchesNoPods,
+		r,
+		subset,
+		service,
+		labels,
+	)
+}
+
+// NewServiceEntryConflict returns a new diag.Message based on ServiceEntryConflict.
+func NewServiceEntryConflict(r *resource.Instance, host string, other string, reason string) diag.Message {
+	return diag.NewMessage(
+		ServiceEntryConflict,
+		r,
+		host,
+		other,
+		reason,
+	)
+}
@@ -33,6 +33,8 @@ const (
 	namespace  = "namespace"
 	name       = "name"
 	version    = "version"
+	code       = "code"
+	level      = "level"
 )
 
 var (
@@ -44,6 +46,10 @@ var (
 	NameTag tag.Key
 	// VersionTag holds version of the resource for the context.
 	VersionTag tag.Key
+	// CodeTag holds the code of the analysis messages for the context.
+	CodeTag tag.Key
+	// LevelTag holds the level of the analysis messages for the context.
+	LevelTag tag.Key
 	// StateTypeConfigKeys holds key tags for runtime state metrics.
 	StateTypeConfigKeys []tag.Key
 )
@@ -89,6 +95,10 @@ var (
 		"galley/runtime/state/type_instances_total",
 		"The number of type instances per type URL",
 		stats.UnitDimensionless)
+	analysisMessages = stats.Int64(
+		"galley/analysis/messages",
+		"The number of analysis messages per code, in the last analysis",
+		stats.UnitDimensionless)
 
 	durationDistributionMs = view.Distribution(0, 1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8193, 16384, 32768, 65536,
 		131072, 262144, 524288, 1048576, 2097152, 4194304, 8388608)
@@ -145,6 +155,16 @@ func RecordStateTypeCountWithContext(ctx context.Context, count int) {
 	}
 }
 
+// RecordAnalysisMessages records the number of analysis messages with the code and the level
+func RecordAnalysisMessages(code, level string, count int) {
+	ctx, err := tag.New(context.Background(), tag.Insert(CodeTag, code), tag.Insert(LevelTag, level))
+	if err != nil {
+		scope.Analysis.Errorf("Error creating monitoring context for counting analysis messages: %v", err)
+		return
+	}
+	stats.Record(ctx, analysisMessages.M(int64(count)))
+}
+
 // RecordDetailedStateType records name, namespace, version of the resource in Galley.
 func RecordDetailedStateType(namespace, name string, collection fmt.Stringer, count int) {
 	collectionStr := strings.Split(collection.String(), "/")
@@ -224,6 +244,12 @@ func init() {
 	if CollectionTag, err = tag.NewKey(collection); err != nil {
 		panic(err)
 	}
+	if CodeTag, err = tag.NewKey(code); err != nil {
+		panic(err)
+	}
+	if LevelTag, err = tag.NewKey(level); err != nil {
+		panic(err)
+	}
 
 	var noKeys []tag.Key
 	collectionKeys := []tag.Key{CollectionTag}
@@ -239,6 +265,7 @@ func init() {
 		newView(processorEventsPerSnapshot, noKeys, view.Distribution(0, 1, 2, 4, 8, 16, 32, 64, 128, 256)),
 		newView(processorSnapshotLifetimesMs, noKeys, durationDistributionMs),
 		newView(stateTypeInstancesTotal, collectionKeys, view.LastValue()),
+		newView(analysisMessages, []tag.Key{CodeTag, LevelTag}, view.LastValue()),
 	)
 
 	if err != nil {
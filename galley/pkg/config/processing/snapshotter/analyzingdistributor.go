@@ -25,6 +25,7 @@ import (
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	coll "istio.io/istio/galley/pkg/config/collection"
+	"istio.io/istio/galley/pkg/config/monitoring"
 	"istio.io/istio/galley/pkg/config/scope"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema/collection"
@@ -43,6 +44,15 @@ type AnalyzingDistributor struct {
 
 	snapshotsMu   sync.RWMutex
 	lastSnapshots map[string]*Snapshot
+
+	countsMu   sync.Mutex
+	lastCounts map[messageKind]int
+}
+
+// messageKind identifies the messages counted in the metrics
+type messageKind struct {
+	code  string
+	level string
 }
 
 var _ Distributor = &AnalyzingDistributor{}
@@ -164,13 +174,36 @@ func (d *AnalyzingDistributor) analyzeAndDistribute(cancelCh chan struct{}, name
 
 	msgs := filterMessages(ctx.messages, namespaces, d.s.Suppressions)
 	if !ctx.Canceled() {
-		d.s.StatusUpdater.Update(msgs.SortedDedupedCopy())
+		msgs = msgs.SortedDedupedCopy()
+		d.s.StatusUpdater.Update(msgs)
+		d.recordCounts(msgs)
 	}
 
 	// Execution only reaches this point for trigger snapshot group
 	d.s.Distributor.Distribute(name, s)
 }
 
+// recordCounts records the number of messages per code. The codes without messages anymore are
+// recorded with a zero count, so that the fixed issues are cleared from the metrics.
+func (d *AnalyzingDistributor) recordCounts(msgs diag.Messages) {
+	counts := make(map[messageKind]int)
+	for _, m := range msgs {
+		counts[messageKind{code: m.Type.Code(), level: m.Type.Level().String()}]++
+	}
+
+	d.countsMu.Lock()
+	defer d.countsMu.Unlock()
+	for k := range d.lastCounts {
+		if _, ok := counts[k]; !ok {
+			monitoring.RecordAnalysisMessages(k.code, k.level, 0)
+		}
+	}
+	for k, count := range counts {
+		monitoring.RecordAnalysisMessages(k.code, k.level, count)
+	}
+	d.lastCounts = counts
+}
+
 // getCombinedSnapshot creates a new snapshot from the last snapshots of each snapshot group
 // Important assumption: the collections in each snapshot don't overlap.
 func (d *AnalyzingDistributor) getCombinedSnapshot() *Snapshot {
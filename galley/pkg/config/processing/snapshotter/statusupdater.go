@@ -86,3 +86,17 @@ func (u *InMemoryStatusUpdater) WaitForReport(cancelCh chan struct{}) error {
 		return nil
 	}
 }
+
+// CombineStatusUpdaters returns a StatusUpdater that updates each of the given updaters, in order.
+func CombineStatusUpdaters(updaters ...StatusUpdater) StatusUpdater {
+	return statusUpdaters(updaters)
+}
+
+type statusUpdaters []StatusUpdater
+
+// Update implements StatusUpdater
+func (u statusUpdaters) Update(m diag.Messages) {
+	for _, updater := range u {
+		updater.Update(m)
+	}
+}
@@ -103,3 +103,15 @@ func TestInMemoryStatusUpdaterCancelled(t *testing.T) {
 	g.Expect(err).To(Not(BeNil()))
 	g.Expect(err.Error()).To(ContainSubstring("cancelled"))
 }
+
+func TestCombineStatusUpdaters(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	su1 := &InMemoryStatusUpdater{}
+	su2 := &InMemoryStatusUpdater{}
+	m := diag.Messages{diag.NewMessage(diag.NewMessageType(diag.Error, "IST-0001", "Template: %q"), nil, "")}
+
+	CombineStatusUpdaters(su1, su2).Update(m)
+	g.Expect(su1.Get()).To(Equal(m))
+	g.Expect(su2.Get()).To(Equal(m))
+}
@@ -0,0 +1,118 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/scope"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// EventReporter records a Kubernetes Event on the resources for each new error message. The errors
+// that were already reported by the previous analysis do not produce Events again, and the ones
+// reported again after being fixed do.
+type EventReporter struct {
+	client    kubernetes.Interface
+	component string
+
+	mu       sync.Mutex
+	reported map[string]struct{}
+}
+
+// NewEventReporter returns a new EventReporter, recording the Events as the given component.
+func NewEventReporter(client kubernetes.Interface, component string) *EventReporter {
+	return &EventReporter{
+		client:    client,
+		component: component,
+		reported:  map[string]struct{}{},
+	}
+}
+
+// Update implements snapshotter.StatusUpdater
+func (r *EventReporter) Update(messages diag.Messages) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reported := make(map[string]struct{})
+	for _, m := range messages {
+		if m.Type.Level() != diag.Error || m.Resource == nil {
+			continue
+		}
+		origin, ok := m.Resource.Origin.(*rt.Origin)
+		if !ok {
+			continue
+		}
+
+		k := fmt.Sprintf("%s/%s/%s", origin.Collection, origin.FullName, m.String())
+		reported[k] = struct{}{}
+		if _, ok := r.reported[k]; ok {
+			continue
+		}
+		if err := r.record(origin, m); err != nil {
+			scope.Source.Errorf("Unable to record an event for %v(%v): %v", origin.Collection, origin.FullName, err)
+		}
+	}
+	r.reported = reported
+}
+
+func (r *EventReporter) record(origin *rt.Origin, m diag.Message) error {
+	s, ok := collections.All.Find(origin.Collection.String())
+	if !ok {
+		return fmt.Errorf("unknown collection %v", origin.Collection)
+	}
+	apiVersion := s.Resource().Version()
+	if s.Resource().Group() != "" {
+		apiVersion = s.Resource().Group() + "/" + apiVersion
+	}
+
+	m.DocRef = DocRef
+	namespace := origin.FullName.Namespace.String()
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	now := metav1.NewTime(time.Now())
+	event := &v1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", origin.FullName.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: v1.ObjectReference{
+			Kind:            origin.Kind,
+			APIVersion:      apiVersion,
+			Namespace:       origin.FullName.Namespace.String(),
+			Name:            origin.FullName.Name.String(),
+			ResourceVersion: string(origin.Version),
+		},
+		Reason:         m.Type.Code(),
+		Message:        fmt.Sprintf("%s %s", fmt.Sprintf(m.Type.Template(), m.Parameters...), m.DocumentationURL()),
+		Type:           v1.EventTypeWarning,
+		Source:         v1.EventSource{Component: r.component},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err := r.client.CoreV1().Events(namespace).Create(context.TODO(), event, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package status
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/analysis/msg"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func virtualService(name string) *resource.Instance {
+	return &resource.Instance{
+		Origin: &rt.Origin{
+			Collection: collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+			Kind:       "VirtualService",
+			FullName:   resource.NewFullName("ns", resource.LocalName(name)),
+			Version:    "v1",
+		},
+	}
+}
+
+func listEvents(g *GomegaWithT, client *fake.Clientset) []v1.Event {
+	events, err := client.CoreV1().Events("ns").List(context.TODO(), metav1.ListOptions{})
+	g.Expect(err).To(BeNil())
+	return events.Items
+}
+
+func TestEventReporter(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	client := fake.NewSimpleClientset()
+	r := NewEventReporter(client, "test")
+
+	gatewayNotFound := msg.NewReferencedResourceNotFound(virtualService("vs1"), "gateway", "gw")
+	r.Update(diag.Messages{
+		gatewayNotFound,
+		msg.NewDeprecated(virtualService("vs2"), "deprecated"),
+	})
+
+	events := listEvents(g, client)
+	g.Expect(events).To(HaveLen(1))
+	g.Expect(events[0].Type).To(Equal(v1.EventTypeWarning))
+	g.Expect(events[0].Reason).To(Equal("IST0101"))
+	g.Expect(events[0].Message).To(HavePrefix(`Referenced gateway not found: "gw"`))
+	g.Expect(events[0].Source.Component).To(Equal("test"))
+	g.Expect(events[0].InvolvedObject).To(Equal(v1.ObjectReference{
+		Kind:            "VirtualService",
+		APIVersion:      "networking.istio.io/v1alpha3",
+		Namespace:       "ns",
+		Name:            "vs1",
+		ResourceVersion: "v1",
+	}))
+
+	// The errors already reported do not produce events again
+	r.Update(diag.Messages{gatewayNotFound})
+	g.Expect(listEvents(g, client)).To(HaveLen(1))
+
+	// Until they are fixed and reported again
+	r.Update(diag.Messages{})
+	r.Update(diag.Messages{gatewayNotFound})
+	g.Expect(listEvents(g, client)).To(HaveLen(2))
+}
@@ -58,7 +58,12 @@ import (
 	"istio.io/istio/pkg/mcp/source"
 )
 
-const versionMetadataKey = "config.source.version"
+const (
+	versionMetadataKey = "config.source.version"
+
+	// analysisEventSource is the component recording the Kubernetes Events of the analysis
+	analysisEventSource = "istio-config-analysis"
+)
 
 // Processing component is the main config processing component that will listen to a config source and publish
 // resources through an MCP server, or a dialout connection.
@@ -328,6 +333,16 @@ func (p *Processing) createSourceAndStatusUpdater(schemas collection.Schemas) (
 		s := apiserver.New(o)
 		src = s
 		updater = s
+
+		if p.args.EnableConfigAnalysis {
+			client, clientErr := k.KubeClient()
+			if clientErr != nil {
+				err = clientErr
+				return
+			}
+			// Also notify the owners of the resources of the new errors with Kubernetes Events
+			updater = snapshotter.CombineStatusUpdaters(s, status.NewEventReporter(client, analysisEventSource))
+		}
 	}
 	return
 }
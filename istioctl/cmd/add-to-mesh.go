@@ -118,11 +118,12 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 			if err != nil {
 				return err
 			}
-			var sidecarTemplate, valuesConfig string
+			var injectConfig *inject.Config
+			var valuesConfig string
 			ns := handlers.HandleNamespace(namespace, defaultNamespace)
 			writer := cmd.OutOrStdout()
 
-			meshConfig, err := setupParameters(&sidecarTemplate, &valuesConfig)
+			meshConfig, err := setupParameters(&injectConfig, &valuesConfig)
 			if err != nil {
 				return err
 			}
@@ -132,7 +133,7 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 			}
 			deps := make([]appsv1.Deployment, 0)
 			deps = append(deps, *dep)
-			return injectSideCarIntoDeployment(client, deps, sidecarTemplate, valuesConfig,
+			return injectSideCarIntoDeployment(client, deps, injectConfig, valuesConfig,
 				args[0], ns, revision, meshConfig, writer)
 		},
 	}
@@ -164,11 +165,12 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 			if err != nil {
 				return err
 			}
-			var sidecarTemplate, valuesConfig string
+			var injectConfig *inject.Config
+			var valuesConfig string
 			ns := handlers.HandleNamespace(namespace, defaultNamespace)
 			writer := cmd.OutOrStdout()
 
-			meshConfig, err := setupParameters(&sidecarTemplate, &valuesConfig)
+			meshConfig, err := setupParameters(&injectConfig, &valuesConfig)
 			if err != nil {
 				return err
 			}
@@ -180,7 +182,7 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 				_, _ = fmt.Fprintf(writer, "No deployments found for service %s.%s\n", args[0], ns)
 				return nil
 			}
-			return injectSideCarIntoDeployment(client, matchingDeployments, sidecarTemplate, valuesConfig,
+			return injectSideCarIntoDeployment(client, matchingDeployments, injectConfig, valuesConfig,
 				args[0], ns, revision, meshConfig, writer)
 		},
 	}
@@ -232,7 +234,7 @@ http:9080 tcp:8888 -l app=test,version=v1 -a env=stage -s stageAdmin`,
 	return cmd
 }
 
-func setupParameters(sidecarTemplate, valuesConfig *string) (*meshconfig.MeshConfig, error) {
+func setupParameters(injectConfig **inject.Config, valuesConfig *string) (*meshconfig.MeshConfig, error) {
 	var meshConfig *meshconfig.MeshConfig
 	var err error
 	if meshConfigFile != "" {
@@ -249,12 +251,11 @@ func setupParameters(sidecarTemplate, valuesConfig *string) (*meshconfig.MeshCon
 		if err != nil {
 			return nil, err
 		}
-		var injectConfig inject.Config
-		if err := yaml.Unmarshal(injectionConfig, &injectConfig); err != nil {
+		*injectConfig = &inject.Config{}
+		if err := yaml.Unmarshal(injectionConfig, *injectConfig); err != nil {
 			return nil, multierror.Append(err, fmt.Errorf("loading --injectConfigFile"))
 		}
-		*sidecarTemplate = injectConfig.Template
-	} else if *sidecarTemplate, err = getInjectConfigFromConfigMap(kubeconfig); err != nil {
+	} else if *injectConfig, err = getInjectConfigFromConfigMap(kubeconfig); err != nil {
 		return nil, err
 	}
 	if valuesFile != "" {
@@ -269,13 +270,13 @@ func setupParameters(sidecarTemplate, valuesConfig *string) (*meshconfig.MeshCon
 	return meshConfig, err
 }
 
-func injectSideCarIntoDeployment(client kubernetes.Interface, deps []appsv1.Deployment, sidecarTemplate, valuesConfig,
-	svcName, svcNamespace string, revision string, meshConfig *meshconfig.MeshConfig, writer io.Writer) error {
+func injectSideCarIntoDeployment(client kubernetes.Interface, deps []appsv1.Deployment, injectConfig *inject.Config,
+	valuesConfig, svcName, svcNamespace string, revision string, meshConfig *meshconfig.MeshConfig, writer io.Writer) error {
 	var errs error
 	for _, dep := range deps {
 		log.Debugf("updating deployment %s.%s with Istio sidecar injected",
 			dep.Name, dep.Namespace)
-		newDep, err := inject.IntoObject(sidecarTemplate, valuesConfig, revision, meshConfig, &dep)
+		newDep, err := inject.IntoObject(injectConfig, valuesConfig, revision, meshConfig, &dep)
 		if err != nil {
 			errs = multierror.Append(errs, fmt.Errorf("failed to inject sidecar to deployment resource %s.%s for service %s.%s due to %v",
 				dep.Name, dep.Namespace, svcName, svcNamespace, err))
@@ -0,0 +1,165 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/local"
+	cfgKube "istio.io/istio/galley/pkg/config/source/kube"
+	"istio.io/istio/istioctl/pkg/proxydiff"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema"
+)
+
+// diffProxyConfig generates the proxy configuration of the workloads for the current state of the mesh,
+// and for the state after applying the files, and prints the changes. The current state is read from the
+// cluster. When k is nil, it is made of the Services and Pods of the files, so that the changes made by
+// their Istio configuration are printed.
+func diffProxyConfig(out io.Writer, k cfgKube.Interfaces, readers []local.ReaderSource, cancel chan struct{}) error {
+	proposed, err := collectResources(k, readers, cancel)
+	if err != nil {
+		return err
+	}
+	current := proposed.WithoutIstioConfig()
+	if k != nil {
+		if current, err = collectResources(k, nil, cancel); err != nil {
+			return err
+		}
+	}
+
+	currentGen, err := proxydiff.NewGenerator(current)
+	if err != nil {
+		return err
+	}
+	proposedGen, err := proxydiff.NewGenerator(proposed)
+	if err != nil {
+		return err
+	}
+
+	workloads := selectWorkloads(currentGen.Workloads(), proposedGen.Workloads())
+	if len(workloads) == 0 {
+		fmt.Fprintf(out, "No workloads with a proxy found when analyzing %s.\n", analyzeTargetAsString())
+		return nil
+	}
+
+	changed := 0
+	for _, w := range workloads {
+		a, err := currentGen.Generate(w)
+		if err != nil {
+			return fmt.Errorf("failed to generate the current configuration of %s: %v", w, err)
+		}
+		b, err := proposedGen.Generate(w)
+		if err != nil {
+			return fmt.Errorf("failed to generate the proposed configuration of %s: %v", w, err)
+		}
+
+		var buf bytes.Buffer
+		ok, err := proxydiff.Diff(&buf, a, b)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		changed++
+		switch {
+		case a == nil:
+			fmt.Fprintf(out, "Proxy configuration of %s (new workload):\n", w)
+		case b == nil:
+			fmt.Fprintf(out, "Proxy configuration of %s (removed workload):\n", w)
+		default:
+			fmt.Fprintf(out, "Proxy configuration of %s:\n", w)
+		}
+		fmt.Fprintln(out, buf.String())
+	}
+
+	if changed == 0 {
+		fmt.Fprintf(out, "\u2714 No proxy configuration changes for the %d workloads found when analyzing %s.\n",
+			len(workloads), analyzeTargetAsString())
+	}
+	return nil
+}
+
+// collectResources reads the state of the mesh from the cluster (or the default resources when k is nil)
+// and the files, with the same precedence as the analysis.
+func collectResources(k cfgKube.Interfaces, readers []local.ReaderSource, cancel chan struct{}) (proxydiff.Resources, error) {
+	collector := &proxydiff.Collector{}
+	sa := local.NewSourceAnalyzer(schema.MustGet(), analysis.Combine("proxydiff", collector),
+		resource.Namespace(selectedNamespace), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
+
+	if k != nil {
+		sa.AddRunningKubeSource(k)
+	}
+	if meshCfgFile != "" {
+		if err := sa.AddFileKubeMeshConfig(meshCfgFile); err != nil {
+			return nil, err
+		}
+	}
+	if k == nil {
+		if err := sa.AddDefaultResources(); err != nil {
+			return nil, err
+		}
+	}
+	// Always add the files, even when there are none, so that there is at least one source
+	if err := sa.AddReaderKubeSource(readers); err != nil {
+		return nil, err
+	}
+
+	if _, err := sa.Analyze(cancel); err != nil {
+		return nil, err
+	}
+	return collector.Resources(), nil
+}
+
+// selectWorkloads returns the workloads of either state selected with --workload, or all of them in the
+// analyzed namespaces.
+func selectWorkloads(current, proposed []string) []string {
+	found := map[string]bool{}
+	for _, w := range append(current, proposed...) {
+		found[w] = true
+	}
+
+	var selected []string
+	if len(diffWorkloads) > 0 {
+		for _, name := range diffWorkloads {
+			podName, ns := handlers.InferPodInfo(name, handlers.HandleNamespace(namespace, defaultNamespace))
+			w := fmt.Sprintf("%s.%s", podName, ns)
+			if found[w] {
+				selected = append(selected, w)
+				delete(found, w)
+			}
+		}
+		return selected
+	}
+
+	for _, w := range append(current, proposed...) {
+		if !found[w] {
+			continue
+		}
+		delete(found, w)
+		if selectedNamespace == "" || strings.HasSuffix(w, "."+selectedNamespace) {
+			selected = append(selected, w)
+		}
+	}
+	sort.Strings(selected)
+	return selected
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/galley/pkg/config/util/kubeyaml"
+)
+
+// fixer applies the fixes suggested by validation messages to the files the resources were read
+// from, after confirmation. The fixes of the resources read from the cluster are printed instead.
+type fixer struct {
+	in               *bufio.Reader
+	out              io.Writer
+	skipConfirmation bool
+
+	files map[string]*fixedFile
+}
+
+// fixedFile is a file being fixed, split into its YAML documents
+type fixedFile struct {
+	docs    [][]byte
+	lines   []int // The first line of each document
+	changed bool
+}
+
+func newFixer(in io.Reader, out io.Writer, skipConfirmation bool) *fixer {
+	return &fixer{
+		in:               bufio.NewReader(in),
+		out:              out,
+		skipConfirmation: skipConfirmation,
+		files:            map[string]*fixedFile{},
+	}
+}
+
+// fix offers the fixes of the messages, and writes the files fixed.
+func (f *fixer) fix(messages diag.Messages) error {
+	for _, m := range messages {
+		for _, fix := range m.Fixes {
+			if err := f.offer(m, fix); err != nil {
+				return err
+			}
+		}
+	}
+	return f.write()
+}
+
+func (f *fixer) offer(m diag.Message, fix diag.Fix) error {
+	patch, err := json.Marshal(fix.Patch)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f.out, "%s\n  Fix: %s\n  Patch: %s\n", m.String(), fix.Description, patch)
+
+	if m.Resource == nil {
+		return nil
+	}
+	origin, ok := m.Resource.Origin.(*rt.Origin)
+	if !ok {
+		return nil
+	}
+	position, ok := origin.Ref.(*rt.Position)
+	if !ok || position == nil || position.Filename == "" {
+		args := []string{"kubectl", "patch", strings.ToLower(origin.Kind), origin.FullName.Name.String()}
+		if origin.FullName.Namespace != "" {
+			args = append(args, "-n", origin.FullName.Namespace.String())
+		}
+		fmt.Fprintf(f.out, "  Apply with: %s --type=json -p '%s'\n", strings.Join(args, " "), patch)
+		return nil
+	}
+
+	if !f.skipConfirmation && !f.confirm(fmt.Sprintf("  Apply to %s? (y/N)", position)) {
+		return nil
+	}
+	return f.apply(position, patch)
+}
+
+// confirm waits for the user to confirm with the supplied message.
+func (f *fixer) confirm(msg string) bool {
+	fmt.Fprintf(f.out, "%s ", msg)
+	response, err := f.in.ReadString('\n')
+	if err != nil && response == "" {
+		return false
+	}
+	response = strings.ToUpper(strings.TrimSpace(response))
+	return response == "Y" || response == "YES"
+}
+
+// apply applies the patch to the document at the position
+func (f *fixer) apply(position *rt.Position, patch []byte) error {
+	file, err := f.load(position.Filename)
+	if err != nil {
+		return err
+	}
+	i := sort.SearchInts(file.lines, position.Line)
+	if i == len(file.lines) || file.lines[i] != position.Line {
+		return fmt.Errorf("no resource found at %s", position)
+	}
+
+	doc, err := yaml.YAMLToJSON(file.docs[i])
+	if err != nil {
+		return fmt.Errorf("cannot read the resource at %s: %v", position, err)
+	}
+	p, err := jsonpatch.DecodePatch(patch)
+	if err != nil {
+		return err
+	}
+	if doc, err = p.Apply(doc); err != nil {
+		return fmt.Errorf("cannot apply the fix to the resource at %s: %v", position, err)
+	}
+	if file.docs[i], err = yaml.JSONToYAML(doc); err != nil {
+		return err
+	}
+	file.changed = true
+	return nil
+}
+
+func (f *fixer) load(filename string) (*fixedFile, error) {
+	if file, ok := f.files[filename]; ok {
+		return file, nil
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	file := &fixedFile{}
+	reader := kubeyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(content)))
+	for {
+		doc, line, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("cannot read %s: %v", filename, err)
+		}
+		file.docs = append(file.docs, doc)
+		file.lines = append(file.lines, line)
+	}
+	f.files[filename] = file
+	return file, nil
+}
+
+// write writes the files changed
+func (f *fixer) write() error {
+	filenames := make([]string, 0, len(f.files))
+	for filename, file := range f.files {
+		if file.changed {
+			filenames = append(filenames, filename)
+		}
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, kubeyaml.Join(f.files[filename].docs...), info.Mode()); err != nil {
+			return err
+		}
+		fmt.Fprintf(f.out, "Fixed %s\n", filename)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
+)
+
+const fixServices = `apiVersion: v1
+kind: Service
+metadata:
+  name: first
+spec:
+  ports:
+  - port: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: second
+spec:
+  ports:
+  - port: 8080
+`
+
+func fixMessage(ref resource.Reference, name string, value string) diag.Message {
+	r := &resource.Instance{
+		Origin: &rt.Origin{
+			Kind:     "Service",
+			FullName: resource.NewFullName("default", resource.LocalName(name)),
+			Ref:      ref,
+		},
+	}
+	m := diag.NewMessage(diag.NewMessageType(diag.Info, "A1", "Template: %q"), r, "")
+	return m.WithFix(diag.NewFix("Rename the port", diag.Add("/spec/ports/0/name", value)))
+}
+
+func TestFixAppliesConfirmedFixesToFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "analyze-fix")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "services.yaml")
+	g.Expect(ioutil.WriteFile(file, []byte(fixServices), 0644)).To(Succeed())
+
+	messages := diag.Messages{
+		fixMessage(&rt.Position{Filename: file, Line: 1}, "first", "http"),
+		fixMessage(&rt.Position{Filename: file, Line: 9}, "second", "http-alt"),
+	}
+	var out bytes.Buffer
+	g.Expect(newFixer(strings.NewReader("n\ny\n"), &out, false).fix(messages)).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(`Patch: [{"op":"add","path":"/spec/ports/0/name","value":"http-alt"}]`))
+	g.Expect(out.String()).To(ContainSubstring("Fixed " + file))
+
+	content, err := ioutil.ReadFile(file)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(content)).NotTo(ContainSubstring("name: http\n"))
+	g.Expect(string(content)).To(ContainSubstring("name: http-alt"))
+	g.Expect(string(content)).To(ContainSubstring("name: first"))
+}
+
+func TestFixWithoutConfirmation(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "analyze-fix")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "services.yaml")
+	g.Expect(ioutil.WriteFile(file, []byte(fixServices), 0644)).To(Succeed())
+
+	messages := diag.Messages{
+		fixMessage(&rt.Position{Filename: file, Line: 1}, "first", "http"),
+		fixMessage(&rt.Position{Filename: file, Line: 9}, "second", "http-alt"),
+	}
+	g.Expect(newFixer(strings.NewReader(""), ioutil.Discard, true).fix(messages)).To(Succeed())
+
+	content, err := ioutil.ReadFile(file)
+	g.Expect(err).To(BeNil())
+	g.Expect(string(content)).To(ContainSubstring("name: http\n"))
+	g.Expect(string(content)).To(ContainSubstring("name: http-alt"))
+}
+
+func TestFixUnknownPosition(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	dir, err := ioutil.TempDir("", "analyze-fix")
+	g.Expect(err).To(BeNil())
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "services.yaml")
+	g.Expect(ioutil.WriteFile(file, []byte(fixServices), 0644)).To(Succeed())
+
+	messages := diag.Messages{fixMessage(&rt.Position{Filename: file, Line: 3}, "first", "http")}
+	g.Expect(newFixer(strings.NewReader(""), ioutil.Discard, true).fix(messages)).NotTo(Succeed())
+}
+
+func TestFixPrintsPatchesForClusterResources(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	var out bytes.Buffer
+	g.Expect(newFixer(strings.NewReader(""), &out, false).fix(diag.Messages{fixMessage(nil, "first", "http")})).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(
+		`Apply with: kubectl patch service first -n default --type=json -p '[{"op":"add","path":"/spec/ports/0/name","value":"http"}]'`))
+}
+
+func TestFixPrintsPatchesOfMessagesWithoutResource(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	m := diag.NewMessage(diag.NewMessageType(diag.Info, "A1", "Template: %q"), nil, "")
+	m = m.WithFix(diag.NewFix("Rename the port", diag.Add("/spec/ports/0/name", "http")))
+	var out bytes.Buffer
+	g.Expect(newFixer(strings.NewReader(""), &out, false).fix(diag.Messages{m})).To(Succeed())
+	g.Expect(out.String()).To(ContainSubstring(`Patch: [{"op":"add","path":"/spec/ports/0/name","value":"http"}]`))
+	g.Expect(out.String()).NotTo(ContainSubstring("Apply with"))
+}
@@ -32,12 +32,14 @@ import (
 	"github.com/spf13/cobra"
 
 	"istio.io/pkg/env"
+	"istio.io/pkg/version"
 
 	"istio.io/istio/galley/pkg/config/analysis"
 	"istio.io/istio/galley/pkg/config/analysis/analyzers"
 	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/analysis/local"
 	cfgKube "istio.io/istio/galley/pkg/config/source/kube"
+	"istio.io/istio/istioctl/pkg/util/formatting"
 	"istio.io/istio/istioctl/pkg/util/handlers"
 	"istio.io/istio/pkg/config/resource"
 	"istio.io/istio/pkg/config/schema"
@@ -55,6 +57,8 @@ const (
 	LogOutput       = "log"
 	JSONOutput      = "json"
 	YamlOutput      = "yaml"
+	SarifOutput     = "sarif"
+	JUnitOutput     = "junit"
 )
 
 func (f AnalyzerFoundIssuesError) Error() string {
@@ -81,6 +85,10 @@ var (
 	suppress          []string
 	analysisTimeout   time.Duration
 	recursive         bool
+	fix               bool
+	skipConfirmation  bool
+	diff              bool
+	diffWorkloads     []string
 
 	termEnvVar = env.RegisterStringVar("TERM", "", "Specifies terminal type.  Use 'dumb' to suppress color output")
 
@@ -96,7 +104,7 @@ var (
 // Analyze command
 func Analyze() *cobra.Command {
 	// Validate the output format before doing potentially expensive work to fail earlier
-	msgOutputFormats := map[string]bool{LogOutput: true, JSONOutput: true, YamlOutput: true}
+	msgOutputFormats := map[string]bool{LogOutput: true, JSONOutput: true, YamlOutput: true, SarifOutput: true, JUnitOutput: true}
 	var msgOutputFormatKeys []string
 
 	for k := range msgOutputFormats {
@@ -126,6 +134,16 @@ istioctl analyze -S "IST0103=Pod mypod.testing"
 # and suppress MisplacedAnnotation on deployment foobar in namespace default.
 istioctl analyze -S "IST0103=Pod *.testing" -S "IST0107=Deployment foobar.default"
 
+# Analyze yaml files without connecting to a live cluster, and apply the suggested fixes to them
+istioctl analyze --use-kube=false --fix a.yaml b.yaml
+
+# Print the changes of the proxy configuration of the workloads in the current namespace
+# the yaml files would make once applied to the live cluster
+istioctl analyze --diff a.yaml b.yaml
+
+# Print the changes of the proxy configuration of a single pod
+istioctl analyze --diff --workload productpage-v1-6b746f74dc-9stvs.default a.yaml
+
 # List available analyzers
 istioctl analyze -L
 `,
@@ -158,6 +176,22 @@ istioctl analyze -L
 				selectedNamespace = ""
 			}
 
+			// If we're using kube, use that as a base source.
+			var k cfgKube.Interfaces
+			if useKube {
+				// Set up the kube client
+				config := kube.BuildClientCmd(kubeconfig, configContext)
+				restConfig, err := config.ClientConfig()
+				if err != nil {
+					return err
+				}
+				k = cfgKube.NewInterfaces(restConfig)
+			}
+
+			if diff {
+				return diffProxyConfig(cmd.OutOrStdout(), k, readers, cancel)
+			}
+
 			sa := local.NewSourceAnalyzer(schema.MustGet(), analyzers.AllCombined(),
 				resource.Namespace(selectedNamespace), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
 
@@ -188,15 +222,7 @@ istioctl analyze -L
 			}
 			sa.SetSuppressions(suppressions)
 
-			// If we're using kube, use that as a base source.
-			if useKube {
-				// Set up the kube client
-				config := kube.BuildClientCmd(kubeconfig, configContext)
-				restConfig, err := config.ClientConfig()
-				if err != nil {
-					return err
-				}
-				k := cfgKube.NewInterfaces(restConfig)
+			if k != nil {
 				sa.AddRunningKubeSource(k)
 			}
 
@@ -293,10 +319,30 @@ istioctl analyze -L
 					return err
 				}
 				fmt.Fprintln(cmd.OutOrStdout(), string(yamlOutput))
+			case SarifOutput:
+				sarifOutput, err := formatting.SARIF(outputMessages, version.Info.Version)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(sarifOutput))
+			case JUnitOutput:
+				junitOutput, err := formatting.JUnit(outputMessages, failureLevel.Level)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), string(junitOutput))
 			default: // This should never happen since we validate this already
 				panic(fmt.Sprintf("%q not found in output format switch statement post validate?", msgOutputFormat))
 			}
 
+			if fix {
+				if err := newFixer(cmd.InOrStdin(), cmd.ErrOrStderr(), skipConfirmation).fix(outputMessages); err != nil {
+					return err
+				}
+			} else if fixes := countFixes(outputMessages); fixes > 0 && msgOutputFormat == LogOutput {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%d fixes are suggested. Run with --fix to apply them.\n", fixes)
+			}
+
 			// Return code is based on the unfiltered validation message list/parse errors
 			// We're intentionally keeping failure threshold and output threshold decoupled for now
 			returnError := errorIfMessagesExceedThreshold(result.Messages)
@@ -333,6 +379,17 @@ istioctl analyze -L
 		"the duration to wait before failing")
 	analysisCmd.PersistentFlags().BoolVarP(&recursive, "recursive", "R", false,
 		"Process directory arguments recursively. Useful when you want to analyze related manifests organized within the same directory.")
+	analysisCmd.PersistentFlags().BoolVar(&fix, "fix", false,
+		"Offer the fixes suggested for the messages. The fixes are applied to the analyzed files after confirmation, "+
+			"and printed as patches for the resources of the cluster. The comments of the fixed resources are not kept.")
+	analysisCmd.PersistentFlags().BoolVarP(&skipConfirmation, "skip-confirmation", "y", false,
+		"Apply the fixes without prompting for confirmation. Used with --fix.")
+	analysisCmd.PersistentFlags().BoolVar(&diff, "diff", false,
+		"Instead of the validation messages, print the changes of the listeners, routes and clusters of the workloads "+
+			"between the current state and the state with the files applied, generating the proxy configuration offline.")
+	analysisCmd.PersistentFlags().StringArrayVar(&diffWorkloads, "workload", []string{},
+		"Pod to print the proxy configuration changes of, in the <pod-name>[.<namespace>] format. Used with --diff. "+
+			"Can be repeated. Defaults to all the pods with a proxy in the analyzed namespaces.")
 	return analysisCmd
 }
 
@@ -482,6 +539,14 @@ func errorIfMessagesExceedThreshold(messages []diag.Message) error {
 	return nil
 }
 
+func countFixes(messages []diag.Message) int {
+	count := 0
+	for _, m := range messages {
+		count += len(m.Fixes)
+	}
+	return count
+}
+
 func isValidFile(f string) bool {
 	ext := filepath.Ext(f)
 	for _, e := range fileExtensions {
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 
@@ -25,14 +26,31 @@ import (
 	"istio.io/istio/istioctl/pkg/kubernetes"
 	"istio.io/istio/istioctl/pkg/util/configdump"
 	"istio.io/istio/istioctl/pkg/util/handlers"
+	envoyconfigdump "istio.io/istio/istioctl/pkg/writer/envoy/configdump"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/spiffe"
 	"istio.io/pkg/log"
 )
 
 var (
 	printAll       bool
 	configDumpFile string
+
+	policyFiles []string
+	checkLabels []string
+
+	checkPrincipal, checkSourceNamespace, checkSourceIP   string
+	checkDestinationIP, checkMethod, checkHost, checkPath string
+	checkPort                                             int
+	checkHeaders, checkClaims                             []string
 )
 
+// checkRequestFlags are the flags describing the request to evaluate.
+var checkRequestFlags = []string{"principal", "source-namespace", "source-ip", "destination-ip",
+	"method", "host", "path", "header", "port", "claim"}
+
 var (
 	checkCmd = &cobra.Command{
 		Use:   "check <pod-name>[.<pod-namespace>]",
@@ -44,13 +62,26 @@ with authorization and the rules used in the authorization.
 The Envoy config dump could be provided either by pod name or from a config dump file
 (the whole output of http://localhost:15000/config_dump of an Envoy instance).
 
-THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+When a request is described with the --principal, --source-namespace, --source-ip,
+--method, --host, --path, --header, --port or --claim flags, check evaluates it against
+the RBAC rules and shadow rules of the filters it goes through, and reports whether it is
+allowed or denied and the policy and rule that decided it. The request is evaluated
+against the AuthorizationPolicy resources of the --policy files instead of a config dump
+when they are given, for a workload with the --labels in the namespace of the -n flag.
 `,
 		Example: `  # Check Envoy authorization configuration for pod httpbin-88ddbcfdd-nt5jb:
   istioctl x authz check httpbin-88ddbcfdd-nt5jb
 
   # Check Envoy authorization configuration from a config dump file:
-  istioctl x authz check -f httpbin_config_dump.json`,
+  istioctl x authz check -f httpbin_config_dump.json
+
+  # Check whether pod httpbin-88ddbcfdd-nt5jb allows a GET request on port 8000 from the sleep service account:
+  istioctl x authz check httpbin-88ddbcfdd-nt5jb --port 8000 --method GET --path /headers \
+    --principal cluster.local/ns/default/sa/sleep
+
+  # Check a request with JWT claims against AuthorizationPolicy resources in a file:
+  istioctl x authz check -p policies.yaml -n foo --labels app=httpbin --port 8000 --path /ip \
+    --claim iss=issuer@foo.com --claim groups=admin`,
 		Args: func(cmd *cobra.Command, args []string) error {
 			if len(args) > 1 {
 				cmd.Println(cmd.UsageString())
@@ -59,6 +90,10 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(policyFiles) > 0 || hasCheckRequest(cmd) {
+				return checkRequest(cmd, args)
+			}
+
 			var configDump *configdump.Wrapper
 			var err error
 			if configDumpFile != "" {
@@ -86,6 +121,132 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 	}
 )
 
+func hasCheckRequest(cmd *cobra.Command) bool {
+	for _, name := range checkRequestFlags {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRequest evaluates the request described by the flags against the AuthorizationPolicy resources of
+// the policy files, or against the RBAC filters of the config dump.
+func checkRequest(cmd *cobra.Command, args []string) error {
+	r, err := checkRequestFromFlags()
+	if err != nil {
+		return err
+	}
+	if checkPrincipal == "" && checkSourceNamespace != "" {
+		cmd.Printf("Note: no --principal given, evaluating the principal %s\n", r.SourcePrincipal)
+	}
+
+	var filters []*authz.RBACFilter
+	if len(policyFiles) > 0 {
+		if filters, err = rbacFiltersFromPolicyFiles(r); err != nil {
+			return err
+		}
+	} else {
+		cw, err := checkConfigWriter(cmd, args)
+		if err != nil {
+			return err
+		}
+		if r.DestinationIP == "" {
+			if ips := cw.InstanceIPs(); len(ips) > 0 {
+				r.DestinationIP = ips[0]
+			}
+		}
+		if r.DestinationIP == "" || r.DestinationPort == 0 {
+			return fmt.Errorf("check requires the --port of the request, and its --destination-ip " +
+				"when the IP of the workload is not in the config dump")
+		}
+		if filters, err = cw.RBACFilters(r); err != nil {
+			return err
+		}
+	}
+
+	if !authz.Check(cmd.OutOrStdout(), filters, r) {
+		return fmt.Errorf("the request is denied")
+	}
+	return nil
+}
+
+func checkRequestFromFlags() (*authz.Request, error) {
+	r := &authz.Request{
+		SourcePrincipal: strings.TrimPrefix(checkPrincipal, spiffe.URIPrefix),
+		SourceIP:        checkSourceIP,
+		DestinationIP:   checkDestinationIP,
+		DestinationPort: uint32(checkPort),
+		Method:          checkMethod,
+		Host:            checkHost,
+		Path:            checkPath,
+		Headers:         map[string]string{},
+		Claims:          map[string][]string{},
+	}
+	if checkSourceNamespace != "" {
+		if r.SourcePrincipal == "" {
+			r.SourcePrincipal = fmt.Sprintf("%s/ns/%s/sa/default", spiffe.GetTrustDomain(), checkSourceNamespace)
+		} else if !strings.Contains(r.SourcePrincipal, "/ns/"+checkSourceNamespace+"/") {
+			return nil, fmt.Errorf("principal %s is not in namespace %s", r.SourcePrincipal, checkSourceNamespace)
+		}
+	}
+	for _, h := range checkHeaders {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid header %q, expected <name>=<value>", h)
+		}
+		r.Headers[strings.ToLower(kv[0])] = kv[1]
+	}
+	for _, c := range checkClaims {
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid claim %q, expected <name>=<value>", c)
+		}
+		r.Claims[kv[0]] = append(r.Claims[kv[0]], kv[1])
+	}
+	if r.IsHTTP() {
+		if r.Method == "" {
+			r.Method = "GET"
+		}
+		if r.Path == "" {
+			r.Path = "/"
+		}
+	}
+	return r, nil
+}
+
+func rbacFiltersFromPolicyFiles(r *authz.Request) ([]*authz.RBACFilter, error) {
+	ns := handlers.HandleNamespace(namespace, defaultNamespace)
+	var policies []model.Config
+	for _, f := range policyFiles {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		configs, _, err := crd.ParseInputs(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", f, err)
+		}
+		for _, c := range configs {
+			if c.Type == collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().Kind() {
+				policies = append(policies, c)
+			}
+		}
+	}
+	return authz.RBACFiltersFromPolicies(policies, ns, istioNamespace, convertToMap(checkLabels), !r.IsHTTP())
+}
+
+func checkConfigWriter(cmd *cobra.Command, args []string) (*envoyconfigdump.ConfigWriter, error) {
+	if configDumpFile != "" {
+		return setupFileConfigdumpWriter(configDumpFile, cmd.OutOrStdout())
+	}
+	if len(args) == 1 {
+		podName, podNamespace := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+		return setupPodConfigdumpWriter(podName, podNamespace, cmd.OutOrStdout())
+	}
+	return nil, fmt.Errorf("expecting pod name or config dump, found: %d", len(args))
+}
+
 func getConfigDumpFromFile(filename string) (*configdump.Wrapper, error) {
 	file, err := os.Open(filename)
 	if err != nil {
@@ -136,13 +297,12 @@ func getConfigDumpFromPod(podName, podNamespace string) (*configdump.Wrapper, er
 }
 
 // AuthZ groups commands used for inspecting and interacting the authorization policy.
-// Note: this is still under active development and is not ready for real use.
 func AuthZ() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "authz",
 		Short: "Inspect and interact with authorization policies",
 		Long: `Commands to inspect and interact with the authorization policies
-  check - check Envoy config dump for authorization configuration
+  check - check Envoy config dump for authorization configuration, or whether a request is allowed
 `,
 		Example: `  # Check Envoy authorization configuration for pod httpbin-88ddbcfdd-nt5jb:
   istioctl x authz check httpbin-88ddbcfdd-nt5jb
@@ -158,4 +318,23 @@ func init() {
 		"Show additional information (e.g. SNI and ALPN)")
 	checkCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"The json file with Envoy config dump to be checked")
+	checkCmd.PersistentFlags().StringSliceVarP(&policyFiles, "policy", "p", nil,
+		"AuthorizationPolicy YAML files to evaluate the request against instead of a config dump")
+	checkCmd.PersistentFlags().StringSliceVarP(&checkLabels, "labels", "l", nil,
+		"The labels of the workload the --policy files are evaluated for")
+	checkCmd.PersistentFlags().StringVar(&checkPrincipal, "principal", "",
+		"The principal of the source of the request, e.g. cluster.local/ns/default/sa/sleep")
+	checkCmd.PersistentFlags().StringVar(&checkSourceNamespace, "source-namespace", "",
+		"The namespace of the source of the request")
+	checkCmd.PersistentFlags().StringVar(&checkSourceIP, "source-ip", "", "The IP of the source of the request")
+	checkCmd.PersistentFlags().StringVar(&checkDestinationIP, "destination-ip", "",
+		"The IP the request is sent to, the IP of the pod by default")
+	checkCmd.PersistentFlags().IntVar(&checkPort, "port", 0, "The port the request is sent to")
+	checkCmd.PersistentFlags().StringVar(&checkMethod, "method", "", "The method of the HTTP request")
+	checkCmd.PersistentFlags().StringVar(&checkHost, "host", "", "The host of the HTTP request")
+	checkCmd.PersistentFlags().StringVar(&checkPath, "path", "", "The path of the HTTP request")
+	checkCmd.PersistentFlags().StringArrayVar(&checkHeaders, "header", nil,
+		"A header of the HTTP request, as <name>=<value>")
+	checkCmd.PersistentFlags().StringArrayVar(&checkClaims, "claim", nil,
+		"A claim of the JWT authenticating the request, as <name>=<value>. Repeat it for list claims")
 }
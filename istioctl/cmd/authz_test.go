@@ -20,6 +20,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/spf13/pflag"
+
 	"istio.io/istio/pilot/test/util"
 )
 
@@ -58,3 +60,71 @@ func TestAuthZCheck(t *testing.T) {
 		runCommandWantOutput(command, c.golden, t)
 	}
 }
+
+// reviewsConfigDump is the config dump of the reviews proxy shared with the proxy-config explain tests
+const reviewsConfigDump = "../pkg/writer/envoy/configdump/testdata/explain/reviews-configdump.json"
+
+func TestAuthZCheckRequest(t *testing.T) {
+	testCases := []struct {
+		name    string
+		args    string
+		golden  string
+		wantErr bool
+	}{
+		{
+			name:   "config dump allows",
+			args:   "-f " + reviewsConfigDump + " --port 9080 --path /reviews/1 --principal cluster.local/ns/default/sa/productpage",
+			golden: "testdata/authz/check-dump-allowed.golden",
+		},
+		{
+			name:    "config dump denies",
+			args:    "-f " + reviewsConfigDump + " --port 9080 --method POST --path /reviews/1 --principal cluster.local/ns/default/sa/productpage",
+			golden:  "testdata/authz/check-dump-denied.golden",
+			wantErr: true,
+		},
+		{
+			name:   "policy allows principal",
+			args:   "-p testdata/authz/check-policies.yaml -n foo -l app=httpbin --port 8000 --path /ip --principal cluster.local/ns/foo/sa/sleep",
+			golden: "testdata/authz/check-policy-principal.golden",
+		},
+		{
+			name:   "policy allows claim",
+			args:   "-p testdata/authz/check-policies.yaml -n foo -l app=httpbin --port 8000 --path /status/200 --claim groups=dev --claim groups=admin",
+			golden: "testdata/authz/check-policy-claim.golden",
+		},
+		{
+			name:    "policy denies path",
+			args:    "-p testdata/authz/check-policies.yaml -n foo -l app=httpbin --port 8000 --path /admin --principal cluster.local/ns/foo/sa/sleep",
+			golden:  "testdata/authz/check-policy-deny.golden",
+			wantErr: true,
+		},
+		{
+			name:    "policy denies unmatched",
+			args:    "-p testdata/authz/check-policies.yaml -n foo -l app=httpbin --port 8000 --method POST --path /ip --source-namespace foo",
+			golden:  "testdata/authz/check-policy-unmatched.golden",
+			wantErr: true,
+		},
+	}
+
+	for _, c := range testCases {
+		t.Run(c.name, func(t *testing.T) {
+			resetCheckFlags()
+			out, err := runCommand("experimental authz check "+c.args, t)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			util.CompareContent(out.Bytes(), c.golden, t)
+		})
+	}
+	resetCheckFlags()
+}
+
+func resetCheckFlags() {
+	checkCmd.Flags().VisitAll(func(f *pflag.Flag) {
+		f.Changed = false
+	})
+	configDumpFile, policyFiles, checkLabels = "", nil, nil
+	checkPrincipal, checkSourceNamespace, checkSourceIP = "", "", ""
+	checkDestinationIP, checkMethod, checkHost, checkPath = "", "", "", ""
+	checkPort, checkHeaders, checkClaims = 0, nil, nil
+}
@@ -0,0 +1,420 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/local"
+	cfgKube "istio.io/istio/galley/pkg/config/source/kube"
+	"istio.io/istio/istioctl/pkg/bugreport"
+	"istio.io/istio/istioctl/pkg/clioptions"
+	istioctl_kubernetes "istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/istio/pkg/config/resource"
+	istio_schema "istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/kube"
+	"istio.io/pkg/version"
+)
+
+// istiodDebugEndpoints are the debug endpoints of istiod collected in bug reports.
+var istiodDebugEndpoints = []string{"syncz", "configz", "registryz", "push_status"}
+
+// proxyAdminEndpoints are the admin endpoints of Envoy collected in bug reports, by file name.
+var proxyAdminEndpoints = map[string]string{
+	"config_dump.json": "config_dump",
+	"stats.txt":        "stats",
+	"clusters.json":    "clusters?format=json",
+}
+
+type bugReportArgs struct {
+	output      string
+	namespaces  []string
+	since       time.Duration
+	endTime     string
+	timeout     time.Duration
+	concurrency int
+}
+
+// bugReportCollector builds the tasks collecting the state of the mesh.
+type bugReportCollector struct {
+	kubeClient kubernetes.Interface
+	dynamic    dynamic.Interface
+	exec       *istioctl_kubernetes.Client
+	opts       clioptions.ControlPlaneOptions
+
+	namespaces []string
+	start, end time.Time
+}
+
+func bugReportCmd() *cobra.Command {
+	args := bugReportArgs{}
+	opts := clioptions.ControlPlaneOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "bug-report",
+		Short: "Collects the state of the mesh into an archive to attach to bug reports",
+		Long: `Collects the state of the mesh into a tar.gz archive to attach to bug reports: the Istio
+and Kubernetes resources, the mesh and injection config, the debug output of istiod
+(syncz, configz, registryz and push_status), the Envoy config dump, stats and clusters of
+every proxy, the logs of the proxies and of istiod, and the output of istioctl analyze.
+
+The files are collected in parallel, each within --timeout. The archive holds index.json,
+listing every file and the error of the ones that could not be collected. Private keys,
+passwords and tokens are redacted, and Secrets are not collected.`,
+		Example: `  # Collect a bug report for the whole mesh, with the logs of the last 30 minutes:
+  istioctl bug-report
+
+  # Collect a bug report for namespaces foo and bar, with the logs of the hour before an incident:
+  istioctl bug-report --namespaces foo,bar --since 1h --end-time 2020-05-01T10:00:00Z -o incident.tar.gz`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			end := time.Now()
+			if args.endTime != "" {
+				var err error
+				if end, err = time.Parse(time.RFC3339, args.endTime); err != nil {
+					return fmt.Errorf("invalid --end-time %q, expected RFC3339: %v", args.endTime, err)
+				}
+			}
+			if args.output == "" {
+				args.output = fmt.Sprintf("bug-report-%s.tar.gz", time.Now().UTC().Format("20060102-150405"))
+			}
+
+			kubeClient, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %v", err)
+			}
+			dynamicClient, err := crdFactory(kubeconfig)
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %v", err)
+			}
+			exec, err := istioctl_kubernetes.NewExtendedClient(kubeconfig, configContext, opts)
+			if err != nil {
+				return fmt.Errorf("failed to create Kubernetes client: %v", err)
+			}
+			c := &bugReportCollector{
+				kubeClient: kubeClient,
+				dynamic:    dynamicClient,
+				exec:       exec,
+				opts:       opts,
+				namespaces: args.namespaces,
+				start:      end.Add(-args.since),
+				end:        end,
+			}
+
+			f, err := os.Create(args.output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			root := strings.TrimSuffix(path.Base(args.output), ".tar.gz")
+			archive := bugreport.NewArchive(f, root)
+
+			tasks := c.tasks()
+			fmt.Fprintf(cmd.ErrOrStderr(), "Collecting %d files from the cluster...\n", len(tasks))
+			collector := &bugreport.Collector{Concurrency: args.concurrency, Timeout: args.timeout}
+			entries := collector.Run(context.Background(), tasks, archive)
+			err = archive.Close(&bugreport.Index{
+				Created:    time.Now(),
+				Start:      c.start,
+				End:        c.end,
+				Namespaces: c.namespaces,
+				Entries:    entries,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %v", args.output, err)
+			}
+
+			printBugReportSummary(cmd, args.output, entries)
+			return nil
+		},
+	}
+
+	cmd.PersistentFlags().StringVarP(&args.output, "output", "o", "",
+		"The archive to write, bug-report-<timestamp>.tar.gz by default")
+	cmd.PersistentFlags().StringSliceVar(&args.namespaces, "namespaces", nil,
+		"The namespaces of the resources, proxies and logs to collect, all namespaces by default")
+	cmd.PersistentFlags().DurationVar(&args.since, "since", 30*time.Minute,
+		"The duration before --end-time of the logs to collect")
+	cmd.PersistentFlags().StringVar(&args.endTime, "end-time", "",
+		"The end of the time window of the logs to collect, in RFC3339 format. Now by default")
+	cmd.PersistentFlags().DurationVar(&args.timeout, "timeout", 30*time.Second,
+		"The maximum duration of the collection of each file")
+	cmd.PersistentFlags().IntVar(&args.concurrency, "concurrency", 10,
+		"The maximum number of files collected at once")
+	opts.AttachControlPlaneFlags(cmd)
+	return cmd
+}
+
+func printBugReportSummary(cmd *cobra.Command, output string, entries []bugreport.Entry) {
+	var failed []bugreport.Entry
+	for _, e := range entries {
+		if e.Error != "" {
+			failed = append(failed, e)
+		}
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s: %d files collected, %d failed\n",
+		output, len(entries)-len(failed), len(failed))
+	for _, e := range failed {
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s: %s\n", e.Path, e.Error)
+	}
+}
+
+func (c *bugReportCollector) tasks() []bugreport.Task {
+	tasks := []bugreport.Task{c.versionTask(), c.analyzeTask()}
+	tasks = append(tasks, c.meshConfigTasks()...)
+	tasks = append(tasks, c.resourceTasks()...)
+	tasks = append(tasks, c.istiodTasks()...)
+	tasks = append(tasks, c.proxyTasks()...)
+	return tasks
+}
+
+func (c *bugReportCollector) versionTask() bugreport.Task {
+	return bugreport.Task{
+		Path: "version.json",
+		Collect: func(context.Context) ([]byte, error) {
+			meshInfo, err := c.exec.GetIstioVersions(istioNamespace)
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(map[string]interface{}{
+				"client": version.Info,
+				"mesh":   meshInfo,
+			}, "", "  ")
+		},
+	}
+}
+
+// analyzeTask runs istioctl analyze against the cluster, in the namespace of the report when it is
+// restricted to one.
+func (c *bugReportCollector) analyzeTask() bugreport.Task {
+	return bugreport.Task{
+		Path: "analyze.json",
+		Collect: func(ctx context.Context) ([]byte, error) {
+			restConfig, err := kube.BuildClientConfig(kubeconfig, configContext)
+			if err != nil {
+				return nil, err
+			}
+			selected := ""
+			if len(c.namespaces) == 1 {
+				selected = c.namespaces[0]
+			}
+			sa := local.NewSourceAnalyzer(istio_schema.MustGet(), analyzers.AllCombined(),
+				resource.Namespace(selected), resource.Namespace(istioNamespace), nil, true, analysisTimeout)
+			sa.AddRunningKubeSource(cfgKube.NewInterfaces(restConfig))
+			cancel := make(chan struct{})
+			go func() {
+				<-ctx.Done()
+				close(cancel)
+			}()
+			result, err := sa.Analyze(cancel)
+			if err != nil {
+				return nil, err
+			}
+			return json.MarshalIndent(result.Messages, "", "  ")
+		},
+	}
+}
+
+func (c *bugReportCollector) meshConfigTasks() []bugreport.Task {
+	var tasks []bugreport.Task
+	for _, name := range []string{defaultMeshConfigMapName, defaultInjectConfigMapName} {
+		if c.opts.Revision != "" {
+			name = name + "-" + c.opts.Revision
+		}
+		name := name
+		tasks = append(tasks, bugreport.Task{
+			Path: path.Join("mesh", name+".yaml"),
+			Collect: func(ctx context.Context) ([]byte, error) {
+				cm, err := c.kubeClient.CoreV1().ConfigMaps(istioNamespace).Get(ctx, name, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				cm.ManagedFields = nil
+				return yaml.Marshal(cm)
+			},
+		})
+	}
+	return tasks
+}
+
+// resourceTasks lists the Kubernetes and Istio resources, except Secrets, ConfigMaps which may hold
+// credentials, and CustomResourceDefinitions. The resources of kinds not installed in the cluster are
+// skipped.
+func (c *bugReportCollector) resourceTasks() []bugreport.Task {
+	excluded := map[collection.Name]bool{
+		collections.K8SCoreV1Secrets.Name():                                      true,
+		collections.K8SCoreV1Configmaps.Name():                                   true,
+		collections.K8SApiextensionsK8SIoV1Beta1Customresourcedefinitions.Name(): true,
+	}
+	var tasks []bugreport.Task
+	for _, s := range collections.Kube.All() {
+		if excluded[s.Name()] {
+			continue
+		}
+		r := s.Resource()
+		group := r.Group()
+		if group == "" {
+			group = "core"
+		}
+		gvr := schema.GroupVersionResource{Group: r.Group(), Version: r.Version(), Resource: r.Plural()}
+		clusterScoped := r.IsClusterScoped()
+		tasks = append(tasks, bugreport.Task{
+			Path: path.Join("resources", group, r.Plural()+".yaml"),
+			Collect: func(ctx context.Context) ([]byte, error) {
+				return c.listResources(ctx, gvr, clusterScoped)
+			},
+		})
+	}
+	return tasks
+}
+
+func (c *bugReportCollector) listResources(ctx context.Context, gvr schema.GroupVersionResource,
+	clusterScoped bool) ([]byte, error) {
+	namespaces := c.namespaces
+	if clusterScoped || len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var items []interface{}
+	for _, ns := range namespaces {
+		list, err := c.dynamic.Resource(gvr).Namespace(ns).List(ctx, metav1.ListOptions{})
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range list.Items {
+			unstructured.RemoveNestedField(item.Object, "metadata", "managedFields")
+			items = append(items, item.Object)
+		}
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+	return yaml.Marshal(map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      items,
+	})
+}
+
+func (c *bugReportCollector) istiodTasks() []bugreport.Task {
+	pods, err := c.exec.GetIstioPods(istioNamespace, map[string]string{
+		"labelSelector": "app=istiod",
+		"fieldSelector": "status.phase=Running",
+	})
+	if err != nil {
+		return []bugreport.Task{bugreport.FailedTask("istiod", err)}
+	}
+
+	var tasks []bugreport.Task
+	for _, pod := range pods {
+		pod := pod
+		dir := path.Join("istiod", pod.Name)
+		for _, endpoint := range istiodDebugEndpoints {
+			endpoint := endpoint
+			tasks = append(tasks, bugreport.Task{
+				Path: path.Join(dir, endpoint+".json"),
+				Collect: func(context.Context) ([]byte, error) {
+					return c.exec.PilotDiscoveryPodDo(pod.Name, pod.Namespace, "GET", "/debug/"+endpoint, nil)
+				},
+			})
+		}
+		tasks = append(tasks, c.logsTask(dir, pod, "discovery"))
+	}
+	return tasks
+}
+
+func (c *bugReportCollector) proxyTasks() []bugreport.Task {
+	namespaces := c.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	var tasks []bugreport.Task
+	for _, ns := range namespaces {
+		pods, err := c.kubeClient.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{
+			FieldSelector: "status.phase=Running",
+		})
+		if err != nil {
+			tasks = append(tasks, bugreport.FailedTask(path.Join("proxies", ns), err))
+			continue
+		}
+		for _, pod := range pods.Items {
+			if !hasContainer(pod, proxyContainerName) {
+				continue
+			}
+			pod := pod
+			dir := path.Join("proxies", pod.Namespace, pod.Name)
+			for file, endpoint := range proxyAdminEndpoints {
+				endpoint := endpoint
+				tasks = append(tasks, bugreport.Task{
+					Path: path.Join(dir, file),
+					Collect: func(context.Context) ([]byte, error) {
+						return c.exec.EnvoyDo(pod.Name, pod.Namespace, "GET", endpoint, nil)
+					},
+				})
+			}
+			tasks = append(tasks, c.logsTask(dir, pod, proxyContainerName))
+		}
+	}
+	return tasks
+}
+
+// logsTask collects the logs of the container written in the time window of the report.
+func (c *bugReportCollector) logsTask(dir string, pod v1.Pod, container string) bugreport.Task {
+	return bugreport.Task{
+		Path: path.Join(dir, container+".log"),
+		Collect: func(ctx context.Context) ([]byte, error) {
+			logs, err := c.kubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+				Container:  container,
+				Timestamps: true,
+				SinceTime:  &metav1.Time{Time: c.start},
+			}).DoRaw(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return bugreport.FilterLogs(logs, c.end), nil
+		},
+	}
+}
+
+func hasContainer(pod v1.Pod, name string) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/istioctl/pkg/certs"
+	istioctl_kubernetes "istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	sdscompare "istio.io/istio/istioctl/pkg/writer/compare/sds"
+	pilotcontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/constants"
+)
+
+// CertificateIssuesError indicates that istioctl x certs check found problems with the certificates.
+type CertificateIssuesError struct {
+	count int
+}
+
+func (e CertificateIssuesError) Error() string {
+	return fmt.Sprintf("found %d certificate problem(s)", e.count)
+}
+
+func certsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "certs",
+		Short: "Inspect the certificates of the mesh",
+	}
+	cmd.AddCommand(certsCheckCmd())
+	return cmd
+}
+
+func certsCheckCmd() *cobra.Command {
+	var (
+		selector        string
+		expiryThreshold time.Duration
+		output          string
+	)
+	cmd := &cobra.Command{
+		Use:   "check [<pod-name>[.<namespace>]...]",
+		Short: "Check the certificates of the proxies of the mesh",
+		Long: `Checks the certificates the proxies received through SDS, as proxy-config secret shows them,
+for the given pods, the pods matching the selector or all the pods with a proxy in the namespace, or
+in the mesh when no namespace is given.
+
+The proxies are grouped by the root which signed their workload certificate, and the following
+problems are reported:
+- certificates expired or expiring within the expiry threshold
+- workload certificates whose SPIFFE ID does not match the service account of the pod
+- workload certificates signed by a root which is not the root distributed by the control plane,
+  for instance after a CA rotation, or by a root the proxy does not trust
+- proxies which do not trust the root distributed by the control plane
+- proxies whose secrets cannot be read, the other proxies being checked anyway
+
+The command exits with code 80 when problems are found, so that it can run as a periodic check.
+`,
+		Example: `# Check the certificates of all the proxies of the mesh
+istioctl experimental certs check
+
+# Check the certificates of the reviews pods, reporting those expiring within 12 hours
+istioctl experimental certs check -n bookinfo -l app=reviews --expiry-threshold 12h`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "" && output != jsonOutput {
+				return fmt.Errorf("output format %q not supported", output)
+			}
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			pods, err := certsPods(client, args, selector)
+			if err != nil {
+				return err
+			}
+			if len(pods) == 0 {
+				return fmt.Errorf("no pods with a proxy found")
+			}
+			meshRoots, err := meshRootCertificates(client)
+			if err != nil {
+				return err
+			}
+			kubeClient, err := envoyClientFactory(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+
+			proxies := []certs.Proxy{}
+			for _, pod := range pods {
+				// A proxy failing, for instance while its pod restarts, is reported without hiding the others
+				secrets, err := proxySecrets(kubeClient, pod)
+				serviceAccount := pod.Spec.ServiceAccountName
+				if serviceAccount == "" {
+					serviceAccount = "default"
+				}
+				proxies = append(proxies, certs.Proxy{
+					Name:           fmt.Sprintf("%s.%s", pod.Name, pod.Namespace),
+					Namespace:      pod.Namespace,
+					ServiceAccount: serviceAccount,
+					Secrets:        secrets,
+					Err:            err,
+				})
+			}
+
+			report := certs.Check(proxies, certs.Options{
+				Now:             time.Now(),
+				ExpiryThreshold: expiryThreshold,
+				MeshRoots:       meshRoots,
+			})
+			writer := cmd.OutOrStdout()
+			if output == jsonOutput {
+				err = certs.PrintJSON(writer, report)
+			} else {
+				if len(meshRoots) == 0 {
+					fmt.Fprintf(writer, "WARNING: the root distributed by the control plane is unknown, "+
+						"stale roots are not detected\n\n")
+				}
+				err = certs.PrintReport(writer, report)
+			}
+			if err != nil {
+				return err
+			}
+			if count := report.ProblemCount(); count > 0 {
+				return CertificateIssuesError{count: count}
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "l", "",
+		"Label selector of the pods")
+	cmd.PersistentFlags().DurationVar(&expiryThreshold, "expiry-threshold", 6*time.Hour,
+		"Report certificates expiring within this duration. Workload certificates are rotated when half of "+
+			"their lifetime remains")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "",
+		"Output format: one of json")
+	return cmd
+}
+
+// certsPods returns the pods given as arguments, or the running pods with a proxy matching the selector,
+// in the namespace or in all namespaces.
+func certsPods(client kubernetes.Interface, args []string, selector string) ([]v1.Pod, error) {
+	if len(args) > 0 {
+		if selector != "" {
+			return nil, fmt.Errorf("pod names and --selector are mutually exclusive")
+		}
+		pods := []v1.Pod{}
+		for _, arg := range args {
+			podName, ns := handlers.InferPodInfo(arg, handlers.HandleNamespace(namespace, defaultNamespace))
+			pod, err := client.CoreV1().Pods(ns).Get(context.TODO(), podName, metav1.GetOptions{})
+			if err != nil {
+				return nil, err
+			}
+			pods = append(pods, *pod)
+		}
+		return pods, nil
+	}
+
+	list, err := client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := []v1.Pod{}
+	for _, pod := range list.Items {
+		pod := pod
+		if pod.Status.Phase == v1.PodRunning && isMeshed(&pod) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// meshRootCertificates returns the roots the control plane distributes to the namespaces, or nothing
+// when they are not found.
+func meshRootCertificates(client kubernetes.Interface) ([]*x509.Certificate, error) {
+	cm, err := client.CoreV1().ConfigMaps(istioNamespace).Get(context.TODO(),
+		pilotcontroller.CACertNamespaceConfigMap, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the mesh root: %v", err)
+	}
+	roots, err := certs.ParseCertificates([]byte(cm.Data[constants.CACertNamespaceConfigMapDataName]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid mesh root in config map %s.%s: %v",
+			pilotcontroller.CACertNamespaceConfigMap, istioNamespace, err)
+	}
+	return roots, nil
+}
+
+func proxySecrets(kubeClient istioctl_kubernetes.ExecClient, pod v1.Pod) ([]sdscompare.SecretItem, error) {
+	data, err := kubeClient.EnvoyDo(pod.Name, pod.Namespace, "GET", "config_dump", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command on sidecar: %v", err)
+	}
+	cd := &configdump.Wrapper{}
+	if err := cd.UnmarshalJSON(data); err != nil {
+		return nil, fmt.Errorf("can't parse sidecar config_dump: %v", err)
+	}
+	secrets, err := sdscompare.GetEnvoySecrets(cd)
+	if err != nil {
+		return nil, fmt.Errorf("can't read the secrets: %v", err)
+	}
+	return secrets, nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+func TestCertsCheck(t *testing.T) {
+	pod := func(name, serviceAccount string, containers ...string) coreV1.Pod {
+		p := coreV1.Pod{
+			ObjectMeta: metaV1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       coreV1.PodSpec{ServiceAccountName: serviceAccount},
+			Status:     coreV1.PodStatus{Phase: coreV1.PodRunning},
+		}
+		for _, c := range containers {
+			p.Spec.Containers = append(p.Spec.Containers, coreV1.Container{Name: c})
+		}
+		return p
+	}
+	configDump := util.ReadFile("../pkg/writer/compare/testdata/envoyconfigdump.json", t)
+
+	cases := []execAndK8sConfigTestCase{
+		{ // case 0 no proxy
+			k8sConfigs: []runtime.Object{
+				&coreV1.PodList{Items: []coreV1.Pod{pod("mysql-1", "default", "mysql")}},
+			},
+			args:           strings.Split("x certs check", " "),
+			expectedString: "no pods with a proxy found",
+			wantException:  true,
+		},
+		{ // case 1 expired certificate, for the wrong service account, of a root the proxy does not trust
+			execClientConfig: map[string][]byte{"details-v1-1": configDump},
+			k8sConfigs: []runtime.Object{
+				&coreV1.PodList{Items: []coreV1.Pod{
+					pod("details-v1-1", "bookinfo-ratings", "details", "istio-proxy"),
+					pod("mysql-1", "default", "mysql"),
+				}},
+			},
+			args: strings.Split("x certs check", " "),
+			expectedOutput: `WARNING: the root distributed by the control plane is unknown, stale roots are not detected
+
+Root fe98c4420a2d8a42dd28479f4efaa76871475f76b9db0efe7deb0bb3f1c76ba3
+   Subject: O=cluster.local, valid until 2029-08-18T22:02:40Z, not distributed by the control plane, trusted by 0 proxies
+   PROXY                  SPIFFE ID                                               NOT AFTER              ISSUERS           STATUS
+   details-v1-1.default   spiffe://cluster.local/ns/default/sa/bookinfo-details   2019-08-28T17:19:57Z   O=cluster.local   workload certificate expired at 2019-08-28T17:19:57Z; SPIFFE ID "spiffe://cluster.local/ns/default/sa/bookinfo-details" does not match service account default/bookinfo-ratings; workload certificate is signed by a root the proxy does not trust
+Error: found 3 certificate problem(s)
+`,
+			wantException: true,
+		},
+		{ // case 2 the failure of a proxy is reported along with the other proxies
+			execClientConfig: map[string][]byte{"details-v1-1": configDump},
+			k8sConfigs: []runtime.Object{
+				&coreV1.PodList{Items: []coreV1.Pod{
+					pod("details-v1-1", "bookinfo-details", "details", "istio-proxy"),
+					pod("reviews-v1-1", "bookinfo-reviews", "reviews", "istio-proxy"),
+				}},
+			},
+			args: strings.Split("x certs check", " "),
+			expectedOutput: `WARNING: the root distributed by the control plane is unknown, stale roots are not detected
+
+Root fe98c4420a2d8a42dd28479f4efaa76871475f76b9db0efe7deb0bb3f1c76ba3
+   Subject: O=cluster.local, valid until 2029-08-18T22:02:40Z, not distributed by the control plane, trusted by 0 proxies
+   PROXY                  SPIFFE ID                                               NOT AFTER              ISSUERS           STATUS
+   details-v1-1.default   spiffe://cluster.local/ns/default/sa/bookinfo-details   2019-08-28T17:19:57Z   O=cluster.local   workload certificate expired at 2019-08-28T17:19:57Z; workload certificate is signed by a root the proxy does not trust
+
+Unknown root
+   PROXY                  SPIFFE ID   NOT AFTER   ISSUERS   STATUS
+   reviews-v1-1.default   -           -           -         failed to execute command on sidecar: unable to retrieve Pod: pods "reviews-v1-1" not found
+Error: found 3 certificate problem(s)
+`,
+			wantException: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			envoyClientFactory = mockEnvoyClientFactoryGenerator(c.execClientConfig)
+			verifyExecAndK8sConfigTestCaseTestOutput(t, c)
+		})
+	}
+
+	if got := GetExitCode(CertificateIssuesError{count: 1}); got != ExitCertificateIssues {
+		t.Errorf("got exit code %d, want %d", got, ExitCertificateIssues)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/pilot/pkg/config/history"
+)
+
+func configHistoryCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	var outputFormat string
+
+	historyCmd := &cobra.Command{
+		Use:   "config-history",
+		Short: "Inspect and restore past versions of the config distributed by Istiod [kube only]",
+		Long: `A group of commands used to list the versions of the config Istiod keeps in its history, fetch or
+compare them, and build the YAML restoring one of them.
+
+The history is enabled by the PILOT_ENABLE_CONFIG_HISTORY environment variable of Istiod, and is bounded by
+PILOT_CONFIG_HISTORY_MAX_VERSIONS and PILOT_CONFIG_HISTORY_RETENTION. When Istiod runs several replicas,
+each keeps its own history: the commands use the first one.`,
+		Example: `  # List the config versions
+  istioctl experimental config-history list
+
+  # Show what changed since a version
+  istioctl experimental config-history diff 1GDqDY9bzzE=
+
+  # Restore a version
+  istioctl experimental config-history rollback 1GDqDY9bzzE= > rollback.yaml
+  kubectl apply -f rollback.yaml`,
+	}
+	opts.AttachControlPlaneFlags(historyCmd)
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the config versions kept in the history, oldest first",
+		Args:  cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			if outputFormat != jsonOutput && outputFormat != summaryOutput {
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			out, err := configHistoryDo(opts, "/debug/config_history", nil)
+			if err != nil {
+				return err
+			}
+			if outputFormat == jsonOutput {
+				_, err := c.OutOrStdout().Write(out)
+				return err
+			}
+			var versions []history.Version
+			if err := json.Unmarshal(out, &versions); err != nil {
+				return fmt.Errorf("unable to parse the config history: %v", err)
+			}
+			return printConfigVersions(c.OutOrStdout(), versions)
+		},
+	}
+	listCmd.Flags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+
+	getCmd := &cobra.Command{
+		Use:   "get <version>",
+		Short: "Prints the config of a version as YAML",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			out, err := configHistoryDo(opts, "/debug/config_history/snapshot", url.Values{"version": {args[0]}})
+			if err != nil {
+				return err
+			}
+			_, err = c.OutOrStdout().Write(out)
+			return err
+		},
+	}
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <from-version> [<to-version>]",
+		Short: "Lists the config changes from a version to another, or to the latest version",
+		Args:  cobra.RangeArgs(1, 2),
+		RunE: func(c *cobra.Command, args []string) error {
+			query := url.Values{"from": {args[0]}}
+			if len(args) > 1 {
+				query.Set("to", args[1])
+			}
+			if outputFormat != jsonOutput && outputFormat != summaryOutput {
+				return fmt.Errorf("output format %q not supported", outputFormat)
+			}
+			out, err := configHistoryDo(opts, "/debug/config_history/diff", query)
+			if err != nil {
+				return err
+			}
+			if outputFormat == jsonOutput {
+				_, err := c.OutOrStdout().Write(out)
+				return err
+			}
+			var changes []history.Change
+			if err := json.Unmarshal(out, &changes); err != nil {
+				return fmt.Errorf("unable to parse the config changes: %v", err)
+			}
+			return printConfigChanges(c.OutOrStdout(), changes)
+		},
+	}
+	diffCmd.Flags().StringVarP(&outputFormat, "output", "o", summaryOutput, "Output format: one of json|short")
+
+	rollbackCmd := &cobra.Command{
+		Use:   "rollback <version>",
+		Short: "Prints the YAML restoring the config of a version",
+		Long: `Prints the YAML restoring the config of a version from the latest version. Applying the YAML
+restores the configs changed or deleted since the version; the configs created since the version
+are listed in comments, to be deleted separately.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(c *cobra.Command, args []string) error {
+			out, err := configHistoryDo(opts, "/debug/config_history/rollback", url.Values{"version": {args[0]}})
+			if err != nil {
+				return err
+			}
+			_, err = c.OutOrStdout().Write(out)
+			return err
+		},
+	}
+
+	historyCmd.AddCommand(listCmd, getCmd, diffCmd, rollbackCmd)
+	return historyCmd
+}
+
+func configHistoryDo(opts clioptions.ControlPlaneOptions, path string, query url.Values) ([]byte, error) {
+	kubeClient, err := clientExecFactory(kubeconfig, configContext, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	out, err := kubeClient.PilotDiscoveryDo(istioNamespace, "GET", path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to query Istiod for the config history "+
+			"(is PILOT_ENABLE_CONFIG_HISTORY set?): %v", err)
+	}
+	return out, nil
+}
+
+func printConfigVersions(writer io.Writer, versions []history.Version) error {
+	w := tabwriter.NewWriter(writer, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tRECORDED\tCONFIGS")
+	for _, v := range versions {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\n", v.Version, v.Time.Format(time.RFC3339), v.Configs)
+	}
+	return w.Flush()
+}
+
+func printConfigChanges(writer io.Writer, changes []history.Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(writer, "No config changes")
+		return err
+	}
+	w := tabwriter.NewWriter(writer, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHANGE\tKIND\tNAMESPACE\tNAME")
+	for _, c := range changes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", c.Event, c.Kind, c.Namespace, c.Name)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestConfigHistory(t *testing.T) {
+	versions := map[string][]byte{
+		"istiod-7b69ff6f8c-fvjvw": []byte(`[
+    {"version": "IY8NiA0zT3Y=", "time": "2020-05-01T10:00:00Z", "configs": 3},
+    {"version": "C1weAdnShGc=", "time": "2020-05-01T10:05:00Z", "configs": 4}
+]`),
+	}
+	changes := map[string][]byte{
+		"istiod-7b69ff6f8c-fvjvw": []byte(`[
+    {"event": "add", "kind": "VirtualService", "name": "reviews", "namespace": "default"},
+    {"event": "delete", "kind": "Gateway", "name": "bookinfo", "namespace": "default"}
+]`),
+	}
+	cases := []execTestCase{
+		{
+			execClientConfig: versions,
+			args:             strings.Split("x config-history list", " "),
+			expectedOutput: `VERSION        RECORDED               CONFIGS
+IY8NiA0zT3Y=   2020-05-01T10:00:00Z   3
+C1weAdnShGc=   2020-05-01T10:05:00Z   4
+`,
+		},
+		{
+			execClientConfig: versions,
+			args:             strings.Split("x config-history list -o json", " "),
+			expectedOutput:   string(versions["istiod-7b69ff6f8c-fvjvw"]),
+		},
+		{
+			execClientConfig: versions,
+			args:             strings.Split("x config-history list -o yaml", " "),
+			wantException:    true,
+		},
+		{
+			execClientConfig: changes,
+			args:             strings.Split("x config-history diff IY8NiA0zT3Y=", " "),
+			expectedOutput: `CHANGE   KIND             NAMESPACE   NAME
+add      VirtualService   default     reviews
+delete   Gateway          default     bookinfo
+`,
+		},
+		{
+			execClientConfig: map[string][]byte{"istiod-7b69ff6f8c-fvjvw": []byte("[]")},
+			args:             strings.Split("x config-history diff IY8NiA0zT3Y= C1weAdnShGc=", " "),
+			expectedOutput:   "No config changes\n",
+		},
+		{
+			args:          strings.Split("x config-history get", " "),
+			wantException: true,
+		},
+		{
+			// No Istiod
+			args:          strings.Split("x config-history rollback IY8NiA0zT3Y=", " "),
+			wantException: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecTestOutput(t, c)
+		})
+	}
+}
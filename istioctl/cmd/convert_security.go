@@ -0,0 +1,227 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"istio.io/istio/istioctl/pkg/convert"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+var securitySchemas = collection.SchemasFor(
+	collections.IstioSecurityV1Beta1Peerauthentications,
+	collections.IstioSecurityV1Beta1Requestauthentications,
+	collections.IstioSecurityV1Beta1Authorizationpolicies)
+
+// deprecatedSecurityResources are the resources read from the cluster by convert-security.
+var deprecatedSecurityResources = []schema.GroupVersionResource{
+	{Group: convert.AuthenticationGroup, Version: "v1alpha1", Resource: "meshpolicies"},
+	{Group: convert.AuthenticationGroup, Version: "v1alpha1", Resource: "policies"},
+	gvrOf(collections.IstioRbacV1Alpha1Clusterrbacconfigs),
+	gvrOf(collections.IstioRbacV1Alpha1Rbacconfigs),
+	gvrOf(collections.IstioRbacV1Alpha1Serviceroles),
+	gvrOf(collections.IstioRbacV1Alpha1Servicerolebindings),
+}
+
+func gvrOf(s collection.Schema) schema.GroupVersionResource {
+	return schema.GroupVersionResource{
+		Group:    s.Resource().Group(),
+		Version:  s.Resource().Version(),
+		Resource: s.Resource().Plural(),
+	}
+}
+
+func convertSecurityCmd() *cobra.Command {
+	var (
+		filenames    []string
+		output       string
+		domainSuffix string
+	)
+	cmd := &cobra.Command{
+		Use:   "convert-security",
+		Short: "Convert the deprecated authentication policies and RBAC resources to the security.istio.io/v1beta1 API",
+		Long: `Converts the authentication.istio.io/v1alpha1 Policy and MeshPolicy resources and the rbac.istio.io/v1alpha1
+ClusterRbacConfig, ServiceRole and ServiceRoleBinding resources into PeerAuthentication, RequestAuthentication
+and AuthorizationPolicy resources, on a best effort basis.
+
+The resources are read from the files, along with the Services they refer to, or from the cluster when no file
+is given. The services the deprecated resources apply to are translated into the workload selectors of their
+Services. Mesh-wide resources are created in the Istio namespace, which is the root namespace by default.
+
+Warnings are printed for anything which can't be converted exactly; review the output before applying it, and
+remove the deprecated resources afterwards.`,
+		Example: `# Convert the deprecated security resources of the cluster
+istioctl experimental convert-security -o security-v1beta1.yaml
+
+# Convert the resources of files
+istioctl experimental convert-security -f policies.yaml -f services.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			var resources *convert.SecurityResources
+			var err error
+			if len(filenames) > 0 {
+				resources, err = readSecurityResourceFiles(filenames)
+			} else {
+				resources, err = readClusterSecurityResources()
+			}
+			if err != nil {
+				return err
+			}
+
+			configs, warnings := convert.SecurityPolicies(resources, istioNamespace, domainSuffix)
+			for _, warning := range warnings {
+				fmt.Fprintf(c.ErrOrStderr(), "WARNING: %s\n", warning)
+			}
+			// sanity check that the outputs are valid
+			if err := validateSecurityConfigs(configs); err != nil {
+				return multierror.Prefix(err, "output config(s) are invalid:")
+			}
+
+			writer := c.OutOrStdout()
+			if output != "-" {
+				file, err := os.Create(output)
+				if err != nil {
+					return err
+				}
+				defer file.Close() // nolint: errcheck
+				writer = file
+			}
+			writeYAMLOutput(securitySchemas, configs, writer)
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringSliceVarP(&filenames, "filenames", "f", nil,
+		"Input filenames, or - for the standard input. The resources are read from the cluster when not set")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "-", "Output filename")
+	cmd.PersistentFlags().StringVar(&domainSuffix, "domain-suffix", "cluster.local",
+		"Domain suffix of the hostnames of the services")
+	return cmd
+}
+
+func readSecurityResourceFiles(filenames []string) (*convert.SecurityResources, error) {
+	resources := &convert.SecurityResources{}
+	for _, filename := range filenames {
+		var reader io.Reader = os.Stdin
+		if filename != "-" {
+			file, err := os.Open(filename)
+			if err != nil {
+				return nil, err
+			}
+			defer file.Close() // nolint: errcheck
+			reader = file
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+		if err := addSecurityResources(resources, string(data)); err != nil {
+			return nil, fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return resources, nil
+}
+
+// readClusterSecurityResources reads the deprecated security resources and the services of all the namespaces.
+func readClusterSecurityResources() (*convert.SecurityResources, error) {
+	dynamicClient, err := crdFactory(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	var docs []string
+	for _, gvr := range deprecatedSecurityResources {
+		list, err := dynamicClient.Resource(gvr).Namespace(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+		if errors.IsNotFound(err) {
+			// The CRD is not installed
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", gvr.GroupResource(), err)
+		}
+		for _, item := range list.Items {
+			doc, err := yaml.Marshal(item.Object)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, string(doc))
+		}
+	}
+	resources := &convert.SecurityResources{}
+	if err := addSecurityResources(resources, strings.Join(docs, "---\n")); err != nil {
+		return nil, err
+	}
+
+	client, err := interfaceFactory(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	services, err := client.CoreV1().Services(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range services.Items {
+		resources.Services = append(resources.Services, &services.Items[i])
+	}
+	return resources, nil
+}
+
+func addSecurityResources(resources *convert.SecurityResources, data string) error {
+	configs, kinds, err := crd.ParseInputsWithoutValidation(data)
+	if err != nil {
+		return err
+	}
+	others := resources.AddConfigs(configs)
+	unsupported, err := resources.AddObjects(kinds)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for _, cfg := range others {
+		errs = multierror.Append(errs, fmt.Errorf("unsupported kind: %v", cfg.Type))
+	}
+	for _, kind := range unsupported {
+		errs = multierror.Append(errs, fmt.Errorf("unsupported kind: %v", kind.Kind))
+	}
+	return errs
+}
+
+func validateSecurityConfigs(configs []model.Config) error {
+	var errs error
+	for _, cfg := range configs {
+		s, exists := securitySchemas.FindByGroupVersionKind(cfg.GroupVersionKind())
+		if !exists {
+			continue
+		}
+		if err := s.Resource().ValidateProto(cfg.Name, cfg.Namespace, cfg.Spec); err != nil {
+			errs = multierror.Append(errs, multierror.Prefix(err, fmt.Sprintf("%s %s/%s:", cfg.Type, cfg.Namespace, cfg.Name)))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pilot/test/util"
+)
+
+func TestConvertSecurity(t *testing.T) {
+	var out, stderr bytes.Buffer
+	rootCmd := GetRootCmd(strings.Split("x convert-security -f testdata/convert-security/policies.yaml "+
+		"-f testdata/convert-security/services.yaml", " "))
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&stderr)
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	util.CompareContent(out.Bytes(), "testdata/convert-security/v1beta1.yaml.golden", t)
+
+	for _, want := range []string{
+		"WARNING: Policy bookinfo/productpage-jwt: principal binding USE_ORIGIN can't be converted",
+		"WARNING: ServiceRole bookinfo/viewer: constraint destination.labels[version] can't be converted",
+		"WARNING: ServiceRole bookinfo/reviews-writer: services reviews* are expanded to the existing services",
+		"WARNING: ServiceRole bookinfo/unused: not bound, not converted",
+	} {
+		if !strings.Contains(stderr.String(), want) {
+			t.Errorf("got warnings\n%s\nwant them to contain %q", stderr.String(), want)
+		}
+	}
+}
+
+func TestConvertSecurityFromCluster(t *testing.T) {
+	meshPolicy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "authentication.istio.io/v1alpha1",
+		"kind":       "MeshPolicy",
+		"metadata":   map[string]interface{}{"name": "default"},
+		"spec": map[string]interface{}{
+			"peers": []interface{}{map[string]interface{}{"mtls": map[string]interface{}{}}},
+		},
+	}}
+	crdFactory = mockDynamicClientGenerator([]runtime.Object{meshPolicy})
+	interfaceFactory = mockInterfaceFactoryGenerator([]runtime.Object{&coreV1.ServiceList{Items: []coreV1.Service{{
+		ObjectMeta: metaV1.ObjectMeta{Name: "productpage", Namespace: "bookinfo"},
+	}}}})
+
+	out, err := runCommand("x convert-security", t)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, want := range []string{"kind: PeerAuthentication", "namespace: istio-system", "mode: STRICT"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+}
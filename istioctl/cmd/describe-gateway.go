@@ -0,0 +1,443 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	envoy_api "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/istioctl/pkg/clioptions"
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	sdscompare "istio.io/istio/istioctl/pkg/writer/compare/sds"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func gatewayDescribeCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	cmd := &cobra.Command{
+		Use:     "gateway <gateway>",
+		Aliases: []string{"gw"},
+		Short:   "Describe gateways and their Istio configuration [kube-only]",
+		Long: `Analyzes a Gateway, the gateway pods it selects and the Services exposing them, and reports
+the servers of the Gateway with the VirtualServices attached to each host and the status of
+the TLS credentials loaded by the gateway pods.
+
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `istioctl experimental describe gateway bookinfo-gateway`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("expecting gateway name")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			gwName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+
+			var configClient model.ConfigStore
+			var err error
+			if configClient, err = clientFactory(); err != nil {
+				return err
+			}
+			gw := configClient.Get(collections.IstioNetworkingV1Alpha3Gateways.Resource().GroupVersionKind(), gwName, ns)
+			if gw == nil {
+				return fmt.Errorf("gateway %s.%s not found", gwName, ns)
+			}
+			gwSpec, ok := gw.Spec.(*v1alpha3.Gateway)
+			if !ok {
+				return fmt.Errorf("unexpected gateway spec %T", gw.Spec)
+			}
+
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			writer := cmd.OutOrStdout()
+			fmt.Fprintf(writer, "Gateway: %s\n", name(*gw))
+
+			pods, err := printGatewayPods(writer, client.CoreV1(), gwSpec.Selector)
+			if err != nil {
+				return err
+			}
+
+			// Describe based on the Envoy config of the first ready pod only
+			var cd *configdump.Wrapper
+			var pod *v1.Pod
+			if len(pods) > 0 {
+				pod = &pods[0]
+				kubeClient, err := clientExecFactory(kubeconfig, configContext, opts)
+				if err != nil {
+					return err
+				}
+				byConfigDump, err := kubeClient.EnvoyDo(pod.Name, pod.Namespace, "GET", "config_dump", nil)
+				if err != nil {
+					return fmt.Errorf("failed to execute command on gateway sidecar: %v", err)
+				}
+				cd = &configdump.Wrapper{}
+				if err = cd.UnmarshalJSON(byConfigDump); err != nil {
+					return fmt.Errorf("can't parse gateway sidecar config_dump for %v: %v", pod.Name, err)
+				}
+			}
+
+			virtualServices, err := gatewayVirtualServices(configClient, *gw)
+			if err != nil {
+				return err
+			}
+			for _, server := range gwSpec.Servers {
+				printGatewayServer(writer, gw.Namespace, server, virtualServices, cd, pod)
+			}
+			return nil
+		},
+	}
+	opts.AttachControlPlaneFlags(cmd)
+	return cmd
+}
+
+// printGatewayPods prints the pods selected by the gateway selector, in every namespace, and the Services
+// exposing them. It returns the running pods with a ready proxy.
+func printGatewayPods(writer io.Writer, client typedcorev1.CoreV1Interface, selector map[string]string) ([]v1.Pod, error) {
+	labelSelector := k8s_labels.SelectorFromSet(selector).String()
+	fmt.Fprintf(writer, "   Selector: %s\n", labelSelector)
+	pods, err := client.Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(pods.Items) == 0 {
+		fmt.Fprintf(writer, "WARNING: No pods match the selector of the Gateway\n")
+		return nil, nil
+	}
+
+	ready := []v1.Pod{}
+	namespaces := map[string]bool{}
+	for _, pod := range pods.Items {
+		namespaces[pod.Namespace] = true
+		if pod.Status.Phase != v1.PodRunning {
+			fmt.Fprintf(writer, "   Pod: %s (%s)\n", kname(pod.ObjectMeta), pod.Status.Phase)
+			continue
+		}
+		if proxyReady, err := containerReady(&pod, proxyContainerName); err != nil || !proxyReady {
+			fmt.Fprintf(writer, "   Pod: %s (proxy not ready)\n", kname(pod.ObjectMeta))
+			continue
+		}
+		fmt.Fprintf(writer, "   Pod: %s\n", kname(pod.ObjectMeta))
+		ready = append(ready, pod)
+	}
+
+	for _, ns := range sortedKeys(namespaces) {
+		svcs, err := client.Services(ns).List(context.TODO(), metav1.ListOptions{})
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range svcs.Items {
+			if len(svc.Spec.Selector) == 0 ||
+				!k8s_labels.SelectorFromSet(svc.Spec.Selector).Matches(k8s_labels.Set(pods.Items[0].Labels)) {
+				continue
+			}
+			printGatewayService(writer, svc)
+		}
+	}
+	return ready, nil
+}
+
+// printGatewayService prints the external addresses and ports of a Service exposing gateway pods.
+func printGatewayService(writer io.Writer, svc v1.Service) {
+	addresses := []string{}
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			addresses = append(addresses, ingress.IP)
+		}
+		if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		}
+	}
+	addresses = append(addresses, svc.Spec.ExternalIPs...)
+	external := "no external address"
+	if len(addresses) > 0 {
+		external = "external address " + strings.Join(addresses, ", ")
+	} else if svc.Spec.Type == v1.ServiceTypeLoadBalancer {
+		external = "external address pending"
+	}
+	fmt.Fprintf(writer, "   Service: %s (%s, %s)\n", kname(svc.ObjectMeta), svc.Spec.Type, external)
+
+	ports := []string{}
+	for _, port := range svc.Spec.Ports {
+		if port.NodePort != 0 {
+			ports = append(ports, fmt.Sprintf("%d (node port %d)", port.Port, port.NodePort))
+		} else {
+			ports = append(ports, fmt.Sprintf("%d", port.Port))
+		}
+	}
+	if len(ports) > 0 {
+		fmt.Fprintf(writer, "      Ports: %s\n", strings.Join(ports, ", "))
+	}
+}
+
+// gatewayVirtualServices returns the VirtualServices bound to the gateway.
+func gatewayVirtualServices(configClient model.ConfigStore, gw model.Config) ([]model.Config, error) {
+	vss, err := configClient.List(collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(),
+		metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	bound := []model.Config{}
+	for _, vs := range vss {
+		vsSpec, ok := vs.Spec.(*v1alpha3.VirtualService)
+		if !ok {
+			continue
+		}
+		for _, ref := range vsSpec.Gateways {
+			if gatewayRefMatches(ref, vs.Namespace, gw) {
+				bound = append(bound, vs)
+				break
+			}
+		}
+	}
+	return bound, nil
+}
+
+// gatewayRefMatches returns whether a gateway reference of a VirtualService in namespace ns, either
+// <name>, <namespace>/<name> or the legacy <name>.<namespace>.svc.cluster.local, refers to the gateway.
+func gatewayRefMatches(ref, ns string, gw model.Config) bool {
+	if parts := strings.SplitN(ref, "/", 2); len(parts) == 2 {
+		return parts[0] == gw.Namespace && parts[1] == gw.Name
+	}
+	if parts := strings.Split(ref, "."); len(parts) > 1 {
+		return parts[0] == gw.Name && parts[1] == gw.Namespace
+	}
+	return ref == gw.Name && ns == gw.Namespace
+}
+
+// serverHostMatches returns whether the host of a VirtualService in namespace ns matches the host of a
+// server of a gateway in namespace gwNamespace, in the [namespace/]host format where "." is the namespace
+// of the gateway.
+func serverHostMatches(serverHost, vsHost, ns, gwNamespace string) bool {
+	if parts := strings.SplitN(serverHost, "/", 2); len(parts) == 2 {
+		switch parts[0] {
+		case "*":
+		case ".":
+			if ns != gwNamespace {
+				return false
+			}
+		default:
+			if parts[0] != ns {
+				return false
+			}
+		}
+		serverHost = parts[1]
+	}
+	return host.Name(serverHost).Matches(host.Name(vsHost))
+}
+
+func printGatewayServer(writer io.Writer, gwNamespace string, server *v1alpha3.Server, virtualServices []model.Config,
+	cd *configdump.Wrapper, pod *v1.Pod) {
+	port := server.GetPort()
+	fmt.Fprintf(writer, "Server: %d/%s (%s)\n", port.GetNumber(), port.GetProtocol(), port.GetName())
+	fmt.Fprintf(writer, "   Hosts: %s\n", strings.Join(server.Hosts, ", "))
+
+	if tls := server.GetTls(); tls != nil {
+		printGatewayServerTLS(writer, tls, cd, pod)
+	}
+
+	// The VirtualServices routing the port of the server in the proxy config
+	var applied map[string]bool
+	if cd != nil {
+		var err error
+		if applied, err = getVirtualServicesForGatewayPort(cd, port.GetNumber()); err != nil {
+			fmt.Fprintf(writer, "   WARNING: can't read routes of port %d: %v\n", port.GetNumber(), err)
+		}
+	}
+
+	for _, serverHost := range server.Hosts {
+		matches := []string{}
+		for _, vs := range virtualServices {
+			vsSpec := vs.Spec.(*v1alpha3.VirtualService)
+			for _, vsHost := range vsSpec.Hosts {
+				if !serverHostMatches(serverHost, vsHost, vs.Namespace, gwNamespace) {
+					continue
+				}
+				description := fmt.Sprintf("VirtualService %s (%s)", name(vs), describeVirtualServiceRoutes(vsSpec))
+				if applied != nil && !applied[vs.Name+"."+vs.Namespace] && isHTTPServer(server) {
+					description += fmt.Sprintf(", WARNING: not applied by pod %s", kname(pod.ObjectMeta))
+				}
+				matches = append(matches, description)
+				break
+			}
+		}
+		if len(matches) == 0 {
+			fmt.Fprintf(writer, "   Host %s: no VirtualService\n", serverHost)
+			continue
+		}
+		for _, match := range matches {
+			fmt.Fprintf(writer, "   Host %s: %s\n", serverHost, match)
+		}
+	}
+}
+
+func describeVirtualServiceRoutes(vs *v1alpha3.VirtualService) string {
+	routes := []string{}
+	if len(vs.Http) > 0 {
+		routes = append(routes, fmt.Sprintf("%d HTTP route(s)", len(vs.Http)))
+	}
+	if len(vs.Tls) > 0 {
+		routes = append(routes, fmt.Sprintf("%d TLS route(s)", len(vs.Tls)))
+	}
+	if len(vs.Tcp) > 0 {
+		routes = append(routes, fmt.Sprintf("%d TCP route(s)", len(vs.Tcp)))
+	}
+	if len(routes) == 0 {
+		return "no routes"
+	}
+	return strings.Join(routes, ", ")
+}
+
+func isHTTPServer(server *v1alpha3.Server) bool {
+	switch strings.ToUpper(server.GetPort().GetProtocol()) {
+	case "HTTP", "HTTP2", "GRPC":
+		return true
+	case "HTTPS":
+		return server.GetTls().GetMode() != v1alpha3.ServerTLSSettings_PASSTHROUGH &&
+			server.GetTls().GetMode() != v1alpha3.ServerTLSSettings_AUTO_PASSTHROUGH
+	}
+	return false
+}
+
+// printGatewayServerTLS prints the TLS mode of the server and the status of its credential in the
+// secrets the gateway pod received from SDS.
+func printGatewayServerTLS(writer io.Writer, tls *v1alpha3.ServerTLSSettings, cd *configdump.Wrapper, pod *v1.Pod) {
+	if tls.GetHttpsRedirect() {
+		fmt.Fprintf(writer, "   TLS: redirects HTTP to HTTPS\n")
+	}
+	if tls.GetMode() == v1alpha3.ServerTLSSettings_PASSTHROUGH && !tls.GetHttpsRedirect() ||
+		tls.GetMode() == v1alpha3.ServerTLSSettings_AUTO_PASSTHROUGH || tls.GetMode() == v1alpha3.ServerTLSSettings_ISTIO_MUTUAL {
+		fmt.Fprintf(writer, "   TLS: %s\n", tls.GetMode())
+		return
+	}
+	if tls.GetCredentialName() == "" {
+		if tls.GetServerCertificate() != "" {
+			fmt.Fprintf(writer, "   TLS: %s, certificate file %s\n", tls.GetMode(), tls.GetServerCertificate())
+		}
+		return
+	}
+
+	fmt.Fprintf(writer, "   TLS: %s, credential %s\n", tls.GetMode(), tls.GetCredentialName())
+	if cd == nil {
+		return
+	}
+	secrets, err := sdscompare.GetEnvoySecrets(cd)
+	if err != nil {
+		fmt.Fprintf(writer, "   WARNING: can't read secrets of pod %s: %v\n", kname(pod.ObjectMeta), err)
+		return
+	}
+	for _, secret := range secrets {
+		if secret.Name != tls.GetCredentialName() {
+			continue
+		}
+		if !secret.Valid {
+			fmt.Fprintf(writer, "   WARNING: credential %s is %s but not valid, expired %s\n",
+				secret.Name, secret.State, secret.NotAfter)
+			return
+		}
+		fmt.Fprintf(writer, "   Credential %s: %s, valid until %s\n", secret.Name, secret.State, secret.NotAfter)
+		return
+	}
+	fmt.Fprintf(writer, "   WARNING: credential %s is not loaded by pod %s\n", tls.GetCredentialName(), kname(pod.ObjectMeta))
+}
+
+// getVirtualServicesForGatewayPort returns the VirtualServices, as name.namespace, of the routes of the
+// gateway listener of the port.
+func getVirtualServicesForGatewayPort(cd *configdump.Wrapper, port uint32) (map[string]bool, error) {
+	listeners, err := cd.GetListenerConfigDump()
+	if err != nil {
+		return nil, err
+	}
+	routeNames := map[string]bool{}
+	for _, listener := range listeners.DynamicListeners {
+		if listener.ActiveState == nil {
+			continue
+		}
+		listenerTyped := &envoy_api.Listener{}
+		if err = ptypes.UnmarshalAny(listener.ActiveState.Listener, listenerTyped); err != nil {
+			return nil, err
+		}
+		if listenerTyped.GetAddress().GetSocketAddress().GetPortValue() != port {
+			continue
+		}
+		for _, filterChain := range listenerTyped.FilterChains {
+			for _, filter := range filterChain.Filters {
+				hcm := &http_conn.HttpConnectionManager{}
+				if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), hcm); err == nil && hcm.GetRds() != nil {
+					routeNames[hcm.GetRds().GetRouteConfigName()] = true
+				}
+			}
+		}
+	}
+
+	rcd, err := cd.GetDynamicRouteDump(false)
+	if err != nil {
+		return nil, err
+	}
+	virtualServices := map[string]bool{}
+	for _, rcd := range rcd.DynamicRouteConfigs {
+		routeTyped := &envoy_api.RouteConfiguration{}
+		if err = ptypes.UnmarshalAny(rcd.RouteConfig, routeTyped); err != nil {
+			return nil, err
+		}
+		if !routeNames[routeTyped.Name] {
+			continue
+		}
+		for _, vh := range routeTyped.VirtualHosts {
+			for _, route := range vh.Routes {
+				path, err := getIstioConfig(route.Metadata)
+				if err != nil {
+					continue
+				}
+				if vsName, vsNamespace, err := parseVirtualServicePath(path); err == nil {
+					virtualServices[vsName+"."+vsNamespace] = true
+				}
+			}
+		}
+	}
+	return virtualServices, nil
+}
+
+// sortedKeys returns the keys of the set in order.
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,345 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8s_labels "k8s.io/apimachinery/pkg/labels"
+
+	"istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/istioctl/pkg/clioptions"
+	istioctl_kubernetes "istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/istio/istioctl/pkg/util/clusters"
+	"istio.io/istio/istioctl/pkg/util/configdump"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func serviceEntryDescribeCmd() *cobra.Command {
+	var opts clioptions.ControlPlaneOptions
+	cmd := &cobra.Command{
+		Use:     "serviceentry <serviceentry>",
+		Aliases: []string{"se"},
+		Short:   "Describe service entries and their Istio configuration [kube-only]",
+		Long: `Analyzes a ServiceEntry and reports the namespaces whose sidecars can see it, according to
+its exportTo and the egress of the Sidecar resources, and, from the configuration of a sidecar
+that can see it, the endpoints its hosts resolve to and the DestinationRules applied to them.
+
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Example: `istioctl experimental describe serviceentry external-svc-httpbin`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("expecting service entry name")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			seName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+
+			var configClient model.ConfigStore
+			var err error
+			if configClient, err = clientFactory(); err != nil {
+				return err
+			}
+			se := configClient.Get(collections.IstioNetworkingV1Alpha3Serviceentries.Resource().GroupVersionKind(), seName, ns)
+			if se == nil {
+				return fmt.Errorf("service entry %s.%s not found", seName, ns)
+			}
+			seSpec, ok := se.Spec.(*v1alpha3.ServiceEntry)
+			if !ok {
+				return fmt.Errorf("unexpected service entry spec %T", se.Spec)
+			}
+
+			writer := cmd.OutOrStdout()
+			printServiceEntry(writer, *se, seSpec)
+
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(), metav1.ListOptions{
+				FieldSelector: "status.phase=Running",
+			})
+			if err != nil {
+				return err
+			}
+			sidecars, err := configClient.List(collections.IstioNetworkingV1Alpha3Sidecars.Resource().GroupVersionKind(),
+				metav1.NamespaceAll)
+			if err != nil {
+				return err
+			}
+
+			pod := printServiceEntryVisibility(writer, *se, seSpec, pods.Items, sidecars)
+			if pod == nil {
+				fmt.Fprintf(writer, "No sidecar can see the ServiceEntry\n")
+				return nil
+			}
+
+			kubeClient, err := clientExecFactory(kubeconfig, configContext, opts)
+			if err != nil {
+				return err
+			}
+			byConfigDump, err := kubeClient.EnvoyDo(pod.Name, pod.Namespace, "GET", "config_dump", nil)
+			if err != nil {
+				return fmt.Errorf("failed to execute command on sidecar: %v", err)
+			}
+			cd := &configdump.Wrapper{}
+			if err = cd.UnmarshalJSON(byConfigDump); err != nil {
+				return fmt.Errorf("can't parse sidecar config_dump for %v: %v", pod.Name, err)
+			}
+			endpoints, err := getClusterEndpoints(kubeClient, pod)
+			if err != nil {
+				fmt.Fprintf(writer, "WARNING: can't read endpoints of pod %s: %v\n", kname(pod.ObjectMeta), err)
+			}
+
+			fmt.Fprintf(writer, "Configuration of pod %s:\n", kname(pod.ObjectMeta))
+			for _, h := range seSpec.Hosts {
+				for _, port := range seSpec.Ports {
+					printServiceEntryHost(writer, configClient, cd, endpoints, h, port)
+				}
+			}
+			return nil
+		},
+	}
+	opts.AttachControlPlaneFlags(cmd)
+	return cmd
+}
+
+func printServiceEntry(writer io.Writer, se model.Config, seSpec *v1alpha3.ServiceEntry) {
+	fmt.Fprintf(writer, "ServiceEntry: %s\n", name(se))
+	fmt.Fprintf(writer, "   Hosts: %s\n", strings.Join(seSpec.Hosts, ", "))
+	ports := []string{}
+	for _, port := range seSpec.Ports {
+		ports = append(ports, fmt.Sprintf("%d/%s (%s)", port.Number, port.Protocol, port.Name))
+	}
+	fmt.Fprintf(writer, "   Ports: %s\n", strings.Join(ports, ", "))
+	fmt.Fprintf(writer, "   Location: %s, Resolution: %s\n", seSpec.Location, seSpec.Resolution)
+	if seSpec.WorkloadSelector != nil {
+		fmt.Fprintf(writer, "   Workload selector: %s\n",
+			k8s_labels.SelectorFromSet(seSpec.WorkloadSelector.Labels).String())
+	}
+	for _, ep := range seSpec.Endpoints {
+		fmt.Fprintf(writer, "   Endpoint: %s\n", ep.Address)
+	}
+}
+
+// printServiceEntryVisibility prints whether the sidecars of each namespace with meshed pods can see the
+// service entry, and returns a pod that can see it, in the namespace of the entry if possible.
+func printServiceEntryVisibility(writer io.Writer, se model.Config, seSpec *v1alpha3.ServiceEntry,
+	pods []v1.Pod, sidecars []model.Config) *v1.Pod {
+	podsByNamespace := map[string][]v1.Pod{}
+	for _, pod := range pods {
+		if isMeshed(&pod) {
+			podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], pod)
+		}
+	}
+	if len(podsByNamespace) == 0 {
+		return nil
+	}
+
+	var chosen *v1.Pod
+	fmt.Fprintf(writer, "Visibility:\n")
+	namespaces := map[string]bool{}
+	for ns := range podsByNamespace {
+		namespaces[ns] = true
+	}
+	for _, ns := range sortedKeys(namespaces) {
+		if reason, ok := serviceEntryExportedTo(se, seSpec, ns); !ok {
+			fmt.Fprintf(writer, "   Namespace %s: not visible (%s)\n", ns, reason)
+			continue
+		}
+
+		sidecar := namespaceSidecar(sidecars, ns)
+		visible, reason := serviceEntrySidecarVisibility(se, seSpec, sidecar)
+		if visible {
+			fmt.Fprintf(writer, "   Namespace %s: visible (%s)\n", ns, reason)
+		} else {
+			fmt.Fprintf(writer, "   Namespace %s: not visible (%s)\n", ns, reason)
+		}
+
+		for _, pod := range podsByNamespace[ns] {
+			pod := pod
+			podVisible := visible
+			if s := workloadSidecar(sidecars, &pod); s != nil {
+				podVisible, _ = serviceEntrySidecarVisibility(se, seSpec, s)
+			}
+			if podVisible && (chosen == nil || chosen.Namespace != se.Namespace && pod.Namespace == se.Namespace) {
+				chosen = &pod
+			}
+		}
+		// Sidecars with a workload selector take precedence over the Sidecar of the namespace
+		for _, s := range sidecars {
+			sSpec := s.Spec.(*v1alpha3.Sidecar)
+			if s.Namespace != ns || sSpec.WorkloadSelector == nil {
+				continue
+			}
+			_, reason := serviceEntrySidecarVisibility(se, seSpec, &s)
+			fmt.Fprintf(writer, "      Pods selected by Sidecar %s: %s\n", name(s), reason)
+		}
+	}
+	return chosen
+}
+
+// serviceEntryExportedTo returns whether the service entry is exported to the namespace, and why.
+func serviceEntryExportedTo(se model.Config, seSpec *v1alpha3.ServiceEntry, ns string) (string, bool) {
+	if len(seSpec.ExportTo) == 0 {
+		return "exported to all namespaces", true
+	}
+	for _, e := range seSpec.ExportTo {
+		if e == "*" || e == ns || e == "." && se.Namespace == ns {
+			return fmt.Sprintf("exportTo %s", e), true
+		}
+	}
+	return fmt.Sprintf("exportTo %s", strings.Join(seSpec.ExportTo, ", ")), false
+}
+
+// namespaceSidecar returns the Sidecar without workload selector of the namespace, or of the root
+// namespace when there is none.
+func namespaceSidecar(sidecars []model.Config, ns string) *model.Config {
+	var root *model.Config
+	for i, s := range sidecars {
+		if s.Spec.(*v1alpha3.Sidecar).WorkloadSelector != nil {
+			continue
+		}
+		if s.Namespace == ns {
+			return &sidecars[i]
+		}
+		if s.Namespace == istioNamespace {
+			root = &sidecars[i]
+		}
+	}
+	return root
+}
+
+// workloadSidecar returns the Sidecar with a workload selector selecting the pod, if any.
+func workloadSidecar(sidecars []model.Config, pod *v1.Pod) *model.Config {
+	for i, s := range sidecars {
+		selector := s.Spec.(*v1alpha3.Sidecar).WorkloadSelector
+		if s.Namespace != pod.Namespace || selector == nil {
+			continue
+		}
+		if k8s_labels.SelectorFromSet(selector.Labels).Matches(k8s_labels.Set(pod.Labels)) {
+			return &sidecars[i]
+		}
+	}
+	return nil
+}
+
+// serviceEntrySidecarVisibility returns whether the egress of the Sidecar includes a host of the service
+// entry, and why. Every service is visible without Sidecar.
+func serviceEntrySidecarVisibility(se model.Config, seSpec *v1alpha3.ServiceEntry, sidecar *model.Config) (bool, string) {
+	if sidecar == nil {
+		return true, "no Sidecar restricts egress"
+	}
+	for _, egress := range sidecar.Spec.(*v1alpha3.Sidecar).Egress {
+		for _, egressHost := range egress.Hosts {
+			parts := strings.SplitN(egressHost, "/", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			switch parts[0] {
+			case "*":
+			case ".":
+				if se.Namespace != sidecar.Namespace {
+					continue
+				}
+			default:
+				if parts[0] != se.Namespace {
+					continue
+				}
+			}
+			for _, h := range seSpec.Hosts {
+				if host.Name(parts[1]).Matches(host.Name(h)) {
+					reason := fmt.Sprintf("Sidecar %s egress includes %s", name(*sidecar), egressHost)
+					if egress.Port != nil {
+						reason += fmt.Sprintf(" on port %d", egress.Port.Number)
+					}
+					return true, reason
+				}
+			}
+		}
+	}
+	return false, fmt.Sprintf("Sidecar %s egress does not include it", name(*sidecar))
+}
+
+func getClusterEndpoints(kubeClient istioctl_kubernetes.ExecClient, pod *v1.Pod) (*clusters.Wrapper, error) {
+	data, err := kubeClient.EnvoyDo(pod.Name, pod.Namespace, "GET", "clusters?format=json", nil)
+	if err != nil {
+		return nil, err
+	}
+	return parseEndpoints(data)
+}
+
+// printServiceEntryHost prints the DestinationRule applied to the outbound cluster of the host and port
+// and its endpoints, as configured in the sidecar.
+func printServiceEntryHost(writer io.Writer, configClient model.ConfigStore, cd *configdump.Wrapper,
+	endpoints *clusters.Wrapper, hostname string, port *v1alpha3.Port) {
+	fmt.Fprintf(writer, "   Host %s port %d:\n", hostname, port.Number)
+
+	drName, drNamespace, err := getIstioDestinationRuleNameForHost(cd, hostname, int32(port.Number))
+	switch {
+	case err != nil:
+		fmt.Fprintf(writer, "      WARNING: %v\n", err)
+	case drName == "":
+		fmt.Fprintf(writer, "      No DestinationRule\n")
+	default:
+		dr := configClient.Get(collections.IstioNetworkingV1Alpha3Destinationrules.Resource().GroupVersionKind(), drName, drNamespace)
+		if dr == nil {
+			fmt.Fprintf(writer,
+				"      WARNING: Proxy is stale; it references to non-existent destination rule %s.%s\n",
+				drName, drNamespace)
+		} else {
+			fmt.Fprintf(writer, "      DestinationRule: %s\n", name(*dr))
+			if tls := dr.Spec.(*v1alpha3.DestinationRule).GetTrafficPolicy().GetTls(); tls != nil {
+				fmt.Fprintf(writer, "         Traffic Policy TLS Mode: %s\n", tls.GetMode())
+			}
+		}
+	}
+
+	if endpoints == nil {
+		return
+	}
+	cluster := model.BuildSubsetKey(model.TrafficDirectionOutbound, "", host.Name(hostname), int(port.Number))
+	for _, cs := range endpoints.GetClusterStatuses() {
+		if cs.GetName() != cluster {
+			continue
+		}
+		if len(cs.GetHostStatuses()) == 0 {
+			fmt.Fprintf(writer, "      WARNING: No endpoints\n")
+			return
+		}
+		addresses := []string{}
+		for _, h := range cs.GetHostStatuses() {
+			addr := h.GetAddress().GetSocketAddress()
+			addresses = append(addresses, fmt.Sprintf("%s:%d (%s)", addr.GetAddress(), addr.GetPortValue(),
+				h.GetHealthStatus().GetEdsHealthStatus()))
+		}
+		fmt.Fprintf(writer, "      Endpoints: %s\n", strings.Join(addresses, ", "))
+		return
+	}
+	fmt.Fprintf(writer, "      WARNING: No cluster %s in the proxy\n", cluster)
+}
@@ -174,6 +174,8 @@ func describe() *cobra.Command {
 
 	describeCmd.AddCommand(podDescribeCmd())
 	describeCmd.AddCommand(svcDescribeCmd())
+	describeCmd.AddCommand(gatewayDescribeCmd())
+	describeCmd.AddCommand(serviceEntryDescribeCmd())
 	return describeCmd
 }
 
@@ -665,6 +667,11 @@ func getIstioVirtualServiceNameForSvc(cd *configdump.Wrapper, svc v1.Service, po
 		}
 	}
 
+	return parseVirtualServicePath(path)
+}
+
+// parseVirtualServicePath returns name, namespace of the VirtualService of an Istio config path
+func parseVirtualServicePath(path string) (string, string, error) {
 	// Starting with recent 1.5.0 builds, the path will include .istio.io.  Handle both.
 	// nolint: gosimple
 	re := regexp.MustCompile("/apis/networking(\\.istio\\.io)?/v1alpha3/namespaces/(?P<namespace>[^/]+)/virtual-service/(?P<name>[^/]+)")
@@ -814,7 +821,14 @@ func getIstioConfig(metadata *envoy_api_core.Metadata) (string, error) {
 
 // getIstioConfigNameForSvc returns name, namespace
 func getIstioDestinationRuleNameForSvc(cd *configdump.Wrapper, svc v1.Service, port int32) (string, string, error) {
-	path, err := getIstioDestinationRulePathForSvc(cd, svc, port)
+	svcHost := extendFQDN(fmt.Sprintf("%s.%s", svc.ObjectMeta.Name, svc.ObjectMeta.Namespace))
+	return getIstioDestinationRuleNameForHost(cd, svcHost, port)
+}
+
+// getIstioDestinationRuleNameForHost returns name, namespace of the DestinationRule applied to the
+// outbound cluster of the host and port
+func getIstioDestinationRuleNameForHost(cd *configdump.Wrapper, hostname string, port int32) (string, string, error) {
+	path, err := getIstioDestinationRulePathForHost(cd, hostname, port)
 	if err != nil {
 		return "", "", err
 	}
@@ -829,12 +843,11 @@ func getIstioDestinationRuleNameForSvc(cd *configdump.Wrapper, svc v1.Service, p
 	return ss[3], ss[2], nil
 }
 
-// getIstioDestinationRulePathForSvc returns something like "/apis/networking/v1alpha3/namespaces/default/destination-rule/reviews"
-func getIstioDestinationRulePathForSvc(cd *configdump.Wrapper, svc v1.Service, port int32) (string, error) {
+// getIstioDestinationRulePathForHost returns something like "/apis/networking/v1alpha3/namespaces/default/destination-rule/reviews"
+func getIstioDestinationRulePathForHost(cd *configdump.Wrapper, hostname string, port int32) (string, error) {
 
-	svcHost := extendFQDN(fmt.Sprintf("%s.%s", svc.ObjectMeta.Name, svc.ObjectMeta.Namespace))
 	filter := istio_envoy_configdump.ClusterFilter{
-		FQDN: host.Name(svcHost),
+		FQDN: host.Name(hostname),
 		Port: int(port),
 		// Although we want inbound traffic, ask for outbound traffic, as the DR is
 		// not associated with the inbound traffic.
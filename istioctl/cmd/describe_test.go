@@ -795,3 +795,208 @@ func mockInterfaceFactoryGenerator(k8sConfigs []runtime.Object) func(kubeconfig
 
 	return outFactory
 }
+
+func TestDescribeGateway(t *testing.T) {
+	readyGatewayPod := cannedIngressGatewayPod.DeepCopy()
+	readyGatewayPod.Status.ContainerStatuses = []coreV1.ContainerStatus{{Name: "istio-proxy", Ready: true}}
+	gatewayService := cannedIngressGatewayService.DeepCopy()
+	gatewayService.Spec.Type = coreV1.ServiceTypeLoadBalancer
+	gateway := model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Name:      "bookinfo-gateway",
+			Namespace: "default",
+			Type:      collections.IstioNetworkingV1Alpha3Gateways.Resource().Kind(),
+			Group:     collections.IstioNetworkingV1Alpha3Gateways.Resource().Group(),
+			Version:   collections.IstioNetworkingV1Alpha3Gateways.Resource().Version(),
+		},
+		Spec: &networking.Gateway{
+			Selector: map[string]string{"istio": "ingressgateway"},
+			Servers: []*networking.Server{
+				{
+					Port:  &networking.Port{Number: 80, Name: "http", Protocol: "HTTP"},
+					Hosts: []string{"*"},
+				},
+				{
+					Port:  &networking.Port{Number: 443, Name: "https", Protocol: "HTTPS"},
+					Hosts: []string{"./bookinfo.example.com"},
+					Tls: &networking.ServerTLSSettings{
+						Mode:           networking.ServerTLSSettings_SIMPLE,
+						CredentialName: "bookinfo-credential",
+					},
+				},
+			},
+		},
+	}
+
+	cases := []execAndK8sConfigTestCase{
+		{ // case 0 no gateway
+			args:           strings.Split("x describe gateway", " "),
+			expectedString: "Error: expecting gateway name",
+			wantException:  true,
+		},
+		{ // case 1 unknown gateway
+			configs:        cannedIstioConfig,
+			args:           strings.Split("x describe gateway not-a-gateway", " "),
+			expectedString: "gateway not-a-gateway.default not found",
+			wantException:  true,
+		},
+		{ // case 2 has data
+			execClientConfig: map[string][]byte{
+				"istio-ingressgateway-5bf6c9887-vvvmj": util.ReadFile("testdata/describe/istio-ingressgateway-5bf6c9887-vvvmj.json", t),
+			},
+			configs: append([]model.Config{gateway}, cannedIstioConfig...),
+			k8sConfigs: []runtime.Object{
+				&coreV1.PodList{Items: []coreV1.Pod{*readyGatewayPod}},
+				&coreV1.ServiceList{Items: []coreV1.Service{*gatewayService}},
+			},
+			args: strings.Split("-n default x describe gw bookinfo-gateway", " "),
+			expectedOutput: `Gateway: bookinfo-gateway
+   Selector: istio=ingressgateway
+   Pod: istio-ingressgateway-5bf6c9887-vvvmj.istio-system
+   Service: istio-ingressgateway.istio-system (LoadBalancer, external address 10.1.2.3)
+      Ports: 80 (node port 31380)
+Server: 80/HTTP (http)
+   Hosts: *
+   Host *: VirtualService bookinfo (1 HTTP route(s))
+Server: 443/HTTPS (https)
+   Hosts: ./bookinfo.example.com
+   TLS: SIMPLE, credential bookinfo-credential
+   WARNING: credential bookinfo-credential is not loaded by pod istio-ingressgateway-5bf6c9887-vvvmj.istio-system
+   Host ./bookinfo.example.com: VirtualService bookinfo (1 HTTP route(s)), WARNING: not applied by pod istio-ingressgateway-5bf6c9887-vvvmj.istio-system
+`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecAndK8sConfigTestCaseTestOutput(t, c)
+		})
+	}
+}
+
+func TestServerHostMatches(t *testing.T) {
+	cases := []struct {
+		serverHost string
+		vsHost     string
+		ns         string
+		want       bool
+	}{
+		{"*", "bookinfo.example.com", "default", true},
+		{"*.example.com", "bookinfo.example.com", "default", true},
+		{"*.example.com", "bookinfo.example.org", "default", false},
+		{"*/bookinfo.example.com", "bookinfo.example.com", "other", true},
+		{"default/bookinfo.example.com", "bookinfo.example.com", "default", true},
+		{"default/bookinfo.example.com", "bookinfo.example.com", "other", false},
+		{"./*.example.com", "bookinfo.example.com", "default", true},
+		{"./*.example.com", "bookinfo.example.com", "other", false},
+	}
+	for _, c := range cases {
+		if got := serverHostMatches(c.serverHost, c.vsHost, c.ns, "default"); got != c.want {
+			t.Errorf("serverHostMatches(%q, %q, %q) => %v, want %v", c.serverHost, c.vsHost, c.ns, got, c.want)
+		}
+	}
+}
+
+func TestDescribeServiceEntry(t *testing.T) {
+	sleepPod := coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "sleep-7d457d69d7-abcde",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "sleep"},
+		},
+		Spec: coreV1.PodSpec{
+			Containers: []coreV1.Container{{Name: "sleep"}, {Name: "istio-proxy"}},
+		},
+		Status: coreV1.PodStatus{Phase: coreV1.PodRunning},
+	}
+	ratingsPod := coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "ratings-v1-f745cf57b-vfwcv",
+			Namespace: "bookinfo",
+			Labels:    map[string]string{"app": "ratings", "version": "v1"},
+		},
+		Spec: coreV1.PodSpec{
+			Containers: []coreV1.Container{{Name: "ratings"}, {Name: "istio-proxy"}},
+		},
+		Status: coreV1.PodStatus{Phase: coreV1.PodRunning},
+	}
+	configs := []model.Config{
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "httpbin-ext",
+				Namespace: "default",
+				Type:      collections.IstioNetworkingV1Alpha3Serviceentries.Resource().Kind(),
+				Group:     collections.IstioNetworkingV1Alpha3Serviceentries.Resource().Group(),
+				Version:   collections.IstioNetworkingV1Alpha3Serviceentries.Resource().Version(),
+			},
+			Spec: &networking.ServiceEntry{
+				Hosts:      []string{"httpbin.org"},
+				Ports:      []*networking.Port{{Number: 443, Name: "https", Protocol: "TLS"}},
+				Location:   networking.ServiceEntry_MESH_EXTERNAL,
+				Resolution: networking.ServiceEntry_DNS,
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "httpbin-ext",
+				Namespace: "default",
+				Type:      collections.IstioNetworkingV1Alpha3Destinationrules.Resource().Kind(),
+				Group:     collections.IstioNetworkingV1Alpha3Destinationrules.Resource().Group(),
+				Version:   collections.IstioNetworkingV1Alpha3Destinationrules.Resource().Version(),
+			},
+			Spec: &networking.DestinationRule{
+				Host: "httpbin.org",
+			},
+		},
+		{
+			ConfigMeta: model.ConfigMeta{
+				Name:      "default",
+				Namespace: "bookinfo",
+				Type:      collections.IstioNetworkingV1Alpha3Sidecars.Resource().Kind(),
+				Group:     collections.IstioNetworkingV1Alpha3Sidecars.Resource().Group(),
+				Version:   collections.IstioNetworkingV1Alpha3Sidecars.Resource().Version(),
+			},
+			Spec: &networking.Sidecar{
+				Egress: []*networking.IstioEgressListener{{Hosts: []string{"./*", "istio-system/*"}}},
+			},
+		},
+	}
+
+	cases := []execAndK8sConfigTestCase{
+		{ // case 0 unknown service entry
+			configs:        configs,
+			args:           strings.Split("x describe se not-a-service-entry", " "),
+			expectedString: "service entry not-a-service-entry.default not found",
+			wantException:  true,
+		},
+		{ // case 1 has data
+			execClientConfig: map[string][]byte{
+				"sleep-7d457d69d7-abcde":                      util.ReadFile("testdata/describe/sleep-7d457d69d7-abcde.json", t),
+				"sleep-7d457d69d7-abcde/clusters?format=json": util.ReadFile("testdata/describe/sleep-7d457d69d7-abcde-clusters.json", t),
+			},
+			configs: configs,
+			k8sConfigs: []runtime.Object{
+				&coreV1.PodList{Items: []coreV1.Pod{sleepPod, ratingsPod}},
+			},
+			args: strings.Split("-n default x describe serviceentry httpbin-ext", " "),
+			expectedOutput: `ServiceEntry: httpbin-ext
+   Hosts: httpbin.org
+   Ports: 443/TLS (https)
+   Location: MESH_EXTERNAL, Resolution: DNS
+Visibility:
+   Namespace bookinfo: not visible (Sidecar default.bookinfo egress does not include it)
+   Namespace default: visible (no Sidecar restricts egress)
+Configuration of pod sleep-7d457d69d7-abcde:
+   Host httpbin.org port 443:
+      DestinationRule: httpbin-ext
+      Endpoints: 54.175.219.8:443 (HEALTHY)
+`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyExecAndK8sConfigTestCaseTestOutput(t, c)
+		})
+	}
+}
@@ -47,6 +47,15 @@ func TestKubeInject(t *testing.T) {
 				" "),
 			goldenFilename: "testdata/deployment/hello.yaml.injected",
 		},
+		{ // case 3
+			configs: []model.Config{},
+			args: strings.Split(
+				"kube-inject --meshConfigFile testdata/mesh-config.yaml"+
+					" --injectConfigFile testdata/inject-config-templates.yaml -f testdata/deployment/hello-templates.yaml"+
+					" --valuesFile testdata/inject-values.yaml",
+				" "),
+			goldenFilename: "testdata/deployment/hello-templates.yaml.injected",
+		},
 	}
 
 	for i, c := range cases {
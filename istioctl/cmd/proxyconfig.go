@@ -15,6 +15,7 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -27,11 +28,14 @@ import (
 
 	"istio.io/pkg/log"
 
+	"istio.io/istio/istioctl/pkg/authz"
+	envoyclusters "istio.io/istio/istioctl/pkg/util/clusters"
 	"istio.io/istio/istioctl/pkg/util/handlers"
 	"istio.io/istio/istioctl/pkg/writer/envoy/clusters"
 	"istio.io/istio/istioctl/pkg/writer/envoy/configdump"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/spiffe"
 )
 
 const (
@@ -48,6 +52,11 @@ var (
 	routeName string
 
 	clusterName, status string
+
+	explainSNI, explainHost, explainPath, explainMethod string
+	explainSourceIP, explainSourcePrincipal             string
+	explainHeaders                                      []string
+	clustersFile                                        string
 )
 
 // Level is an enumeration of all supported log levels.
@@ -261,6 +270,73 @@ func setupClustersEnvoyConfigWriter(debug []byte, out io.Writer) (*clusters.Conf
 	return cw, nil
 }
 
+// retrievePodEndpoints returns the endpoints of the clusters of the Envoy in the pod.
+func retrievePodEndpoints(podName, podNamespace string) (*envoyclusters.Wrapper, error) {
+	kubeClient, err := envoyClientFactory(kubeconfig, configContext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create k8s client: %v", err)
+	}
+	debug, err := kubeClient.EnvoyDo(podName, podNamespace, "GET", "clusters?format=json", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute command on Envoy: %v", err)
+	}
+	return parseEndpoints(debug)
+}
+
+// retrieveFileEndpoints returns the endpoints of the clusters in the output of the Envoy clusters endpoint.
+func retrieveFileEndpoints(filename string) (*envoyclusters.Wrapper, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseEndpoints(data)
+}
+
+func parseEndpoints(data []byte) (*envoyclusters.Wrapper, error) {
+	endpoints := &envoyclusters.Wrapper{}
+	if err := json.Unmarshal(data, endpoints); err != nil {
+		return nil, fmt.Errorf("error unmarshalling clusters response from Envoy: %v", err)
+	}
+	return endpoints, nil
+}
+
+// explainRequest returns the request described by the flags of proxy-config explain.
+func explainRequest() (*authz.Request, error) {
+	if address == "" || port == 0 {
+		return nil, fmt.Errorf("explain requires the --address and --port of the destination")
+	}
+	r := &authz.Request{
+		SourcePrincipal: strings.TrimPrefix(explainSourcePrincipal, spiffe.URIPrefix),
+		SourceIP:        explainSourceIP,
+		DestinationIP:   address,
+		DestinationPort: uint32(port),
+		SNI:             explainSNI,
+		Method:          explainMethod,
+		Host:            explainHost,
+		Path:            explainPath,
+		Headers:         map[string]string{},
+	}
+	for _, h := range explainHeaders {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid header %q, expected <name>=<value>", h)
+		}
+		r.Headers[strings.ToLower(kv[0])] = kv[1]
+	}
+	if r.IsHTTP() {
+		if r.Method == "" {
+			r.Method = "GET"
+		}
+		if r.Host == "" {
+			r.Host = fmt.Sprintf("%s:%d", address, port)
+		}
+		if r.Path == "" {
+			r.Path = "/"
+		}
+	}
+	return r, nil
+}
+
 func proxyConfig() *cobra.Command {
 	// output format (yaml or short)
 	var outputFormat string
@@ -714,8 +790,89 @@ THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
 	secretConfigCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
 		"Envoy config dump JSON file")
 
+	explainCmd := &cobra.Command{
+		Use:   "explain [<pod-name[.namespace]>]",
+		Short: "(experimental) Explains how the Envoy in the specified pod handles a request",
+		Long: `(experimental) Trace a request through the configuration of the Envoy instance in the specified pod: the
+listener and filter chain the connection is matched to, the RBAC decisions, the virtual host and route of HTTP
+requests, and the clusters and endpoints the request is sent to.
+
+A request with a --host, --path, --method or --header is an HTTP request, and a TCP connection otherwise.
+A request with a --source-principal is sent with mutual TLS.`,
+		Example: `  # Explain how a pod sends an HTTP request to the reviews service.
+  istioctl proxy-config explain <pod-name[.namespace]> --address 10.96.0.10 --port 9080 \
+    --host reviews:9080 --path /reviews/1 --header end-user=jason
+
+  # Explain whether a pod accepts a request from the productpage service account.
+  istioctl proxy-config explain <pod-name[.namespace]> --address 10.0.0.5 --port 9080 --path /reviews/1 \
+    --source-principal cluster.local/ns/default/sa/productpage
+
+  # Explain a TLS connection to an external service.
+  istioctl proxy-config explain <pod-name[.namespace]> --address 1.2.3.4 --port 443 --sni www.example.com
+
+  # Explain a request without using Kubernetes API
+  ssh <user@hostname> 'curl localhost:15000/config_dump' > envoy-config.json
+  ssh <user@hostname> 'curl localhost:15000/clusters?format=json' > envoy-clusters.json
+  istioctl proxy-config explain --file envoy-config.json --clusters-file envoy-clusters.json \
+    --address 10.96.0.10 --port 9080 --path /
+
+THIS COMMAND IS STILL UNDER ACTIVE DEVELOPMENT AND NOT READY FOR PRODUCTION USE.
+`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if (len(args) == 1) != (configDumpFile == "") {
+				cmd.Println(cmd.UsageString())
+				return fmt.Errorf("explain requires pod name or --file parameter")
+			}
+			return nil
+		},
+		RunE: func(c *cobra.Command, args []string) error {
+			request, err := explainRequest()
+			if err != nil {
+				return err
+			}
+			var configWriter *configdump.ConfigWriter
+			var endpoints *envoyclusters.Wrapper
+			if len(args) == 1 {
+				podName, ns := handlers.InferPodInfo(args[0], handlers.HandleNamespace(namespace, defaultNamespace))
+				if configWriter, err = setupPodConfigdumpWriter(podName, ns, c.OutOrStdout()); err != nil {
+					return err
+				}
+				endpoints, err = retrievePodEndpoints(podName, ns)
+			} else {
+				if configWriter, err = setupFileConfigdumpWriter(configDumpFile, c.OutOrStdout()); err != nil {
+					return err
+				}
+				if clustersFile != "" {
+					endpoints, err = retrieveFileEndpoints(clustersFile)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			return configWriter.Explain(request, endpoints)
+		},
+	}
+
+	explainCmd.PersistentFlags().StringVar(&address, "address", "", "Destination IP of the request")
+	explainCmd.PersistentFlags().IntVar(&port, "port", 0, "Destination port of the request")
+	explainCmd.PersistentFlags().StringVar(&explainSNI, "sni", "", "Server name indicated by the TLS connection")
+	explainCmd.PersistentFlags().StringVar(&explainHost, "host", "",
+		"Host header of the request, defaults to the destination address and port")
+	explainCmd.PersistentFlags().StringVar(&explainPath, "path", "", "Path of the request, including its query")
+	explainCmd.PersistentFlags().StringVar(&explainMethod, "method", "", "Method of the request, defaults to GET")
+	explainCmd.PersistentFlags().StringArrayVar(&explainHeaders, "header", nil,
+		"Header of the request, as <name>=<value>. Can be repeated")
+	explainCmd.PersistentFlags().StringVar(&explainSourceIP, "source-ip", "", "Source IP of the request")
+	explainCmd.PersistentFlags().StringVar(&explainSourcePrincipal, "source-principal", "",
+		"Identity of the mutual TLS peer sending the request, e.g. cluster.local/ns/default/sa/productpage")
+	explainCmd.PersistentFlags().StringVarP(&configDumpFile, "file", "f", "",
+		"Envoy config dump JSON file")
+	explainCmd.PersistentFlags().StringVar(&clustersFile, "clusters-file", "",
+		"Envoy clusters JSON file, to explain the endpoints of the clusters along with --file")
+
 	configCmd.AddCommand(
-		clusterConfigCmd, listenerConfigCmd, logCmd, routeConfigCmd, bootstrapConfigCmd, endpointConfigCmd, secretConfigCmd)
+		clusterConfigCmd, listenerConfigCmd, logCmd, routeConfigCmd, bootstrapConfigCmd, endpointConfigCmd, secretConfigCmd,
+		explainCmd)
 
 	return configCmd
 }
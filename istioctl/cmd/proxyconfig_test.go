@@ -326,6 +326,10 @@ func (client mockExecConfig) AllPilotsDiscoveryDo(pilotNamespace, method, path s
 
 // nolint: unparam
 func (client mockExecConfig) EnvoyDo(podName, podNamespace, method, path string, body []byte) ([]byte, error) {
+	// Results for a specific path take precedence over the results for any path
+	if results, ok := client.results[podName+"/"+path]; ok {
+		return results, nil
+	}
 	results, ok := client.results[podName]
 	if !ok {
 		return nil, fmt.Errorf("unable to retrieve Pod: pods %q not found", podName)
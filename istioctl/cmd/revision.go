@@ -0,0 +1,189 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/kube/inject"
+)
+
+// revisionUsage is the control plane and the workloads of a revision.
+type revisionUsage struct {
+	name         string
+	controlPlane string
+	namespaces   []string
+	pods         []string
+}
+
+func revisionCmd() *cobra.Command {
+	revisionCmd := &cobra.Command{
+		Use:   "revision",
+		Short: "Inspect the revisions of the control plane [kube only]",
+		Long: `A group of commands used to inspect the revisions of the control plane installed in the cluster,
+and the workloads they inject, when canary control planes are used.`,
+	}
+
+	var verbose bool
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the revisions of the control plane, and the namespaces and pods using them",
+		Long: `Lists the revisions of the control plane, and the namespaces and pods using them.
+
+The namespaces of a revision are those labeled with istio.io/rev=<revision>, or istio-injection=enabled for the
+default revision. The pods of a revision are those injected by it, which are labeled with istio.io/rev. Revisions
+used by namespaces or pods, but which are no longer installed, are listed without a control plane.`,
+		Example: `  # List the revisions
+  istioctl experimental revision list
+
+  # List the revisions along with the pods using them
+  istioctl experimental revision list -v`,
+		Args: cobra.NoArgs,
+		RunE: func(c *cobra.Command, args []string) error {
+			client, err := interfaceFactory(kubeconfig)
+			if err != nil {
+				return err
+			}
+			revisions, err := listRevisions(client)
+			if err != nil {
+				return err
+			}
+			if verbose {
+				return printRevisionPods(c.OutOrStdout(), revisions)
+			}
+			return printRevisions(c.OutOrStdout(), revisions)
+		},
+	}
+	listCmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List the pods of each revision")
+
+	revisionCmd.AddCommand(listCmd)
+	return revisionCmd
+}
+
+// listRevisions returns the revisions of the control plane installed in the cluster, or used by its namespaces
+// and pods, sorted by name.
+func listRevisions(client kubernetes.Interface) ([]*revisionUsage, error) {
+	revisions := map[string]*revisionUsage{}
+	revision := func(name string) *revisionUsage {
+		if name == "" {
+			name = inject.DefaultRevision
+		}
+		if revisions[name] == nil {
+			revisions[name] = &revisionUsage{name: name}
+		}
+		return revisions[name]
+	}
+
+	webhookConfigs, err := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations().List(context.TODO(),
+		metav1.ListOptions{LabelSelector: model.RevisionLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the sidecar injector webhooks: %v", err)
+	}
+	for _, config := range webhookConfigs.Items {
+		for _, webhook := range config.Webhooks {
+			if service := webhook.ClientConfig.Service; service != nil {
+				revision(config.Labels[model.RevisionLabel]).controlPlane = service.Name + "." + service.Namespace
+				break
+			}
+		}
+	}
+
+	namespaces, err := client.CoreV1().Namespaces().List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the namespaces: %v", err)
+	}
+	for _, ns := range namespaces.Items {
+		// The istio-injection label takes precedence, as in the selectors of the webhooks
+		if injection, ok := ns.Labels["istio-injection"]; ok {
+			if injection == "enabled" {
+				r := revision(inject.DefaultRevision)
+				r.namespaces = append(r.namespaces, ns.Name)
+			}
+			continue
+		}
+		if rev, ok := ns.Labels[model.RevisionLabel]; ok {
+			r := revision(rev)
+			r.namespaces = append(r.namespaces, ns.Name)
+		}
+	}
+
+	pods, err := client.CoreV1().Pods(metav1.NamespaceAll).List(context.TODO(),
+		metav1.ListOptions{LabelSelector: model.RevisionLabel})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the pods: %v", err)
+	}
+	for _, pod := range pods.Items {
+		r := revision(pod.Labels[model.RevisionLabel])
+		r.pods = append(r.pods, pod.Name+"."+pod.Namespace)
+	}
+
+	out := make([]*revisionUsage, 0, len(revisions))
+	for _, r := range revisions {
+		sort.Strings(r.namespaces)
+		sort.Strings(r.pods)
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out, nil
+}
+
+func printRevisions(writer io.Writer, revisions []*revisionUsage) error {
+	if len(revisions) == 0 {
+		_, err := fmt.Fprintln(writer, "No revisions found")
+		return err
+	}
+	w := tabwriter.NewWriter(writer, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REVISION\tCONTROL PLANE\tNAMESPACES\tPODS")
+	for _, r := range revisions {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", r.name, orNone(r.controlPlane), orNone(strings.Join(r.namespaces, ",")),
+			len(r.pods))
+	}
+	return w.Flush()
+}
+
+func printRevisionPods(writer io.Writer, revisions []*revisionUsage) error {
+	if len(revisions) == 0 {
+		_, err := fmt.Fprintln(writer, "No revisions found")
+		return err
+	}
+	w := tabwriter.NewWriter(writer, 0, 8, 3, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REVISION\tCONTROL PLANE\tPOD")
+	for _, r := range revisions {
+		if len(r.pods) == 0 {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", r.name, orNone(r.controlPlane), orNone(""))
+		}
+		for _, pod := range r.pods {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", r.name, orNone(r.controlPlane), pod)
+		}
+	}
+	return w.Flush()
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/pkg/kube/inject"
+)
+
+func TestRevisionList(t *testing.T) {
+	namespace := func(name string, labels map[string]string) *coreV1.Namespace {
+		return &coreV1.Namespace{ObjectMeta: metaV1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	pod := func(name, namespace, revision string) *coreV1.Pod {
+		return &coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"istio.io/rev": revision},
+		}}
+	}
+	interfaceFactory = mockInterfaceFactoryGenerator([]runtime.Object{
+		inject.WebhookConfiguration("", "istio-system", nil),
+		inject.WebhookConfiguration("canary", "istio-system", nil),
+		namespace("bookinfo", map[string]string{"istio-injection": "enabled"}),
+		namespace("canary", map[string]string{"istio.io/rev": "canary"}),
+		namespace("disabled", map[string]string{"istio-injection": "disabled", "istio.io/rev": "canary"}),
+		namespace("old", map[string]string{"istio.io/rev": "old"}),
+		namespace("plain", nil),
+		pod("productpage", "bookinfo", ""),
+		pod("reviews", "bookinfo", "canary"),
+		pod("ratings", "canary", "canary"),
+		&coreV1.Pod{ObjectMeta: metaV1.ObjectMeta{Name: "details", Namespace: "plain"}},
+	})
+
+	cases := []testCase{
+		{
+			args: []string{"x", "revision", "list"},
+			expectedOutput: `REVISION   CONTROL PLANE                NAMESPACES   PODS
+canary     istiod-canary.istio-system   canary       2
+default    istiod.istio-system          bookinfo     1
+old        <none>                       old          0
+`,
+		},
+		{
+			args: []string{"x", "revision", "list", "-v"},
+			expectedOutput: `REVISION   CONTROL PLANE                POD
+canary     istiod-canary.istio-system   ratings.canary
+canary     istiod-canary.istio-system   reviews.bookinfo
+default    istiod.istio-system          productpage.bookinfo
+old        <none>                       <none>
+`,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			verifyOutput(t, c)
+		})
+	}
+}
@@ -137,6 +137,7 @@ debug and diagnose their Istio mesh.
 	rootCmd.AddCommand(dashboard())
 	rootCmd.AddCommand(statusCommand())
 	rootCmd.AddCommand(Analyze())
+	rootCmd.AddCommand(bugReportCmd())
 
 	rootCmd.AddCommand(install.NewVerifyCommand())
 	experimentalCmd.AddCommand(install.NewPrecheckCommand())
@@ -146,11 +147,16 @@ debug and diagnose their Istio mesh.
 	experimentalCmd.AddCommand(graduatedCmd("dashboard"))
 	experimentalCmd.AddCommand(uninjectCommand())
 	experimentalCmd.AddCommand(metricsCmd)
+	experimentalCmd.AddCommand(topCmd())
+	experimentalCmd.AddCommand(certsCmd())
+	experimentalCmd.AddCommand(convertSecurityCmd())
+	experimentalCmd.AddCommand(revisionCmd())
 	experimentalCmd.AddCommand(describe())
 	experimentalCmd.AddCommand(addToMeshCmd())
 	experimentalCmd.AddCommand(removeFromMeshCmd())
 	experimentalCmd.AddCommand(softGraduatedCmd(Analyze()))
 	experimentalCmd.AddCommand(waitCmd())
+	experimentalCmd.AddCommand(configHistoryCmd())
 
 	postInstallCmd.AddCommand(Webhook())
 	experimentalCmd.AddCommand(postInstallCmd)
@@ -0,0 +1,201 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/istioctl/pkg/kubernetes"
+	"istio.io/istio/istioctl/pkg/util/handlers"
+	"istio.io/istio/istioctl/pkg/writer/envoy/stats"
+)
+
+const (
+	// Clears the terminal and moves the cursor to its top left corner
+	clearScreen = "\033[H\033[2J"
+)
+
+func topCmd() *cobra.Command {
+	var (
+		selector   string
+		interval   time.Duration
+		sortBy     string
+		output     string
+		iterations int
+	)
+	cmd := &cobra.Command{
+		Use:   "top [<pod-name>[.<namespace>]...]",
+		Short: "Display the traffic of sidecars from their Envoy stats",
+		Long: `Displays the request rate, the ratio of 5xx responses and the latency of the requests sent by
+sidecars to each of their upstream clusters, computed from the Envoy stats of the sidecars between two
+scrapes, without Prometheus.
+
+The stats are scraped like proxy-config does. The pods are the ones given, or the ones matching the
+selector, or all pods with a sidecar in the namespace. The table is refreshed every interval until
+interrupted, unless the number of iterations is limited. With -o json, the traffic between two scrapes
+is printed once.
+
+The latency estimates are the quantiles of the last stats flush interval of the sidecars. The stats of
+the upstream clusters are only available when the sidecars are configured to emit them, for instance
+with the annotation sidecar.istio.io/statsInclusionPrefixes: cluster.outbound,cluster.inbound.
+`,
+		Example: `# Display the traffic of the productpage sidecar
+istioctl experimental top productpage-123-456.default
+
+# Display the traffic of all reviews sidecars, with the highest error ratios first
+istioctl experimental top -l app=reviews --sort errors
+
+# Print the traffic of the sidecars of the namespace over 10 seconds, as JSON
+istioctl experimental top -n bookinfo --interval 10s -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if sortBy != stats.SortByRPS && sortBy != stats.SortByErrors {
+				return fmt.Errorf("--sort must be %s or %s, got: %s", stats.SortByRPS, stats.SortByErrors, sortBy)
+			}
+			if output != "" && output != jsonOutput {
+				return fmt.Errorf("output format %q not supported", output)
+			}
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			pods, err := topPods(args, selector)
+			if err != nil {
+				return err
+			}
+			if len(pods) == 0 {
+				return fmt.Errorf("no pods with a sidecar found")
+			}
+			kubeClient, err := envoyClientFactory(kubeconfig, configContext)
+			if err != nil {
+				return fmt.Errorf("failed to create k8s client: %v", err)
+			}
+
+			writer := cmd.OutOrStdout()
+			clearTable := output == "" && isTerminal(writer)
+			previous, err := scrapeStats(kubeClient, pods)
+			if err != nil {
+				return err
+			}
+			for i := 0; iterations <= 0 || i < iterations; i++ {
+				time.Sleep(interval)
+				current, err := scrapeStats(kubeClient, pods)
+				if err != nil {
+					return err
+				}
+				traffic := []stats.Traffic{}
+				for _, pod := range pods {
+					traffic = append(traffic, stats.Rates(pod, previous[pod], current[pod])...)
+				}
+				previous = current
+				if err := stats.Sort(traffic, sortBy); err != nil {
+					return err
+				}
+
+				if output == jsonOutput {
+					return stats.PrintJSON(writer, traffic)
+				}
+				if clearTable {
+					fmt.Fprint(writer, clearScreen)
+				}
+				if err := stats.PrintTable(writer, traffic); err != nil {
+					return err
+				}
+				if len(traffic) == 0 {
+					fmt.Fprintf(writer, "No requests in the last %v\n", interval)
+				}
+			}
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&selector, "selector", "l", "",
+		"Label selector of the pods, in the namespace")
+	cmd.PersistentFlags().DurationVar(&interval, "interval", 2*time.Second,
+		"Duration between two scrapes of the stats")
+	cmd.PersistentFlags().StringVar(&sortBy, "sort", stats.SortByRPS,
+		fmt.Sprintf("Sort by %s or %s", stats.SortByRPS, stats.SortByErrors))
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "",
+		"Output format: json prints the traffic between two scrapes once")
+	cmd.PersistentFlags().IntVar(&iterations, "iterations", 0,
+		"Number of times the table is refreshed, 0 to refresh until interrupted")
+	return cmd
+}
+
+// topPods returns the pods given as arguments, formatted as name.namespace, or the pods with a sidecar
+// matching the selector in the namespace.
+func topPods(args []string, selector string) ([]string, error) {
+	ns := handlers.HandleNamespace(namespace, defaultNamespace)
+	if len(args) > 0 {
+		if selector != "" {
+			return nil, fmt.Errorf("pod names and --selector are mutually exclusive")
+		}
+		pods := []string{}
+		for _, arg := range args {
+			podName, podNamespace := handlers.InferPodInfo(arg, ns)
+			pods = append(pods, fmt.Sprintf("%s.%s", podName, podNamespace))
+		}
+		return pods, nil
+	}
+
+	client, err := interfaceFactory(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.CoreV1().Pods(ns).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := []string{}
+	for _, pod := range list.Items {
+		pod := pod
+		if pod.Status.Phase == v1.PodRunning && isMeshed(&pod) {
+			pods = append(pods, fmt.Sprintf("%s.%s", pod.Name, pod.Namespace))
+		}
+	}
+	return pods, nil
+}
+
+// scrapeStats returns the snapshots of the stats of the sidecars of the pods, by pod.
+func scrapeStats(kubeClient kubernetes.ExecClient, pods []string) (map[string]*stats.Snapshot, error) {
+	snapshots := map[string]*stats.Snapshot{}
+	for _, pod := range pods {
+		podName, podNamespace := handlers.InferPodInfo(pod, "")
+		data, err := kubeClient.EnvoyDo(podName, podNamespace, "GET", "stats?usedonly", nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to execute command on %s sidecar: %v", pod, err)
+		}
+		snapshot, err := stats.ParseStats(data, time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the stats of %s: %v", pod, err)
+		}
+		snapshots[pod] = snapshot
+	}
+	return snapshots, nil
+}
+
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	return ok && isatty.IsTerminal(file.Fd())
+}
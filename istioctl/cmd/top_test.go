@@ -0,0 +1,154 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	coreV1 "k8s.io/api/core/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/istioctl/pkg/kubernetes"
+)
+
+// mockStatsExecConfig returns the next stats of a pod on every scrape
+type mockStatsExecConfig struct {
+	mockExecConfig
+	stats map[string][]string
+}
+
+func (client *mockStatsExecConfig) EnvoyDo(podName, podNamespace, method, path string, body []byte) ([]byte, error) {
+	stats := client.stats[podName]
+	if len(stats) == 0 {
+		return nil, fmt.Errorf("unable to retrieve Pod: pods %q not found", podName)
+	}
+	if len(stats) > 1 {
+		client.stats[podName] = stats[1:]
+	}
+	return []byte(stats[0]), nil
+}
+
+func TestTop(t *testing.T) {
+	productpageStats := []string{
+		`cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_5xx: 1
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: 10
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_total: 10
+`,
+		`cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_5xx: 1
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: 12
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_5xx: 1
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_total: 11
+cluster.outbound|9080||details.default.svc.cluster.local.upstream_rq_time: P0(1,1) P25(1,1) P50(1.5,1) P75(2,2) P90(3,3) P95(4,4) P99(5,5) P99.5(5,5) P99.9(5,5) P100(5,5)
+`,
+	}
+	pods := []runtime.Object{
+		&coreV1.PodList{Items: []coreV1.Pod{
+			{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "productpage-v1-7bbd79f8fd-k6j79",
+					Namespace: "default",
+					Labels:    map[string]string{"app": "productpage"},
+				},
+				Spec: coreV1.PodSpec{
+					Containers: []coreV1.Container{{Name: "productpage"}, {Name: "istio-proxy"}},
+				},
+				Status: coreV1.PodStatus{Phase: coreV1.PodRunning},
+			},
+			{
+				ObjectMeta: metaV1.ObjectMeta{
+					Name:      "mysql-5b7f94f9bc-wp5tb",
+					Namespace: "default",
+					Labels:    map[string]string{"app": "mysql"},
+				},
+				Spec: coreV1.PodSpec{
+					Containers: []coreV1.Container{{Name: "mysql"}},
+				},
+				Status: coreV1.PodStatus{Phase: coreV1.PodRunning},
+			},
+		}},
+	}
+
+	cases := []struct {
+		args []string
+		// The pod, cluster and error ratio of the rows of the table, as the rates and the latencies
+		// depend on the duration between the scrapes
+		expectedRows   [][]string
+		expectedString string
+		wantException  bool
+	}{
+		{ // case 0 sorted by errors, pods of the namespace
+			args: strings.Split("x top -n default --interval 1ms --iterations 1 --sort errors", " "),
+			expectedRows: [][]string{
+				{"POD", "CLUSTER", "ERRORS"},
+				{"productpage-v1-7bbd79f8fd-k6j79.default", "outbound|9080||details.default.svc.cluster.local", "100.00%"},
+				{"productpage-v1-7bbd79f8fd-k6j79.default", "outbound|9080||reviews.default.svc.cluster.local", "0.00%"},
+			},
+		},
+		{ // case 1 JSON, pod given
+			args: strings.Split("x top productpage-v1-7bbd79f8fd-k6j79.default --interval 1ms -o json", " "),
+			expectedString: `"cluster": "outbound|9080||details.default.svc.cluster.local",
+        "requests": 1,`,
+		},
+		{ // case 2 no sidecar
+			args:           strings.Split("x top -n default -l app=mysql --interval 1ms", " "),
+			expectedString: "no pods with a sidecar found",
+			wantException:  true,
+		},
+		{ // case 3 unknown sort key
+			args:           strings.Split("x top --sort latency", " "),
+			expectedString: "--sort must be rps or errors, got: latency",
+			wantException:  true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("case %d %s", i, strings.Join(c.args, " ")), func(t *testing.T) {
+			envoyClientFactory = func(kubeconfig, configContext string) (kubernetes.ExecClient, error) {
+				return &mockStatsExecConfig{stats: map[string][]string{
+					"productpage-v1-7bbd79f8fd-k6j79": productpageStats,
+				}}, nil
+			}
+			interfaceFactory = mockInterfaceFactoryGenerator(pods)
+
+			var out bytes.Buffer
+			rootCmd := GetRootCmd(c.args)
+			rootCmd.SetOutput(&out)
+			err := rootCmd.Execute()
+			output := out.String()
+
+			if c.wantException != (err != nil) {
+				t.Fatalf("got error %v, want error %v, output was %q", err, c.wantException, output)
+			}
+			if c.expectedRows != nil {
+				rows := [][]string{}
+				for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+					fields := strings.Fields(line)
+					rows = append(rows, []string{fields[0], fields[1], fields[3]})
+				}
+				if !reflect.DeepEqual(rows, c.expectedRows) {
+					t.Fatalf("got rows %v, want %v, output was\n%s", rows, c.expectedRows, output)
+				}
+			}
+			if !strings.Contains(output, c.expectedString) {
+				t.Fatalf("got\n%s\nwant it to contain\n%s", output, c.expectedString)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	rbac_http_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/rbac/v2"
+	rbac_tcp_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/rbac/v2"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/security/authz/builder"
+	"istio.io/istio/pilot/pkg/security/trustdomain"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// RBACFilter is the configuration of an RBAC filter of a proxy, HTTP or network.
+type RBACFilter struct {
+	Name        string
+	Rules       *rbacpb.RBAC
+	ShadowRules *rbacpb.RBAC
+}
+
+// policyNamePattern matches the names istiod gives to the RBAC policies generated from the rules of an
+// AuthorizationPolicy.
+var policyNamePattern = regexp.MustCompile(`^ns\[(.+)\]-policy\[(.+)\]-rule\[(\d+)\]$`)
+
+// DescribePolicy describes the RBAC policy with the AuthorizationPolicy rule it is generated from, when
+// it is generated by istiod.
+func DescribePolicy(name string) string {
+	if m := policyNamePattern.FindStringSubmatch(name); m != nil {
+		return fmt.Sprintf("rule %s of AuthorizationPolicy %s/%s", m[3], m[1], m[2])
+	}
+	return fmt.Sprintf("policy %q", name)
+}
+
+// RBACFiltersFromPolicies builds the RBAC filters of a workload from AuthorizationPolicy configs as istiod
+// does, for HTTP or TCP. Policies in rootNamespace apply to the workloads of every namespace.
+func RBACFiltersFromPolicies(configs []model.Config, namespace, rootNamespace string,
+	workloadLabels map[string]string, forTCP bool) ([]*RBACFilter, error) {
+	store := model.MakeIstioStore(memory.Make(collections.Pilot))
+	for _, c := range configs {
+		if c.Namespace == "" {
+			c.Namespace = namespace
+		}
+		if _, err := store.Create(c); err != nil {
+			return nil, fmt.Errorf("failed to add %s %s/%s: %v", c.Type, c.Namespace, c.Name, err)
+		}
+	}
+
+	m := mesh.DefaultMeshConfig()
+	m.RootNamespace = rootNamespace
+	policies, err := model.GetAuthorizationPolicies(&model.Environment{
+		IstioConfigStore: store,
+		Watcher:          mesh.NewFixedWatcher(&m),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tdBundle := trustdomain.NewBundle(spiffe.GetTrustDomain(), m.TrustDomainAliases)
+	b := builder.New(tdBundle, labels.Collection{workloadLabels}, namespace, policies, true)
+	if b == nil {
+		return nil, nil
+	}
+
+	var filters []*RBACFilter
+	if forTCP {
+		for _, f := range b.BuildTCP() {
+			rbac := &rbac_tcp_filter.RBAC{}
+			if err := ptypes.UnmarshalAny(f.GetTypedConfig(), rbac); err != nil {
+				return nil, err
+			}
+			filters = append(filters, &RBACFilter{Name: "Network RBAC", Rules: rbac.Rules, ShadowRules: rbac.ShadowRules})
+		}
+		return filters, nil
+	}
+	for _, f := range b.BuildHTTP() {
+		rbac := &rbac_http_filter.RBAC{}
+		if err := ptypes.UnmarshalAny(f.GetTypedConfig(), rbac); err != nil {
+			return nil, err
+		}
+		filters = append(filters, &RBACFilter{Name: "HTTP RBAC", Rules: rbac.Rules, ShadowRules: rbac.ShadowRules})
+	}
+	return filters, nil
+}
+
+// Check evaluates the request against the RBAC filters in order, as Envoy does, and prints the decision of
+// each filter followed by the final one: the request is denied by the first filter denying it. The shadow
+// rules are evaluated and printed, but only logged by Envoy. It returns whether the request is allowed.
+func Check(w io.Writer, filters []*RBACFilter, r *Request) bool {
+	if len(filters) == 0 {
+		_, _ = fmt.Fprintln(w, "No RBAC filter applies to the request.")
+		_, _ = fmt.Fprintln(w, "Result: ALLOW, no authorization policy applies")
+		return true
+	}
+
+	allowedBy := ""
+	for _, f := range filters {
+		if f.ShadowRules != nil {
+			d := Evaluate(f.ShadowRules, r)
+			_, _ = fmt.Fprintf(w, "%s (shadow %s rules): %s\n", f.Name, d.Action, describeMatch(d))
+		}
+		if f.Rules == nil {
+			continue
+		}
+		d := Evaluate(f.Rules, r)
+		_, _ = fmt.Fprintf(w, "%s (%s rules): %s\n", f.Name, d.Action, describeMatch(d))
+		if d.Allowed {
+			if d.Action == rbacpb.RBAC_ALLOW.String() {
+				allowedBy = d.Policy
+			}
+			continue
+		}
+		if d.Policy != "" {
+			_, _ = fmt.Fprintf(w, "Result: DENY by %s\n", DescribePolicy(d.Policy))
+		} else {
+			_, _ = fmt.Fprintf(w, "Result: DENY, no %s policy matched\n", d.Action)
+		}
+		return false
+	}
+
+	if allowedBy != "" {
+		_, _ = fmt.Fprintf(w, "Result: ALLOW by %s\n", DescribePolicy(allowedBy))
+	} else {
+		_, _ = fmt.Fprintln(w, "Result: ALLOW, no DENY policy matched")
+	}
+	return true
+}
+
+func describeMatch(d Decision) string {
+	if d.Policy == "" {
+		return "no policy matched"
+	}
+	return fmt.Sprintf("%s matched", DescribePolicy(d.Policy))
+}
@@ -0,0 +1,358 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package authz
+
+import (
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2"
+	matcherpb "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
+
+	sm "istio.io/istio/pilot/pkg/security/model"
+	"istio.io/istio/pkg/spiffe"
+)
+
+// Request is the attributes of a request evaluated against the RBAC policies of a proxy. Headers are keyed
+// by their lower case name. A request without Method, Host, Path or Headers is a TCP connection. Claims are
+// the claims of the JWT authenticating the request, if any.
+type Request struct {
+	SourcePrincipal string
+	SourceIP        string
+	DestinationIP   string
+	DestinationPort uint32
+	SNI             string
+	Method          string
+	Host            string
+	Path            string
+	Headers         map[string]string
+	Claims          map[string][]string
+}
+
+// IsHTTP returns whether the request is an HTTP request rather than a TCP connection.
+func (r *Request) IsHTTP() bool {
+	return r.Method != "" || r.Host != "" || r.Path != "" || len(r.Headers) > 0
+}
+
+// HTTPHeaders returns the headers of the request, including the pseudo headers Envoy matches
+// against: :method, :authority and :path.
+func (r *Request) HTTPHeaders() map[string]string {
+	headers := map[string]string{}
+	for k, v := range r.Headers {
+		headers[strings.ToLower(k)] = v
+	}
+	if r.Method != "" {
+		headers[":method"] = r.Method
+	}
+	if r.Host != "" {
+		headers[":authority"] = r.Host
+	}
+	if r.Path != "" {
+		headers[":path"] = r.Path
+	}
+	return headers
+}
+
+// authnMetadata returns the value of the dynamic metadata the Istio authentication filter sets for the
+// request at path, which the RBAC filter matches the principals and the JWT claims against.
+func (r *Request) authnMetadata(path []string) ([]string, bool) {
+	var values []string
+	switch {
+	case len(path) == 1 && path[0] == "source.principal":
+		values = nonEmpty(r.SourcePrincipal)
+	case len(path) == 1 && path[0] == "request.auth.principal":
+		if iss, sub := r.Claims["iss"], r.Claims["sub"]; len(iss) > 0 && len(sub) > 0 {
+			values = []string{iss[0] + "/" + sub[0]}
+		}
+	case len(path) == 1 && path[0] == "request.auth.audiences":
+		values = r.Claims["aud"]
+	case len(path) == 1 && path[0] == "request.auth.presenter":
+		values = r.Claims["azp"]
+	case len(path) == 2 && path[0] == "request.auth.claims":
+		values = r.Claims[path[1]]
+	}
+	return values, len(values) > 0
+}
+
+func nonEmpty(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return []string{s}
+}
+
+// Decision is the result of evaluating a request against the rules of an RBAC filter.
+type Decision struct {
+	// Allowed is whether the rules allow the request.
+	Allowed bool
+	// Policy is the name of the policy that matched the request, or empty when none did.
+	Policy string
+	// Action is the action of the rules, ALLOW or DENY.
+	Action string
+}
+
+// Evaluate evaluates the request against RBAC rules the way Envoy does: with the ALLOW action the request
+// is allowed when a policy matches it, and with the DENY action it is denied when a policy matches it.
+// Nil rules allow every request. The policies are evaluated in the order of their names.
+func Evaluate(rules *rbacpb.RBAC, r *Request) Decision {
+	if rules == nil {
+		return Decision{Allowed: true}
+	}
+
+	names := make([]string, 0, len(rules.Policies))
+	for name := range rules.Policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	d := Decision{Action: rules.Action.String()}
+	for _, name := range names {
+		if matchPolicy(rules.Policies[name], r) {
+			d.Policy = name
+			break
+		}
+	}
+	if rules.Action == rbacpb.RBAC_DENY {
+		d.Allowed = d.Policy == ""
+	} else {
+		d.Allowed = d.Policy != ""
+	}
+	return d
+}
+
+func matchPolicy(p *rbacpb.Policy, r *Request) bool {
+	permitted := false
+	for _, perm := range p.Permissions {
+		if matchPermission(perm, r) {
+			permitted = true
+			break
+		}
+	}
+	if !permitted {
+		return false
+	}
+	for _, id := range p.Principals {
+		if matchPrincipal(id, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPermission(p *rbacpb.Permission, r *Request) bool {
+	switch rule := p.Rule.(type) {
+	case *rbacpb.Permission_AndRules:
+		for _, sub := range rule.AndRules.Rules {
+			if !matchPermission(sub, r) {
+				return false
+			}
+		}
+		return true
+	case *rbacpb.Permission_OrRules:
+		for _, sub := range rule.OrRules.Rules {
+			if matchPermission(sub, r) {
+				return true
+			}
+		}
+		return false
+	case *rbacpb.Permission_Any:
+		return rule.Any
+	case *rbacpb.Permission_Header:
+		return MatchHeader(rule.Header, r.HTTPHeaders())
+	case *rbacpb.Permission_UrlPath:
+		return matchPath(rule.UrlPath, r.Path)
+	case *rbacpb.Permission_DestinationIp:
+		return matchCIDR(rule.DestinationIp, r.DestinationIP)
+	case *rbacpb.Permission_DestinationPort:
+		return rule.DestinationPort == r.DestinationPort
+	case *rbacpb.Permission_Metadata:
+		return matchMetadata(rule.Metadata, r)
+	case *rbacpb.Permission_NotRule:
+		return !matchPermission(rule.NotRule, r)
+	case *rbacpb.Permission_RequestedServerName:
+		return MatchString(rule.RequestedServerName, r.SNI)
+	}
+	return false
+}
+
+func matchPrincipal(p *rbacpb.Principal, r *Request) bool {
+	switch id := p.Identifier.(type) {
+	case *rbacpb.Principal_AndIds:
+		for _, sub := range id.AndIds.Ids {
+			if !matchPrincipal(sub, r) {
+				return false
+			}
+		}
+		return true
+	case *rbacpb.Principal_OrIds:
+		for _, sub := range id.OrIds.Ids {
+			if matchPrincipal(sub, r) {
+				return true
+			}
+		}
+		return false
+	case *rbacpb.Principal_Any:
+		return id.Any
+	case *rbacpb.Principal_Authenticated_:
+		if r.SourcePrincipal == "" {
+			return false
+		}
+		if id.Authenticated.PrincipalName == nil {
+			return true
+		}
+		// The principal of the peer certificate is its SPIFFE URI
+		return MatchString(id.Authenticated.PrincipalName, spiffe.URIPrefix+r.SourcePrincipal)
+	case *rbacpb.Principal_SourceIp:
+		return matchCIDR(id.SourceIp, r.SourceIP)
+	case *rbacpb.Principal_Header:
+		return MatchHeader(id.Header, r.HTTPHeaders())
+	case *rbacpb.Principal_UrlPath:
+		return matchPath(id.UrlPath, r.Path)
+	case *rbacpb.Principal_Metadata:
+		return matchMetadata(id.Metadata, r)
+	case *rbacpb.Principal_NotId:
+		return !matchPrincipal(id.NotId, r)
+	}
+	return false
+}
+
+// matchMetadata matches the dynamic metadata set by the Istio authentication filter. Other metadata is
+// unknown and never matches.
+func matchMetadata(m *matcherpb.MetadataMatcher, r *Request) bool {
+	if m.Filter != sm.AuthnFilterName {
+		return false
+	}
+	path := make([]string, 0, len(m.Path))
+	for _, segment := range m.Path {
+		path = append(path, segment.GetKey())
+	}
+	values, ok := r.authnMetadata(path)
+	if !ok {
+		return false
+	}
+	for _, value := range values {
+		if matchValue(m.Value, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchValue(m *matcherpb.ValueMatcher, value string) bool {
+	switch p := m.MatchPattern.(type) {
+	case *matcherpb.ValueMatcher_StringMatch:
+		return MatchString(p.StringMatch, value)
+	case *matcherpb.ValueMatcher_PresentMatch:
+		return p.PresentMatch
+	case *matcherpb.ValueMatcher_ListMatch:
+		return matchValue(p.ListMatch.GetOneOf(), value)
+	}
+	return false
+}
+
+func matchPath(m *matcherpb.PathMatcher, path string) bool {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	return MatchString(m.GetPath(), path)
+}
+
+func matchCIDR(cidr *core.CidrRange, ip string) bool {
+	addr := net.ParseIP(ip)
+	if cidr == nil || addr == nil {
+		return false
+	}
+	bits := 8 * net.IPv4len
+	if addr.To4() == nil {
+		bits = 8 * net.IPv6len
+	}
+	prefixLen := bits
+	if cidr.PrefixLen != nil {
+		prefixLen = int(cidr.PrefixLen.Value)
+	}
+	_, network, err := net.ParseCIDR(cidr.AddressPrefix + "/" + strconv.Itoa(prefixLen))
+	return err == nil && network.Contains(addr)
+}
+
+// MatchString returns whether the value matches the Envoy string matcher.
+func MatchString(m *matcherpb.StringMatcher, value string) bool {
+	if m == nil {
+		return false
+	}
+	if m.IgnoreCase {
+		value = strings.ToLower(value)
+	}
+	lower := func(s string) string {
+		if m.IgnoreCase {
+			return strings.ToLower(s)
+		}
+		return s
+	}
+	switch p := m.MatchPattern.(type) {
+	case *matcherpb.StringMatcher_Exact:
+		return value == lower(p.Exact)
+	case *matcherpb.StringMatcher_Prefix:
+		return strings.HasPrefix(value, lower(p.Prefix))
+	case *matcherpb.StringMatcher_Suffix:
+		return strings.HasSuffix(value, lower(p.Suffix))
+	case *matcherpb.StringMatcher_Regex:
+		return MatchRegex(p.Regex, value)
+	case *matcherpb.StringMatcher_SafeRegex:
+		return MatchRegex(p.SafeRegex.GetRegex(), value)
+	}
+	return false
+}
+
+// MatchRegex returns whether the regular expression matches the whole value, as Envoy's regex matchers do.
+func MatchRegex(expr, value string) bool {
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	return err == nil && re.MatchString(value)
+}
+
+// MatchHeader returns whether the headers match the Envoy header matcher.
+func MatchHeader(m *route.HeaderMatcher, headers map[string]string) bool {
+	value, found := headers[strings.ToLower(m.Name)]
+
+	var matched bool
+	switch p := m.HeaderMatchSpecifier.(type) {
+	case *route.HeaderMatcher_ExactMatch:
+		matched = found && value == p.ExactMatch
+	case *route.HeaderMatcher_RegexMatch:
+		matched = found && MatchRegex(p.RegexMatch, value)
+	case *route.HeaderMatcher_SafeRegexMatch:
+		matched = found && MatchRegex(p.SafeRegexMatch.GetRegex(), value)
+	case *route.HeaderMatcher_RangeMatch:
+		n, err := strconv.ParseInt(value, 10, 64)
+		matched = found && err == nil && n >= p.RangeMatch.Start && n < p.RangeMatch.End
+	case *route.HeaderMatcher_PresentMatch:
+		matched = found == p.PresentMatch
+	case *route.HeaderMatcher_PrefixMatch:
+		matched = found && strings.HasPrefix(value, p.PrefixMatch)
+	case *route.HeaderMatcher_SuffixMatch:
+		matched = found && strings.HasSuffix(value, p.SuffixMatch)
+	default:
+		// A matcher without specifier matches the presence of the header
+		matched = found
+	}
+	if m.InvertMatch {
+		return !matched
+	}
+	return matched
+}
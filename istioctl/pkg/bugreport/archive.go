@@ -0,0 +1,94 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"path"
+	"sync"
+	"time"
+)
+
+// IndexFile is the path of the index of the archive.
+const IndexFile = "index.json"
+
+// Index describes the content of the archive.
+type Index struct {
+	// Created is when the bug report was created.
+	Created time.Time `json:"created"`
+	// Start and End bound the time window of the logs.
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Namespaces are the namespaces the report is restricted to, all namespaces when empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Entries are the outcomes of the collection of the files.
+	Entries []Entry `json:"entries"`
+}
+
+// Archive is a tar.gz archive of the files of a bug report, all in a root directory. Files can be added
+// concurrently.
+type Archive struct {
+	mu      sync.Mutex
+	root    string
+	modTime time.Time
+	gz      *gzip.Writer
+	tw      *tar.Writer
+}
+
+// NewArchive returns an archive writing to w, with files in the root directory.
+func NewArchive(w io.Writer, root string) *Archive {
+	gz := gzip.NewWriter(w)
+	return &Archive{
+		root:    root,
+		modTime: time.Now(),
+		gz:      gz,
+		tw:      tar.NewWriter(gz),
+	}
+}
+
+// Add adds a file to the archive.
+func (a *Archive) Add(name string, data []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	header := &tar.Header{
+		Name:    path.Join(a.root, name),
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: a.modTime,
+	}
+	if err := a.tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := a.tw.Write(data)
+	return err
+}
+
+// Close adds the index to the archive and flushes it.
+func (a *Archive) Close(index *Index) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := a.Add(IndexFile, data); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.gz.Close()
+}
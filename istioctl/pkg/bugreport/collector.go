@@ -0,0 +1,141 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package bugreport collects the state of a mesh into an archive attached to bug reports: the files are
+// collected in parallel, each with a timeout, their secrets are redacted and the archive is indexed with
+// the outcome of every collection, so that partial failures do not prevent the report.
+package bugreport
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Task collects a file of the bug report.
+type Task struct {
+	// Path is the path of the file in the archive.
+	Path string
+	// Collect returns the content of the file, or nil when there is nothing to collect. It should return
+	// when ctx is done.
+	Collect func(ctx context.Context) ([]byte, error)
+}
+
+// FailedTask returns a task failing with err, to record in the index a file that could not be collected
+// before collection starts.
+func FailedTask(path string, err error) Task {
+	return Task{
+		Path: path,
+		Collect: func(context.Context) ([]byte, error) {
+			return nil, err
+		},
+	}
+}
+
+// Entry is the outcome of the collection of a file, listed in the index of the archive.
+type Entry struct {
+	Path     string `json:"path"`
+	Size     int    `json:"size"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Collector runs the tasks of a bug report.
+type Collector struct {
+	// Concurrency is the maximum number of tasks run at once.
+	Concurrency int
+	// Timeout is the maximum duration of a task.
+	Timeout time.Duration
+}
+
+// Run runs the tasks and adds the redacted files they collect to the archive. A task failing or timing out
+// does not stop the others: its error is recorded in the returned entries, sorted by path. Tasks with nothing
+// to collect have no entry.
+func (c *Collector) Run(ctx context.Context, tasks []Task, a *Archive) []Entry {
+	concurrency := c.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	results := make([]*Entry, len(tasks))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, t := range tasks {
+		wg.Add(1)
+		go func(i int, t Task) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = c.run(ctx, t, a)
+		}(i, t)
+	}
+	wg.Wait()
+
+	var entries []Entry
+	for _, e := range results {
+		if e != nil {
+			entries = append(entries, *e)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+type result struct {
+	data []byte
+	err  error
+}
+
+func (c *Collector) run(ctx context.Context, t Task, a *Archive) *Entry {
+	start := time.Now()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	// Tasks executing commands in pods cannot be interrupted, they are abandoned when they time out.
+	done := make(chan result, 1)
+	go func() {
+		data, err := t.Collect(ctx)
+		done <- result{data, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		r.err = fmt.Errorf("timed out after %v", time.Since(start).Round(time.Millisecond))
+	}
+
+	if r.err == nil && r.data == nil {
+		return nil
+	}
+	entry := &Entry{Path: t.Path}
+	if r.err == nil {
+		data := Redact(r.data)
+		entry.Size = len(data)
+		r.err = a.Add(t.Path, data)
+	}
+	if r.err != nil {
+		entry.Error = r.err.Error()
+	}
+	entry.Duration = time.Since(start).Round(time.Millisecond).String()
+	return entry
+}
@@ -0,0 +1,39 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bugreport
+
+import (
+	"regexp"
+)
+
+const redacted = "[redacted]"
+
+var (
+	// privateKeyPattern matches PEM encoded private keys.
+	privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	// secretFieldPattern matches the JSON fields holding secrets, such as the private keys of the SDS
+	// secrets of Envoy config dumps, with a string or flat object value.
+	secretFieldPattern = regexp.MustCompile(
+		`("(?:private_key|privateKey|password|token|client_secret|clientSecret)"\s*:\s*)("(?:[^"\\]|\\.)*"|\{[^{}]*\})`)
+	// bearerTokenPattern matches the bearer tokens of authorization headers.
+	bearerTokenPattern = regexp.MustCompile(`(?i)(bearer\s+)[^\s"',]+`)
+)
+
+// Redact replaces the private keys, passwords and tokens in data.
+func Redact(data []byte) []byte {
+	data = privateKeyPattern.ReplaceAll(data, []byte(redacted))
+	data = secretFieldPattern.ReplaceAll(data, []byte(`${1}"`+redacted+`"`))
+	return bearerTokenPattern.ReplaceAll(data, []byte("${1}"+redacted))
+}
@@ -0,0 +1,333 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package certs checks the certificates the proxies of a mesh received through SDS: their expiry, the
+// root of trust which signed them and the identity they carry.
+package certs
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	sdscompare "istio.io/istio/istioctl/pkg/writer/compare/sds"
+)
+
+const (
+	// WorkloadSecret is the name of the SDS secret holding the certificate chain of the workload identity.
+	WorkloadSecret = "default"
+	// RootSecret is the name of the SDS secret holding the roots trusted by the proxy.
+	RootSecret = "ROOTCA"
+)
+
+// Proxy holds the SDS secrets of a proxy to check.
+type Proxy struct {
+	// Name identifies the proxy, as pod.namespace.
+	Name string
+	// Namespace and ServiceAccount are the expected identity of the workload certificate.
+	Namespace      string
+	ServiceAccount string
+	Secrets        []sdscompare.SecretItem
+	// Err is the failure to read the secrets of the proxy, reported as its problem instead of checking them.
+	Err error
+}
+
+// Options configures the checks.
+type Options struct {
+	// Now is the time the expiry of the certificates is checked against.
+	Now time.Time
+	// ExpiryThreshold is the minimum remaining validity of a certificate. Workload certificates are
+	// normally rotated when half of their lifetime remains.
+	ExpiryThreshold time.Duration
+	// MeshRoots are the roots currently distributed by the control plane. Certificates signed by other
+	// roots are reported as stale. When empty, certificates signed by different roots are reported instead.
+	MeshRoots []*x509.Certificate
+}
+
+// ProxyResult is the result of the checks of a proxy.
+type ProxyResult struct {
+	Proxy    string    `json:"proxy"`
+	SPIFFEID string    `json:"spiffeID,omitempty"`
+	NotAfter time.Time `json:"notAfter"`
+	// Issuers are the subjects of the issuers of the workload certificate, from its issuer to the root.
+	Issuers  []string `json:"issuers,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// Root is a root of trust and the proxies whose workload certificate it signed.
+type Root struct {
+	// Fingerprint is the SHA-256 fingerprint of the root certificate, empty for the proxies whose workload
+	// certificate is not signed by a known root.
+	Fingerprint string    `json:"fingerprint"`
+	Subject     string    `json:"subject,omitempty"`
+	NotAfter    time.Time `json:"notAfter"`
+	// MeshRoot is whether the root is currently distributed by the control plane.
+	MeshRoot bool `json:"meshRoot"`
+	// TrustedBy is the number of proxies trusting the root.
+	TrustedBy int            `json:"trustedBy"`
+	Proxies   []*ProxyResult `json:"proxies"`
+}
+
+// Report is the result of the checks of the proxies, grouped by root.
+type Report struct {
+	Roots []*Root `json:"roots"`
+	// Problems are the problems of the mesh as a whole.
+	Problems []string `json:"problems,omitempty"`
+}
+
+// ProblemCount returns the number of problems found.
+func (r *Report) ProblemCount() int {
+	count := len(r.Problems)
+	for _, root := range r.Roots {
+		for _, p := range root.Proxies {
+			count += len(p.Problems)
+		}
+	}
+	return count
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseCertificates parses the PEM encoded certificates.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found")
+	}
+	return certs, nil
+}
+
+// Check checks the secrets of the proxies.
+func Check(proxies []Proxy, opts Options) *Report {
+	roots := map[string]*Root{}
+	addRoot := func(cert *x509.Certificate) *Root {
+		fingerprint := Fingerprint(cert)
+		root, ok := roots[fingerprint]
+		if !ok {
+			root = &Root{
+				Fingerprint: fingerprint,
+				Subject:     cert.Subject.String(),
+				NotAfter:    cert.NotAfter,
+				Proxies:     []*ProxyResult{},
+			}
+			roots[fingerprint] = root
+		}
+		return root
+	}
+	for _, cert := range opts.MeshRoots {
+		addRoot(cert).MeshRoot = true
+	}
+
+	for _, proxy := range proxies {
+		result, trusted, signer := checkProxy(proxy, opts)
+		for _, cert := range trusted {
+			addRoot(cert).TrustedBy++
+		}
+		if signer == nil {
+			root, ok := roots[""]
+			if !ok {
+				root = &Root{Proxies: []*ProxyResult{}}
+				roots[""] = root
+			}
+			root.Proxies = append(root.Proxies, result)
+			continue
+		}
+		root := addRoot(signer)
+		root.Proxies = append(root.Proxies, result)
+	}
+
+	report := &Report{}
+	signing := 0
+	for _, root := range roots {
+		if root.Fingerprint != "" && len(root.Proxies) > 0 {
+			signing++
+		}
+		sort.Slice(root.Proxies, func(i, j int) bool {
+			return root.Proxies[i].Proxy < root.Proxies[j].Proxy
+		})
+		report.Roots = append(report.Roots, root)
+	}
+	// Mesh roots first, then by decreasing number of proxies, unknown roots last
+	sort.Slice(report.Roots, func(i, j int) bool {
+		a, b := report.Roots[i], report.Roots[j]
+		if (a.Fingerprint == "") != (b.Fingerprint == "") {
+			return b.Fingerprint == ""
+		}
+		if a.MeshRoot != b.MeshRoot {
+			return a.MeshRoot
+		}
+		if len(a.Proxies) != len(b.Proxies) {
+			return len(a.Proxies) > len(b.Proxies)
+		}
+		return a.Fingerprint < b.Fingerprint
+	})
+	if len(opts.MeshRoots) == 0 && signing > 1 {
+		report.Problems = append(report.Problems,
+			fmt.Sprintf("workload certificates are signed by %d different roots", signing))
+	}
+	return report
+}
+
+// checkProxy checks the secrets of the proxy, and returns the roots it trusts and the root which signed
+// its workload certificate, if known.
+func checkProxy(proxy Proxy, opts Options) (*ProxyResult, []*x509.Certificate, *x509.Certificate) {
+	result := &ProxyResult{Proxy: proxy.Name}
+	problem := func(format string, args ...interface{}) {
+		result.Problems = append(result.Problems, fmt.Sprintf(format, args...))
+	}
+
+	if proxy.Err != nil {
+		problem("%v", proxy.Err)
+		return result, nil, nil
+	}
+
+	var trusted []*x509.Certificate
+	var chain []*x509.Certificate
+	for _, secret := range proxy.Secrets {
+		// Warming secrets are not in use yet
+		if secret.State != "ACTIVE" || secret.Data == "" {
+			continue
+		}
+		certs, err := ParseCertificates([]byte(secret.Data))
+		if err != nil {
+			problem("invalid secret %s: %v", secret.Name, err)
+			continue
+		}
+		switch secret.Name {
+		case RootSecret:
+			trusted = certs
+		case WorkloadSecret:
+			chain = certs
+		default:
+			// Gateway credentials are only checked for expiry
+			if !certs[0].IsCA {
+				checkExpiry(certs[0], "secret "+secret.Name, opts, problem)
+			}
+		}
+	}
+
+	if chain == nil {
+		problem("no workload certificate")
+		return result, trusted, nil
+	}
+
+	leaf := chain[0]
+	result.NotAfter = leaf.NotAfter
+	checkExpiry(leaf, "workload certificate", opts, problem)
+	if len(leaf.URIs) > 0 {
+		result.SPIFFEID = leaf.URIs[0].String()
+	}
+	if proxy.ServiceAccount != "" {
+		suffix := fmt.Sprintf("/ns/%s/sa/%s", proxy.Namespace, proxy.ServiceAccount)
+		if !strings.HasPrefix(result.SPIFFEID, "spiffe://") || !strings.HasSuffix(result.SPIFFEID, suffix) {
+			problem("SPIFFE ID %q does not match service account %s/%s",
+				result.SPIFFEID, proxy.Namespace, proxy.ServiceAccount)
+		}
+	}
+
+	for _, cert := range chain[1:] {
+		result.Issuers = append(result.Issuers, cert.Subject.String())
+	}
+	signer := findRoot(chain, append(append([]*x509.Certificate{}, trusted...), opts.MeshRoots...))
+	if signer == nil {
+		if len(chain) == 1 || chain[len(chain)-1].Subject.String() != chain[len(chain)-1].Issuer.String() {
+			result.Issuers = append(result.Issuers, chain[len(chain)-1].Issuer.String())
+		}
+		problem("workload certificate is not signed by a known root")
+		return result, trusted, nil
+	}
+	if Fingerprint(signer) != Fingerprint(chain[len(chain)-1]) {
+		result.Issuers = append(result.Issuers, signer.Subject.String())
+	}
+
+	if !containsCertificate(trusted, signer) {
+		problem("workload certificate is signed by a root the proxy does not trust")
+	}
+	if len(opts.MeshRoots) > 0 {
+		if !containsCertificate(opts.MeshRoots, signer) {
+			problem("workload certificate is signed by a stale root")
+		}
+		meshRootTrusted := false
+		for _, root := range opts.MeshRoots {
+			meshRootTrusted = meshRootTrusted || containsCertificate(trusted, root)
+		}
+		if !meshRootTrusted {
+			problem("proxy does not trust the mesh root")
+		}
+	}
+	return result, trusted, signer
+}
+
+// findRoot returns the root which signed the chain, either a self-signed certificate ending the chain or
+// one of the candidates.
+func findRoot(chain []*x509.Certificate, candidates []*x509.Certificate) *x509.Certificate {
+	for i := 0; i < len(chain)-1; i++ {
+		if chain[i].CheckSignatureFrom(chain[i+1]) != nil {
+			return nil
+		}
+	}
+	last := chain[len(chain)-1]
+	if len(chain) > 1 && last.Subject.String() == last.Issuer.String() && last.CheckSignatureFrom(last) == nil {
+		return last
+	}
+	for _, candidate := range candidates {
+		if last.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
+
+func containsCertificate(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
+
+func checkExpiry(cert *x509.Certificate, what string, opts Options, problem func(string, ...interface{})) {
+	remaining := cert.NotAfter.Sub(opts.Now)
+	switch {
+	case remaining <= 0:
+		problem("%s expired at %s", what, cert.NotAfter.UTC().Format(time.RFC3339))
+	case remaining < opts.ExpiryThreshold:
+		problem("%s expires in %v", what, remaining.Round(time.Minute))
+	}
+}
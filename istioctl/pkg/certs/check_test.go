@@ -0,0 +1,202 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	sdscompare "istio.io/istio/istioctl/pkg/writer/compare/sds"
+	"istio.io/istio/security/pkg/pki/util"
+)
+
+var now = time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+
+type testCert struct {
+	pem  string
+	cert *x509.Certificate
+	key  crypto.PrivateKey
+}
+
+func genCert(t *testing.T, opts util.CertOptions, signer *testCert) *testCert {
+	t.Helper()
+	opts.RSAKeySize = 2048
+	if opts.NotBefore.IsZero() {
+		opts.NotBefore = now
+	}
+	if signer == nil {
+		opts.IsSelfSigned = true
+	} else {
+		opts.SignerCert, opts.SignerPriv = signer.cert, signer.key
+	}
+	certPem, keyPem, err := util.GenCertKeyFromOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := util.ParsePemEncodedCertificate(certPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := util.ParsePemEncodedKey(keyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{pem: string(certPem), cert: cert, key: key}
+}
+
+func secret(name string, certs ...*testCert) sdscompare.SecretItem {
+	data := ""
+	for _, c := range certs {
+		data += c.pem
+	}
+	return sdscompare.SecretItem{Name: name, State: "ACTIVE", Data: data}
+}
+
+func TestCheck(t *testing.T) {
+	oldRoot := genCert(t, util.CertOptions{Org: "old.cluster.local", IsCA: true, TTL: 24 * 365 * time.Hour}, nil)
+	newRoot := genCert(t, util.CertOptions{Org: "cluster.local", IsCA: true, TTL: 24 * 365 * time.Hour}, nil)
+	intermediate := genCert(t, util.CertOptions{Org: "intermediate", IsCA: true, TTL: 24 * 30 * time.Hour}, newRoot)
+	workload := func(ns, sa string, signer *testCert, ttl time.Duration) *testCert {
+		return genCert(t, util.CertOptions{Host: "spiffe://cluster.local/ns/" + ns + "/sa/" + sa, TTL: ttl}, signer)
+	}
+
+	proxies := []Proxy{
+		{
+			Name:           "productpage-v1.default",
+			Namespace:      "default",
+			ServiceAccount: "bookinfo-productpage",
+			Secrets: []sdscompare.SecretItem{
+				secret(WorkloadSecret, workload("default", "bookinfo-productpage", intermediate, 23*time.Hour), intermediate),
+				secret(RootSecret, newRoot),
+				// Warming secrets are ignored
+				{Name: WorkloadSecret, State: "WARMING", Data: "invalid"},
+			},
+		},
+		{
+			Name:           "reviews-v1.default",
+			Namespace:      "default",
+			ServiceAccount: "bookinfo-reviews",
+			Secrets: []sdscompare.SecretItem{
+				secret(WorkloadSecret, workload("default", "bookinfo-ratings", intermediate, time.Hour), intermediate),
+				secret(RootSecret, newRoot),
+			},
+		},
+		{
+			Name:           "ratings-v1.default",
+			Namespace:      "default",
+			ServiceAccount: "bookinfo-ratings",
+			Secrets: []sdscompare.SecretItem{
+				secret(WorkloadSecret, workload("default", "bookinfo-ratings", oldRoot, 23*time.Hour)),
+				secret(RootSecret, oldRoot),
+			},
+		},
+		{
+			Name: "istio-ingressgateway-1.istio-system",
+			Secrets: []sdscompare.SecretItem{
+				secret("bookinfo-credential", workload("istio-system", "gateway", newRoot, time.Minute)),
+				secret(RootSecret, newRoot, oldRoot),
+			},
+		},
+	}
+
+	report := Check(proxies, Options{
+		Now:             now,
+		ExpiryThreshold: 6 * time.Hour,
+		MeshRoots:       []*x509.Certificate{newRoot.cert},
+	})
+
+	got := map[string][]string{}
+	for _, root := range report.Roots {
+		for _, p := range root.Proxies {
+			got[root.Subject+" "+p.Proxy] = p.Problems
+		}
+	}
+	want := map[string][]string{
+		"O=cluster.local productpage-v1.default": nil,
+		"O=cluster.local reviews-v1.default": {
+			"workload certificate expires in 1h0m0s",
+			`SPIFFE ID "spiffe://cluster.local/ns/default/sa/bookinfo-ratings" does not match service account default/bookinfo-reviews`,
+		},
+		"O=old.cluster.local ratings-v1.default": {
+			"workload certificate is signed by a stale root",
+			"proxy does not trust the mesh root",
+		},
+		" istio-ingressgateway-1.istio-system": {
+			"secret bookinfo-credential expires in 1m0s",
+			"no workload certificate",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got problems %v, want %v", got, want)
+	}
+
+	if report.Roots[0].Fingerprint != Fingerprint(newRoot.cert) || !report.Roots[0].MeshRoot ||
+		report.Roots[0].TrustedBy != 3 {
+		t.Errorf("got first root %+v, want the mesh root trusted by 3 proxies", report.Roots[0])
+	}
+	if got := report.Roots[0].Proxies[0].Issuers; !reflect.DeepEqual(got, []string{"O=intermediate", "O=cluster.local"}) {
+		t.Errorf("got issuers %v, want the intermediate and the root", got)
+	}
+	if report.Roots[2].Fingerprint != "" {
+		t.Errorf("got last root %+v, want the unknown root", report.Roots[2])
+	}
+	if got := report.ProblemCount(); got != 6 {
+		t.Errorf("got %d problems, want 6", got)
+	}
+
+	var out bytes.Buffer
+	if err := PrintReport(&out, report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Root " + Fingerprint(newRoot.cert),
+		"mesh root, trusted by 3 proxies",
+		"productpage-v1.default   spiffe://cluster.local/ns/default/sa/bookinfo-productpage",
+		"O=intermediate > O=cluster.local   OK",
+		"not distributed by the control plane, trusted by 2 proxies",
+		"Unknown root",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("got report\n%s\nwant it to contain %q", out.String(), want)
+		}
+	}
+}
+
+func TestCheckWithoutMeshRoots(t *testing.T) {
+	root1 := genCert(t, util.CertOptions{Org: "cluster1", IsCA: true, TTL: time.Hour}, nil)
+	root2 := genCert(t, util.CertOptions{Org: "cluster2", IsCA: true, TTL: time.Hour}, nil)
+	proxy := func(name string, root *testCert) Proxy {
+		leaf := genCert(t, util.CertOptions{Host: "spiffe://cluster.local/ns/default/sa/default", TTL: time.Hour}, root)
+		return Proxy{
+			Name:    name,
+			Secrets: []sdscompare.SecretItem{secret(WorkloadSecret, leaf), secret(RootSecret, root)},
+		}
+	}
+
+	report := Check([]Proxy{proxy("a.default", root1), proxy("b.default", root2)}, Options{Now: now})
+	if want := []string{"workload certificates are signed by 2 different roots"}; !reflect.DeepEqual(report.Problems, want) {
+		t.Errorf("got problems %v, want %v", report.Problems, want)
+	}
+	for _, root := range report.Roots {
+		if len(root.Proxies) != 1 || len(root.Proxies[0].Problems) != 0 {
+			t.Errorf("got root %+v, want a single proxy without problems", root)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// PrintReport prints the report, grouping the proxies by the root which signed their workload certificate.
+func PrintReport(w io.Writer, report *Report) error {
+	for i, root := range report.Roots {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if root.Fingerprint == "" {
+			fmt.Fprintln(w, "Unknown root")
+		} else {
+			status := "not distributed by the control plane"
+			if root.MeshRoot {
+				status = "mesh root"
+			}
+			fmt.Fprintf(w, "Root %s\n", root.Fingerprint)
+			fmt.Fprintf(w, "   Subject: %s, valid until %s, %s, trusted by %d proxies\n",
+				root.Subject, root.NotAfter.UTC().Format(time.RFC3339), status, root.TrustedBy)
+		}
+		if len(root.Proxies) == 0 {
+			fmt.Fprintln(w, "   No workload certificate signed")
+			continue
+		}
+
+		tw := new(tabwriter.Writer).Init(w, 0, 8, 3, ' ', 0)
+		fmt.Fprintln(tw, "   PROXY\tSPIFFE ID\tNOT AFTER\tISSUERS\tSTATUS")
+		for _, p := range root.Proxies {
+			notAfter := "-"
+			if !p.NotAfter.IsZero() {
+				notAfter = p.NotAfter.UTC().Format(time.RFC3339)
+			}
+			status := "OK"
+			if len(p.Problems) > 0 {
+				status = strings.Join(p.Problems, "; ")
+			}
+			fmt.Fprintf(tw, "   %s\t%s\t%s\t%s\t%s\n", p.Proxy, valueOrDash(p.SPIFFEID), notAfter,
+				valueOrDash(strings.Join(p.Issuers, " > ")), status)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	for _, p := range report.Problems {
+		fmt.Fprintf(w, "\nWARNING: %s\n", p)
+	}
+	return nil
+}
+
+// PrintJSON prints the report as JSON.
+func PrintJSON(w io.Writer, report *Report) error {
+	out, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+func valueOrDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,272 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	security "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// policyTarget is the set of workloads an authentication policy applies to.
+type policyTarget struct {
+	name     string
+	selector *typev1beta1.WorkloadSelector
+	// ports are the workload ports, all the ports when empty.
+	ports []uint32
+}
+
+func (c *securityConverter) convertAuthentication() {
+	// v1alpha1 applies the most specific policy only, while the v1beta1 request authentications and
+	// authorization policies of the mesh, the namespace and the workload all apply.
+	meshOrigins := false
+	namespaceOrigins := map[string]bool{}
+	for _, cfg := range c.resources.Policies {
+		spec := cfg.Spec.(*authn.Policy)
+		if len(spec.Origins) == 0 {
+			continue
+		}
+		if cfg.Type == AuthenticationMeshPolicyKind {
+			meshOrigins = true
+		} else if len(spec.Targets) == 0 {
+			namespaceOrigins[cfg.Namespace] = true
+		}
+	}
+
+	for _, cfg := range c.resources.Policies {
+		spec := cfg.Spec.(*authn.Policy)
+		namespace, targets := cfg.Namespace, spec.Targets
+		if cfg.Type == AuthenticationMeshPolicyKind {
+			namespace, targets = c.rootNamespace, nil
+			if len(spec.Targets) > 0 {
+				c.warnf(cfg, "targets are not supported in a mesh policy and are ignored")
+			}
+		} else if meshOrigins || (len(targets) > 0 && namespaceOrigins[namespace]) {
+			c.warnf(cfg, "the origins of the broader policies are not replaced by the origins of this policy "+
+				"anymore, the JWT requirements add up")
+		}
+
+		mode := c.peerMode(cfg, spec)
+		for _, target := range c.policyTargets(cfg, namespace, targets) {
+			pa := &security.PeerAuthentication{Selector: target.selector}
+			if len(target.ports) == 0 {
+				pa.Mtls = &security.PeerAuthentication_MutualTLS{Mode: mode}
+			} else {
+				pa.PortLevelMtls = map[uint32]*security.PeerAuthentication_MutualTLS{}
+				for _, port := range target.ports {
+					pa.PortLevelMtls[port] = &security.PeerAuthentication_MutualTLS{Mode: mode}
+				}
+			}
+			c.add(collections.IstioSecurityV1Beta1Peerauthentications, target.name, namespace, pa)
+			c.convertOrigins(cfg, spec, namespace, target)
+		}
+		if spec.PrincipalBinding == authn.PrincipalBinding_USE_ORIGIN {
+			c.warnf(cfg, "principal binding USE_ORIGIN can't be converted, the authorization policies must match "+
+				"the request principals instead of the principals")
+		}
+	}
+}
+
+// peerMode returns the mutual TLS mode of the peer authentication methods of the policy.
+func (c *securityConverter) peerMode(cfg model.Config, spec *authn.Policy) security.PeerAuthentication_MutualTLS_Mode {
+	mode := security.PeerAuthentication_MutualTLS_DISABLE
+	for _, peer := range spec.Peers {
+		switch p := peer.Params.(type) {
+		case *authn.PeerAuthenticationMethod_Mtls:
+			if p.Mtls.GetMode() == authn.MutualTls_PERMISSIVE {
+				mode = security.PeerAuthentication_MutualTLS_PERMISSIVE
+			} else {
+				mode = security.PeerAuthentication_MutualTLS_STRICT
+			}
+			if p.Mtls.GetAllowTls() {
+				c.warnf(cfg, "allowTls is deprecated and ignored")
+			}
+		case *authn.PeerAuthenticationMethod_Jwt:
+			c.warnf(cfg, "peer JWT authentication can't be converted, use origins instead")
+		}
+	}
+	if spec.PeerIsOptional && mode == security.PeerAuthentication_MutualTLS_STRICT {
+		mode = security.PeerAuthentication_MutualTLS_PERMISSIVE
+	}
+	return mode
+}
+
+// policyTargets translates the target selectors of the policy into workload selectors and ports.
+func (c *securityConverter) policyTargets(cfg model.Config, namespace string,
+	selectors []*authn.TargetSelector) []policyTarget {
+	if len(selectors) == 0 {
+		return []policyTarget{{name: cfg.Name}}
+	}
+	var targets []policyTarget
+	for _, t := range selectors {
+		svc := c.service(namespace, t.Name)
+		if svc == nil {
+			c.warnf(cfg, "service %s not found, target not converted", t.Name)
+			continue
+		}
+		selector := workloadSelector(svc)
+		if selector == nil {
+			c.warnf(cfg, "service %s has no selector, target not converted", t.Name)
+			continue
+		}
+		target := policyTarget{name: cfg.Name + "-" + svc.Name, selector: selector}
+		for _, port := range t.Ports {
+			if p, ok := c.targetPort(cfg, svc, port); ok {
+				target.ports = append(target.ports, p)
+			}
+		}
+		if len(t.Ports) > 0 && len(target.ports) == 0 {
+			c.warnf(cfg, "no port of service %s can be converted, target not converted", t.Name)
+			continue
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// targetPort returns the workload port of the service port selected, as v1beta1 selects workload ports.
+func (c *securityConverter) targetPort(cfg model.Config, svc *v1.Service, selector *authn.PortSelector) (uint32, bool) {
+	for _, port := range svc.Spec.Ports {
+		if selector.GetNumber() != uint32(port.Port) && (selector.GetName() == "" || selector.GetName() != port.Name) {
+			continue
+		}
+		switch {
+		case port.TargetPort.Type == intstr.String:
+			c.warnf(cfg, "port %d of service %s targets the named port %s, which can't be selected",
+				port.Port, svc.Name, port.TargetPort.StrVal)
+			return 0, false
+		case port.TargetPort.IntVal == 0:
+			return uint32(port.Port), true
+		default:
+			return uint32(port.TargetPort.IntVal), true
+		}
+	}
+	name := selector.GetName()
+	if name == "" {
+		name = strconv.Itoa(int(selector.GetNumber()))
+	}
+	c.warnf(cfg, "service %s has no port %s", svc.Name, name)
+	return 0, false
+}
+
+// convertOrigins converts the origins of the policy into a request authentication and, unless they are
+// optional, an authorization policy denying the requests without a valid JWT.
+func (c *securityConverter) convertOrigins(cfg model.Config, spec *authn.Policy, namespace string, target policyTarget) {
+	if len(spec.Origins) == 0 {
+		return
+	}
+	ra := &security.RequestAuthentication{Selector: target.selector}
+	var rules []*security.Rule
+	for _, origin := range spec.Origins {
+		jwt := origin.GetJwt()
+		if jwt == nil {
+			continue
+		}
+		rule := &security.JWTRule{
+			Issuer:     jwt.Issuer,
+			Audiences:  jwt.Audiences,
+			JwksUri:    jwt.JwksUri,
+			Jwks:       jwt.Jwks,
+			FromParams: jwt.JwtParams,
+			// The v1alpha1 policies forwarded the token to the workload
+			ForwardOriginalToken: true,
+		}
+		for _, header := range jwt.JwtHeaders {
+			rule.FromHeaders = append(rule.FromHeaders, &security.JWTHeader{Name: header})
+		}
+		ra.JwtRules = append(ra.JwtRules, rule)
+		if !spec.OriginIsOptional {
+			rules = append(rules, c.requireJWTRules(cfg, jwt, target.ports)...)
+		}
+	}
+	if len(target.ports) > 0 {
+		c.warnf(cfg, "the JWT of the requests is validated on all the ports of the workloads, not only on the "+
+			"ports of the targets")
+	}
+	c.add(collections.IstioSecurityV1Beta1Requestauthentications, target.name, namespace, ra)
+
+	if len(rules) == 0 {
+		return
+	}
+	c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, target.name+"-require-jwt", namespace,
+		&security.AuthorizationPolicy{
+			Selector: target.selector,
+			Action:   security.AuthorizationPolicy_DENY,
+			Rules:    rules,
+		})
+}
+
+// requireJWTRules returns the rules matching the requests without a JWT which the trigger rules of the
+// origin apply to.
+func (c *securityConverter) requireJWTRules(cfg model.Config, jwt *authn.Jwt, ports []uint32) []*security.Rule {
+	operations := []*security.Operation{}
+	if len(jwt.TriggerRules) == 0 {
+		operations = append(operations, &security.Operation{})
+	}
+	for _, trigger := range jwt.TriggerRules {
+		paths, ok := c.paths(cfg, trigger.IncludedPaths)
+		if !ok {
+			continue
+		}
+		notPaths, ok := c.paths(cfg, trigger.ExcludedPaths)
+		if !ok {
+			continue
+		}
+		operations = append(operations, &security.Operation{Paths: paths, NotPaths: notPaths})
+	}
+
+	rules := make([]*security.Rule, 0, len(operations))
+	for _, operation := range operations {
+		for _, port := range ports {
+			operation.Ports = append(operation.Ports, strconv.Itoa(int(port)))
+		}
+		rule := &security.Rule{
+			From: []*security.Rule_From{{Source: &security.Source{NotRequestPrincipals: []string{"*"}}}},
+		}
+		if len(operation.Paths) > 0 || len(operation.NotPaths) > 0 || len(operation.Ports) > 0 {
+			rule.To = []*security.Rule_To{{Operation: operation}}
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// paths converts the string matches of a trigger rule into paths.
+func (c *securityConverter) paths(cfg model.Config, matches []*authn.StringMatch) ([]string, bool) {
+	var paths []string
+	for _, match := range matches {
+		switch m := match.MatchType.(type) {
+		case *authn.StringMatch_Exact:
+			paths = append(paths, m.Exact)
+		case *authn.StringMatch_Prefix:
+			paths = append(paths, m.Prefix+"*")
+		case *authn.StringMatch_Suffix:
+			paths = append(paths, "*"+m.Suffix)
+		case *authn.StringMatch_Regex:
+			c.warnf(cfg, "trigger rule with the regex path %q can't be converted, the JWT is not required for it",
+				m.Regex)
+			return nil, false
+		}
+	}
+	return paths, true
+}
@@ -0,0 +1,410 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+
+	rbac "istio.io/api/rbac/v1alpha1"
+	security "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+	securityattrs "istio.io/istio/pkg/config/security"
+)
+
+const groupsClaim = "request.auth.claims[groups]"
+
+// rbacScope is the set of workloads the ClusterRbacConfig enables RBAC for.
+type rbacScope struct {
+	mode rbac.RbacConfig_Mode
+	// namespaces are the namespaces included or excluded.
+	namespaces map[string]bool
+	// services are the services included or excluded, by namespace.
+	services map[string][]*v1.Service
+}
+
+// enforcedServices returns whether RBAC is enabled for all the workloads of the namespace and, if not,
+// the services it is enabled for.
+func (s *rbacScope) enforcedServices(namespace string) (bool, []*v1.Service) {
+	switch s.mode {
+	case rbac.RbacConfig_ON_WITH_INCLUSION:
+		if s.namespaces[namespace] {
+			return true, nil
+		}
+		return false, s.services[namespace]
+	case rbac.RbacConfig_ON_WITH_EXCLUSION:
+		// The excluded services are allowed by a separate policy
+		return !s.namespaces[namespace], nil
+	default:
+		return true, nil
+	}
+}
+
+// roleTarget is the set of workloads the rules of a role are converted for.
+type roleTarget struct {
+	name     string
+	selector *typev1beta1.WorkloadSelector
+}
+
+// subjectRule is the part of a rule converted from a subject of a binding.
+type subjectRule struct {
+	from []*security.Rule_From
+	when []*security.Condition
+}
+
+func (c *securityConverter) convertAuthorization() {
+	cfg := c.clusterRbacConfig()
+	if cfg == nil {
+		if len(c.resources.ServiceRoles) > 0 || len(c.resources.ServiceRoleBindings) > 0 {
+			c.warnings = append(c.warnings, "no ClusterRbacConfig found: RBAC is disabled, the ServiceRoles and "+
+				"ServiceRoleBindings are not converted")
+		}
+		return
+	}
+	spec := cfg.Spec.(*rbac.RbacConfig)
+	if spec.Mode == rbac.RbacConfig_OFF {
+		c.warnf(*cfg, "RBAC is disabled, the ServiceRoles and ServiceRoleBindings are not converted")
+		return
+	}
+	if spec.EnforcementMode == rbac.EnforcementMode_PERMISSIVE {
+		c.warnf(*cfg, "permissive enforcement mode can't be converted, the authorization policies are enforced")
+	}
+
+	scope := c.convertRbacConfig(*cfg, spec)
+	c.convertServiceRoles(scope)
+}
+
+// clusterRbacConfig returns the ClusterRbacConfig, or the deprecated RbacConfig when there is none.
+func (c *securityConverter) clusterRbacConfig() *model.Config {
+	var found *model.Config
+	for i := range c.resources.RbacConfigs {
+		cfg := &c.resources.RbacConfigs[i]
+		if cfg.Type == collections.IstioRbacV1Alpha1Clusterrbacconfigs.Resource().Kind() {
+			return cfg
+		}
+		if found == nil {
+			found = cfg
+		}
+	}
+	if found != nil {
+		c.warnf(*found, "RbacConfig is deprecated, converted as the ClusterRbacConfig")
+	}
+	return found
+}
+
+// convertRbacConfig creates the authorization policies denying all the requests to the workloads RBAC is
+// enabled for, and returns them.
+func (c *securityConverter) convertRbacConfig(cfg model.Config, spec *rbac.RbacConfig) *rbacScope {
+	scope := &rbacScope{mode: spec.Mode, namespaces: map[string]bool{}, services: map[string][]*v1.Service{}}
+	target := spec.Inclusion
+	if spec.Mode == rbac.RbacConfig_ON_WITH_EXCLUSION {
+		target = spec.Exclusion
+	}
+	if spec.Mode == rbac.RbacConfig_ON || spec.Mode == rbac.RbacConfig_ON_WITH_EXCLUSION {
+		// An empty policy allows nothing
+		c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, "deny-all", c.rootNamespace,
+			&security.AuthorizationPolicy{})
+	}
+	if spec.Mode == rbac.RbacConfig_ON || target == nil {
+		return scope
+	}
+
+	for _, namespace := range target.Namespaces {
+		scope.namespaces[namespace] = true
+		if spec.Mode == rbac.RbacConfig_ON_WITH_INCLUSION {
+			c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, "deny-all", namespace,
+				&security.AuthorizationPolicy{})
+		} else {
+			c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, "allow-all", namespace,
+				&security.AuthorizationPolicy{Rules: []*security.Rule{{}}})
+		}
+	}
+	for _, service := range target.Services {
+		services := c.servicesMatching("", service)
+		if len(services) == 0 {
+			c.warnf(cfg, "service %s not found, not converted", service)
+		}
+		for _, svc := range services {
+			selector := workloadSelector(svc)
+			if selector == nil {
+				c.warnf(cfg, "service %s has no selector, not converted", c.hostname(svc))
+				continue
+			}
+			scope.services[svc.Namespace] = append(scope.services[svc.Namespace], svc)
+			if spec.Mode == rbac.RbacConfig_ON_WITH_INCLUSION {
+				c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, "deny-all-"+svc.Name, svc.Namespace,
+					&security.AuthorizationPolicy{Selector: selector})
+			} else {
+				c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, "allow-all-"+svc.Name, svc.Namespace,
+					&security.AuthorizationPolicy{Selector: selector, Rules: []*security.Rule{{}}})
+			}
+		}
+	}
+	return scope
+}
+
+// convertServiceRoles converts each ServiceRole and its bindings, or each binding with inline actions, into
+// authorization policies allowing the requests they match.
+func (c *securityConverter) convertServiceRoles(scope *rbacScope) {
+	bindings := map[string][]model.Config{}
+	for _, cfg := range c.resources.ServiceRoleBindings {
+		spec := cfg.Spec.(*rbac.ServiceRoleBinding)
+		if spec.Mode == rbac.EnforcementMode_PERMISSIVE {
+			c.warnf(cfg, "permissive binding has no effect on the requests, not converted")
+			continue
+		}
+		if len(spec.Actions) > 0 {
+			c.convertServiceRole(scope, cfg, spec.Actions, []model.Config{cfg})
+			continue
+		}
+		role := strings.TrimPrefix(spec.Role, "/")
+		if spec.RoleRef != nil {
+			role = spec.RoleRef.Name
+		}
+		key := cfg.Namespace + "/" + role
+		bindings[key] = append(bindings[key], cfg)
+	}
+
+	for _, cfg := range c.resources.ServiceRoles {
+		key := cfg.Namespace + "/" + cfg.Name
+		if len(bindings[key]) == 0 {
+			c.warnf(cfg, "not bound, not converted")
+			continue
+		}
+		c.convertServiceRole(scope, cfg, cfg.Spec.(*rbac.ServiceRole).Rules, bindings[key])
+		delete(bindings, key)
+	}
+	keys := make([]string, 0, len(bindings))
+	for key := range bindings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, cfg := range bindings[key] {
+			c.warnf(cfg, "ServiceRole %s not found, not converted", key)
+		}
+	}
+}
+
+// convertServiceRole converts the access rules granted to the subjects of the bindings. Each pair of a
+// subject and an access rule becomes a rule of the authorization policy of the services of the access rule.
+func (c *securityConverter) convertServiceRole(scope *rbacScope, cfg model.Config, accessRules []*rbac.AccessRule,
+	bindings []model.Config) {
+	all, enforced := scope.enforcedServices(cfg.Namespace)
+	if !all && len(enforced) == 0 {
+		c.warnf(cfg, "RBAC is not enabled for namespace %s, not converted", cfg.Namespace)
+		return
+	}
+
+	var subjects []subjectRule
+	for _, binding := range bindings {
+		for _, subject := range binding.Spec.(*rbac.ServiceRoleBinding).Subjects {
+			if s, ok := c.convertSubject(binding, subject); ok {
+				subjects = append(subjects, s)
+			}
+		}
+	}
+	if len(subjects) == 0 {
+		c.warnf(cfg, "no subject can be converted, not converted")
+		return
+	}
+
+	names := []string{}
+	policies := map[string]*security.AuthorizationPolicy{}
+	for _, accessRule := range accessRules {
+		to, when, ok := c.convertAccessRule(cfg, accessRule)
+		if !ok {
+			continue
+		}
+		for _, target := range c.roleTargets(cfg, accessRule.Services, all, enforced) {
+			name := cfg.Name
+			if target.name != "" {
+				name += "-" + target.name
+			}
+			policy, ok := policies[name]
+			if !ok {
+				policy = &security.AuthorizationPolicy{Selector: target.selector}
+				policies[name] = policy
+				names = append(names, name)
+			}
+			for _, subject := range subjects {
+				rule := &security.Rule{From: subject.from, To: to}
+				rule.When = append(append(rule.When, subject.when...), when...)
+				policy.Rules = append(policy.Rules, rule)
+			}
+		}
+	}
+	for _, name := range names {
+		c.add(collections.IstioSecurityV1Beta1Authorizationpolicies, name, cfg.Namespace, policies[name])
+	}
+}
+
+// roleTargets translates the services of an access rule into the workloads RBAC is enabled for.
+func (c *securityConverter) roleTargets(cfg model.Config, services []string, all bool,
+	enforced []*v1.Service) []roleTarget {
+	var targets []roleTarget
+	seen := map[string]bool{}
+	for _, service := range services {
+		if service == "*" && all {
+			return []roleTarget{{}}
+		}
+		matched := c.servicesMatching(cfg.Namespace, service)
+		if service != "*" && strings.Contains(service, "*") {
+			c.warnf(cfg, "services %s are expanded to the existing services", service)
+		}
+		if !all {
+			matched = intersectServices(matched, enforced)
+		}
+		if len(matched) == 0 {
+			c.warnf(cfg, "no service %s RBAC is enabled for in namespace %s", service, cfg.Namespace)
+		}
+		for _, svc := range matched {
+			if seen[svc.Name] {
+				continue
+			}
+			seen[svc.Name] = true
+			selector := workloadSelector(svc)
+			if selector == nil {
+				c.warnf(cfg, "service %s has no selector, not converted", c.hostname(svc))
+				continue
+			}
+			targets = append(targets, roleTarget{name: svc.Name, selector: selector})
+		}
+	}
+	return targets
+}
+
+func intersectServices(services, other []*v1.Service) []*v1.Service {
+	var out []*v1.Service
+	for _, svc := range services {
+		for _, o := range other {
+			if svc == o {
+				out = append(out, svc)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// convertSubject converts a subject of a binding into the sources and conditions of a rule.
+func (c *securityConverter) convertSubject(cfg model.Config, subject *rbac.Subject) (subjectRule, bool) {
+	source := &security.Source{
+		Principals:    subject.Names,
+		NotPrincipals: subject.NotNames,
+		Namespaces:    subject.Namespaces,
+		NotNamespaces: subject.NotNamespaces,
+		IpBlocks:      subject.Ips,
+		NotIpBlocks:   subject.NotIps,
+	}
+	// The user "*" matches all the requests, authenticated or not
+	if subject.User != "" && subject.User != "*" {
+		source.Principals = append([]string{subject.User}, source.Principals...)
+	}
+
+	var when []*security.Condition
+	groups := subject.Groups
+	if subject.Group != "" {
+		groups = append([]string{subject.Group}, groups...)
+	}
+	if len(groups) > 0 || len(subject.NotGroups) > 0 {
+		when = append(when, &security.Condition{Key: groupsClaim, Values: groups, NotValues: subject.NotGroups})
+	}
+
+	keys := make([]string, 0, len(subject.Properties))
+	for key := range subject.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := subject.Properties[key]
+		switch key {
+		case "source.principal":
+			source.Principals = append(source.Principals, value)
+		case "source.namespace":
+			source.Namespaces = append(source.Namespaces, value)
+		case "source.ip":
+			source.IpBlocks = append(source.IpBlocks, value)
+		case "request.auth.principal":
+			source.RequestPrincipals = append(source.RequestPrincipals, value)
+		default:
+			if err := securityattrs.ValidateAttribute(key, []string{value}); err != nil {
+				c.warnf(cfg, "subject property %s can't be converted (%v), subject not converted", key, err)
+				return subjectRule{}, false
+			}
+			when = append(when, &security.Condition{Key: key, Values: []string{value}})
+		}
+	}
+
+	rule := subjectRule{when: when}
+	if len(source.Principals) > 0 || len(source.NotPrincipals) > 0 || len(source.RequestPrincipals) > 0 ||
+		len(source.Namespaces) > 0 || len(source.NotNamespaces) > 0 ||
+		len(source.IpBlocks) > 0 || len(source.NotIpBlocks) > 0 {
+		rule.from = []*security.Rule_From{{Source: source}}
+	}
+	return rule, true
+}
+
+// convertAccessRule converts an access rule into the operations and conditions of a rule.
+func (c *securityConverter) convertAccessRule(cfg model.Config, rule *rbac.AccessRule) ([]*security.Rule_To,
+	[]*security.Condition, bool) {
+	operation := &security.Operation{
+		Hosts:      rule.Hosts,
+		NotHosts:   rule.NotHosts,
+		Paths:      rule.Paths,
+		NotPaths:   rule.NotPaths,
+		NotMethods: rule.NotMethods,
+		Ports:      portStrings(rule.Ports),
+		NotPorts:   portStrings(rule.NotPorts),
+	}
+	for _, method := range rule.Methods {
+		// The method "*" matches all the methods
+		if method == "*" {
+			operation.Methods = nil
+			break
+		}
+		operation.Methods = append(operation.Methods, method)
+	}
+
+	var when []*security.Condition
+	for _, constraint := range rule.Constraints {
+		if err := securityattrs.ValidateAttribute(constraint.Key, constraint.Values); err != nil {
+			c.warnf(cfg, "constraint %s can't be converted (%v), rule not converted", constraint.Key, err)
+			return nil, nil, false
+		}
+		when = append(when, &security.Condition{Key: constraint.Key, Values: constraint.Values})
+	}
+
+	if len(operation.Hosts) == 0 && len(operation.NotHosts) == 0 && len(operation.Paths) == 0 &&
+		len(operation.NotPaths) == 0 && len(operation.Methods) == 0 && len(operation.NotMethods) == 0 &&
+		len(operation.Ports) == 0 && len(operation.NotPorts) == 0 {
+		return nil, when, true
+	}
+	return []*security.Rule_To{{Operation: operation}}, when, true
+}
+
+func portStrings(ports []int32) []string {
+	var out []string
+	for _, port := range ports {
+		out = append(out, strconv.Itoa(int(port)))
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/util/gogoprotomarshal"
+)
+
+const (
+	// AuthenticationPolicyKind is the kind of the namespaced authentication.istio.io/v1alpha1 policies.
+	AuthenticationPolicyKind = "Policy"
+	// AuthenticationMeshPolicyKind is the kind of the mesh-wide authentication.istio.io/v1alpha1 policy.
+	AuthenticationMeshPolicyKind = "MeshPolicy"
+	// AuthenticationGroup is the API group of the authentication policies, which have no schema anymore.
+	AuthenticationGroup = "authentication.istio.io"
+)
+
+// SecurityResources are the deprecated security resources of a mesh, and the services they refer to.
+type SecurityResources struct {
+	// Policies are the Policy and MeshPolicy resources, with *authn.Policy specs.
+	Policies            []model.Config
+	ServiceRoles        []model.Config
+	ServiceRoleBindings []model.Config
+	// RbacConfigs are the ClusterRbacConfig resources, and the RbacConfig resources which preceded them.
+	RbacConfigs []model.Config
+	// Services translate the services the resources apply to into workload selectors.
+	Services []*v1.Service
+}
+
+// AddConfigs adds the RBAC resources among the configs, and returns the configs of other kinds.
+func (r *SecurityResources) AddConfigs(configs []model.Config) []model.Config {
+	var others []model.Config
+	for _, cfg := range configs {
+		switch cfg.Type {
+		case collections.IstioRbacV1Alpha1Serviceroles.Resource().Kind():
+			r.ServiceRoles = append(r.ServiceRoles, cfg)
+		case collections.IstioRbacV1Alpha1Servicerolebindings.Resource().Kind():
+			r.ServiceRoleBindings = append(r.ServiceRoleBindings, cfg)
+		case collections.IstioRbacV1Alpha1Clusterrbacconfigs.Resource().Kind(),
+			collections.IstioRbacV1Alpha1Rbacconfigs.Resource().Kind():
+			r.RbacConfigs = append(r.RbacConfigs, cfg)
+		default:
+			others = append(others, cfg)
+		}
+	}
+	return others
+}
+
+// AddObjects adds the authentication policies and the services among the objects, and returns the objects
+// of other kinds.
+func (r *SecurityResources) AddObjects(objects []crd.IstioKind) ([]crd.IstioKind, error) {
+	var others []crd.IstioKind
+	for _, obj := range objects {
+		group := strings.Split(obj.APIVersion, "/")[0]
+		switch {
+		case group == AuthenticationGroup &&
+			(obj.Kind == AuthenticationPolicyKind || obj.Kind == AuthenticationMeshPolicyKind):
+			policy, err := parseAuthenticationPolicy(obj)
+			if err != nil {
+				return nil, err
+			}
+			r.Policies = append(r.Policies, policy)
+		case obj.APIVersion == "v1" && obj.Kind == "Service":
+			// To convert to a v1.Service Marshal into JSON and Unmarshal back
+			b, err := json.Marshal(obj)
+			if err != nil {
+				return nil, fmt.Errorf("can't reserialize Service %s: %v", obj.Name, err)
+			}
+			svc := &v1.Service{}
+			if err := json.Unmarshal(b, svc); err != nil {
+				return nil, fmt.Errorf("can't deserialize as Service %s: %v", obj.Name, err)
+			}
+			r.Services = append(r.Services, svc)
+		default:
+			others = append(others, obj)
+		}
+	}
+	return others, nil
+}
+
+func parseAuthenticationPolicy(obj crd.IstioKind) (model.Config, error) {
+	b, err := json.Marshal(obj.Spec)
+	if err != nil {
+		return model.Config{}, fmt.Errorf("can't reserialize %s %s: %v", obj.Kind, obj.Name, err)
+	}
+	spec := &authn.Policy{}
+	if err := gogoprotomarshal.ApplyJSON(string(b), spec); err != nil {
+		return model.Config{}, fmt.Errorf("can't deserialize as %s %s: %v", obj.Kind, obj.Name, err)
+	}
+	return model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      obj.Kind,
+			Group:     AuthenticationGroup,
+			Version:   "v1alpha1",
+			Name:      obj.Name,
+			Namespace: obj.Namespace,
+		},
+		Spec: spec,
+	}, nil
+}
+
+// SecurityPolicies converts the authentication policies and the RBAC resources into PeerAuthentication,
+// RequestAuthentication and AuthorizationPolicy resources, on a best effort basis. Mesh-wide resources are
+// created in the root namespace. The returned warnings describe what could not be converted exactly.
+func SecurityPolicies(resources *SecurityResources, rootNamespace, domainSuffix string) ([]model.Config, []string) {
+	if len(domainSuffix) == 0 {
+		domainSuffix = "cluster.local"
+	}
+	c := &securityConverter{
+		resources:     resources,
+		rootNamespace: rootNamespace,
+		domainSuffix:  domainSuffix,
+		out:           make([]model.Config, 0),
+	}
+	c.convertAuthentication()
+	c.convertAuthorization()
+	return c.out, c.warnings
+}
+
+type securityConverter struct {
+	resources     *SecurityResources
+	rootNamespace string
+	domainSuffix  string
+
+	out      []model.Config
+	warnings []string
+}
+
+func (c *securityConverter) warnf(cfg model.Config, format string, args ...interface{}) {
+	name := cfg.Name
+	if cfg.Namespace != "" {
+		name = cfg.Namespace + "/" + name
+	}
+	c.warnings = append(c.warnings, fmt.Sprintf("%s %s: %s", cfg.Type, name, fmt.Sprintf(format, args...)))
+}
+
+func (c *securityConverter) add(s collection.Schema, name, namespace string, spec proto.Message) {
+	r := s.Resource()
+	c.out = append(c.out, model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      r.Kind(),
+			Group:     r.Group(),
+			Version:   r.Version(),
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: spec,
+	})
+}
+
+func (c *securityConverter) hostname(svc *v1.Service) string {
+	return fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, c.domainSuffix)
+}
+
+// service returns the service with the short name in the namespace, or nil.
+func (c *securityConverter) service(namespace, name string) *v1.Service {
+	for _, svc := range c.resources.Services {
+		if svc.Namespace == namespace && svc.Name == name {
+			return svc
+		}
+	}
+	return nil
+}
+
+// servicesMatching returns the services of the namespace, or of all the namespaces when empty, whose
+// hostname matches the pattern: an exact hostname, "*", or a hostname prefix or suffix with a wildcard.
+func (c *securityConverter) servicesMatching(namespace, pattern string) []*v1.Service {
+	var out []*v1.Service
+	for _, svc := range c.resources.Services {
+		if namespace != "" && svc.Namespace != namespace {
+			continue
+		}
+		host := c.hostname(svc)
+		switch {
+		case pattern == "*",
+			strings.HasPrefix(pattern, "*") && strings.HasSuffix(host, pattern[1:]),
+			strings.HasSuffix(pattern, "*") && strings.HasPrefix(host, pattern[:len(pattern)-1]),
+			pattern == host:
+			out = append(out, svc)
+		}
+	}
+	return out
+}
+
+// workloadSelector returns the selector of the workloads of the service, or nil when it selects none.
+func workloadSelector(svc *v1.Service) *typev1beta1.WorkloadSelector {
+	if len(svc.Spec.Selector) == 0 {
+		return nil
+	}
+	return &typev1beta1.WorkloadSelector{MatchLabels: svc.Spec.Selector}
+}
@@ -0,0 +1,330 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package convert
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	authn "istio.io/api/authentication/v1alpha1"
+	rbac "istio.io/api/rbac/v1alpha1"
+	security "istio.io/api/security/v1beta1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func config(kind, name, namespace string, spec proto.Message) model.Config {
+	return model.Config{ConfigMeta: model.ConfigMeta{Type: kind, Name: name, Namespace: namespace}, Spec: spec}
+}
+
+func service(name, namespace string) *v1.Service {
+	return &v1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: v1.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports:    []v1.ServicePort{{Name: "http", Port: 9080}},
+		},
+	}
+}
+
+func findConfig(configs []model.Config, kind, name, namespace string) proto.Message {
+	for _, cfg := range configs {
+		if cfg.Type == kind && cfg.Name == name && cfg.Namespace == namespace {
+			return cfg.Spec
+		}
+	}
+	return nil
+}
+
+func checkWarnings(t *testing.T, warnings []string, want ...string) {
+	t.Helper()
+	if len(warnings) != len(want) {
+		t.Fatalf("got warnings %q, want %d warnings", warnings, len(want))
+	}
+	for i, w := range want {
+		if !strings.Contains(warnings[i], w) {
+			t.Errorf("got warning %q, want it to contain %q", warnings[i], w)
+		}
+	}
+}
+
+var (
+	peerAuthentication    = collections.IstioSecurityV1Beta1Peerauthentications.Resource().Kind()
+	requestAuthentication = collections.IstioSecurityV1Beta1Requestauthentications.Resource().Kind()
+	authorizationPolicy   = collections.IstioSecurityV1Beta1Authorizationpolicies.Resource().Kind()
+)
+
+func TestAddObjects(t *testing.T) {
+	configs, kinds, err := crd.ParseInputsWithoutValidation(`apiVersion: authentication.istio.io/v1alpha1
+kind: Policy
+metadata:
+  name: default
+  namespace: foo
+spec:
+  peers:
+  - mtls:
+      mode: PERMISSIVE
+---
+apiVersion: rbac.istio.io/v1alpha1
+kind: ServiceRole
+metadata:
+  name: viewer
+  namespace: foo
+spec:
+  rules:
+  - services: ["*"]
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: productpage
+  namespace: foo
+spec:
+  selector:
+    app: productpage
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: productpage
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &SecurityResources{}
+	if others := r.AddConfigs(configs); len(others) != 0 {
+		t.Errorf("got other configs %v, want none", others)
+	}
+	others, err := r.AddObjects(kinds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(others) != 1 || others[0].Kind != "Deployment" {
+		t.Errorf("got other objects %v, want the deployment", others)
+	}
+	if len(r.ServiceRoles) != 1 || len(r.Services) != 1 || r.Services[0].Spec.Selector["app"] != "productpage" {
+		t.Errorf("got resources %+v, want a service role and a service", r)
+	}
+	want := &authn.Policy{Peers: []*authn.PeerAuthenticationMethod{{
+		Params: &authn.PeerAuthenticationMethod_Mtls{Mtls: &authn.MutualTls{Mode: authn.MutualTls_PERMISSIVE}},
+	}}}
+	if len(r.Policies) != 1 || r.Policies[0].Type != AuthenticationPolicyKind || !proto.Equal(r.Policies[0].Spec, want) {
+		t.Errorf("got policies %v, want %v", r.Policies, want)
+	}
+}
+
+func TestConvertAuthentication(t *testing.T) {
+	jwt := &authn.Jwt{
+		Issuer:     "issuer",
+		JwtHeaders: []string{"x-jwt"},
+		TriggerRules: []*authn.Jwt_TriggerRule{
+			{IncludedPaths: []*authn.StringMatch{{MatchType: &authn.StringMatch_Suffix{Suffix: ".html"}}}},
+			{IncludedPaths: []*authn.StringMatch{{MatchType: &authn.StringMatch_Regex{Regex: "/api/.*"}}}},
+		},
+	}
+	r := &SecurityResources{
+		Policies: []model.Config{
+			config(AuthenticationPolicyKind, "default", "foo", &authn.Policy{
+				Origins: []*authn.OriginAuthenticationMethod{{Jwt: &authn.Jwt{Issuer: "namespace"}}},
+			}),
+			config(AuthenticationPolicyKind, "optional", "foo", &authn.Policy{
+				Targets: []*authn.TargetSelector{{Name: "productpage"}, {Name: "missing"}},
+				Peers: []*authn.PeerAuthenticationMethod{{
+					Params: &authn.PeerAuthenticationMethod_Mtls{},
+				}},
+				PeerIsOptional:   true,
+				Origins:          []*authn.OriginAuthenticationMethod{{Jwt: jwt}},
+				OriginIsOptional: true,
+			}),
+			config(AuthenticationPolicyKind, "required", "foo", &authn.Policy{
+				Targets: []*authn.TargetSelector{{Name: "reviews"}},
+				Origins: []*authn.OriginAuthenticationMethod{{Jwt: jwt}},
+			}),
+		},
+		Services: []*v1.Service{service("productpage", "foo"), service("reviews", "foo")},
+	}
+
+	configs, warnings := SecurityPolicies(r, "istio-system", "")
+	checkWarnings(t, warnings,
+		"Policy foo/optional: the origins of the broader policies are not replaced",
+		"Policy foo/optional: service missing not found",
+		"Policy foo/required: the origins of the broader policies are not replaced",
+		`Policy foo/required: trigger rule with the regex path "/api/.*" can't be converted`)
+	if len(configs) != 8 {
+		t.Errorf("got %d configs, want 8: %v", len(configs), configs)
+	}
+
+	if got, want := findConfig(configs, peerAuthentication, "default", "foo"), (&security.PeerAuthentication{
+		Mtls: &security.PeerAuthentication_MutualTLS{Mode: security.PeerAuthentication_MutualTLS_DISABLE},
+	}); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := findConfig(configs, peerAuthentication, "optional-productpage", "foo"), (&security.PeerAuthentication{
+		Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "productpage"}},
+		Mtls:     &security.PeerAuthentication_MutualTLS{Mode: security.PeerAuthentication_MutualTLS_PERMISSIVE},
+	}); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := findConfig(configs, requestAuthentication, "optional-productpage", "foo"), (&security.RequestAuthentication{
+		Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "productpage"}},
+		JwtRules: []*security.JWTRule{{
+			Issuer:               "issuer",
+			FromHeaders:          []*security.JWTHeader{{Name: "x-jwt"}},
+			ForwardOriginalToken: true,
+		}},
+	}); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := findConfig(configs, authorizationPolicy, "optional-productpage-require-jwt", "foo"); got != nil {
+		t.Errorf("got %v, want no authorization policy for optional origins", got)
+	}
+	if got, want := findConfig(configs, authorizationPolicy, "required-reviews-require-jwt", "foo"), (&security.AuthorizationPolicy{
+		Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "reviews"}},
+		Action:   security.AuthorizationPolicy_DENY,
+		Rules: []*security.Rule{{
+			From: []*security.Rule_From{{Source: &security.Source{NotRequestPrincipals: []string{"*"}}}},
+			To:   []*security.Rule_To{{Operation: &security.Operation{Paths: []string{"*.html"}}}},
+		}},
+	}); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestConvertAuthorization(t *testing.T) {
+	clusterRbacConfig := collections.IstioRbacV1Alpha1Clusterrbacconfigs.Resource().Kind()
+	serviceRole := collections.IstioRbacV1Alpha1Serviceroles.Resource().Kind()
+	serviceRoleBinding := collections.IstioRbacV1Alpha1Servicerolebindings.Resource().Kind()
+	services := []*v1.Service{service("productpage", "foo"), service("reviews", "foo"), service("ratings", "bar")}
+	roles := []model.Config{
+		config(serviceRole, "viewer", "foo", &rbac.ServiceRole{Rules: []*rbac.AccessRule{{
+			Services: []string{"*"},
+			Methods:  []string{"GET"},
+			Ports:    []int32{9080},
+		}}}),
+		config(serviceRole, "viewer", "bar", &rbac.ServiceRole{Rules: []*rbac.AccessRule{{
+			Services: []string{"*"},
+		}}}),
+	}
+	bindings := []model.Config{
+		config(serviceRoleBinding, "viewer", "foo", &rbac.ServiceRoleBinding{
+			Subjects: []*rbac.Subject{{
+				Names:      []string{"cluster.local/ns/foo/sa/sleep"},
+				Properties: map[string]string{"request.auth.principal": "issuer/subject"},
+			}, {
+				Properties: map[string]string{"destination.name": "productpage"},
+			}},
+			RoleRef: &rbac.RoleRef{Kind: "ServiceRole", Name: "viewer"},
+		}),
+		config(serviceRoleBinding, "viewer", "bar", &rbac.ServiceRoleBinding{
+			Subjects: []*rbac.Subject{{User: "*"}},
+			RoleRef:  &rbac.RoleRef{Kind: "ServiceRole", Name: "viewer"},
+		}),
+		config(serviceRoleBinding, "permissive", "bar", &rbac.ServiceRoleBinding{
+			Subjects: []*rbac.Subject{{User: "*"}},
+			RoleRef:  &rbac.RoleRef{Kind: "ServiceRole", Name: "viewer"},
+			Mode:     rbac.EnforcementMode_PERMISSIVE,
+		}),
+	}
+
+	cases := []struct {
+		name         string
+		rbacConfig   *rbac.RbacConfig
+		wantWarnings []string
+		want         map[string]*security.AuthorizationPolicy
+	}{
+		{
+			name: "off",
+			rbacConfig: &rbac.RbacConfig{
+				Mode: rbac.RbacConfig_OFF,
+			},
+			wantWarnings: []string{"ClusterRbacConfig default: RBAC is disabled"},
+			want:         map[string]*security.AuthorizationPolicy{},
+		},
+		{
+			name: "inclusion",
+			rbacConfig: &rbac.RbacConfig{
+				Mode:      rbac.RbacConfig_ON_WITH_INCLUSION,
+				Inclusion: &rbac.RbacConfig_Target{Services: []string{"reviews.foo.svc.cluster.local"}},
+			},
+			wantWarnings: []string{
+				"ServiceRoleBinding bar/permissive: permissive binding has no effect",
+				"ServiceRoleBinding foo/viewer: subject property destination.name can't be converted",
+				"ServiceRole bar/viewer: RBAC is not enabled for namespace bar",
+			},
+			want: map[string]*security.AuthorizationPolicy{
+				"foo/deny-all-reviews": {
+					Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "reviews"}},
+				},
+				"foo/viewer-reviews": {
+					Selector: &typev1beta1.WorkloadSelector{MatchLabels: map[string]string{"app": "reviews"}},
+					Rules: []*security.Rule{{
+						From: []*security.Rule_From{{Source: &security.Source{
+							Principals:        []string{"cluster.local/ns/foo/sa/sleep"},
+							RequestPrincipals: []string{"issuer/subject"},
+						}}},
+						To: []*security.Rule_To{{Operation: &security.Operation{
+							Methods: []string{"GET"},
+							Ports:   []string{"9080"},
+						}}},
+					}},
+				},
+			},
+		},
+		{
+			name: "exclusion",
+			rbacConfig: &rbac.RbacConfig{
+				Mode:      rbac.RbacConfig_ON_WITH_EXCLUSION,
+				Exclusion: &rbac.RbacConfig_Target{Namespaces: []string{"foo"}},
+			},
+			wantWarnings: []string{
+				"ServiceRoleBinding bar/permissive: permissive binding has no effect",
+				"ServiceRole foo/viewer: RBAC is not enabled for namespace foo",
+			},
+			want: map[string]*security.AuthorizationPolicy{
+				"istio-system/deny-all": {},
+				"foo/allow-all":         {Rules: []*security.Rule{{}}},
+				"bar/viewer":            {Rules: []*security.Rule{{}}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := &SecurityResources{
+				RbacConfigs:         []model.Config{config(clusterRbacConfig, "default", "", c.rbacConfig)},
+				ServiceRoles:        roles,
+				ServiceRoleBindings: bindings,
+				Services:            services,
+			}
+			configs, warnings := SecurityPolicies(r, "istio-system", "cluster.local")
+			checkWarnings(t, warnings, c.wantWarnings...)
+			got := map[string]*security.AuthorizationPolicy{}
+			for _, cfg := range configs {
+				got[cfg.Namespace+"/"+cfg.Name] = cfg.Spec.(*security.AuthorizationPolicy)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -156,10 +156,9 @@ func (client *Client) AllPilotsDiscoveryDo(pilotNamespace, method, path string,
 	if len(pilots) == 0 {
 		return nil, errors.New("unable to find any Pilot instances")
 	}
-	cmd := []string{pilotDiscoveryPath, "request", method, path, string(body)}
 	result := map[string][]byte{}
 	for _, pilot := range pilots {
-		res, err := client.ExtractExecResult(pilot.Name, pilot.Namespace, discoveryContainer, cmd)
+		res, err := client.PilotDiscoveryPodDo(pilot.Name, pilot.Namespace, method, path, body)
 		if err != nil {
 			return nil, err
 		}
@@ -182,8 +181,13 @@ func (client *Client) PilotDiscoveryDo(pilotNamespace, method, path string, body
 	if len(pilots) == 0 {
 		return nil, errors.New("unable to find any Pilot instances")
 	}
+	return client.PilotDiscoveryPodDo(pilots[0].Name, pilots[0].Namespace, method, path, body)
+}
+
+// PilotDiscoveryPodDo makes an http request to the Pilot discovery instance in the specified pod
+func (client *Client) PilotDiscoveryPodDo(podName, podNamespace, method, path string, body []byte) ([]byte, error) {
 	cmd := []string{pilotDiscoveryPath, "request", method, path, string(body)}
-	return client.ExtractExecResult(pilots[0].Name, pilots[0].Namespace, discoveryContainer, cmd)
+	return client.ExtractExecResult(podName, podNamespace, discoveryContainer, cmd)
 }
 
 // EnvoyDo makes an http request to the Envoy in the specified pod
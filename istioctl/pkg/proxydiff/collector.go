@@ -0,0 +1,106 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxydiff
+
+import (
+	"sync"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/config/schema/snapshots"
+)
+
+// platformCollections are the collections of the resources other than the Istio configuration.
+var platformCollections = collection.Names{
+	collections.IstioMeshV1Alpha1MeshConfig.Name(),
+	collections.K8SCoreV1Services.Name(),
+	collections.K8SCoreV1Pods.Name(),
+}
+
+// Resources are the resources of a state of the mesh, by collection.
+type Resources map[collection.Name][]*resource.Instance
+
+// WithoutIstioConfig returns the resources other than the Istio configuration: the mesh config, Services
+// and Pods.
+func (r Resources) WithoutIstioConfig() Resources {
+	out := Resources{}
+	for _, c := range platformCollections {
+		if resources, ok := r[c]; ok {
+			out[c] = resources
+		}
+	}
+	return out
+}
+
+// Collector is an analyzer reporting no message, which collects the resources the proxy configuration
+// is generated from. It is run by a local.SourceAnalyzer to read the state of the mesh from its sources.
+type Collector struct {
+	mu        sync.Mutex
+	resources Resources
+}
+
+var _ analysis.Analyzer = &Collector{}
+
+// Metadata implements analysis.Analyzer
+func (c *Collector) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:        "proxydiff.Collector",
+		Description: "Collects the resources the proxy configuration is generated from",
+		Inputs:      inputs(),
+	}
+}
+
+// Analyze implements analysis.Analyzer
+func (c *Collector) Analyze(ctx analysis.Context) {
+	resources := Resources{}
+	for _, col := range c.Metadata().Inputs {
+		ctx.ForEach(col, func(r *resource.Instance) bool {
+			resources[col] = append(resources[col], r)
+			return true
+		})
+	}
+
+	c.mu.Lock()
+	c.resources = resources
+	c.mu.Unlock()
+}
+
+// Resources returns the resources collected by the last analysis.
+func (c *Collector) Resources() Resources {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resources
+}
+
+// inputs are the Istio configuration collections Pilot uses, along with the mesh config, Services and Pods.
+// The collections missing from the local analysis snapshot are left out, since the analyzers using them
+// are skipped.
+func inputs() collection.Names {
+	inSnapshot := map[string]bool{}
+	for _, c := range schema.MustGet().AllCollectionsInSnapshots([]string{snapshots.LocalAnalysis}) {
+		inSnapshot[c] = true
+	}
+
+	names := append(collection.Names{}, platformCollections...)
+	for _, s := range collections.Pilot.All() {
+		if inSnapshot[s.Name().String()] {
+			names = append(names, s.Name())
+		}
+	}
+	return names
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxydiff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContext is the number of unchanged lines printed around the changes of a resource.
+const diffContext = 3
+
+// Diff writes the changes of the proxy configuration from current to proposed, and returns whether
+// there are any. The resources added or removed are listed by name, and the changed ones are followed
+// by the unified diff of their JSON. A nil configuration has no resources.
+func Diff(w io.Writer, current, proposed *ProxyConfig) (bool, error) {
+	sections := []struct {
+		title             string
+		current, proposed map[string]proto.Message
+	}{
+		{"Listeners", listeners(current), listeners(proposed)},
+		{"Routes", routes(current), routes(proposed)},
+		{"Clusters", clusters(current), clusters(proposed)},
+	}
+
+	changed := false
+	for _, s := range sections {
+		var b bytes.Buffer
+		if err := diffResources(&b, s.current, s.proposed); err != nil {
+			return false, err
+		}
+		if b.Len() > 0 {
+			changed = true
+			fmt.Fprintf(w, "%s:\n%s", s.title, b.String())
+		}
+	}
+	return changed, nil
+}
+
+func diffResources(w io.Writer, current, proposed map[string]proto.Message) error {
+	names := make([]string, 0, len(current)+len(proposed))
+	for name := range current {
+		names = append(names, name)
+	}
+	for name := range proposed {
+		if _, ok := current[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		a, err := toJSON(current[name])
+		if err != nil {
+			return err
+		}
+		b, err := toJSON(proposed[name])
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case a == b:
+			continue
+		case a == "":
+			fmt.Fprintf(w, "  + %s\n", name)
+		case b == "":
+			fmt.Fprintf(w, "  - %s\n", name)
+		default:
+			fmt.Fprintf(w, "  ~ %s\n", name)
+			text, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+				FromFile: "current",
+				A:        difflib.SplitLines(a),
+				ToFile:   "proposed",
+				B:        difflib.SplitLines(b),
+				Context:  diffContext,
+			})
+			if err != nil {
+				return err
+			}
+			for _, line := range strings.SplitAfter(strings.TrimSuffix(text, "\n"), "\n") {
+				fmt.Fprintf(w, "      %s", line)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+func toJSON(m proto.Message) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	jsonm := &jsonpb.Marshaler{Indent: "  "}
+	s, err := jsonm.MarshalToString(m)
+	if err != nil {
+		return "", err
+	}
+	return s + "\n", nil
+}
+
+func listeners(c *ProxyConfig) map[string]proto.Message {
+	out := map[string]proto.Message{}
+	if c != nil {
+		for _, l := range c.Listeners {
+			out[l.Name] = l
+		}
+	}
+	return out
+}
+
+func routes(c *ProxyConfig) map[string]proto.Message {
+	out := map[string]proto.Message{}
+	if c != nil {
+		for _, r := range c.Routes {
+			out[r.Name] = r
+		}
+	}
+	return out
+}
+
+func clusters(c *ProxyConfig) map[string]proto.Message {
+	out := map[string]proto.Message{}
+	if c != nil {
+		for _, cl := range c.Clusters {
+			out[cl.Name] = cl
+		}
+	}
+	return out
+}
@@ -0,0 +1,192 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxydiff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	"github.com/gogo/protobuf/types"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/local"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+// reviewsFile is the reviews Service and Pod as read from a file, where the target port is not defaulted
+const reviewsFile = `apiVersion: v1
+kind: Service
+metadata:
+  name: reviews
+  namespace: default
+spec:
+  selector:
+    app: reviews
+  ports:
+  - name: http
+    port: 9080
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: reviews-v1
+  namespace: default
+  labels:
+    app: reviews
+    version: v1
+spec:
+  containers:
+  - name: app
+    image: reviews
+    ports:
+    - containerPort: 9080
+  - name: istio-proxy
+    image: docker.io/istio/proxyv2:1.6.0
+    args: ["proxy", "sidecar"]
+status:
+  podIP: 10.0.0.5
+`
+
+func bookinfo() Resources {
+	return Resources{
+		collections.K8SCoreV1Services.Name(): {{
+			Metadata: resource.Metadata{FullName: resource.NewFullName("default", "reviews")},
+			Message: &v1.ServiceSpec{
+				Selector: map[string]string{"app": "reviews"},
+				Ports:    []v1.ServicePort{{Name: "http", Port: 9080}},
+			},
+		}},
+		collections.K8SCoreV1Pods.Name(): {
+			pod("reviews-v1", map[string]string{"app": "reviews", "version": "v1"}, "10.0.0.5"),
+			pod("productpage-v1", map[string]string{"app": "productpage"}, "10.0.0.6"),
+		},
+	}
+}
+
+func pod(name string, labels map[string]string, ip string) *resource.Instance {
+	return &resource.Instance{
+		Metadata: resource.Metadata{FullName: resource.NewFullName("default", resource.LocalName(name))},
+		Message: &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: v1.PodSpec{
+				Containers: []v1.Container{
+					{Name: "app", Ports: []v1.ContainerPort{{ContainerPort: 9080}}},
+					{Name: istioProxyName, Image: "docker.io/istio/proxyv2:1.6.0", Args: []string{"proxy", "sidecar"}},
+				},
+			},
+			Status: v1.PodStatus{PodIP: ip},
+		},
+	}
+}
+
+func withTimeout(r Resources) Resources {
+	out := Resources{}
+	for c, resources := range r {
+		out[c] = resources
+	}
+	out[collections.IstioNetworkingV1Alpha3Virtualservices.Name()] = []*resource.Instance{{
+		Metadata: resource.Metadata{FullName: resource.NewFullName("default", "reviews")},
+		Message: &networking.VirtualService{
+			Hosts: []string{"reviews"},
+			Http: []*networking.HTTPRoute{{
+				Timeout: types.DurationProto(5e9),
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "reviews"},
+				}},
+			}},
+		},
+	}}
+	return out
+}
+
+func generate(g *GomegaWithT, r Resources, workload string) *ProxyConfig {
+	gen, err := NewGenerator(r)
+	g.Expect(err).To(BeNil())
+	cfg, err := gen.Generate(workload)
+	g.Expect(err).To(BeNil())
+	return cfg
+}
+
+func TestGenerator(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	gen, err := NewGenerator(bookinfo())
+	g.Expect(err).To(BeNil())
+	g.Expect(gen.Workloads()).To(Equal([]string{"productpage-v1.default", "reviews-v1.default"}))
+
+	cfg, err := gen.Generate("reviews-v1.default")
+	g.Expect(err).To(BeNil())
+	g.Expect(clusters(cfg)).To(HaveKey("inbound|9080|http|reviews.default.svc.cluster.local"))
+	g.Expect(clusters(cfg)).To(HaveKey("outbound|9080||reviews.default.svc.cluster.local"))
+	g.Expect(routes(cfg)).To(HaveKey("9080"))
+
+	cfg, err = gen.Generate("missing.default")
+	g.Expect(err).To(BeNil())
+	g.Expect(cfg).To(BeNil())
+}
+
+func TestDiff(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	current := generate(g, bookinfo(), "productpage-v1.default")
+	proposed := generate(g, withTimeout(bookinfo()), "productpage-v1.default")
+
+	var out bytes.Buffer
+	changed, err := Diff(&out, current, current)
+	g.Expect(err).To(BeNil())
+	g.Expect(changed).To(BeFalse())
+	g.Expect(out.String()).To(BeEmpty())
+
+	changed, err = Diff(&out, current, proposed)
+	g.Expect(err).To(BeNil())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(out.String()).To(ContainSubstring("Routes:\n  ~ 9080\n"))
+	g.Expect(out.String()).To(ContainSubstring(`+            "timeout": "5s",`))
+	g.Expect(out.String()).NotTo(ContainSubstring("Listeners:"))
+
+	out.Reset()
+	changed, err = Diff(&out, nil, current)
+	g.Expect(err).To(BeNil())
+	g.Expect(changed).To(BeTrue())
+	g.Expect(out.String()).To(ContainSubstring("  + outbound|9080||reviews.default.svc.cluster.local\n"))
+}
+
+func TestGeneratorDefaultsTargetPortsOfFiles(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	collector := &Collector{}
+	sa := local.NewSourceAnalyzer(schema.MustGet(), analysis.Combine("proxydiff", collector),
+		"", "istio-system", nil, true, time.Minute)
+	g.Expect(sa.AddReaderKubeSource([]local.ReaderSource{{Name: "reviews.yaml", Reader: strings.NewReader(reviewsFile)}})).To(Succeed())
+	_, err := sa.Analyze(make(chan struct{}))
+	g.Expect(err).To(BeNil())
+
+	cfg := generate(g, collector.Resources(), "reviews-v1.default")
+	g.Expect(cfg).NotTo(BeNil())
+	g.Expect(clusters(cfg)).To(HaveKey("inbound|9080|http|reviews.default.svc.cluster.local"))
+	inbound := clusters(cfg)["inbound|9080|http|reviews.default.svc.cluster.local"].(*xdsapi.Cluster)
+	endpoint := inbound.LoadAssignment.Endpoints[0].LbEndpoints[0].GetEndpoint()
+	g.Expect(endpoint.Address.GetSocketAddress().GetPortValue()).To(Equal(uint32(9080)))
+}
@@ -0,0 +1,266 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxydiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	http_conn "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/ptypes"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/networking/core"
+	"istio.io/istio/pilot/pkg/networking/plugin"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
+	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pkg/config/mesh"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+const (
+	domainSuffix   = "cluster.local"
+	istioProxyName = "istio-proxy"
+)
+
+// plugins are the networking plugins Pilot enables by default.
+var plugins = []string{plugin.Authn, plugin.Authz, plugin.Health, plugin.Mixer}
+
+// ProxyConfig is the xDS configuration generated for a proxy.
+type ProxyConfig struct {
+	Listeners []*xdsapi.Listener
+	Routes    []*xdsapi.RouteConfiguration
+	Clusters  []*xdsapi.Cluster
+}
+
+// Generator generates offline the configuration Pilot would push to the proxies, for a state of the mesh.
+type Generator struct {
+	env       *model.Environment
+	configgen core.ConfigGenerator
+	pods      map[string]*v1.Pod
+}
+
+// NewGenerator creates a Generator for the state of the mesh made of the given resources. The Istio
+// configurations failing validation are skipped, since Pilot would not get them.
+func NewGenerator(r Resources) (*Generator, error) {
+	meshConfig := mesh.DefaultMeshConfig()
+	m := &meshConfig
+	for _, res := range r[collections.IstioMeshV1Alpha1MeshConfig.Name()] {
+		m = res.Message.(*meshconfig.MeshConfig)
+	}
+
+	store := memory.Make(collections.Pilot)
+	for _, s := range collections.Pilot.All() {
+		for _, res := range r[s.Name()] {
+			cfg := model.Config{
+				ConfigMeta: model.ConfigMeta{
+					Type:              s.Resource().Kind(),
+					Group:             s.Resource().Group(),
+					Version:           s.Resource().Version(),
+					Name:              res.Metadata.FullName.Name.String(),
+					Namespace:         res.Metadata.FullName.Namespace.String(),
+					Domain:            domainSuffix,
+					Labels:            res.Metadata.Labels,
+					Annotations:       res.Metadata.Annotations,
+					CreationTimestamp: res.Metadata.CreateTime,
+				},
+				Spec: res.Message,
+			}
+			if _, err := store.Create(cfg); err != nil {
+				log.Warnf("skipping %s %s: %v", s.Resource().Kind(), res.Metadata.FullName, err)
+			}
+		}
+	}
+	configStore := model.MakeIstioStore(store)
+
+	var services []*v1.Service
+	for _, res := range r[collections.K8SCoreV1Services.Name()] {
+		services = append(services, &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              res.Metadata.FullName.Name.String(),
+				Namespace:         res.Metadata.FullName.Namespace.String(),
+				Labels:            res.Metadata.Labels,
+				Annotations:       res.Metadata.Annotations,
+				CreationTimestamp: metav1.NewTime(res.Metadata.CreateTime),
+			},
+			Spec: *res.Message.(*v1.ServiceSpec),
+		})
+	}
+	var pods []*v1.Pod
+	g := &Generator{
+		configgen: core.NewConfigGenerator(plugins),
+		pods:      map[string]*v1.Pod{},
+	}
+	for _, res := range r[collections.K8SCoreV1Pods.Name()] {
+		// The namespace of the pods read from files is defaulted in the resource metadata only
+		pod := res.Message.(*v1.Pod).DeepCopy()
+		pod.Name = res.Metadata.FullName.Name.String()
+		pod.Namespace = res.Metadata.FullName.Namespace.String()
+		pods = append(pods, pod)
+		if hasProxy(pod) && pod.Status.PodIP != "" {
+			g.pods[fmt.Sprintf("%s.%s", pod.Name, pod.Namespace)] = pod
+		}
+	}
+
+	registries := aggregate.NewController()
+	registries.AddRegistry(serviceregistry.Simple{
+		ProviderID:       serviceregistry.Kubernetes,
+		ServiceDiscovery: newKubeRegistry(services, pods, domainSuffix),
+	})
+	serviceEntries := external.NewServiceDiscovery(nil, configStore, nil)
+	registries.AddRegistry(serviceregistry.Simple{
+		ProviderID:       serviceregistry.External,
+		Controller:       serviceEntries,
+		ServiceDiscovery: serviceEntries,
+	})
+
+	g.env = &model.Environment{
+		ServiceDiscovery: registries,
+		IstioConfigStore: configStore,
+		Watcher:          mesh.NewFixedWatcher(m),
+		PushContext:      model.NewPushContext(),
+		DomainSuffix:     domainSuffix,
+	}
+	if err := g.env.PushContext.InitContext(g.env, nil, nil); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Workloads returns the names of the pods running a proxy, in the pod.namespace format.
+func (g *Generator) Workloads() []string {
+	names := make([]string, 0, len(g.pods))
+	for name := range g.pods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate returns the configuration of the proxy of the workload, or nil if the workload is not running
+// a proxy in this state of the mesh.
+func (g *Generator) Generate(workload string) (*ProxyConfig, error) {
+	pod, ok := g.pods[workload]
+	if !ok {
+		return nil, nil
+	}
+
+	proxy := &model.Proxy{
+		Type:            model.SidecarProxy,
+		IPAddresses:     []string{pod.Status.PodIP},
+		ID:              workload,
+		DNSDomain:       fmt.Sprintf("%s.svc.%s", pod.Namespace, domainSuffix),
+		ConfigNamespace: pod.Namespace,
+		IstioVersion:    model.ParseIstioVersion(proxyVersion(pod)),
+		Metadata: &model.NodeMetadata{
+			Namespace:       pod.Namespace,
+			ConfigNamespace: pod.Namespace,
+			Labels:          pod.Labels,
+			InstanceIPs:     []string{pod.Status.PodIP},
+			ServiceAccount:  pod.Spec.ServiceAccountName,
+		},
+	}
+	if isRouter(pod) {
+		proxy.Type = model.Router
+	}
+
+	push := g.env.PushContext
+	if err := proxy.SetWorkloadLabels(g.env); err != nil {
+		return nil, err
+	}
+	if err := proxy.SetServiceInstances(g.env); err != nil {
+		return nil, err
+	}
+	proxy.SetSidecarScope(push)
+	proxy.SetGatewaysForProxy(push)
+	proxy.DiscoverIPVersions()
+
+	listeners := g.configgen.BuildListeners(proxy, push)
+	return &ProxyConfig{
+		Listeners: listeners,
+		Routes:    g.configgen.BuildHTTPRoutes(proxy, push, routeNames(listeners)),
+		Clusters:  g.configgen.BuildClusters(proxy, push),
+	}, nil
+}
+
+func hasProxy(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == istioProxyName {
+			return true
+		}
+	}
+	return false
+}
+
+// isRouter returns whether the proxy of the pod runs as a gateway, with `pilot-agent proxy router`.
+func isRouter(pod *v1.Pod) bool {
+	for _, c := range pod.Spec.Containers {
+		if c.Name != istioProxyName {
+			continue
+		}
+		for i, arg := range c.Args {
+			if arg == "proxy" && i+1 < len(c.Args) {
+				return c.Args[i+1] == string(model.Router)
+			}
+		}
+	}
+	return false
+}
+
+// proxyVersion returns the tag of the proxy image, which the version is parsed from.
+func proxyVersion(pod *v1.Pod) string {
+	for _, c := range pod.Spec.Containers {
+		if c.Name == istioProxyName {
+			if i := strings.LastIndex(c.Image, ":"); i >= 0 {
+				return c.Image[i+1:]
+			}
+		}
+	}
+	return ""
+}
+
+// routeNames returns the names of the routes the HTTP connection managers of the listeners get with RDS.
+func routeNames(listeners []*xdsapi.Listener) []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, l := range listeners {
+		for _, fc := range l.FilterChains {
+			for _, filter := range fc.Filters {
+				if filter.Name != wellknown.HTTPConnectionManager {
+					continue
+				}
+				hcm := &http_conn.HttpConnectionManager{}
+				if err := ptypes.UnmarshalAny(filter.GetTypedConfig(), hcm); err != nil {
+					continue
+				}
+				if rds := hcm.GetRds(); rds != nil && !seen[rds.RouteConfigName] {
+					seen[rds.RouteConfigName] = true
+					names = append(names, rds.RouteConfigName)
+				}
+			}
+		}
+	}
+	return names
+}
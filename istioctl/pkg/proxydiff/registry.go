@@ -0,0 +1,182 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxydiff
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube"
+	"istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+)
+
+// kubeRegistry is a static service registry of the Kubernetes Services and Pods of a state of the mesh.
+// The service instances are the Pods selected by the Services, as the Endpoints would list them.
+type kubeRegistry struct {
+	services      map[host.Name]*model.Service
+	instances     map[host.Name][]*model.ServiceInstance
+	podsByIP      map[string]*v1.Pod
+	instancesByIP map[string][]*model.ServiceInstance
+	serviceList   []*model.Service
+}
+
+var _ model.ServiceDiscovery = &kubeRegistry{}
+
+func newKubeRegistry(services []*v1.Service, pods []*v1.Pod, domainSuffix string) *kubeRegistry {
+	r := &kubeRegistry{
+		services:      map[host.Name]*model.Service{},
+		instances:     map[host.Name][]*model.ServiceInstance{},
+		podsByIP:      map[string]*v1.Pod{},
+		instancesByIP: map[string][]*model.ServiceInstance{},
+	}
+	for _, pod := range pods {
+		if pod.Status.PodIP != "" {
+			r.podsByIP[pod.Status.PodIP] = pod
+		}
+	}
+
+	for _, svc := range services {
+		s := kube.ConvertService(*svc, domainSuffix, "")
+		r.services[s.Hostname] = s
+		r.serviceList = append(r.serviceList, s)
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+
+		selector := klabels.SelectorFromSet(svc.Spec.Selector)
+		for _, pod := range pods {
+			if pod.Namespace != svc.Namespace || pod.Status.PodIP == "" || !selector.Matches(klabels.Set(pod.Labels)) {
+				continue
+			}
+			for _, instance := range serviceInstances(s, svc, pod) {
+				r.instances[s.Hostname] = append(r.instances[s.Hostname], instance)
+				r.instancesByIP[pod.Status.PodIP] = append(r.instancesByIP[pod.Status.PodIP], instance)
+			}
+		}
+	}
+	sort.Slice(r.serviceList, func(i, j int) bool {
+		return r.serviceList[i].Hostname < r.serviceList[j].Hostname
+	})
+	return r
+}
+
+// serviceInstances returns the instances of the service for the ports of the pod, as the Kubernetes
+// registry does.
+func serviceInstances(s *model.Service, svc *v1.Service, pod *v1.Pod) []*model.ServiceInstance {
+	var out []*model.ServiceInstance
+	for i := range svc.Spec.Ports {
+		port := svc.Spec.Ports[i]
+		servicePort, ok := s.Ports.Get(port.Name)
+		if !ok {
+			continue
+		}
+		// The API server defaults the target port to the port, which Services read from files may omit
+		if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+			port.TargetPort = intstr.FromInt(int(port.Port))
+		}
+		targetPort, err := controller.FindPort(pod, &port)
+		if err != nil {
+			continue
+		}
+		out = append(out, &model.ServiceInstance{
+			Service:     s,
+			ServicePort: servicePort,
+			Endpoint: &model.IstioEndpoint{
+				Labels:          pod.Labels,
+				UID:             fmt.Sprintf("kubernetes://%s.%s", pod.Name, pod.Namespace),
+				ServiceAccount:  kube.SecureNamingSAN(pod),
+				TLSMode:         kube.PodTLSMode(pod),
+				Address:         pod.Status.PodIP,
+				EndpointPort:    uint32(targetPort),
+				ServicePortName: servicePort.Name,
+			},
+		})
+	}
+	return out
+}
+
+// Services implements model.ServiceDiscovery
+func (r *kubeRegistry) Services() ([]*model.Service, error) {
+	return r.serviceList, nil
+}
+
+// GetService implements model.ServiceDiscovery
+func (r *kubeRegistry) GetService(hostname host.Name) (*model.Service, error) {
+	return r.services[hostname], nil
+}
+
+// InstancesByPort implements model.ServiceDiscovery
+func (r *kubeRegistry) InstancesByPort(svc *model.Service, port int, labels labels.Collection) ([]*model.ServiceInstance, error) {
+	var out []*model.ServiceInstance
+	for _, instance := range r.instances[svc.Hostname] {
+		if instance.ServicePort.Port == port && labels.HasSubsetOf(instance.Endpoint.Labels) {
+			out = append(out, instance)
+		}
+	}
+	return out, nil
+}
+
+// GetProxyServiceInstances implements model.ServiceDiscovery
+func (r *kubeRegistry) GetProxyServiceInstances(proxy *model.Proxy) ([]*model.ServiceInstance, error) {
+	var out []*model.ServiceInstance
+	for _, ip := range proxy.IPAddresses {
+		out = append(out, r.instancesByIP[ip]...)
+	}
+	return out, nil
+}
+
+// GetProxyWorkloadLabels implements model.ServiceDiscovery
+func (r *kubeRegistry) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collection, error) {
+	for _, ip := range proxy.IPAddresses {
+		if pod, ok := r.podsByIP[ip]; ok {
+			return labels.Collection{pod.Labels}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ManagementPorts implements model.ServiceDiscovery
+func (r *kubeRegistry) ManagementPorts(string) model.PortList {
+	return nil
+}
+
+// WorkloadHealthCheckInfo implements model.ServiceDiscovery
+func (r *kubeRegistry) WorkloadHealthCheckInfo(string) model.ProbeList {
+	return nil
+}
+
+// GetIstioServiceAccounts implements model.ServiceDiscovery
+func (r *kubeRegistry) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, port := range ports {
+		instances, _ := r.InstancesByPort(svc, port, nil)
+		for _, instance := range instances {
+			if sa := instance.Endpoint.ServiceAccount; sa != "" && !seen[sa] {
+				seen[sa] = true
+				out = append(out, sa)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,142 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+func testMessages() diag.Messages {
+	errType := diag.NewMessageType(diag.Error, "IST-0042", "Cheese type not found: %q")
+	infoType := diag.NewMessageType(diag.Info, "IST-0043", "Cheese is %s")
+	r := &resource.Instance{
+		Origin: &rt.Origin{
+			Collection: collections.IstioNetworkingV1Alpha3Virtualservices.Name(),
+			Kind:       "VirtualService",
+			FullName:   resource.NewFullName("default", "pizza"),
+			Ref:        &rt.Position{Filename: "toppings.yaml", Line: 12},
+		},
+	}
+
+	feta := diag.NewMessage(errType, r, "Feta")
+	feta.DocRef = "istioctl-analyze"
+	return diag.Messages{
+		feta,
+		diag.NewMessage(errType, nil, "Brie"),
+		diag.NewMessage(infoType, r, "good"),
+	}
+}
+
+func TestSARIF(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	out, err := SARIF(testMessages(), "1.6.0")
+	g.Expect(err).To(BeNil())
+
+	var log sarifLog
+	g.Expect(json.Unmarshal(out, &log)).To(Succeed())
+	g.Expect(log.Version).To(Equal("2.1.0"))
+	g.Expect(log.Runs).To(HaveLen(1))
+
+	run := log.Runs[0]
+	g.Expect(run.Tool.Driver.Version).To(Equal("1.6.0"))
+	g.Expect(run.Tool.Driver.Rules).To(Equal([]sarifRule{
+		{
+			ID:                   "IST-0042",
+			ShortDescription:     sarifText{Text: "Cheese type not found: %q"},
+			HelpURI:              "https://istio.io/docs/reference/config/analysis/IST-0042",
+			DefaultConfiguration: sarifConfiguration{Level: "error"},
+		},
+		{
+			ID:                   "IST-0043",
+			ShortDescription:     sarifText{Text: "Cheese is %s"},
+			HelpURI:              "https://istio.io/docs/reference/config/analysis/IST-0043",
+			DefaultConfiguration: sarifConfiguration{Level: "note"},
+		},
+	}))
+
+	g.Expect(run.Results).To(HaveLen(3))
+	g.Expect(run.Results[0]).To(Equal(sarifResult{
+		RuleID:    "IST-0042",
+		RuleIndex: 0,
+		Level:     "error",
+		Message:   sarifText{Text: `Cheese type not found: "Feta"`},
+		Locations: []sarifLocation{{
+			PhysicalLocation: &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: "toppings.yaml"},
+				Region:           &sarifRegion{StartLine: 12},
+			},
+			LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: "VirtualService pizza.default", Kind: "resource"}},
+		}},
+	}))
+	g.Expect(run.Results[1].Locations).To(BeEmpty())
+	g.Expect(run.Results[2].RuleIndex).To(Equal(1))
+	g.Expect(run.Results[2].Level).To(Equal("note"))
+}
+
+func TestSARIFWithoutMessages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	out, err := SARIF(nil, "")
+	g.Expect(err).To(BeNil())
+	g.Expect(string(out)).To(ContainSubstring(`"results": []`))
+}
+
+func TestJUnit(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	out, err := JUnit(testMessages(), diag.Warning)
+	g.Expect(err).To(BeNil())
+
+	var suites junitTestSuites
+	g.Expect(xml.Unmarshal(out, &suites)).To(Succeed())
+	g.Expect(suites.Tests).To(Equal(3))
+	g.Expect(suites.Failures).To(Equal(2))
+	g.Expect(suites.Suites).To(HaveLen(1))
+
+	cases := suites.Suites[0].TestCases
+	g.Expect(cases).To(HaveLen(3))
+	g.Expect(cases[0].Name).To(Equal("IST-0042"))
+	g.Expect(cases[0].ClassName).To(Equal("VirtualService pizza.default"))
+	g.Expect(cases[0].File).To(Equal("toppings.yaml"))
+	g.Expect(cases[0].Line).To(Equal(12))
+	g.Expect(cases[0].Failure.Message).To(Equal(`Cheese type not found: "Feta"`))
+	g.Expect(cases[0].Failure.Details).To(ContainSubstring(
+		"https://istio.io/docs/reference/config/analysis/IST-0042?ref=istioctl-analyze"))
+	g.Expect(cases[1].ClassName).To(Equal(ToolName))
+	g.Expect(cases[2].Failure).To(BeNil())
+	g.Expect(cases[2].SystemOut).To(ContainSubstring("Cheese is good"))
+}
+
+func TestJUnitWithoutMessages(t *testing.T) {
+	g := NewGomegaWithT(t)
+
+	out, err := JUnit(nil, diag.Warning)
+	g.Expect(err).To(BeNil())
+
+	var suites junitTestSuites
+	g.Expect(xml.Unmarshal(out, &suites)).To(Succeed())
+	g.Expect(suites.Tests).To(Equal(1))
+	g.Expect(suites.Failures).To(Equal(0))
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package formatting
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+)
+
+// The subset of the JUnit XML format produced for validation messages
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Type    string `xml:"type,attr"`
+	Message string `xml:"message,attr"`
+	Details string `xml:",chardata"`
+}
+
+// JUnit formats the messages as a JUnit XML report, with a test case per message. The messages
+// worse than or equal to the failure level are failures. Without messages, the report has a single
+// passing test case, so that successful analyses are recorded.
+func JUnit(messages diag.Messages, failureLevel diag.Level) ([]byte, error) {
+	suite := junitTestSuite{Name: ToolName}
+	for _, m := range messages {
+		tc := junitTestCase{
+			Name:      m.Type.Code(),
+			ClassName: ToolName,
+		}
+		if m.Resource != nil {
+			tc.ClassName = m.Resource.Origin.FriendlyName()
+			tc.File, tc.Line = position(m)
+		}
+		details := fmt.Sprintf("%s\n%s", m.String(), m.DocumentationURL())
+		if m.Type.Level().IsWorseThanOrEqualTo(failureLevel) {
+			tc.Failure = &junitFailure{
+				Type:    m.Type.Level().String(),
+				Message: text(m),
+				Details: details,
+			}
+			suite.Failures++
+		} else {
+			tc.SystemOut = details
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	if len(suite.TestCases) == 0 {
+		suite.TestCases = []junitTestCase{{Name: "analysis", ClassName: ToolName}}
+	}
+	suite.Tests = len(suite.TestCases)
+
+	out, err := xml.MarshalIndent(junitTestSuites{
+		Name:     ToolName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,818 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdump
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	xdsapi "github.com/envoyproxy/go-control-plane/envoy/api/v2"
+	core "github.com/envoyproxy/go-control-plane/envoy/api/v2/core"
+	listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
+	route "github.com/envoyproxy/go-control-plane/envoy/api/v2/route"
+	rbac_http_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/http/rbac/v2"
+	hcm_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/http_connection_manager/v2"
+	rbac_tcp_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/rbac/v2"
+	tcp_proxy "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/tcp_proxy/v2"
+	rbacpb "github.com/envoyproxy/go-control-plane/envoy/config/rbac/v2"
+	"github.com/envoyproxy/go-control-plane/pkg/conversion"
+	"github.com/envoyproxy/go-control-plane/pkg/wellknown"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+
+	"istio.io/istio/istioctl/pkg/authz"
+	"istio.io/istio/istioctl/pkg/util/clusters"
+)
+
+const (
+	virtualInboundListener  = "virtualInbound"
+	virtualOutboundListener = "virtualOutbound"
+)
+
+// Explain prints how the proxy handles the request: the listener and filter chain the connection is
+// matched to, the RBAC decisions, the virtual host and route of HTTP requests, and the clusters the
+// request is sent to. The endpoints of the clusters are printed from endpoints, when it is not nil.
+func (c *ConfigWriter) Explain(r *authz.Request, endpoints *clusters.Wrapper) error {
+	listeners, err := c.retrieveSortedListenerSlice()
+	if err != nil {
+		return err
+	}
+	e := &explainer{
+		Writer:    c.Stdout,
+		config:    c,
+		request:   r,
+		endpoints: endpoints,
+	}
+
+	e.printRequest()
+	l, how := e.selectListener(listeners)
+	if l == nil {
+		e.printf("Listener: none, the connection is refused\n")
+		return nil
+	}
+	e.printf("Listener: %s (%s)\n", l.Name, how)
+
+	fc, how := selectFilterChain(l, r)
+	if fc == nil {
+		e.printf("Filter chain: none, the connection is closed\n")
+		return nil
+	}
+	e.printf("Filter chain: %s (%s)\n", filterChainName(l, fc), how)
+
+	return e.explainFilters(fc)
+}
+
+// RBACFilters returns the RBAC filters the request goes through, in order: the network RBAC filters of the
+// filter chain the connection is matched to, followed by the HTTP RBAC filters of its HTTP connection
+// manager for HTTP requests.
+func (c *ConfigWriter) RBACFilters(r *authz.Request) ([]*authz.RBACFilter, error) {
+	listeners, err := c.retrieveSortedListenerSlice()
+	if err != nil {
+		return nil, err
+	}
+	e := &explainer{config: c, request: r}
+	l, _ := e.selectListener(listeners)
+	if l == nil {
+		return nil, fmt.Errorf("no listener accepts connections to %s:%d", r.DestinationIP, r.DestinationPort)
+	}
+	fc, _ := selectFilterChain(l, r)
+	if fc == nil {
+		return nil, fmt.Errorf("no filter chain of listener %s matches the connection", l.Name)
+	}
+
+	var filters []*authz.RBACFilter
+	for _, filter := range fc.GetFilters() {
+		switch filter.GetName() {
+		case wellknown.RoleBasedAccessControl:
+			rbac := &rbac_tcp_filter.RBAC{}
+			if err := getFilterConfig(filter, rbac); err != nil {
+				return nil, fmt.Errorf("failed to parse the network RBAC filter: %v", err)
+			}
+			filters = append(filters, &authz.RBACFilter{
+				Name:        "Network RBAC",
+				Rules:       rbac.GetRules(),
+				ShadowRules: rbac.GetShadowRules(),
+			})
+		case wellknown.HTTPConnectionManager:
+			if !r.IsHTTP() {
+				return filters, nil
+			}
+			hcm := &hcm_filter.HttpConnectionManager{}
+			if err := getFilterConfig(filter, hcm); err != nil {
+				return nil, fmt.Errorf("failed to parse the HTTP connection manager filter: %v", err)
+			}
+			for _, httpFilter := range hcm.GetHttpFilters() {
+				if httpFilter.GetName() != wellknown.HTTPRoleBasedAccessControl {
+					continue
+				}
+				rbac := &rbac_http_filter.RBAC{}
+				if err := getHTTPFilterConfig(httpFilter, rbac); err != nil {
+					return nil, fmt.Errorf("failed to parse the HTTP RBAC filter: %v", err)
+				}
+				filters = append(filters, &authz.RBACFilter{
+					Name:        "HTTP RBAC",
+					Rules:       rbac.GetRules(),
+					ShadowRules: rbac.GetShadowRules(),
+				})
+			}
+			return filters, nil
+		}
+	}
+	return filters, nil
+}
+
+type explainer struct {
+	io.Writer
+	config    *ConfigWriter
+	request   *authz.Request
+	endpoints *clusters.Wrapper
+}
+
+func (e *explainer) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(e, format, a...)
+}
+
+func (e *explainer) printRequest() {
+	r := e.request
+	from := ""
+	if r.SourcePrincipal != "" {
+		from = " from " + r.SourcePrincipal
+	} else if r.SourceIP != "" {
+		from = " from " + r.SourceIP
+	}
+	if r.IsHTTP() {
+		e.printf("Request: %s %s%s to %s:%d%s\n", method(r), r.Host, r.Path, r.DestinationIP, r.DestinationPort, from)
+	} else {
+		e.printf("Request: TCP connection to %s:%d%s\n", r.DestinationIP, r.DestinationPort, from)
+	}
+}
+
+func method(r *authz.Request) string {
+	if r.Method == "" {
+		return "GET"
+	}
+	return r.Method
+}
+
+// selectListener returns the listener accepting the connection. Connections redirected to the proxy are
+// accepted by virtualInbound when their destination is an IP of the workload, and by virtualOutbound
+// otherwise. Those listeners hand the connection off to the listener of its original destination, when
+// they use it. Gateways accept connections on the listeners of their port.
+func (e *explainer) selectListener(listeners []*xdsapi.Listener) (*xdsapi.Listener, string) {
+	byName := map[string]*xdsapi.Listener{}
+	for _, l := range listeners {
+		byName[l.Name] = l
+	}
+
+	var entry *xdsapi.Listener
+	if e.isInstanceIP(e.request.DestinationIP) {
+		entry = byName[virtualInboundListener]
+	} else {
+		entry = byName[virtualOutboundListener]
+	}
+	if entry != nil && !entry.GetUseOriginalDst().GetValue() {
+		return entry, "the destination is redirected to it"
+	}
+
+	var exact, wildcard *xdsapi.Listener
+	for _, l := range listeners {
+		if retrieveListenerPort(l) != e.request.DestinationPort {
+			continue
+		}
+		switch address := retrieveListenerAddress(l); {
+		case address == e.request.DestinationIP:
+			exact = l
+		case address == "0.0.0.0" || address == "::":
+			wildcard = l
+		}
+	}
+
+	handoff := ""
+	if entry != nil {
+		handoff = fmt.Sprintf(", handed off by %s", entry.Name)
+	}
+	switch {
+	case exact != nil:
+		return exact, fmt.Sprintf("matched %s:%d%s", e.request.DestinationIP, e.request.DestinationPort, handoff)
+	case wildcard != nil:
+		return wildcard, fmt.Sprintf("matched port %d%s", e.request.DestinationPort, handoff)
+	case entry != nil:
+		return entry, "no listener for the original destination"
+	}
+	return nil, ""
+}
+
+// isInstanceIP returns whether ip is one of the IPs of the workload.
+func (e *explainer) isInstanceIP(ip string) bool {
+	for _, instanceIP := range e.config.InstanceIPs() {
+		if instanceIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// InstanceIPs returns the IPs of the workload of the proxy, read from the bootstrap node metadata.
+func (c *ConfigWriter) InstanceIPs() []string {
+	if c.configDump == nil {
+		return nil
+	}
+	bootstrap, err := c.configDump.GetBootstrapConfigDump()
+	if err != nil {
+		return nil
+	}
+	var ips []string
+	instanceIPs := bootstrap.GetBootstrap().GetNode().GetMetadata().GetFields()["INSTANCE_IPS"].GetStringValue()
+	for _, ip := range strings.Split(instanceIPs, ",") {
+		if ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// chainMatcher scores how specifically a filter chain matches a connection on one of its criteria: a
+// negative score if it does not match, 0 if the chain does not restrict the criteria, and a positive
+// score otherwise, higher scores being more specific.
+type chainMatcher struct {
+	criteria string
+	score    func(m *listener.FilterChainMatch, r *authz.Request) int
+}
+
+// chainMatchers are the criteria of filter chain matches, in the order Envoy narrows the filter chains
+// down to the most specific one.
+var chainMatchers = []chainMatcher{
+	{"destination port", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		if m.GetDestinationPort() == nil {
+			return 0
+		}
+		if m.GetDestinationPort().GetValue() == r.DestinationPort {
+			return 1
+		}
+		return -1
+	}},
+	{"destination IP", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		return scoreCIDRs(m.GetPrefixRanges(), r.DestinationIP)
+	}},
+	{"server name", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		if len(m.GetServerNames()) == 0 {
+			return 0
+		}
+		best := -1
+		for _, name := range m.GetServerNames() {
+			switch {
+			case name == r.SNI:
+				// Exact server names are preferred to any wildcard
+				return 1 << 16
+			case strings.HasPrefix(name, "*") && strings.HasSuffix(r.SNI, name[1:]) && len(name) > best:
+				best = len(name)
+			}
+		}
+		return best
+	}},
+	{"transport protocol", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		if m.GetTransportProtocol() == "" {
+			return 0
+		}
+		if m.GetTransportProtocol() == transportProtocol(r) {
+			return 1
+		}
+		return -1
+	}},
+	{"application protocols", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		if len(m.GetApplicationProtocols()) == 0 {
+			return 0
+		}
+		// The protocols of the connection are looked up in order, so the chain of its first protocol wins
+		protocols := applicationProtocols(r)
+		for i, p := range protocols {
+			for _, mp := range m.GetApplicationProtocols() {
+				if p == mp {
+					return len(protocols) - i
+				}
+			}
+		}
+		return -1
+	}},
+	{"source type", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		local := r.SourceIP != "" && (r.SourceIP == r.DestinationIP || net.ParseIP(r.SourceIP).IsLoopback())
+		switch m.GetSourceType() {
+		case listener.FilterChainMatch_LOCAL:
+			if local {
+				return 1
+			}
+			return -1
+		case listener.FilterChainMatch_EXTERNAL:
+			if !local {
+				return 1
+			}
+			return -1
+		}
+		return 0
+	}},
+	{"source IP", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		return scoreCIDRs(m.GetSourcePrefixRanges(), r.SourceIP)
+	}},
+	{"source port", func(m *listener.FilterChainMatch, r *authz.Request) int {
+		// The request has no source port, so the chains restricting it never match
+		if len(m.GetSourcePorts()) == 0 {
+			return 0
+		}
+		return -1
+	}},
+}
+
+// scoreCIDRs scores the longest of the ranges containing ip by its prefix length.
+func scoreCIDRs(ranges []*core.CidrRange, ip string) int {
+	if len(ranges) == 0 {
+		return 0
+	}
+	addr := net.ParseIP(ip)
+	best := -1
+	for _, cidr := range ranges {
+		prefixLen := int(cidr.GetPrefixLen().GetValue())
+		_, network, err := net.ParseCIDR(fmt.Sprintf("%s/%d", cidr.GetAddressPrefix(), prefixLen))
+		if err == nil && addr != nil && network.Contains(addr) && prefixLen+1 > best {
+			best = prefixLen + 1
+		}
+	}
+	return best
+}
+
+// transportProtocol returns the transport protocol the TLS inspector detects for the request: tls when
+// it is sent with mutual TLS or with SNI.
+func transportProtocol(r *authz.Request) string {
+	if r.SourcePrincipal != "" || r.SNI != "" {
+		return "tls"
+	}
+	return "raw_buffer"
+}
+
+// applicationProtocols returns the application protocols detected for the request, in the order the
+// proxies of the mesh negotiate them over mutual TLS, or detected by the HTTP inspector otherwise.
+func applicationProtocols(r *authz.Request) []string {
+	switch {
+	case r.SourcePrincipal != "" && r.IsHTTP():
+		return []string{"istio-http/1.1", "istio-peer-exchange", "istio"}
+	case r.SourcePrincipal != "":
+		return []string{"istio-peer-exchange", "istio"}
+	case r.SNI == "" && r.IsHTTP():
+		return []string{"http/1.1"}
+	}
+	return nil
+}
+
+// selectFilterChain returns the filter chain of the listener the connection is matched to, narrowing the
+// filter chains down to the most specific ones on each criteria in turn, as Envoy does.
+func selectFilterChain(l *xdsapi.Listener, r *authz.Request) (*listener.FilterChain, string) {
+	candidates := l.GetFilterChains()
+	var matched []string
+	for _, cm := range chainMatchers {
+		best := -1
+		var next []*listener.FilterChain
+		for _, fc := range candidates {
+			score := cm.score(fc.GetFilterChainMatch(), r)
+			switch {
+			case score < 0 || score < best:
+			case score > best:
+				best = score
+				next = []*listener.FilterChain{fc}
+			default:
+				next = append(next, fc)
+			}
+		}
+		if len(next) == 0 {
+			return nil, ""
+		}
+		if best > 0 {
+			matched = append(matched, cm.criteria)
+		}
+		candidates = next
+	}
+
+	if len(matched) == 0 {
+		return candidates[0], "matches any connection"
+	}
+	return candidates[0], "matched " + strings.Join(matched, ", ")
+}
+
+func filterChainName(l *xdsapi.Listener, fc *listener.FilterChain) string {
+	if fc.GetName() != "" {
+		return fc.GetName()
+	}
+	for i, c := range l.GetFilterChains() {
+		if c == fc {
+			return fmt.Sprintf("#%d", i)
+		}
+	}
+	return "unknown"
+}
+
+// explainFilters follows the network filters of the filter chain, checking the connection against the
+// RBAC filters until it is proxied by TCP proxy or HTTP connection manager.
+func (e *explainer) explainFilters(fc *listener.FilterChain) error {
+	for _, filter := range fc.GetFilters() {
+		switch filter.GetName() {
+		case wellknown.RoleBasedAccessControl:
+			rbac := &rbac_tcp_filter.RBAC{}
+			if err := getFilterConfig(filter, rbac); err != nil {
+				return fmt.Errorf("failed to parse the network RBAC filter: %v", err)
+			}
+			if !e.explainRBAC("Network RBAC", rbac.GetRules(), rbac.GetShadowRules()) {
+				e.printf("The connection is closed\n")
+				return nil
+			}
+		case wellknown.TCPProxy:
+			tcp := &tcp_proxy.TcpProxy{}
+			if err := getFilterConfig(filter, tcp); err != nil {
+				return fmt.Errorf("failed to parse the TCP proxy filter: %v", err)
+			}
+			e.printf("TCP proxy: %s\n", tcp.GetStatPrefix())
+			if cluster := tcp.GetCluster(); cluster != "" {
+				return e.explainClusters(map[string]uint32{cluster: 0})
+			}
+			weighted := map[string]uint32{}
+			for _, w := range tcp.GetWeightedClusters().GetClusters() {
+				weighted[w.GetName()] = w.GetWeight()
+			}
+			return e.explainClusters(weighted)
+		case wellknown.HTTPConnectionManager:
+			hcm := &hcm_filter.HttpConnectionManager{}
+			if err := getFilterConfig(filter, hcm); err != nil {
+				return fmt.Errorf("failed to parse the HTTP connection manager filter: %v", err)
+			}
+			return e.explainHTTP(hcm)
+		}
+	}
+	e.printf("The connection is not proxied by any filter\n")
+	return nil
+}
+
+// explainRBAC prints the decision of the RBAC rules, and of the shadow rules which are only logged, and
+// returns whether the request is allowed.
+func (e *explainer) explainRBAC(filter string, rules, shadowRules *rbacpb.RBAC) bool {
+	d := authz.Evaluate(rules, e.request)
+	if rules != nil {
+		e.printf("%s: %s\n", filter, describeDecision(d))
+	}
+	if shadowRules != nil {
+		e.printf("%s (shadow): %s\n", filter, describeDecision(authz.Evaluate(shadowRules, e.request)))
+	}
+	return d.Allowed
+}
+
+func describeDecision(d authz.Decision) string {
+	result := "denied"
+	if d.Allowed {
+		result = "allowed"
+	}
+	if d.Policy == "" {
+		return fmt.Sprintf("%s, no %s policy matched", result, d.Action)
+	}
+	return fmt.Sprintf("%s, %s %s matched", result, d.Action, authz.DescribePolicy(d.Policy))
+}
+
+func (e *explainer) explainHTTP(hcm *hcm_filter.HttpConnectionManager) error {
+	r := e.request
+	if !r.IsHTTP() {
+		e.printf("HTTP connection manager: %s, but the request is not HTTP\n", hcm.GetStatPrefix())
+		return nil
+	}
+
+	for _, filter := range hcm.GetHttpFilters() {
+		if filter.GetName() != wellknown.HTTPRoleBasedAccessControl {
+			continue
+		}
+		rbac := &rbac_http_filter.RBAC{}
+		if err := getHTTPFilterConfig(filter, rbac); err != nil {
+			return fmt.Errorf("failed to parse the HTTP RBAC filter: %v", err)
+		}
+		if !e.explainRBAC("HTTP RBAC", rbac.GetRules(), rbac.GetShadowRules()) {
+			e.printf("The request is denied with 403 Forbidden\n")
+			return nil
+		}
+	}
+
+	var rc *xdsapi.RouteConfiguration
+	switch spec := hcm.GetRouteSpecifier().(type) {
+	case *hcm_filter.HttpConnectionManager_RouteConfig:
+		rc = spec.RouteConfig
+		e.printf("Route configuration: %s (inline)\n", rc.GetName())
+	case *hcm_filter.HttpConnectionManager_Rds:
+		name := spec.Rds.GetRouteConfigName()
+		routes, err := e.config.retrieveSortedRouteSlice()
+		if err != nil {
+			return err
+		}
+		for _, candidate := range routes {
+			if candidate.GetName() == name {
+				rc = candidate
+			}
+		}
+		if rc == nil {
+			e.printf("Route configuration: %s, not received from RDS\n", name)
+			return nil
+		}
+		e.printf("Route configuration: %s\n", name)
+	default:
+		e.printf("Route configuration: unsupported route specifier\n")
+		return nil
+	}
+
+	vh, domain := selectVirtualHost(rc, r.Host)
+	if vh == nil {
+		e.printf("Virtual host: none, the request is answered with 404 Not Found\n")
+		return nil
+	}
+	e.printf("Virtual host: %s (matched domain %q)\n", vh.GetName(), domain)
+
+	rt := selectRoute(vh, r)
+	if rt == nil {
+		e.printf("Route: none, the request is answered with 404 Not Found\n")
+		return nil
+	}
+	e.printf("Route: %s\n", describeRoute(rt))
+
+	switch action := rt.GetAction().(type) {
+	case *route.Route_Redirect:
+		e.printf("The request is redirected to %s%s\n", action.Redirect.GetHostRedirect(), action.Redirect.GetPathRedirect())
+	case *route.Route_DirectResponse:
+		e.printf("The request is answered directly with %d\n", action.DirectResponse.GetStatus())
+	case *route.Route_Route:
+		ra := action.Route
+		switch {
+		case ra.GetCluster() != "":
+			return e.explainClusters(map[string]uint32{ra.GetCluster(): 0})
+		case ra.GetClusterHeader() != "":
+			cluster := r.HTTPHeaders()[strings.ToLower(ra.GetClusterHeader())]
+			if cluster == "" {
+				e.printf("Cluster: none, the request has no %s header\n", ra.GetClusterHeader())
+				return nil
+			}
+			return e.explainClusters(map[string]uint32{cluster: 0})
+		default:
+			weighted := map[string]uint32{}
+			for _, w := range ra.GetWeightedClusters().GetClusters() {
+				weighted[w.GetName()] = w.GetWeight().GetValue()
+			}
+			return e.explainClusters(weighted)
+		}
+	default:
+		e.printf("The route has no supported action\n")
+	}
+	return nil
+}
+
+// selectVirtualHost returns the virtual host for the host of the request, and its domain that matched.
+// As in Envoy, exact domains are preferred to the longest suffix wildcard, then to the longest prefix
+// wildcard, and then to the "*" domain.
+func selectVirtualHost(rc *xdsapi.RouteConfiguration, host string) (*route.VirtualHost, string) {
+	host = strings.ToLower(host)
+	var best *route.VirtualHost
+	bestDomain := ""
+	bestRank, bestLen := 0, 0
+	for _, vh := range rc.GetVirtualHosts() {
+		for _, d := range vh.GetDomains() {
+			d = strings.ToLower(d)
+			rank := 0
+			switch {
+			case d == host:
+				return vh, d
+			case d == "*":
+				rank = 1
+			case strings.HasPrefix(d, "*") && len(host) > len(d)-1 && strings.HasSuffix(host, d[1:]):
+				rank = 3
+			case strings.HasSuffix(d, "*") && len(host) > len(d)-1 && strings.HasPrefix(host, d[:len(d)-1]):
+				rank = 2
+			}
+			if rank > bestRank || (rank == bestRank && rank > 1 && len(d) > bestLen) {
+				best, bestDomain, bestRank, bestLen = vh, d, rank, len(d)
+			}
+		}
+	}
+	return best, bestDomain
+}
+
+// selectRoute returns the first route of the virtual host matching the request.
+func selectRoute(vh *route.VirtualHost, r *authz.Request) *route.Route {
+	for _, rt := range vh.GetRoutes() {
+		if matchRoute(rt.GetMatch(), r) {
+			return rt
+		}
+	}
+	return nil
+}
+
+func matchRoute(m *route.RouteMatch, r *authz.Request) bool {
+	path := r.Path
+	if path == "" {
+		path = "/"
+	}
+	pathOnly := path
+	query := ""
+	if i := strings.Index(path, "?"); i >= 0 {
+		pathOnly, query = path[:i], path[i+1:]
+	}
+
+	caseSensitive := m.GetCaseSensitive() == nil || m.GetCaseSensitive().GetValue()
+	fold := func(s string) string {
+		if caseSensitive {
+			return s
+		}
+		return strings.ToLower(s)
+	}
+	switch spec := m.GetPathSpecifier().(type) {
+	case *route.RouteMatch_Prefix:
+		if !strings.HasPrefix(fold(path), fold(spec.Prefix)) {
+			return false
+		}
+	case *route.RouteMatch_Path:
+		if fold(pathOnly) != fold(spec.Path) {
+			return false
+		}
+	case *route.RouteMatch_Regex:
+		if !authz.MatchRegex(spec.Regex, pathOnly) {
+			return false
+		}
+	case *route.RouteMatch_SafeRegex:
+		if !authz.MatchRegex(spec.SafeRegex.GetRegex(), pathOnly) {
+			return false
+		}
+	}
+
+	headers := r.HTTPHeaders()
+	for _, h := range m.GetHeaders() {
+		if !authz.MatchHeader(h, headers) {
+			return false
+		}
+	}
+	if m.GetGrpc() != nil && !strings.HasPrefix(headers["content-type"], "application/grpc") {
+		return false
+	}
+
+	params, _ := url.ParseQuery(query)
+	for _, q := range m.GetQueryParameters() {
+		values, found := params[q.GetName()]
+		value := ""
+		if len(values) > 0 {
+			value = values[0]
+		}
+		switch spec := q.GetQueryParameterMatchSpecifier().(type) {
+		case *route.QueryParameterMatcher_StringMatch:
+			if !found || !authz.MatchString(spec.StringMatch, value) {
+				return false
+			}
+		case *route.QueryParameterMatcher_PresentMatch:
+			if found != spec.PresentMatch {
+				return false
+			}
+		default:
+			if !found || (q.GetValue() != "" && q.GetValue() != value) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func describeRoute(rt *route.Route) string {
+	m := rt.GetMatch()
+	var match string
+	switch spec := m.GetPathSpecifier().(type) {
+	case *route.RouteMatch_Prefix:
+		match = fmt.Sprintf("prefix %q", spec.Prefix)
+	case *route.RouteMatch_Path:
+		match = fmt.Sprintf("path %q", spec.Path)
+	case *route.RouteMatch_Regex:
+		match = fmt.Sprintf("regex %q", spec.Regex)
+	case *route.RouteMatch_SafeRegex:
+		match = fmt.Sprintf("regex %q", spec.SafeRegex.GetRegex())
+	}
+	for _, h := range m.GetHeaders() {
+		match += fmt.Sprintf(", header %s", h.GetName())
+	}
+	if len(m.GetQueryParameters()) > 0 {
+		match += ", query parameters"
+	}
+	if rt.GetName() == "" {
+		return "matched " + match
+	}
+	return fmt.Sprintf("%s (matched %s)", rt.GetName(), match)
+}
+
+// explainClusters prints the clusters the request is sent to, with their weight when there are several,
+// followed by their endpoints.
+func (e *explainer) explainClusters(weighted map[string]uint32) error {
+	all, err := e.config.retrieveSortedClusterSlice()
+	if err != nil {
+		return err
+	}
+	byName := map[string]*xdsapi.Cluster{}
+	for _, cl := range all {
+		byName[cl.GetName()] = cl
+	}
+
+	names := make([]string, 0, len(weighted))
+	for name := range weighted {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		weight := ""
+		if len(weighted) > 1 {
+			weight = fmt.Sprintf(", weight %d", weighted[name])
+		}
+		cl, ok := byName[name]
+		if !ok {
+			e.printf("Cluster: %s (not received from CDS%s), the request fails with 503\n", name, weight)
+			continue
+		}
+		e.printf("Cluster: %s (%s%s)\n", name, clusterType(cl), weight)
+		e.explainEndpoints(name)
+	}
+	return nil
+}
+
+func clusterType(cl *xdsapi.Cluster) string {
+	if cl.GetClusterType() != nil {
+		return cl.GetClusterType().GetName()
+	}
+	return cl.GetType().String()
+}
+
+func (e *explainer) explainEndpoints(cluster string) {
+	if e.endpoints == nil {
+		return
+	}
+	for _, cs := range e.endpoints.GetClusterStatuses() {
+		if cs.GetName() != cluster {
+			continue
+		}
+		if len(cs.GetHostStatuses()) == 0 {
+			e.printf("Endpoints: none, the request fails with 503\n")
+			return
+		}
+		e.printf("Endpoints:\n")
+		w := new(tabwriter.Writer).Init(e, 0, 8, 5, ' ', 0)
+		fmt.Fprintln(w, "  ENDPOINT\tSTATUS\tLOCALITY\tWEIGHT")
+		for _, host := range cs.GetHostStatuses() {
+			status := host.GetHealthStatus().GetEdsHealthStatus().String()
+			if host.GetHealthStatus().GetFailedOutlierCheck() {
+				status += " (outlier)"
+			}
+			fmt.Fprintf(w, "  %s:%d\t%s\t%s\t%d\n", host.GetAddress().GetSocketAddress().GetAddress(),
+				host.GetAddress().GetSocketAddress().GetPortValue(), status, locality(host.GetLocality().GetRegion(),
+					host.GetLocality().GetZone(), host.GetLocality().GetSubZone()), host.GetWeight())
+		}
+		_ = w.Flush()
+		return
+	}
+}
+
+func locality(parts ...string) string {
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, "/")
+}
+
+func getFilterConfig(filter *listener.Filter, out proto.Message) error {
+	switch c := filter.ConfigType.(type) {
+	case *listener.Filter_Config:
+		return conversion.StructToMessage(c.Config, out)
+	case *listener.Filter_TypedConfig:
+		return ptypes.UnmarshalAny(c.TypedConfig, out)
+	}
+	return nil
+}
+
+func getHTTPFilterConfig(filter *hcm_filter.HttpFilter, out proto.Message) error {
+	switch c := filter.ConfigType.(type) {
+	case *hcm_filter.HttpFilter_Config:
+		return conversion.StructToMessage(c.Config, out)
+	case *hcm_filter.HttpFilter_TypedConfig:
+		return ptypes.UnmarshalAny(c.TypedConfig, out)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package configdump
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"istio.io/istio/istioctl/pkg/authz"
+	"istio.io/istio/istioctl/pkg/util/clusters"
+	"istio.io/istio/pilot/test/util"
+)
+
+func TestConfigWriter_Explain(t *testing.T) {
+	tests := []struct {
+		name           string
+		configDump     string
+		request        authz.Request
+		wantOutputFile string
+	}{
+		{
+			name:       "inbound request allowed by RBAC",
+			configDump: "testdata/explain/reviews-configdump.json",
+			request: authz.Request{
+				DestinationIP:   "10.0.0.5",
+				DestinationPort: 9080,
+				Method:          "GET",
+				Host:            "reviews:9080",
+				Path:            "/reviews/1",
+				SourcePrincipal: "cluster.local/ns/default/sa/productpage",
+			},
+			wantOutputFile: "testdata/explain/inbound-allowed.txt",
+		},
+		{
+			name:       "inbound request denied by RBAC",
+			configDump: "testdata/explain/reviews-configdump.json",
+			request: authz.Request{
+				DestinationIP:   "10.0.0.5",
+				DestinationPort: 9080,
+				Method:          "POST",
+				Host:            "reviews:9080",
+				Path:            "/reviews/1",
+				SourcePrincipal: "cluster.local/ns/default/sa/productpage",
+			},
+			wantOutputFile: "testdata/explain/inbound-denied.txt",
+		},
+		{
+			name:       "outbound request matching a header route",
+			configDump: "testdata/explain/productpage-configdump.json",
+			request: authz.Request{
+				DestinationIP:   "10.96.0.10",
+				DestinationPort: 9080,
+				Host:            "reviews:9080",
+				Path:            "/reviews/1",
+				Headers:         map[string]string{"End-User": "jason"},
+			},
+			wantOutputFile: "testdata/explain/outbound-header.txt",
+		},
+		{
+			name:       "outbound request matching the default route",
+			configDump: "testdata/explain/productpage-configdump.json",
+			request: authz.Request{
+				DestinationIP:   "10.96.0.10",
+				DestinationPort: 9080,
+				Host:            "reviews.default.svc.cluster.local:9080",
+				Path:            "/reviews/1",
+			},
+			wantOutputFile: "testdata/explain/outbound-default.txt",
+		},
+		{
+			name:       "outbound connection passed through",
+			configDump: "testdata/explain/productpage-configdump.json",
+			request: authz.Request{
+				DestinationIP:   "1.2.3.4",
+				DestinationPort: 443,
+				SNI:             "www.example.com",
+			},
+			wantOutputFile: "testdata/explain/outbound-passthrough.txt",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotOut := &bytes.Buffer{}
+			cw := &ConfigWriter{Stdout: gotOut}
+			cd, _ := ioutil.ReadFile(tt.configDump)
+			if err := cw.Prime(cd); err != nil {
+				t.Fatal(err)
+			}
+			endpoints := &clusters.Wrapper{}
+			eds, _ := ioutil.ReadFile("testdata/explain/productpage-clusters.json")
+			if err := json.Unmarshal(eds, endpoints); err != nil {
+				t.Fatal(err)
+			}
+			if err := cw.Explain(&tt.request, endpoints); err != nil {
+				t.Errorf("Explain (%v) produced unexpected err: %v", tt.name, err)
+			}
+			util.CompareContent(gotOut.Bytes(), tt.wantOutputFile, t)
+		})
+	}
+
+	cw := &ConfigWriter{Stdout: &bytes.Buffer{}}
+	if err := cw.Explain(&authz.Request{}, nil); err == nil {
+		t.Errorf("Explain did not produce expected err when the config writer is not primed")
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clusterPrefix   = "cluster."
+	requestsSuffix  = ".upstream_rq_total"
+	errorsSuffix    = ".upstream_rq_5xx"
+	latencySuffix   = ".upstream_rq_time"
+	noRecordedValue = "No recorded values"
+)
+
+// ClusterStats holds the request statistics of an upstream cluster in an Envoy /stats output.
+type ClusterStats struct {
+	// Requests is the number of requests sent to the cluster.
+	Requests uint64
+	// Errors is the number of requests to the cluster answered with a 5xx.
+	Errors uint64
+	// Latency holds the request time quantiles, in milliseconds, of the last stats flush interval, by
+	// quantile in percent. It is empty when no request completed during the interval.
+	Latency map[float64]float64
+}
+
+// Snapshot holds the cluster statistics of an Envoy at a point in time.
+type Snapshot struct {
+	Time     time.Time
+	Clusters map[string]*ClusterStats
+}
+
+// ParseStats parses the plain text output of the Envoy /stats admin endpoint, keeping the request
+// statistics of the upstream clusters.
+func ParseStats(data []byte, t time.Time) (*Snapshot, error) {
+	s := &Snapshot{Time: t, Clusters: map[string]*ClusterStats{}}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, clusterPrefix) {
+			continue
+		}
+		sep := strings.Index(line, ": ")
+		if sep < 0 {
+			continue
+		}
+		name, value := line[:sep], line[sep+2:]
+
+		switch {
+		case strings.HasSuffix(name, requestsSuffix):
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counter %s: %v", name, err)
+			}
+			s.cluster(name, requestsSuffix).Requests = n
+		case strings.HasSuffix(name, errorsSuffix):
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid counter %s: %v", name, err)
+			}
+			s.cluster(name, errorsSuffix).Errors = n
+		case strings.HasSuffix(name, latencySuffix):
+			latency, err := parseQuantiles(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid histogram %s: %v", name, err)
+			}
+			s.cluster(name, latencySuffix).Latency = latency
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshot) cluster(stat, suffix string) *ClusterStats {
+	name := strings.TrimSuffix(strings.TrimPrefix(stat, clusterPrefix), suffix)
+	c, ok := s.Clusters[name]
+	if !ok {
+		c = &ClusterStats{Latency: map[float64]float64{}}
+		s.Clusters[name] = c
+	}
+	return c
+}
+
+// parseQuantiles parses the interval quantiles of a histogram, formatted like
+// "P0(nan,1) P25(nan,1.075) P50(2.05,2.05) ...", where each quantile holds the value of the last
+// flush interval and the cumulative value.
+func parseQuantiles(value string) (map[float64]float64, error) {
+	quantiles := map[float64]float64{}
+	if value == noRecordedValue {
+		return quantiles, nil
+	}
+	for _, field := range strings.Fields(value) {
+		open := strings.Index(field, "(")
+		comma := strings.Index(field, ",")
+		if !strings.HasPrefix(field, "P") || open < 0 || comma < open || !strings.HasSuffix(field, ")") {
+			return nil, fmt.Errorf("invalid quantile %q", field)
+		}
+		q, err := strconv.ParseFloat(field[1:open], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q: %v", field, err)
+		}
+		v, err := strconv.ParseFloat(field[open+1:comma], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q: %v", field, err)
+		}
+		if !math.IsNaN(v) {
+			quantiles[q] = v
+		}
+	}
+	return quantiles, nil
+}
+
+// Traffic is the traffic from a proxy to an upstream cluster between two snapshots.
+type Traffic struct {
+	Pod        string  `json:"pod"`
+	Cluster    string  `json:"cluster"`
+	Requests   uint64  `json:"requests"`
+	RPS        float64 `json:"rps"`
+	ErrorRatio float64 `json:"errorRatio"`
+	// The latency estimates are the quantiles in milliseconds of the last stats flush interval of the
+	// second snapshot, omitted when no request completed during the interval.
+	P50 *float64 `json:"p50,omitempty"`
+	P90 *float64 `json:"p90,omitempty"`
+	P99 *float64 `json:"p99,omitempty"`
+}
+
+// Rates returns the traffic of the proxy of the pod between the two snapshots of its stats, for the
+// clusters which received requests. Counters reset by a proxy restart are counted from zero.
+func Rates(pod string, before, after *Snapshot) []Traffic {
+	traffic := []Traffic{}
+	seconds := after.Time.Sub(before.Time).Seconds()
+	for name, a := range after.Clusters {
+		var b ClusterStats
+		if s, ok := before.Clusters[name]; ok && s.Requests <= a.Requests && s.Errors <= a.Errors {
+			b = *s
+		}
+		requests := a.Requests - b.Requests
+		if requests == 0 {
+			continue
+		}
+		t := Traffic{
+			Pod:        pod,
+			Cluster:    name,
+			Requests:   requests,
+			ErrorRatio: float64(a.Errors-b.Errors) / float64(requests),
+			P50:        quantile(a.Latency, 50),
+			P90:        quantile(a.Latency, 90),
+			P99:        quantile(a.Latency, 99),
+		}
+		if seconds > 0 {
+			t.RPS = float64(requests) / seconds
+		}
+		traffic = append(traffic, t)
+	}
+	return traffic
+}
+
+func quantile(quantiles map[float64]float64, q float64) *float64 {
+	if v, ok := quantiles[q]; ok {
+		return &v
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	before = `cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_200: 90
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_5xx: 10
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: 100
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_time: No recorded values
+cluster.outbound|9080||ratings.default.svc.cluster.local.upstream_rq_total: 50
+cluster.xds-grpc.upstream_rq_total: 3
+server.uptime: 120
+`
+	after = `cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_200: 110
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_5xx: 30
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: 140
+cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_time: P0(1,1) P25(1.5,1.2) P50(2.05,2) P75(3,2.5) P90(4.02,3) P95(5,4) P99(9.08,8) P99.5(9.5,9) P99.9(9.9,9.9) P100(10,10)
+cluster.outbound|9080||ratings.default.svc.cluster.local.upstream_rq_total: 50
+cluster.outbound|443||httpbin.org.upstream_rq_total: 4
+cluster.outbound|443||httpbin.org.upstream_rq_time: P0(nan,1) P25(nan,1) P50(nan,1) P75(nan,1) P90(nan,1) P95(nan,1) P99(nan,1) P99.5(nan,1) P99.9(nan,1) P100(nan,1)
+cluster.xds-grpc.upstream_rq_total: 3
+server.uptime: 130
+`
+)
+
+func TestRates(t *testing.T) {
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	b, err := ParseStats([]byte(before), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseStats([]byte(after), start.Add(2*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traffic := Rates("productpage-v1.default", b, a)
+	if err := Sort(traffic, SortByRPS); err != nil {
+		t.Fatal(err)
+	}
+	p50, p90, p99 := 2.05, 4.02, 9.08
+	want := []Traffic{
+		{
+			Pod:        "productpage-v1.default",
+			Cluster:    "outbound|9080||reviews.default.svc.cluster.local",
+			Requests:   40,
+			RPS:        20,
+			ErrorRatio: 0.5,
+			P50:        &p50,
+			P90:        &p90,
+			P99:        &p99,
+		},
+		{
+			// New clusters are counted from zero
+			Pod:      "productpage-v1.default",
+			Cluster:  "outbound|443||httpbin.org",
+			Requests: 4,
+			RPS:      2,
+		},
+	}
+	if !reflect.DeepEqual(traffic, want) {
+		t.Errorf("got %+v, want %+v", traffic, want)
+	}
+
+	var out bytes.Buffer
+	if err := PrintTable(&out, traffic); err != nil {
+		t.Fatal(err)
+	}
+	wantTable := `POD                      CLUSTER                                            RPS     ERRORS   P50     P90     P99
+productpage-v1.default   outbound|9080||reviews.default.svc.cluster.local   20.00   50.00%   2.0ms   4.0ms   9.1ms
+productpage-v1.default   outbound|443||httpbin.org                          2.00    0.00%    -       -       -
+`
+	if out.String() != wantTable {
+		t.Errorf("got table\n%s\nwant\n%s", out.String(), wantTable)
+	}
+}
+
+func TestParseStatsRestart(t *testing.T) {
+	start := time.Date(2020, 5, 1, 10, 0, 0, 0, time.UTC)
+	b, err := ParseStats([]byte(after), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseStats([]byte(before), start.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The counters of the reviews cluster went down: the proxy restarted and they are counted from zero
+	traffic := Rates("productpage-v1.default", b, a)
+	if err := Sort(traffic, SortByErrors); err != nil {
+		t.Fatal(err)
+	}
+	if len(traffic) != 1 || traffic[0].Requests != 100 || traffic[0].ErrorRatio != 0.1 {
+		t.Errorf("got %+v, want 100 requests to reviews with 10%% errors", traffic)
+	}
+}
+
+func TestParseStatsInvalid(t *testing.T) {
+	for _, stats := range []string{
+		"cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_total: many",
+		"cluster.outbound|9080||reviews.default.svc.cluster.local.upstream_rq_time: P50",
+	} {
+		if _, err := ParseStats([]byte(stats), time.Now()); err == nil {
+			t.Errorf("expected an error parsing %q", stats)
+		}
+	}
+}
+
+func TestSortUnknownKey(t *testing.T) {
+	if err := Sort(nil, "latency"); err == nil {
+		t.Error("expected an error sorting by an unknown key")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+const (
+	// SortByRPS sorts traffic by decreasing request rate.
+	SortByRPS = "rps"
+	// SortByErrors sorts traffic by decreasing error ratio.
+	SortByErrors = "errors"
+)
+
+// Sort sorts the traffic by the given key, then by pod and cluster.
+func Sort(traffic []Traffic, by string) error {
+	var less func(a, b Traffic) bool
+	switch by {
+	case SortByRPS:
+		less = func(a, b Traffic) bool { return a.RPS > b.RPS }
+	case SortByErrors:
+		less = func(a, b Traffic) bool { return a.ErrorRatio > b.ErrorRatio }
+	default:
+		return fmt.Errorf("unknown sort key %q, expected one of %s|%s", by, SortByRPS, SortByErrors)
+	}
+	sort.SliceStable(traffic, func(i, j int) bool {
+		if less(traffic[i], traffic[j]) {
+			return true
+		}
+		if less(traffic[j], traffic[i]) {
+			return false
+		}
+		if traffic[i].Pod != traffic[j].Pod {
+			return traffic[i].Pod < traffic[j].Pod
+		}
+		return traffic[i].Cluster < traffic[j].Cluster
+	})
+	return nil
+}
+
+// PrintTable prints the traffic as a table.
+func PrintTable(w io.Writer, traffic []Traffic) error {
+	tw := new(tabwriter.Writer).Init(w, 0, 8, 3, ' ', 0)
+	fmt.Fprintln(tw, "POD\tCLUSTER\tRPS\tERRORS\tP50\tP90\tP99")
+	for _, t := range traffic {
+		fmt.Fprintf(tw, "%s\t%s\t%.2f\t%.2f%%\t%s\t%s\t%s\n", t.Pod, t.Cluster, t.RPS, t.ErrorRatio*100,
+			formatLatency(t.P50), formatLatency(t.P90), formatLatency(t.P99))
+	}
+	return tw.Flush()
+}
+
+// PrintJSON prints the traffic as a JSON array.
+func PrintJSON(w io.Writer, traffic []Traffic) error {
+	out, err := json.MarshalIndent(traffic, "", "    ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+func formatLatency(ms *float64) string {
+	if ms == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fms", *ms)
+}
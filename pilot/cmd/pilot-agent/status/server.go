@@ -37,6 +37,8 @@ import (
 	"istio.io/istio/pilot/cmd/pilot-agent/status/ready"
 	"istio.io/pkg/log"
 
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
@@ -47,11 +49,14 @@ const (
 	// quitPath is to notify the pilot agent to quit.
 	quitPath = "/quitquitquit"
 	// KubeAppProberEnvName is the name of the command line flag for pilot agent to pass app prober config.
-	// The json encoded string to pass app HTTP probe information from injector(istioctl or webhook).
+	// The json encoded string to pass app HTTP, TCP and gRPC probe information from injector(istioctl or webhook).
 	// For example, ISTIO_KUBE_APP_PROBERS='{"/app-health/httpbin/livez":{"httpGet":{"path": "/hello", "port": 8080}}.
 	// indicates that httpbin container liveness prober port is 8080 and probing path is /hello.
 	// This environment variable should never be set manually.
 	KubeAppProberEnvName = "ISTIO_KUBE_APP_PROBERS"
+
+	// defaultProbeTimeout is the timeout of the TCP and gRPC probes without one, the Kubernetes default.
+	defaultProbeTimeout = time.Second
 )
 
 var PrometheusScrapingConfig = env.RegisterStringVar("ISTIO_PROMETHEUS_ANNOTATIONS", "", "")
@@ -66,10 +71,20 @@ var (
 // container "hello-world".
 type KubeAppProbers map[string]*Prober
 
-// Prober represents a single container prober
+// Prober represents a single container prober, one of an HTTP GET request, a TCP connection or a gRPC
+// health check.
 type Prober struct {
-	HTTPGet        *corev1.HTTPGetAction `json:"httpGet"`
-	TimeoutSeconds int32                 `json:"timeoutSeconds,omitempty"`
+	HTTPGet        *corev1.HTTPGetAction   `json:"httpGet,omitempty"`
+	TCPSocket      *corev1.TCPSocketAction `json:"tcpSocket,omitempty"`
+	GRPC           *GRPCAction             `json:"grpc,omitempty"`
+	TimeoutSeconds int32                   `json:"timeoutSeconds,omitempty"`
+}
+
+// GRPCAction is a check of the gRPC health checking protocol, for the overall health of the server when the
+// service is empty.
+type GRPCAction struct {
+	Port    int    `json:"port"`
+	Service string `json:"service,omitempty"`
 }
 
 // Config for the status server.
@@ -115,11 +130,18 @@ func NewServer(config Config) (*Server, error) {
 		if !appProberPattern.Match([]byte(path)) {
 			return nil, fmt.Errorf(`invalid key, must be in form of regex pattern ^/app-health/[^\/]+/(livez|readyz)$`)
 		}
-		if prober.HTTPGet == nil {
-			return nil, fmt.Errorf(`invalid prober type, must be of type httpGet`)
-		}
-		if prober.HTTPGet.Port.Type != intstr.Int {
-			return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
+		switch {
+		case prober.HTTPGet != nil && prober.TCPSocket == nil && prober.GRPC == nil:
+			if prober.HTTPGet.Port.Type != intstr.Int {
+				return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
+			}
+		case prober.TCPSocket != nil && prober.HTTPGet == nil && prober.GRPC == nil:
+			if prober.TCPSocket.Port.Type != intstr.Int {
+				return nil, fmt.Errorf("invalid prober config for %v, the port must be int type", path)
+			}
+		case prober.GRPC != nil && prober.HTTPGet == nil && prober.TCPSocket == nil:
+		default:
+			return nil, fmt.Errorf(`invalid prober type, must be one of httpGet, tcpSocket or grpc`)
 		}
 	}
 
@@ -349,6 +371,17 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	switch {
+	case prober.TCPSocket != nil:
+		handleAppProbeTCPSocket(w, prober, path)
+	case prober.GRPC != nil:
+		handleAppProbeGRPC(w, prober, path)
+	default:
+		handleAppProbeHTTPGet(w, req, prober, path)
+	}
+}
+
+func handleAppProbeHTTPGet(w http.ResponseWriter, req *http.Request, prober *Prober, path string) {
 	// Construct a request sent to the application.
 	httpClient := &http.Client{
 		Timeout: time.Duration(prober.TimeoutSeconds) * time.Second,
@@ -403,6 +436,55 @@ func (s *Server) handleAppProbe(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(response.StatusCode)
 }
 
+// handleAppProbeTCPSocket succeeds if a TCP connection to the app can be established.
+func handleAppProbeTCPSocket(w http.ResponseWriter, prober *Prober, path string) {
+	addr := net.JoinHostPort("localhost", strconv.Itoa(prober.TCPSocket.Port.IntValue()))
+	conn, err := net.DialTimeout("tcp", addr, prober.timeout())
+	if err != nil {
+		log.Errorf("TCP connection to probe app failed: %v, original URL path = %v\napp address = %v", err, path, addr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_ = conn.Close()
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleAppProbeGRPC succeeds if the gRPC health check of the app reports the service as serving.
+func handleAppProbeGRPC(w http.ResponseWriter, prober *Prober, path string) {
+	ctx, cancel := context.WithTimeout(context.Background(), prober.timeout())
+	defer cancel()
+
+	addr := net.JoinHostPort("localhost", strconv.Itoa(prober.GRPC.Port))
+	conn, err := grpc.DialContext(ctx, addr, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		log.Errorf("gRPC connection to probe app failed: %v, original URL path = %v\napp address = %v", err, path, addr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{Service: prober.GRPC.Service})
+	if err != nil {
+		log.Errorf("gRPC health check to probe app failed: %v, original URL path = %v\napp service = %q",
+			err, path, prober.GRPC.Service)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// timeout returns the timeout of the TCP and gRPC probes.
+func (p *Prober) timeout() time.Duration {
+	if p.TimeoutSeconds <= 0 {
+		return defaultProbeTimeout
+	}
+	return time.Duration(p.TimeoutSeconds) * time.Second
+}
+
 // notifyExit sends SIGTERM to itself
 func notifyExit() {
 	p, err := os.FindProcess(os.Getpid())
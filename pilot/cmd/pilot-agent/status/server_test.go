@@ -28,6 +28,10 @@ import (
 	"testing"
 	"time"
 
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
 	"istio.io/istio/pkg/test/util/retry"
 
 	"istio.io/istio/pkg/test/env"
@@ -60,9 +64,24 @@ func TestNewServer(t *testing.T) {
 		},
 		// invalid probe type
 		{
-			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": "8888"}}}`,
+			probe: `{"/app-health/hello-world/readyz": {"exec": {"command": ["true"]}}}`,
+			err:   "invalid prober type",
+		},
+		// several probe types
+		{
+			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": 8888}, "grpc": {"port": 8888}}}`,
 			err:   "invalid prober type",
 		},
+		// TCP port is not Int typed.
+		{
+			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": "8888"}}}`,
+			err:   "must be int type",
+		},
+		// valid TCP and gRPC probes
+		{
+			probe: `{"/app-health/hello-world/readyz": {"tcpSocket": {"port": 8888}},` +
+				`"/app-health/hello-world/livez": {"grpc": {"port": 8888, "service": "hello"}}}`,
+		},
 		// Port is not Int typed.
 		{
 			probe: `{"/app-health/hello-world/readyz": {"httpGet": {"path": "/hello/sunnyvale", "port": "container-port-dontknow"}}}`,
@@ -379,6 +398,101 @@ func TestHttpsAppProbe(t *testing.T) {
 	}
 }
 
+func TestTCPAndGRPCAppProbe(t *testing.T) {
+	// Starts a gRPC application serving the health checking protocol.
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to allocate unused port %v", err)
+	}
+	grpcServer := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("serving", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("not-serving", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+	go grpcServer.Serve(listener)
+	defer grpcServer.Stop()
+	appPort := listener.Addr().(*net.TCPAddr).Port
+
+	// A port nothing listens on.
+	closed, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("failed to allocate unused port %v", err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	server, err := NewServer(Config{
+		StatusPort: 0,
+		KubeAppProbers: fmt.Sprintf(`{"/app-health/tcp/readyz": {"tcpSocket": {"port": %v}},
+"/app-health/tcp-closed/readyz": {"tcpSocket": {"port": %v}},
+"/app-health/grpc/readyz": {"grpc": {"port": %v}},
+"/app-health/grpc-serving/readyz": {"grpc": {"port": %v, "service": "serving"}},
+"/app-health/grpc-not-serving/readyz": {"grpc": {"port": %v, "service": "not-serving"}},
+"/app-health/grpc-unknown/readyz": {"grpc": {"port": %v, "service": "unknown"}},
+"/app-health/grpc-closed/readyz": {"grpc": {"port": %v}}}`,
+			appPort, closedPort, appPort, appPort, appPort, appPort, closedPort),
+	})
+	if err != nil {
+		t.Fatalf("failed to create status server %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.Run(ctx)
+
+	var statusPort uint16
+	for statusPort == 0 {
+		server.mutex.RLock()
+		statusPort = server.statusPort
+		server.mutex.RUnlock()
+	}
+
+	testCases := []struct {
+		probePath  string
+		statusCode int
+	}{
+		{
+			probePath:  "/app-health/tcp/readyz",
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  "/app-health/tcp-closed/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			probePath:  "/app-health/grpc/readyz",
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  "/app-health/grpc-serving/readyz",
+			statusCode: http.StatusOK,
+		},
+		{
+			probePath:  "/app-health/grpc-not-serving/readyz",
+			statusCode: http.StatusServiceUnavailable,
+		},
+		{
+			probePath:  "/app-health/grpc-unknown/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+		{
+			probePath:  "/app-health/grpc-closed/readyz",
+			statusCode: http.StatusInternalServerError,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.probePath, func(t *testing.T) {
+			resp, err := http.Get(fmt.Sprintf("http://localhost:%v%s", statusPort, tc.probePath))
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.statusCode {
+				t.Errorf("unexpected status code, want = %v, got = %v", tc.statusCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
 func TestHandleQuit(t *testing.T) {
 	statusPort := 15020
 	s, err := NewServer(Config{StatusPort: uint16(statusPort)})
@@ -29,8 +29,11 @@ import (
 	"istio.io/pkg/version"
 
 	"istio.io/istio/pilot/pkg/bootstrap"
+	configgit "istio.io/istio/pilot/pkg/config/git"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pilot/pkg/serviceregistry/eureka"
+	"istio.io/istio/pilot/pkg/serviceregistry/zookeeper"
 	"istio.io/istio/pkg/cmd"
 	"istio.io/istio/pkg/spiffe"
 )
@@ -112,8 +115,9 @@ func init() {
 	// Process commandline args.
 	discoveryCmd.PersistentFlags().StringSliceVar(&serverArgs.Service.Registries, "registries",
 		[]string{string(serviceregistry.Kubernetes)},
-		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s, %s})",
-			serviceregistry.Kubernetes, serviceregistry.Consul, serviceregistry.Mock))
+		fmt.Sprintf("Comma separated list of platform service registries to read from (choose one or more from {%s, %s, %s, %s, %s, %s})",
+			serviceregistry.Kubernetes, serviceregistry.Consul, serviceregistry.Eureka, serviceregistry.Zookeeper,
+			serviceregistry.File, serviceregistry.Mock))
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.ClusterRegistriesNamespace, "clusterRegistriesNamespace",
 		serverArgs.Config.ClusterRegistriesNamespace, "Namespace for ConfigMap which stores clusters configs")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.KubeConfig, "kubeconfig", "",
@@ -143,6 +147,15 @@ func init() {
 		"Setting this flag has no effect. Install CRD definitions directly or with the operator")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.FileDir, "configDir", "",
 		"Directory to watch for updates to config yaml files. If specified, the files will be used as the source of config, rather than a CRD client.")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.GitRepository, "configGitRepository", "",
+		"Local Git repository, as a path or a file:// URL, to follow for config yaml files. If specified, the files of the branch "+
+			"will be used as the source of config, rather than a CRD client.")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.GitBranch, "configGitBranch", configgit.DefaultBranch,
+		"Branch of the Git repository to follow")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Config.GitPath, "configGitPath", "",
+		"Directory of the Git repository holding the config yaml files, the whole repository if not set")
+	discoveryCmd.PersistentFlags().DurationVar(&serverArgs.Config.GitPollInterval, "configGitPollInterval", configgit.DefaultPollInterval,
+		"Interval between two fetches of the Git repository")
 	discoveryCmd.PersistentFlags().StringVarP(&serverArgs.Config.ControllerOptions.WatchedNamespace, "appNamespace",
 		"a", metav1.NamespaceAll,
 		"Restrict the applications namespace the controller manages; if not set, controller watches all namespaces")
@@ -156,6 +169,16 @@ func init() {
 		"The domain serves to identify the system with spiffe")
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Service.Consul.ServerURL, "consulserverURL", "",
 		"URL for the Consul server")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Service.Eureka.ServerURL, "eurekaserverURL", "",
+		"URL for the Eureka server, including the REST API base path (e.g. http://eureka:8761/eureka)")
+	discoveryCmd.PersistentFlags().DurationVar(&serverArgs.Service.Eureka.PollInterval, "eurekaPollInterval", eureka.DefaultPollInterval,
+		"Interval between two fetches of the Eureka registry")
+	discoveryCmd.PersistentFlags().StringSliceVar(&serverArgs.Service.Zookeeper.Servers, "zookeeperServers", nil,
+		"Comma separated list of ZooKeeper servers (host:port) holding the Dubbo registry")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Service.Zookeeper.Root, "zookeeperRoot", zookeeper.DefaultRoot,
+		"ZooKeeper path under which Dubbo publishes its interfaces")
+	discoveryCmd.PersistentFlags().StringVar(&serverArgs.Service.File.Dir, "registryDir", "",
+		"Directory to watch for YAML files declaring services and endpoints, used by the File registry")
 
 	// using address, so it can be configured as localhost:.. (possibly UDS in future)
 	discoveryCmd.PersistentFlags().StringVar(&serverArgs.DiscoveryOptions.HTTPAddr, "httpAddr", ":8080",
@@ -45,6 +45,7 @@ import (
 	"istio.io/pkg/log"
 
 	configaggregate "istio.io/istio/pilot/pkg/config/aggregate"
+	configgit "istio.io/istio/pilot/pkg/config/git"
 	"istio.io/istio/pilot/pkg/config/kube/crd/controller"
 	"istio.io/istio/pilot/pkg/config/kube/ingress"
 	"istio.io/istio/pilot/pkg/config/memory"
@@ -84,6 +85,18 @@ func (s *Server) initConfigController(args *PilotArgs) error {
 			return err
 		}
 		s.ConfigStores = append(s.ConfigStores, configController)
+	} else if args.Config.GitRepository != "" {
+		store := memory.MakeWithLedger(collections.Pilot, buildLedger(args.Config))
+		configController, err := configgit.NewStore(store, configgit.Options{
+			Repository:   args.Config.GitRepository,
+			Branch:       args.Config.GitBranch,
+			Path:         args.Config.GitPath,
+			PollInterval: args.Config.GitPollInterval,
+		})
+		if err != nil {
+			return err
+		}
+		s.ConfigStores = append(s.ConfigStores, configController)
 	} else {
 		configController, err := s.makeKubeConfigController(args)
 		if err != nil {
@@ -37,13 +37,20 @@ type MeshArgs struct {
 
 // ConfigArgs provide configuration options for the configuration controller. If FileDir is set, that directory will
 // be monitored for CRD yaml files and will update the controller as those files change (This is used for testing
-// purposes). Otherwise, a CRD client is created based on the configuration.
+// purposes). If GitRepository is set, the yaml files of a branch of that repository are followed instead.
+// Otherwise, a CRD client is created based on the configuration.
 type ConfigArgs struct {
 	ControllerOptions          kubecontroller.Options
 	ClusterRegistriesNamespace string
 	KubeConfig                 string
 	FileDir                    string
 
+	// Git repository, as a path or a file:// URL, whose branch is followed for config yaml files
+	GitRepository   string
+	GitBranch       string
+	GitPath         string
+	GitPollInterval time.Duration
+
 	// DistributionTracking control
 	DistributionCacheRetention time.Duration
 
@@ -51,6 +58,9 @@ type ConfigArgs struct {
 
 	// DistributionTracking control
 	DistributionTrackingEnabled bool
+
+	// ConfigHistory control
+	ConfigHistoryEnabled bool
 }
 
 // ConsulArgs provides configuration for the Consul service registry.
@@ -58,10 +68,30 @@ type ConsulArgs struct {
 	ServerURL string
 }
 
+// EurekaArgs provides configuration for the Eureka service registry.
+type EurekaArgs struct {
+	ServerURL    string
+	PollInterval time.Duration
+}
+
+// ZookeeperArgs provides configuration for the ZooKeeper (Dubbo) service registry.
+type ZookeeperArgs struct {
+	Servers []string
+	Root    string
+}
+
+// FileRegistryArgs provides configuration for the file service registry.
+type FileRegistryArgs struct {
+	Dir string
+}
+
 // ServiceArgs provides the composite configuration for all service registries in the system.
 type ServiceArgs struct {
 	Registries []string
 	Consul     ConsulArgs
+	Eureka     EurekaArgs
+	Zookeeper  ZookeeperArgs
+	File       FileRegistryArgs
 }
 
 // PilotArgs provides all of the configuration parameters for the Pilot discovery service.
@@ -157,4 +187,5 @@ func (p *PilotArgs) applyDefaults() {
 	p.KeepaliveOptions = istiokeepalive.DefaultOption()
 	p.Config.DistributionTrackingEnabled = features.EnableDistributionTracking
 	p.Config.DistributionCacheRetention = features.DistributionHistoryRetention
+	p.Config.ConfigHistoryEnabled = features.EnableConfigHistory
 }
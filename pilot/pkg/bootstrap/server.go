@@ -48,6 +48,7 @@ import (
 	"istio.io/pkg/log"
 	"istio.io/pkg/version"
 
+	"istio.io/istio/pilot/pkg/config/history"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/leaderelection"
 	"istio.io/istio/pilot/pkg/model"
@@ -776,6 +777,14 @@ func (s *Server) initEventHandlers() error {
 
 			s.configController.RegisterEventHandler(schema.Resource().GroupVersionKind(), configHandler)
 		}
+
+		if features.EnableConfigHistory {
+			configHistory := history.NewHistory(s.configController, features.ConfigHistoryRetention, features.ConfigHistoryMaxVersions)
+			for _, schema := range schemas {
+				s.configController.RegisterEventHandler(schema.Resource().GroupVersionKind(), configHistory.Handle)
+			}
+			s.EnvoyXdsServer.ConfigHistory = configHistory
+		}
 	}
 
 	return nil
@@ -24,9 +24,12 @@ import (
 	"istio.io/istio/pilot/pkg/serviceregistry"
 	"istio.io/istio/pilot/pkg/serviceregistry/aggregate"
 	"istio.io/istio/pilot/pkg/serviceregistry/consul"
+	"istio.io/istio/pilot/pkg/serviceregistry/eureka"
 	"istio.io/istio/pilot/pkg/serviceregistry/external"
+	"istio.io/istio/pilot/pkg/serviceregistry/file"
 	kubecontroller "istio.io/istio/pilot/pkg/serviceregistry/kube/controller"
 	"istio.io/istio/pilot/pkg/serviceregistry/mock"
+	"istio.io/istio/pilot/pkg/serviceregistry/zookeeper"
 	"istio.io/istio/pkg/config/host"
 )
 
@@ -55,6 +58,16 @@ func (s *Server) initServiceControllers(args *PilotArgs) error {
 			if err := s.initConsulRegistry(serviceControllers, args); err != nil {
 				return err
 			}
+		case serviceregistry.Eureka:
+			s.initEurekaRegistry(serviceControllers, args)
+		case serviceregistry.Zookeeper:
+			if err := s.initZookeeperRegistry(serviceControllers, args); err != nil {
+				return err
+			}
+		case serviceregistry.File:
+			if err := s.initFileRegistry(serviceControllers, args); err != nil {
+				return err
+			}
 		case serviceregistry.Mock:
 			s.initMockRegistry(serviceControllers)
 		default:
@@ -107,6 +120,33 @@ func (s *Server) initConsulRegistry(serviceControllers *aggregate.Controller, ar
 	return nil
 }
 
+func (s *Server) initEurekaRegistry(serviceControllers *aggregate.Controller, args *PilotArgs) {
+	log.Infof("Eureka url: %v", args.Service.Eureka.ServerURL)
+	client := eureka.NewClient(args.Service.Eureka.ServerURL)
+	serviceControllers.AddRegistry(eureka.NewController(client, args.Service.Eureka.PollInterval, ""))
+}
+
+func (s *Server) initZookeeperRegistry(serviceControllers *aggregate.Controller, args *PilotArgs) error {
+	log.Infof("ZooKeeper servers: %v", args.Service.Zookeeper.Servers)
+	zkctl, err := zookeeper.NewController(args.Service.Zookeeper.Servers, args.Service.Zookeeper.Root, "")
+	if err != nil {
+		return fmt.Errorf("failed to create ZooKeeper controller: %v", err)
+	}
+	serviceControllers.AddRegistry(zkctl)
+
+	return nil
+}
+
+func (s *Server) initFileRegistry(serviceControllers *aggregate.Controller, args *PilotArgs) error {
+	if args.Service.File.Dir == "" {
+		return fmt.Errorf("a directory is required for the %s registry", serviceregistry.File)
+	}
+	log.Infof("File registry directory: %v", args.Service.File.Dir)
+	serviceControllers.AddRegistry(file.NewController(args.Service.File.Dir, ""))
+
+	return nil
+}
+
 func (s *Server) initMockRegistry(serviceControllers *aggregate.Controller) {
 	// MemServiceDiscovery implementation
 	discovery := mock.NewDiscovery(map[host.Name]*model.Service{}, 2)
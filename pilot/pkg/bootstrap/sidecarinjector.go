@@ -23,10 +23,14 @@ import (
 	"time"
 
 	"k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
 
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pkg/util"
 	"istio.io/pkg/env"
 
@@ -37,11 +41,11 @@ import (
 var (
 	injectionWebhookConfigName = env.RegisterStringVar("INJECTION_WEBHOOK_CONFIG_NAME", "istio-sidecar-injector",
 		"Name of the mutatingwebhookconfiguration to patch, if istioctl is not used.")
-)
 
-const (
-	// Name of the webhook config in the config - no need to change it.
-	webhookName = "sidecar-injector.istio.io"
+	injectionWebhookReconcile = env.RegisterBoolVar("INJECTION_WEBHOOK_RECONCILE", false,
+		"If enabled, the client config of the webhooks of the revision in INJECTION_WEBHOOK_CONFIG_NAME is replaced, "+
+			"and the missing ones are added with the default selectors, instead of only patching their CA bundle. "+
+			"The webhook config is never created.")
 )
 
 // Was not used in Pilot for 1.3/1.4 (injector was standalone).
@@ -84,12 +88,18 @@ func (s *Server) initSidecarInjector(args *PilotArgs) error {
 	if injectionWebhookConfigName.Get() != "" {
 		s.addStartFunc(func(stop <-chan struct{}) error {
 			// No leader election - different istiod revisions will patch their own cert.
-			if err := s.patchCertLoop(s.kubeClient, stop); err != nil {
+			if err := s.patchCertLoop(s.kubeClient, args.Revision, args.Namespace, injectionWebhookReconcile.Get(), stop); err != nil {
 				log.Errorf("failed to start patch cert loop: %v", err)
 			}
 			return nil
 		})
 	}
+	if s.kubeClient != nil {
+		s.addStartFunc(func(stop <-chan struct{}) error {
+			watchRevisions(s.kubeClient, wh, args.Revision, args.Namespace, stop)
+			return nil
+		})
+	}
 	s.injectionWebhook = wh
 	s.addStartFunc(func(stop <-chan struct{}) error {
 		go wh.Run(stop)
@@ -104,7 +114,9 @@ const delayedRetryTime = time.Second
 // - pass the existing k8s client
 // - use the K8S root instead of citadel root CA
 // - removed the watcher - the k8s CA is already mounted at startup, no more delay waiting for it
-func (s *Server) patchCertLoop(client kubernetes.Interface, stopCh <-chan struct{}) error {
+// - patch the webhooks of the revision, or reconcile them if enabled
+func (s *Server) patchCertLoop(client kubernetes.Interface, revision, namespace string, reconcile bool,
+	stopCh <-chan struct{}) error {
 
 	// K8S own CA
 	caCertPem, err := ioutil.ReadFile(s.caBundlePath)
@@ -112,21 +124,19 @@ func (s *Server) patchCertLoop(client kubernetes.Interface, stopCh <-chan struct
 		log.Warna("Skipping webhook patch, missing CA path ", s.caBundlePath)
 		return err
 	}
+	desired := inject.WebhookConfiguration(revision, namespace, caCertPem)
+	desired.Name = injectionWebhookConfigName.Get()
 
-	var retry bool
-	if err = util.PatchMutatingWebhookConfig(client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations(),
-		injectionWebhookConfigName.Get(), webhookName, caCertPem); err != nil {
-		log.Warna("Error patching Webhook ", err)
-		retry = true
-	}
+	retry := doPatch(client, desired, reconcile)
 
-	shouldPatch := make(chan struct{})
+	// Buffered, so that the informer never blocks: a pending patch covers the changes detected meanwhile
+	shouldPatch := make(chan struct{}, 1)
 
 	watchlist := cache.NewListWatchFromClient(
 		client.AdmissionregistrationV1beta1().RESTClient(),
 		"mutatingwebhookconfigurations",
 		"",
-		fields.ParseSelectorOrDie(fmt.Sprintf("metadata.name=%s", injectionWebhookConfigName.Get())))
+		fields.ParseSelectorOrDie(fmt.Sprintf("metadata.name=%s", desired.Name)))
 
 	_, controller := cache.NewInformer(
 		watchlist,
@@ -137,13 +147,11 @@ func (s *Server) patchCertLoop(client kubernetes.Interface, stopCh <-chan struct
 				oldConfig := oldObj.(*v1beta1.MutatingWebhookConfiguration)
 				newConfig := newObj.(*v1beta1.MutatingWebhookConfiguration)
 
-				if oldConfig.ResourceVersion != newConfig.ResourceVersion {
-					for i, w := range newConfig.Webhooks {
-						if w.Name == webhookName && !bytes.Equal(newConfig.Webhooks[i].ClientConfig.CABundle, caCertPem) {
-							log.Infof("Detected a change in CABundle, patching MutatingWebhookConfiguration again")
-							shouldPatch <- struct{}{}
-							break
-						}
+				if oldConfig.ResourceVersion != newConfig.ResourceVersion && needsPatch(newConfig, desired, reconcile) {
+					log.Infof("Detected a change in MutatingWebhookConfiguration %s, patching it again", desired.Name)
+					select {
+					case shouldPatch <- struct{}{}:
+					default:
 					}
 				}
 			},
@@ -160,20 +168,22 @@ func (s *Server) patchCertLoop(client kubernetes.Interface, stopCh <-chan struct
 		for {
 			select {
 			case <-delayedRetryC:
-				if retry := doPatch(client, injectionWebhookConfigName.Get(), webhookName, caCertPem); retry {
+				if retry := doPatch(client, desired, reconcile); retry {
 					delayedRetryC = time.After(delayedRetryTime)
 				} else {
 					log.Infof("Retried patch succeeded")
 					delayedRetryC = nil
 				}
 			case <-shouldPatch:
-				if retry := doPatch(client, injectionWebhookConfigName.Get(), webhookName, caCertPem); retry {
+				if retry := doPatch(client, desired, reconcile); retry {
 					if delayedRetryC == nil {
 						delayedRetryC = time.After(delayedRetryTime)
 					}
 				} else {
 					delayedRetryC = nil
 				}
+			case <-stopCh:
+				return
 			}
 		}
 	}()
@@ -181,12 +191,102 @@ func (s *Server) patchCertLoop(client kubernetes.Interface, stopCh <-chan struct
 	return nil
 }
 
-func doPatch(cs kubernetes.Interface, webhookConfigName, webhookName string, caCertPem []byte) (retry bool) {
+// needsPatch returns whether the webhooks of the revision in the config differ from the desired ones, in their
+// CA bundle or, when reconciling, in their client config or presence.
+func needsPatch(config, desired *v1beta1.MutatingWebhookConfiguration, reconcile bool) bool {
+	for _, d := range desired.Webhooks {
+		found := false
+		for _, w := range config.Webhooks {
+			if w.Name != d.Name {
+				continue
+			}
+			found = true
+			if !bytes.Equal(w.ClientConfig.CABundle, d.ClientConfig.CABundle) ||
+				(reconcile && !apiequality.Semantic.DeepEqual(w.ClientConfig, d.ClientConfig)) {
+				return true
+			}
+		}
+		if !found && reconcile {
+			return true
+		}
+	}
+	return false
+}
+
+// doPatch patches the CA bundle of the webhooks of the revision found in the config, or reconciles them.
+func doPatch(cs kubernetes.Interface, desired *v1beta1.MutatingWebhookConfiguration, reconcile bool) (retry bool) {
 	client := cs.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
-	if err := util.PatchMutatingWebhookConfig(client, webhookConfigName, webhookName, caCertPem); err != nil {
-		log.Errorf("Patch webhook failed: %v", err)
-		return true
+	if reconcile {
+		if err := util.ReconcileMutatingWebhookConfig(client, desired.DeepCopy()); err != nil {
+			log.Errorf("Reconcile webhook failed: %v", err)
+			return true
+		}
+		log.Infof("Reconciled webhook config %s", desired.Name)
+		return false
+	}
+
+	for _, w := range desired.Webhooks {
+		if err := util.PatchMutatingWebhookConfig(client, desired.Name, w.Name, w.ClientConfig.CABundle); err != nil {
+			log.Errorf("Patch webhook failed: %v", err)
+			return true
+		}
+		log.Infof("Patched webhook %s", w.Name)
 	}
-	log.Infof("Patched webhook %s", webhookName)
 	return false
 }
+
+// watchRevisions watches the injector config maps of the other revisions installed in the namespace, so that
+// the pods labeled with one of them are injected with its configuration.
+func watchRevisions(client kubernetes.Interface, wh *inject.Webhook, revision, namespace string, stopCh <-chan struct{}) {
+	watchlist := cache.NewFilteredListWatchFromClient(
+		client.CoreV1().RESTClient(),
+		"configmaps",
+		namespace,
+		func(options *metav1.ListOptions) {
+			options.LabelSelector = model.RevisionLabel
+		})
+
+	update := func(obj interface{}) {
+		cm, ok := obj.(*corev1.ConfigMap)
+		if !ok || cm.Name != inject.InjectorConfigMapName(inject.RevisionFromLabel(cm.Labels[model.RevisionLabel])) {
+			return
+		}
+		rev, config, err := inject.RevisionConfigFromConfigMap(cm)
+		if err != nil {
+			log.Errorf("Failed to load the injection config of %s: %v", cm.Name, err)
+			return
+		}
+		if rev == revision {
+			return
+		}
+		log.Infof("Updated the injection config of revision %q", inject.RevisionLabelValue(rev))
+		wh.UpdateRevision(rev, config)
+	}
+	_, controller := cache.NewInformer(
+		watchlist,
+		&corev1.ConfigMap{},
+		0,
+		cache.ResourceEventHandlerFuncs{
+			AddFunc: update,
+			UpdateFunc: func(_, newObj interface{}) {
+				update(newObj)
+			},
+			DeleteFunc: func(obj interface{}) {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				cm, ok := obj.(*corev1.ConfigMap)
+				if !ok {
+					return
+				}
+				rev := inject.RevisionFromLabel(cm.Labels[model.RevisionLabel])
+				if rev == revision || cm.Name != inject.InjectorConfigMapName(rev) {
+					return
+				}
+				log.Infof("Removed the injection config of revision %q", inject.RevisionLabelValue(rev))
+				wh.DeleteRevision(rev)
+			},
+		},
+	)
+	go controller.Run(stopCh)
+}
@@ -31,7 +31,8 @@ func hasKubeRegistry(registries []string) bool {
 
 func buildLedger(ca ConfigArgs) ledger.Ledger {
 	var result ledger.Ledger
-	if ca.DistributionTrackingEnabled {
+	// The config history labels the versions of the config with the root hash of the ledger
+	if ca.DistributionTrackingEnabled || ca.ConfigHistoryEnabled {
 		result = ledger.Make(ca.DistributionCacheRetention)
 	} else {
 		result = &model.DisabledLedger{}
@@ -15,6 +15,7 @@
 package bootstrap
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -23,6 +24,9 @@ import (
 	"istio.io/pkg/env"
 	"istio.io/pkg/log"
 
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/analyzers"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
 	"istio.io/istio/galley/pkg/config/source/kube"
 	"istio.io/istio/mixer/pkg/validate"
 	"istio.io/istio/pilot/pkg/features"
@@ -51,6 +55,7 @@ func (s *Server) initConfigValidation(args *PilotArgs) error {
 	}
 
 	// always start the validation server
+	var err error
 	params := server.Options{
 		MixerValidator: validate.NewDefaultValidator(false),
 		Schemas:        collections.Istio,
@@ -59,6 +64,11 @@ func (s *Server) initConfigValidation(args *PilotArgs) error {
 		KeyFile:        filepath.Join(dnsCertDir, "key.pem"),
 		Mux:            s.httpsMux,
 	}
+	if features.EnableValidationAnalysis {
+		if params.Analysis, err = s.initValidationAnalysis(args); err != nil {
+			return err
+		}
+	}
 	whServer, err := server.New(params)
 	if err != nil {
 		return err
@@ -119,3 +129,59 @@ func (s *Server) initConfigValidation(args *PilotArgs) error {
 	}
 	return nil
 }
+
+// initValidationAnalysis creates the options of the cross-resource validation, and watches the
+// resources of the cluster the analyzers use.
+func (s *Server) initValidationAnalysis(args *PilotArgs) (*server.AnalysisOptions, error) {
+	all := map[string]analysis.Analyzer{}
+	for _, a := range analyzers.All() {
+		all[a.Metadata().Name] = a
+	}
+	options := &server.AnalysisOptions{}
+	for _, name := range splitNames(features.ValidationAnalyzers) {
+		a, ok := all[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown validation analyzer %q", name)
+		}
+		options.Analyzers = append(options.Analyzers, a)
+	}
+	options.BlockingAnalyzers = splitNames(features.BlockingValidationAnalyzers)
+	level, ok := diag.GetUppercaseStringToLevelMap()[strings.ToUpper(features.BlockingValidationLevel)]
+	if !ok {
+		return nil, fmt.Errorf("unknown blocking validation level %q, expected one of %v",
+			features.BlockingValidationLevel, diag.GetAllLevelStrings())
+	}
+	options.BlockingLevel = level
+
+	var iface kube.Interfaces
+	if s.kubeConfig != nil {
+		iface = kube.NewInterfaces(s.kubeConfig)
+	} else {
+		var err error
+		if iface, err = kube.NewInterfacesFromConfigFile(args.Config.KubeConfig); err != nil {
+			return nil, err
+		}
+	}
+	snapshotter, err := server.NewKubeSnapshotter(iface, options.Analyzers, args.Mesh.ConfigFile,
+		args.Config.ControllerOptions.DomainSuffix)
+	if err != nil {
+		return nil, err
+	}
+	options.Snapshot = snapshotter.Snapshot
+
+	s.addStartFunc(func(stop <-chan struct{}) error {
+		go snapshotter.Run(stop)
+		return nil
+	})
+	return options, nil
+}
+
+func splitNames(s string) []string {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
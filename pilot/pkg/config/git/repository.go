@@ -0,0 +1,160 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	supportedExtensions = map[string]bool{
+		".yaml": true,
+		".yml":  true,
+	}
+
+	// scpLikeURL matches the scp-like syntax of remote repositories, such as git@example.com:config.git
+	scpLikeURL = regexp.MustCompile(`^[^/]+:`)
+)
+
+// repository is a bare mirror of a branch of a local Git repository. It runs the git command line,
+// which must be installed.
+type repository struct {
+	url    string
+	branch string
+	// dir is the Git directory of the mirror
+	dir string
+}
+
+// file is a config file read from a commit
+type file struct {
+	path string
+	data []byte
+}
+
+// newRepository creates the mirror of the branch of the repository in dir. The repository is
+// either a path or a file:// URL.
+func newRepository(repo, branch, dir string) (*repository, error) {
+	url, err := repositoryURL(repo)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRefFormat(branch); err != nil {
+		return nil, err
+	}
+	r := &repository{
+		url:    url,
+		branch: branch,
+		dir:    dir,
+	}
+	if _, err := r.git("init", "--quiet", "--bare", dir); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// repositoryURL returns the URL of a local repository given as a path or a file:// URL. Remote
+// repositories are not supported.
+func repositoryURL(repo string) (string, error) {
+	if repo == "" {
+		return "", fmt.Errorf("no Git repository configured")
+	}
+	if strings.HasPrefix(repo, "file://") {
+		return repo, nil
+	}
+	if strings.Contains(repo, "://") || (!filepath.IsAbs(repo) && scpLikeURL.MatchString(repo)) {
+		return "", fmt.Errorf("unsupported Git repository %q: only local paths and file:// URLs are supported", repo)
+	}
+	abs, err := filepath.Abs(repo)
+	if err != nil {
+		return "", fmt.Errorf("invalid Git repository %q: %v", repo, err)
+	}
+	return abs, nil
+}
+
+func checkRefFormat(branch string) error {
+	if _, err := exec.Command("git", "check-ref-format", "--branch", branch).Output(); err != nil {
+		return fmt.Errorf("invalid Git branch %q", branch)
+	}
+	return nil
+}
+
+// trackingRef is the reference of the mirror following the branch
+func (r *repository) trackingRef() string {
+	return "refs/remotes/origin/" + r.branch
+}
+
+// fetch fetches the branch and returns the commit it points to
+func (r *repository) fetch() (string, error) {
+	refspec := fmt.Sprintf("+refs/heads/%s:%s", r.branch, r.trackingRef())
+	if _, err := r.git("--git-dir", r.dir, "fetch", "--quiet", "--no-tags", r.url, refspec); err != nil {
+		return "", err
+	}
+	out, err := r.git("--git-dir", r.dir, "rev-parse", "--verify", r.trackingRef()+"^{commit}")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// readFiles returns the YAML files under root at the commit, sorted by path
+func (r *repository) readFiles(commit, root string) ([]file, error) {
+	args := []string{"--git-dir", r.dir, "ls-tree", "-r", "-z", commit}
+	if root = strings.Trim(path.Clean("/"+root), "/"); root != "" {
+		args = append(args, "--", root)
+	}
+	out, err := r.git(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []file
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// Entries are "<mode> <type> <object>\t<path>"
+		tab := bytes.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(string(entry[:tab]))
+		name := string(entry[tab+1:])
+		if len(fields) != 3 || fields[1] != "blob" || !supportedExtensions[path.Ext(name)] {
+			continue
+		}
+		data, err := r.git("--git-dir", r.dir, "cat-file", "blob", fields[2])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{path: name, data: data})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+func (r *repository) git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s failed: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
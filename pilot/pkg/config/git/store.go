@@ -0,0 +1,254 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package git provides a config store following a branch of a Git repository
+package git
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/config/monitor"
+	"istio.io/istio/pilot/pkg/model"
+)
+
+const (
+	// DefaultBranch is the branch followed when none is configured
+	DefaultBranch = "master"
+	// DefaultPollInterval is the interval between two fetches of the repository
+	DefaultPollInterval = 10 * time.Second
+
+	// versionLen is the length of the abbreviated commit SHA used as store version. Proxies
+	// receive the version as the prefix of the xDS nonces, which the debug endpoints expect to
+	// be 12 characters long.
+	versionLen = 12
+
+	// maxVersions is the number of applied commits whose version can be looked up in the ledger
+	maxVersions = 100
+)
+
+// Options configure the Git config store
+type Options struct {
+	// Repository is the path or the file:// URL of the Git repository
+	Repository string
+	// Branch is the branch to follow, DefaultBranch when empty
+	Branch string
+	// Path is the directory of the repository holding the config files, the root when empty
+	Path string
+	// PollInterval is the interval between two fetches, DefaultPollInterval when zero
+	PollInterval time.Duration
+	// CacheDir is where the repository is mirrored. A temporary directory, removed when the
+	// store stops, is used when empty.
+	CacheDir string
+}
+
+// Store is a config store following a branch of a Git repository. It periodically fetches the
+// branch and, whenever it moves, parses the YAML files of the new commit like the files of a
+// monitor.FileSnapshot and applies the difference to an in-memory store, which dispatches the
+// events. The version of the store is the abbreviated SHA of the commit applied.
+type Store struct {
+	model.ConfigStoreCache
+
+	options  Options
+	repo     *repository
+	snapshot *monitor.FileSnapshot
+	monitor  *monitor.Monitor
+	tempDir  bool
+
+	// The fields below are only used by the goroutine running the store
+	// fetched is the last commit fetched, which may have failed to parse
+	fetched string
+	// configs are the configs of the commit applied
+	configs []*model.Config
+	// pending is the commit of the snapshot being applied
+	pending string
+
+	mutex sync.RWMutex
+	// commit is the commit applied
+	commit string
+	synced bool
+	// ledgerVersions maps the version of the recently applied commits to the version of the
+	// ledger once they were applied, oldest first in versions
+	ledgerVersions map[string]string
+	versions       []string
+}
+
+var _ model.ConfigStoreCache = &Store{}
+
+// NewStore creates a store applying the config of the repository to an in-memory store, which
+// should be empty.
+func NewStore(store model.ConfigStore, options Options) (*Store, error) {
+	if options.Branch == "" {
+		options.Branch = DefaultBranch
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = DefaultPollInterval
+	}
+	tempDir := false
+	if options.CacheDir == "" {
+		dir, err := ioutil.TempDir("", "istio-config-git")
+		if err != nil {
+			return nil, err
+		}
+		options.CacheDir = dir
+		tempDir = true
+	}
+
+	repo, err := newRepository(options.Repository, options.Branch, options.CacheDir)
+	if err != nil {
+		if tempDir {
+			_ = os.RemoveAll(options.CacheDir)
+		}
+		return nil, err
+	}
+
+	controller := memory.NewController(store)
+	s := &Store{
+		ConfigStoreCache: controller,
+		options:          options,
+		repo:             repo,
+		snapshot:         monitor.NewFileSnapshot("", store.Schemas()),
+		tempDir:          tempDir,
+		ledgerVersions:   make(map[string]string),
+	}
+	s.monitor = monitor.NewMonitor("git-monitor", controller, s.readCommit, "")
+	return s, nil
+}
+
+// Commit returns the SHA of the commit applied, empty until the first commit is applied.
+func (s *Store) Commit() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.commit
+}
+
+// Version returns the abbreviated SHA of the commit applied.
+func (s *Store) Version() string {
+	return abbreviate(s.Commit())
+}
+
+// GetResourceAtVersion returns the resource version of the config when the commit of the given
+// version was applied.
+func (s *Store) GetResourceAtVersion(version string, key string) (string, error) {
+	s.mutex.RLock()
+	ledgerVersion, ok := s.ledgerVersions[version]
+	s.mutex.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("unknown config version %q, it may have expired", version)
+	}
+	return s.ConfigStoreCache.GetResourceAtVersion(ledgerVersion, key)
+}
+
+// HasSynced returns true once the repository has been fetched a first time, even if this failed,
+// so that an unavailable repository does not prevent serving the other config.
+func (s *Store) HasSynced() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.synced
+}
+
+// Run fetches the repository periodically until a signal is received
+func (s *Store) Run(stop <-chan struct{}) {
+	go s.ConfigStoreCache.Run(stop)
+
+	s.update()
+	s.mutex.Lock()
+	s.synced = true
+	s.mutex.Unlock()
+
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.update()
+		case <-stop:
+			if s.tempDir {
+				_ = os.RemoveAll(s.options.CacheDir)
+			}
+			return
+		}
+	}
+}
+
+// update applies the commit the branch points to, if it is new and valid
+func (s *Store) update() {
+	s.monitor.CheckAndUpdate()
+	if s.pending == "" {
+		return
+	}
+
+	commit := s.pending
+	s.pending = ""
+	version := abbreviate(commit)
+	s.mutex.Lock()
+	s.commit = commit
+	if _, exists := s.ledgerVersions[version]; !exists {
+		s.versions = append(s.versions, version)
+	}
+	s.ledgerVersions[version] = s.GetLedger().RootHash()
+	for len(s.versions) > maxVersions {
+		delete(s.ledgerVersions, s.versions[0])
+		s.versions = s.versions[1:]
+	}
+	s.mutex.Unlock()
+	log.Infof("Applied config of commit %s of %s branch %s", commit, s.options.Repository, s.options.Branch)
+}
+
+// readCommit fetches the branch and returns the configs of the commit it points to. A commit
+// failing to parse is reported once, then ignored until the branch moves again.
+func (s *Store) readCommit() ([]*model.Config, error) {
+	commit, err := s.repo.fetch()
+	if err != nil {
+		return nil, err
+	}
+	if commit == s.fetched {
+		return s.configs, nil
+	}
+
+	files, err := s.repo.readFiles(commit, s.options.Path)
+	if err != nil {
+		return nil, err
+	}
+	s.fetched = commit
+
+	var configs []*model.Config
+	for _, f := range files {
+		parsed, err := s.snapshot.ParseConfigData(f.data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s at commit %s: %v", f.path, commit, err)
+		}
+		configs = append(configs, parsed...)
+	}
+	// The monitor expects the configs sorted by key
+	sort.SliceStable(configs, func(i, j int) bool { return configs[i].Key() < configs[j].Key() })
+
+	s.configs = configs
+	s.pending = commit
+	return configs, nil
+}
+
+func abbreviate(commit string) string {
+	if len(commit) > versionLen {
+		return commit[:versionLen]
+	}
+	return commit
+}
@@ -0,0 +1,281 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+const gatewayYAML = `
+apiVersion: networking.istio.io/v1alpha3
+kind: Gateway
+metadata:
+  name: some-ingress
+  namespace: istio-system
+spec:
+  servers:
+  - port:
+      number: %d
+      name: http
+      protocol: http
+    hosts:
+    - "*.example.com"
+`
+
+const virtualServiceYAML = `
+apiVersion: networking.istio.io/v1alpha3
+kind: VirtualService
+metadata:
+  name: route-for-myapp
+  namespace: default
+spec:
+  hosts:
+  - some.example.com
+  http:
+  - route:
+    - destination:
+        host: some.example.internal
+`
+
+// testRepository is a Git repository with a working tree, committing the files written
+type testRepository struct {
+	t   *testing.T
+	dir string
+}
+
+func newTestRepository(t *testing.T) *testRepository {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir, err := ioutil.TempDir("", "git-store-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRepository{t: t, dir: dir}
+	r.git("init", "--quiet")
+	r.git("checkout", "--quiet", "-b", "config")
+	return r
+}
+
+func (r *testRepository) git(args ...string) string {
+	r.t.Helper()
+	args = append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com", "-C", r.dir}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %v failed: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// commit writes the files, removing those with no content, and returns the SHA of the commit
+func (r *testRepository) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		p := filepath.Join(r.dir, name)
+		if content == "" {
+			r.git("rm", "--quiet", name)
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			r.t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			r.t.Fatal(err)
+		}
+		r.git("add", name)
+	}
+	r.git("commit", "--quiet", "--allow-empty", "-m", "update")
+	return r.git("rev-parse", "HEAD")
+}
+
+type event struct {
+	kind  string
+	name  string
+	event model.Event
+}
+
+func TestStore(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+
+	first := repo.commit(map[string]string{
+		"mesh/gateway.yaml": strings.Replace(gatewayYAML, "%d", "80", 1),
+		"README.md":         "not config",
+		"other/vs.yaml":     virtualServiceYAML,
+	})
+
+	store, err := NewStore(memory.Make(collections.Pilot), Options{
+		Repository: "file://" + repo.dir,
+		Branch:     "config",
+		Path:       "mesh",
+	})
+	if err != nil {
+		t.Fatalf("NewStore() failed: %v", err)
+	}
+	defer os.RemoveAll(store.options.CacheDir)
+
+	events := make(chan event, 10)
+	for _, s := range collections.Pilot.All() {
+		store.RegisterEventHandler(s.Resource().GroupVersionKind(), func(_, cfg model.Config, e model.Event) {
+			events <- event{cfg.Type, cfg.Name, e}
+		})
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go store.ConfigStoreCache.Run(stop)
+
+	expectEvents := func(t *testing.T, want ...event) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case got := <-events:
+				if got != w {
+					t.Fatalf("got event %v, want %v", got, w)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for event %v", w)
+			}
+		}
+		select {
+		case got := <-events:
+			t.Fatalf("unexpected event %v", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	expectVersion := func(t *testing.T, commit string) {
+		t.Helper()
+		if store.Commit() != commit {
+			t.Fatalf("Commit() => %q, want %q", store.Commit(), commit)
+		}
+		if store.Version() != commit[:versionLen] {
+			t.Fatalf("Version() => %q, want %q", store.Version(), commit[:versionLen])
+		}
+	}
+
+	store.update()
+	expectEvents(t, event{"Gateway", "some-ingress", model.EventAdd})
+	expectVersion(t, first)
+
+	// Files outside of the path are ignored
+	second := repo.commit(map[string]string{
+		"mesh/gateway.yaml":  strings.Replace(gatewayYAML, "%d", "8080", 1),
+		"mesh/routes/vs.yml": virtualServiceYAML,
+	})
+	store.update()
+	expectEvents(t,
+		event{"Gateway", "some-ingress", model.EventUpdate},
+		event{"VirtualService", "route-for-myapp", model.EventAdd},
+	)
+	expectVersion(t, second)
+	gw := store.Get(collections.IstioNetworkingV1Alpha3Gateways.Resource().GroupVersionKind(), "some-ingress", "istio-system")
+	if gw == nil || gw.Spec.(*networking.Gateway).Servers[0].Port.Number != 8080 {
+		t.Fatalf("Get() => %v, want the gateway of the second commit", gw)
+	}
+
+	// An invalid commit is not applied
+	repo.commit(map[string]string{"mesh/broken.yaml": "kind: [VirtualService"})
+	store.update()
+	expectEvents(t)
+	expectVersion(t, second)
+
+	// Fixing the branch applies the fixed commit
+	fixed := repo.commit(map[string]string{
+		"mesh/broken.yaml":   "",
+		"mesh/routes/vs.yml": "",
+	})
+	store.update()
+	expectEvents(t, event{"VirtualService", "route-for-myapp", model.EventDelete})
+	expectVersion(t, fixed)
+
+	// Nothing changes while the branch does not move
+	store.update()
+	expectEvents(t)
+	expectVersion(t, fixed)
+
+	if _, err := store.GetResourceAtVersion(first[:versionLen], "any"); err != nil {
+		t.Fatalf("GetResourceAtVersion() of an applied commit failed: %v", err)
+	}
+	if _, err := store.GetResourceAtVersion("unknown", "any"); err == nil {
+		t.Fatal("GetResourceAtVersion() of an unknown version succeeded")
+	}
+}
+
+func TestStoreRun(t *testing.T) {
+	repo := newTestRepository(t)
+	defer os.RemoveAll(repo.dir)
+	commit := repo.commit(map[string]string{"vs.yaml": virtualServiceYAML})
+
+	store, err := NewStore(memory.Make(collections.Pilot), Options{
+		Repository:   repo.dir,
+		Branch:       "config",
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewStore() failed: %v", err)
+	}
+	if store.HasSynced() {
+		t.Fatal("HasSynced() => true before running")
+	}
+
+	stop := make(chan struct{})
+	go store.Run(stop)
+	defer close(stop)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !store.HasSynced() || store.Commit() != commit {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for commit %s, got %q", commit, store.Commit())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	configs, _ := store.List(collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind(), "")
+	if len(configs) != 1 {
+		t.Fatalf("List() => %v, want the virtual service", configs)
+	}
+}
+
+func TestRepositoryURL(t *testing.T) {
+	cases := []struct {
+		repo    string
+		wantErr bool
+	}{
+		{"/srv/config.git", false},
+		{"file:///srv/config.git", false},
+		{"config", false},
+		{"", true},
+		{"https://example.com/config.git", true},
+		{"ssh://git@example.com/config.git", true},
+		{"git@example.com:config.git", true},
+	}
+	for _, c := range cases {
+		_, err := repositoryURL(c.repo)
+		if (err != nil) != c.wantErr {
+			t.Errorf("repositoryURL(%q) => error %v, want error %v", c.repo, err, c.wantErr)
+		}
+	}
+}
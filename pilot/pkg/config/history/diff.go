@@ -0,0 +1,155 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collection"
+)
+
+// Change is the change of a config between two versions
+type Change struct {
+	// Event is "add", "update" or "delete"
+	Event     string `json:"event"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+
+	// From is the config before the change, nil when added
+	From *model.Config `json:"-"`
+	// To is the config after the change, nil when deleted
+	To *model.Config `json:"-"`
+}
+
+// Diff returns the changes from a set of configs to another, sorted by key. Configs are compared
+// on their spec, labels and annotations: a new resource version alone is not a change.
+func Diff(from, to []model.Config) []Change {
+	fromByKey := make(map[string]*model.Config, len(from))
+	for i := range from {
+		fromByKey[from[i].Key()] = &from[i]
+	}
+	toByKey := make(map[string]*model.Config, len(to))
+	for i := range to {
+		toByKey[to[i].Key()] = &to[i]
+	}
+
+	var changes []Change
+	for key, cfg := range toByKey {
+		prev, exists := fromByKey[key]
+		if !exists {
+			changes = append(changes, newChange(model.EventAdd, nil, cfg))
+		} else if !equal(prev, cfg) {
+			changes = append(changes, newChange(model.EventUpdate, prev, cfg))
+		}
+	}
+	for key, prev := range fromByKey {
+		if _, exists := toByKey[key]; !exists {
+			changes = append(changes, newChange(model.EventDelete, prev, nil))
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].key() < changes[j].key() })
+	return changes
+}
+
+func newChange(event model.Event, from, to *model.Config) Change {
+	cfg := to
+	if cfg == nil {
+		cfg = from
+	}
+	return Change{
+		Event:     event.String(),
+		Kind:      cfg.Type,
+		Name:      cfg.Name,
+		Namespace: cfg.Namespace,
+		From:      from,
+		To:        to,
+	}
+}
+
+func (c Change) key() string {
+	return model.Key(c.Kind, c.Name, c.Namespace)
+}
+
+func equal(a, b *model.Config) bool {
+	return proto.Equal(a.Spec, b.Spec) &&
+		reflect.DeepEqual(a.Labels, b.Labels) &&
+		reflect.DeepEqual(a.Annotations, b.Annotations)
+}
+
+// WriteYAML writes the configs as a multi-document YAML file of Kubernetes resources. Resource
+// versions are left out, so that the resources can be applied over the current ones.
+func WriteYAML(w io.Writer, schemas collection.Schemas, configs []model.Config) error {
+	for i, cfg := range configs {
+		s, exists := schemas.FindByGroupVersionKind(cfg.GroupVersionKind())
+		if !exists {
+			return fmt.Errorf("unknown kind %q of %s/%s", cfg.Type, cfg.Namespace, cfg.Name)
+		}
+		cfg.ResourceVersion = ""
+		obj, err := crd.ConvertConfig(s, cfg)
+		if err != nil {
+			return fmt.Errorf("could not convert %s %s/%s: %v", cfg.Type, cfg.Namespace, cfg.Name, err)
+		}
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("could not convert %s %s/%s to YAML: %v", cfg.Type, cfg.Namespace, cfg.Name, err)
+		}
+		if i > 0 {
+			if _, err := w.Write([]byte("---\n")); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RollbackBundle returns the YAML restoring the state of the changes: the configs added or updated
+// are to be applied, and the configs deleted are listed in comments at the top of the bundle, as
+// applying YAML cannot delete resources.
+func RollbackBundle(schemas collection.Schemas, changes []Change) ([]byte, error) {
+	var buf bytes.Buffer
+	var apply []model.Config
+	var deletes []string
+	for _, c := range changes {
+		if c.To == nil {
+			deletes = append(deletes, fmt.Sprintf("#   kubectl delete %s %s -n %s\n", strings.ToLower(c.Kind), c.Name, c.Namespace))
+			continue
+		}
+		apply = append(apply, *c.To)
+	}
+	if len(deletes) > 0 {
+		buf.WriteString("# The following resources must also be deleted:\n")
+		for _, d := range deletes {
+			buf.WriteString(d)
+		}
+	}
+	if err := WriteYAML(&buf, schemas, apply); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
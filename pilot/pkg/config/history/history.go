@@ -0,0 +1,281 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package history keeps track of the recent versions of the config store, so that past config can
+// be looked at, compared and restored.
+package history
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collection"
+)
+
+// Version describes a version of the config store kept in the history
+type Version struct {
+	// Version of the config store, which is the root hash of the ledger or, for a Git config
+	// store, the abbreviated SHA of the commit
+	Version string `json:"version"`
+	// Time the version was recorded
+	Time time.Time `json:"time"`
+	// Configs is the number of configs in the version
+	Configs int `json:"configs"`
+
+	// seq orders the versions recorded, starting at 1
+	seq int
+}
+
+// revision is the content of a config from a version until another
+type revision struct {
+	config model.Config
+	// since is the sequence number of the first version with the revision
+	since int
+	// until is the sequence number of the first version without the revision, zero while it is
+	// the current one
+	until int
+}
+
+// History keeps track of the versions of a config store. It is fed by the event handlers of the
+// store, so that nothing is done on the push path: each change records the version of the store
+// it results in, and the content of the config changed. The state of the store at a version is
+// rebuilt from the changes, so that the configs which did not change are not copied.
+//
+// A version is kept until it is older than the retention, or until the maximum number of versions
+// is exceeded. The latest version is always kept.
+type History struct {
+	store       model.ConfigStore
+	retention   time.Duration
+	maxVersions int
+
+	mutex sync.RWMutex
+	// versions, oldest first
+	versions []Version
+	// revisions of each config by key, oldest first
+	revisions map[string][]*revision
+	// live is the number of configs currently in the store
+	live int
+
+	// now is replaced by tests
+	now func() time.Time
+}
+
+// NewHistory creates a history of the store keeping at most maxVersions versions, for the
+// retention duration. A zero retention keeps versions regardless of their age.
+func NewHistory(store model.ConfigStore, retention time.Duration, maxVersions int) *History {
+	return &History{
+		store:       store,
+		retention:   retention,
+		maxVersions: maxVersions,
+		revisions:   make(map[string][]*revision),
+		now:         time.Now,
+	}
+}
+
+// Handle is a config event handler recording the change of a config, and the version of the store
+// once changed. Several changes resulting in the same version, such as the configs of a commit of a
+// Git config store, are recorded in that version. Stores which do not track versions are not recorded.
+func (h *History) Handle(_, curr model.Config, event model.Event) {
+	version := h.store.Version()
+	if version == "" {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	latest := h.latestLocked()
+	seq := 1
+	if latest != nil {
+		seq = latest.seq
+		if latest.Version != version {
+			seq++
+		}
+	}
+
+	key := curr.Key()
+	revisions := h.revisions[key]
+	var current *revision
+	if n := len(revisions); n > 0 && revisions[n-1].until == 0 {
+		current = revisions[n-1]
+	}
+	switch {
+	case event == model.EventDelete:
+		if current == nil {
+			return
+		}
+		h.replaceLocked(key, current, nil, seq)
+		h.live--
+	case current == nil:
+		h.replaceLocked(key, nil, &revision{config: curr, since: seq}, seq)
+		h.live++
+	case curr.ResourceVersion != "" && curr.ResourceVersion == current.config.ResourceVersion:
+		// Resynced without change
+		return
+	default:
+		h.replaceLocked(key, current, &revision{config: curr, since: seq}, seq)
+	}
+
+	if latest != nil && latest.seq == seq {
+		latest.Configs = h.live
+		return
+	}
+	h.versions = append(h.versions, Version{
+		Version: version,
+		Time:    h.now(),
+		Configs: h.live,
+		seq:     seq,
+	})
+	h.pruneLocked()
+}
+
+// replaceLocked ends the current revision of a config at the version, and appends the next one
+func (h *History) replaceLocked(key string, current, next *revision, seq int) {
+	revisions := h.revisions[key]
+	if current != nil {
+		current.until = seq
+		if current.since == seq {
+			// Replaced within the version it was added in, no version has it
+			revisions = revisions[:len(revisions)-1]
+		}
+	}
+	if next != nil {
+		revisions = append(revisions, next)
+	}
+	if len(revisions) == 0 {
+		delete(h.revisions, key)
+		return
+	}
+	h.revisions[key] = revisions
+}
+
+func (h *History) pruneLocked() {
+	drop := 0
+	if h.maxVersions > 0 && len(h.versions) > h.maxVersions {
+		drop = len(h.versions) - h.maxVersions
+	}
+	if h.retention > 0 {
+		oldest := h.now().Add(-h.retention)
+		for drop < len(h.versions)-1 && h.versions[drop].Time.Before(oldest) {
+			drop++
+		}
+	}
+	if drop == 0 {
+		return
+	}
+	h.versions = append([]Version(nil), h.versions[drop:]...)
+
+	// Revisions which ended before the oldest version are no longer needed
+	oldest := h.versions[0].seq
+	for key, revisions := range h.revisions {
+		keep := 0
+		for keep < len(revisions) && revisions[keep].until != 0 && revisions[keep].until <= oldest {
+			keep++
+		}
+		if keep == len(revisions) {
+			delete(h.revisions, key)
+		} else if keep > 0 {
+			h.revisions[key] = append([]*revision(nil), revisions[keep:]...)
+		}
+	}
+}
+
+func (h *History) latestLocked() *Version {
+	if len(h.versions) == 0 {
+		return nil
+	}
+	return &h.versions[len(h.versions)-1]
+}
+
+// snapshotLocked rebuilds the configs of a version, sorted by key
+func (h *History) snapshotLocked(version string) ([]model.Config, error) {
+	var v *Version
+	// The same version may be recorded again after going back to a previous state; the latest wins.
+	for i := len(h.versions) - 1; i >= 0 && v == nil; i-- {
+		if h.versions[i].Version == version {
+			v = &h.versions[i]
+		}
+	}
+	if v == nil {
+		return nil, fmt.Errorf("config version %q is not in the history", version)
+	}
+
+	var out []model.Config
+	for _, revisions := range h.revisions {
+		for _, r := range revisions {
+			if r.since <= v.seq && (r.until == 0 || r.until > v.seq) {
+				out = append(out, r.config)
+				break
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
+	return out, nil
+}
+
+// Schemas returns the schemas of the recorded configs
+func (h *History) Schemas() collection.Schemas {
+	return h.store.Schemas()
+}
+
+// Versions returns the versions in the history, oldest first
+func (h *History) Versions() []Version {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return append([]Version(nil), h.versions...)
+}
+
+// Latest returns the latest version recorded, empty when none is.
+func (h *History) Latest() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if latest := h.latestLocked(); latest != nil {
+		return latest.Version
+	}
+	return ""
+}
+
+// Snapshot returns the configs of a version, sorted by key
+func (h *History) Snapshot(version string) ([]model.Config, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.snapshotLocked(version)
+}
+
+// Diff returns the changes from a version to another
+func (h *History) Diff(from, to string) ([]Change, error) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	fromConfigs, err := h.snapshotLocked(from)
+	if err != nil {
+		return nil, err
+	}
+	toConfigs, err := h.snapshotLocked(to)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(fromConfigs, toConfigs), nil
+}
+
+// Rollback returns the changes restoring a version from the latest version
+func (h *History) Rollback(version string) ([]Change, error) {
+	latest := h.Latest()
+	if latest == "" {
+		return nil, fmt.Errorf("no config version is recorded")
+	}
+	return h.Diff(latest, version)
+}
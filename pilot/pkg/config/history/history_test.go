@@ -0,0 +1,280 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package history
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	networking "istio.io/api/networking/v1alpha3"
+
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pilot/pkg/config/memory"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/schema/collections"
+)
+
+var gvk = collections.IstioNetworkingV1Alpha3Virtualservices.Resource().GroupVersionKind()
+
+func virtualService(name, host string) model.Config {
+	return model.Config{
+		ConfigMeta: model.ConfigMeta{
+			Type:      gvk.Kind,
+			Group:     gvk.Group,
+			Version:   gvk.Version,
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: &networking.VirtualService{
+			Hosts: []string{host},
+			Http: []*networking.HTTPRoute{{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: host}}},
+			}},
+		},
+	}
+}
+
+// versionedStore numbers its versions, like the commits of a Git config store, so that they
+// change on every step
+type versionedStore struct {
+	model.ConfigStore
+	version string
+}
+
+func (s *versionedStore) Version() string {
+	return s.version
+}
+
+// step changes the store and returns the config changed and the event, as a config event handler
+// of the store would receive them
+type step func(store model.ConfigStore) (model.Config, model.Event)
+
+var lastVersion int
+
+func newTestHistory(retention time.Duration, maxVersions int) (*History, *versionedStore) {
+	store := &versionedStore{ConfigStore: memory.Make(collections.Pilot)}
+	return NewHistory(store, retention, maxVersions), store
+}
+
+// historyOf applies each step in a new version of the store and returns the versions
+func historyOf(t *testing.T, h *History, store *versionedStore, steps ...step) []string {
+	t.Helper()
+	var versions []string
+	for _, step := range steps {
+		lastVersion++
+		store.version = fmt.Sprintf("v%d", lastVersion)
+		cfg, event := step(store)
+		h.Handle(model.Config{}, cfg, event)
+		versions = append(versions, store.version)
+	}
+	return versions
+}
+
+func create(cfg model.Config) step {
+	return func(store model.ConfigStore) (model.Config, model.Event) {
+		if _, err := store.Create(cfg); err != nil {
+			panic(err)
+		}
+		return *store.Get(gvk, cfg.Name, cfg.Namespace), model.EventAdd
+	}
+}
+
+func update(cfg model.Config) step {
+	return func(store model.ConfigStore) (model.Config, model.Event) {
+		cfg.ResourceVersion = store.Get(gvk, cfg.Name, cfg.Namespace).ResourceVersion
+		if _, err := store.Update(cfg); err != nil {
+			panic(err)
+		}
+		return *store.Get(gvk, cfg.Name, cfg.Namespace), model.EventUpdate
+	}
+}
+
+func remove(name string) step {
+	return func(store model.ConfigStore) (model.Config, model.Event) {
+		cfg := *store.Get(gvk, name, "default")
+		if err := store.Delete(gvk, name, "default"); err != nil {
+			panic(err)
+		}
+		return cfg, model.EventDelete
+	}
+}
+
+func TestHistory(t *testing.T) {
+	h, store := newTestHistory(0, 0)
+	versions := historyOf(t, h, store,
+		create(virtualService("reviews", "reviews.default.svc.cluster.local")),
+		create(virtualService("ratings", "ratings.default.svc.cluster.local")),
+		update(virtualService("reviews", "reviews-v2.default.svc.cluster.local")),
+		remove("ratings"),
+	)
+
+	got := h.Versions()
+	if len(got) != 4 {
+		t.Fatalf("Versions() => %v, want 4 versions", got)
+	}
+	for i, v := range got {
+		if v.Version != versions[i] {
+			t.Errorf("Versions()[%d] => %s, want %s", i, v.Version, versions[i])
+		}
+	}
+	if h.Latest() != versions[3] {
+		t.Errorf("Latest() => %s, want %s", h.Latest(), versions[3])
+	}
+
+	snapshot, err := h.Snapshot(versions[1])
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if len(snapshot) != 2 || snapshot[0].Name != "ratings" || snapshot[1].Name != "reviews" {
+		t.Fatalf("Snapshot() => %v, want ratings and reviews", snapshot)
+	}
+	if _, err := h.Snapshot("unknown"); err == nil {
+		t.Fatal("Snapshot() of an unknown version succeeded")
+	}
+
+	changes, err := h.Diff(versions[1], versions[3])
+	if err != nil {
+		t.Fatalf("Diff() failed: %v", err)
+	}
+	want := []Change{
+		{Event: "delete", Kind: gvk.Kind, Name: "ratings", Namespace: "default"},
+		{Event: "update", Kind: gvk.Kind, Name: "reviews", Namespace: "default"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Diff() => %v, want %v", changes, want)
+	}
+	for i := range want {
+		changes[i].From, changes[i].To = nil, nil
+		if !reflect.DeepEqual(changes[i], want[i]) {
+			t.Errorf("Diff()[%d] => %v, want %v", i, changes[i], want[i])
+		}
+	}
+
+	// The state at each version is read from the ledger, regardless of later changes
+	snapshot, err = h.Snapshot(versions[0])
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if len(snapshot) != 1 || snapshot[0].Spec.(*networking.VirtualService).Hosts[0] != "reviews.default.svc.cluster.local" {
+		t.Fatalf("Snapshot() => %v, want the first version of reviews", snapshot)
+	}
+}
+
+func TestHistoryGroupsChangesOfAVersion(t *testing.T) {
+	h, store := newTestHistory(0, 0)
+	versions := historyOf(t, h, store, create(virtualService("reviews", "reviews.default.svc.cluster.local")))
+
+	// Several changes to the same version, like the configs of a commit, are recorded in it
+	cfg, event := create(virtualService("ratings", "ratings.default.svc.cluster.local"))(store)
+	h.Handle(model.Config{}, cfg, event)
+	cfg, event = update(virtualService("reviews", "reviews-v2.default.svc.cluster.local"))(store)
+	h.Handle(model.Config{}, cfg, event)
+	// A resync does not change anything
+	h.Handle(model.Config{}, cfg, model.EventUpdate)
+
+	got := h.Versions()
+	if len(got) != 1 || got[0].Version != versions[0] || got[0].Configs != 2 {
+		t.Fatalf("Versions() => %v, want a single version with 2 configs", got)
+	}
+	snapshot, err := h.Snapshot(versions[0])
+	if err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+	if len(snapshot) != 2 || snapshot[1].Spec.(*networking.VirtualService).Hosts[0] != "reviews-v2.default.svc.cluster.local" {
+		t.Fatalf("Snapshot() => %v, want ratings and the last reviews", snapshot)
+	}
+	if revisions := h.revisions[cfg.Key()]; len(revisions) != 1 {
+		t.Fatalf("%d revisions of reviews are kept, want 1", len(revisions))
+	}
+}
+
+func TestHistoryIgnoresStoresWithoutVersions(t *testing.T) {
+	store := memory.MakeWithLedger(collections.Pilot, &model.DisabledLedger{})
+	h := NewHistory(store, 0, 0)
+	cfg, event := create(virtualService("reviews", "reviews.default.svc.cluster.local"))(store)
+	h.Handle(model.Config{}, cfg, event)
+	if got := h.Versions(); len(got) != 0 {
+		t.Fatalf("Versions() => %v, want none", got)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	h, store := newTestHistory(0, 0)
+	versions := historyOf(t, h, store,
+		create(virtualService("reviews", "reviews.default.svc.cluster.local")),
+		update(virtualService("reviews", "reviews-v2.default.svc.cluster.local")),
+		create(virtualService("ratings", "ratings.default.svc.cluster.local")),
+	)
+
+	changes, err := h.Rollback(versions[0])
+	if err != nil {
+		t.Fatalf("Rollback() failed: %v", err)
+	}
+	bundle, err := RollbackBundle(h.Schemas(), changes)
+	if err != nil {
+		t.Fatalf("RollbackBundle() failed: %v", err)
+	}
+	if !strings.HasPrefix(string(bundle), "# The following resources must also be deleted:\n"+
+		"#   kubectl delete virtualservice ratings -n default\n") {
+		t.Fatalf("RollbackBundle() => %s, want the deletion of ratings first", bundle)
+	}
+
+	configs, _, err := crd.ParseInputs(string(bundle))
+	if err != nil {
+		t.Fatalf("RollbackBundle() => invalid YAML %s: %v", bundle, err)
+	}
+	if len(configs) != 1 || configs[0].Name != "reviews" || configs[0].ResourceVersion != "" {
+		t.Fatalf("RollbackBundle() => %v, want reviews without resource version", configs)
+	}
+	if host := configs[0].Spec.(*networking.VirtualService).Hosts[0]; host != "reviews.default.svc.cluster.local" {
+		t.Fatalf("RollbackBundle() => host %s, want the host of the first version", host)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	now := time.Now()
+	h, store := newTestHistory(time.Hour, 3)
+	h.now = func() time.Time { return now }
+
+	step := func(i int) step {
+		return func(store model.ConfigStore) (model.Config, model.Event) {
+			now = now.Add(20 * time.Minute)
+			return update(virtualService("reviews", fmt.Sprintf("v%d.example.com", i)))(store)
+		}
+	}
+	versions := historyOf(t, h, store, create(virtualService("reviews", "example.com")), step(1), step(2), step(3))
+	if got := h.Versions(); len(got) != 3 || got[0].Version != versions[1] {
+		t.Fatalf("Versions() => %v, want the last 3 versions", got)
+	}
+
+	// Versions older than the retention are dropped, but the latest one is kept
+	now = now.Add(2 * time.Hour)
+	versions = historyOf(t, h, store, step(4))
+	if got := h.Versions(); len(got) != 1 || got[0].Version != versions[0] {
+		t.Fatalf("Versions() => %v, want the latest version only", got)
+	}
+
+	// Only the revisions of the versions kept are
+	reviews := virtualService("reviews", "")
+	if revisions := h.revisions[reviews.Key()]; len(revisions) != 1 {
+		t.Fatalf("%d revisions of reviews are kept, want 1", len(revisions))
+	}
+	if _, err := h.Snapshot(versions[0]); err != nil {
+		t.Fatalf("Snapshot() failed: %v", err)
+	}
+}
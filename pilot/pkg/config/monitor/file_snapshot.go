@@ -81,19 +81,12 @@ func (f *FileSnapshot) ReadConfigFiles() ([]*model.Config, error) {
 			log.Warnf("Failed to read %s: %v", path, err)
 			return err
 		}
-		configs, err := parseInputs(data)
+		configs, err := f.ParseConfigData(data)
 		if err != nil {
 			log.Warnf("Failed to parse %s: %v", path, err)
 			return err
 		}
-
-		// Filter any unsupported types before appending to the result.
-		for _, cfg := range configs {
-			if !f.configTypeFilter[cfg.GroupVersionKind()] {
-				continue
-			}
-			result = append(result, cfg)
-		}
+		result = append(result, configs...)
 		return nil
 	})
 	if err != nil {
@@ -105,6 +98,25 @@ func (f *FileSnapshot) ReadConfigFiles() ([]*model.Config, error) {
 	return result, err
 }
 
+// ParseConfigData parses the crd config in data and returns the model.Config of the types allowed by
+// the snapshot, in the order they appear. This is the parser used for the files of the root directory.
+func (f *FileSnapshot) ParseConfigData(data []byte) ([]*model.Config, error) {
+	configs, err := parseInputs(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// Filter any unsupported types.
+	result := make([]*model.Config, 0, len(configs))
+	for _, cfg := range configs {
+		if !f.configTypeFilter[cfg.GroupVersionKind()] {
+			continue
+		}
+		result = append(result, cfg)
+	}
+	return result, nil
+}
+
 // parseInputs is identical to crd.ParseInputs, except that it returns an array of config pointers.
 func parseInputs(data []byte) ([]*model.Config, error) {
 	configs, _, err := crd.ParseInputs(string(data))
@@ -56,7 +56,7 @@ func NewMonitor(name string, delegateStore model.ConfigStore, getSnapshotFunc fu
 // and updates the controller. It then kicks off an asynchronous event loop that
 // periodically polls the getSnapshotFunc for changes until a close event is sent.
 func (m *Monitor) Start(stop <-chan struct{}) {
-	m.checkAndUpdate()
+	m.CheckAndUpdate()
 
 	c := make(chan appsignals.Signal, 1)
 	appsignals.Watch(c)
@@ -71,7 +71,7 @@ func (m *Monitor) Start(stop <-chan struct{}) {
 			case trigger := <-c:
 				if trigger.Signal == syscall.SIGUSR1 {
 					log.Infof("Triggering reload in response to: %v", trigger.Source)
-					m.checkAndUpdate()
+					m.CheckAndUpdate()
 				}
 			case <-stop:
 				shut <- syscall.SIGTERM
@@ -81,7 +81,10 @@ func (m *Monitor) Start(stop <-chan struct{}) {
 	}()
 }
 
-func (m *Monitor) checkAndUpdate() {
+// CheckAndUpdate checks the Monitor getSnapshotFunc once and updates the store with the changes
+// found. Start calls it whenever the root directory changes; sources which are not directories,
+// such as Git repositories, may call it directly on their own schedule instead of calling Start.
+func (m *Monitor) CheckAndUpdate() {
 	newConfigs, err := m.getSnapshotFunc()
 	//If an error exists then log it and return to running the check and update
 	//Do not edit the local []*model.config until the connection has been reestablished
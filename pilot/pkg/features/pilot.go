@@ -228,6 +228,27 @@ var (
 		"If enabled, Pilot will keep track of old versions of distributed config for this duration.",
 	).Get()
 
+	EnableConfigHistory = env.RegisterBoolVar(
+		"PILOT_ENABLE_CONFIG_HISTORY",
+		false,
+		"If enabled, Pilot will keep track of the versions of its config, which can be listed, compared and rolled back to "+
+			"from the debug interface.",
+	).Get()
+
+	ConfigHistoryMaxVersions = env.RegisterIntVar(
+		"PILOT_CONFIG_HISTORY_MAX_VERSIONS",
+		20,
+		"The number of config versions Pilot keeps in its history when PILOT_ENABLE_CONFIG_HISTORY is set. "+
+			"Set to 0 to only bound the history by PILOT_CONFIG_HISTORY_RETENTION.",
+	).Get()
+
+	ConfigHistoryRetention = env.RegisterDurationVar(
+		"PILOT_CONFIG_HISTORY_RETENTION",
+		time.Hour,
+		"How long Pilot keeps the versions of its config history when PILOT_ENABLE_CONFIG_HISTORY is set. "+
+			"Set to 0 to keep them until PILOT_CONFIG_HISTORY_MAX_VERSIONS is reached.",
+	).Get()
+
 	EnableEndpointSliceController = env.RegisterBoolVar(
 		"PILOT_USE_ENDPOINT_SLICE",
 		false,
@@ -248,7 +269,35 @@ var (
 		"PILOT_ENABLE_ANALYSIS",
 		false,
 		"If enabled, pilot will run istio analyzers and write analysis errors to the Status field of any "+
-			"Istio Resources",
+			"Istio Resources. The new errors are also recorded as Kubernetes Events.",
+	).Get()
+
+	EnableValidationAnalysis = env.RegisterBoolVar(
+		"PILOT_ENABLE_VALIDATION_ANALYSIS",
+		false,
+		"If enabled, the validation webhook will run the analyzers of PILOT_VALIDATION_ANALYZERS on the incoming "+
+			"Istio Resources and the other resources of the cluster, and return their messages as warnings.",
+	).Get()
+
+	ValidationAnalyzers = env.RegisterStringVar(
+		"PILOT_VALIDATION_ANALYZERS",
+		"serviceentry.ConflictAnalyzer,virtualservice.ConflictingMeshGatewayHostsAnalyzer,"+
+			"virtualservice.GatewayAnalyzer,virtualservice.UnreachableRouteAnalyzer",
+		"Comma separated names of the analyzers run by the validation webhook, if PILOT_ENABLE_VALIDATION_ANALYSIS is enabled.",
+	).Get()
+
+	BlockingValidationAnalyzers = env.RegisterStringVar(
+		"PILOT_BLOCKING_VALIDATION_ANALYZERS",
+		"",
+		"Comma separated names of the analyzers run by the validation webhook whose messages of "+
+			"PILOT_BLOCKING_VALIDATION_LEVEL or worse reject the Istio Resources, instead of being returned as warnings.",
+	).Get()
+
+	BlockingValidationLevel = env.RegisterStringVar(
+		"PILOT_BLOCKING_VALIDATION_LEVEL",
+		"Warn",
+		"The minimum level, one of Info, Warn or Error, of the messages of PILOT_BLOCKING_VALIDATION_ANALYZERS "+
+			"rejecting the Istio Resources.",
 	).Get()
 
 	EnableStatus = env.RegisterBoolVar(
@@ -35,6 +35,7 @@ import (
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/any"
 
+	"istio.io/istio/pilot/pkg/config/history"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/util"
 	"istio.io/istio/pilot/pkg/serviceregistry"
@@ -117,6 +118,14 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 
 	s.addDebugHandler(mux, "/debug/syncz", "Synchronization status of all Envoys connected to this Pilot instance", s.Syncz)
 	s.addDebugHandler(mux, "/debug/config_distribution", "Version status of all Envoys connected to this Pilot instance", s.distributedVersions)
+	s.addDebugHandler(mux, "/debug/config_history", "Config versions kept in the history", s.configHistory)
+	s.addDebugHandler(mux, "/debug/config_history/snapshot", "Config at the given ?version= of the history, as YAML", s.configHistorySnapshot)
+	s.addDebugHandler(mux, "/debug/config_history/diff", "Config changes from the given ?from= to the given ?to= version, or to the latest one",
+		s.configHistoryDiff)
+	s.addDebugHandler(mux, "/debug/config_history/rollback", "YAML restoring the config of the given ?version= of the history",
+		s.configHistoryRollback)
+	s.addDebugHandler(mux, "/debug/config_versionz", "Config version, such as the Git commit, acked by all Envoys connected to this Pilot instance",
+		s.ConfigVersionz)
 
 	s.addDebugHandler(mux, "/debug/registryz", "Debug support for registry", s.registryz)
 	s.addDebugHandler(mux, "/debug/endpointz", "Debug support for endpoints", s.endpointz)
@@ -128,7 +137,7 @@ func (s *DiscoveryServer) InitDebug(mux *http.ServeMux, sctl *aggregate.Controll
 	s.addDebugHandler(mux, "/debug/config_dump", "ConfigDump in the form of the Envoy admin config dump API for passed in proxyID", s.ConfigDump)
 	s.addDebugHandler(mux, "/debug/push_status", "Last PushContext Details", s.PushStatusHandler)
 
-	s.addDebugHandler(mux, "/debug/inject", "Active inject template", s.InjectTemplateHandler(webhook))
+	s.addDebugHandler(mux, "/debug/inject", "Active inject templates", s.InjectTemplateHandler(webhook))
 }
 
 func (s *DiscoveryServer) addDebugHandler(mux *http.ServeMux, path string, help string,
@@ -185,6 +194,46 @@ func (s *DiscoveryServer) Syncz(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write(out)
 }
 
+// ConfigVersionStatus shows the version of the config store, such as the commit of a Git config
+// store, each type of config acked by a proxy was generated from.
+type ConfigVersionStatus struct {
+	ProxyID       string `json:"proxy,omitempty"`
+	ClusterAcked  string `json:"cluster_acked,omitempty"`
+	ListenerAcked string `json:"listener_acked,omitempty"`
+	RouteAcked    string `json:"route_acked,omitempty"`
+	EndpointAcked string `json:"endpoint_acked,omitempty"`
+}
+
+// ConfigVersionz dumps the config version acked by all Envoys connected to this Pilot instance.
+// The current config version is returned in the X-Istio-Config-Version header.
+func (s *DiscoveryServer) ConfigVersionz(w http.ResponseWriter, _ *http.Request) {
+	versions := make([]ConfigVersionStatus, 0)
+	s.adsClientsMutex.RLock()
+	for _, con := range s.adsClients {
+		con.mu.RLock()
+		if con.node != nil {
+			versions = append(versions, ConfigVersionStatus{
+				ProxyID:       con.node.ID,
+				ClusterAcked:  nonceVersion(con.ClusterNonceAcked),
+				ListenerAcked: nonceVersion(con.ListenerNonceAcked),
+				RouteAcked:    nonceVersion(con.RouteNonceAcked),
+				EndpointAcked: nonceVersion(con.EndpointNonceAcked),
+			})
+		}
+		con.mu.RUnlock()
+	}
+	s.adsClientsMutex.RUnlock()
+	out, err := json.MarshalIndent(&versions, "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal config version information: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("X-Istio-Config-Version", s.globalPushContext().Version)
+	_, _ = w.Write(out)
+}
+
 // registryz providees debug support for registry - adding and listing model items.
 // Can be combined with the push debug interface to reproduce changes.
 func (s *DiscoveryServer) registryz(w http.ResponseWriter, req *http.Request) {
@@ -321,11 +370,19 @@ func (s *DiscoveryServer) distributedVersions(w http.ResponseWriter, req *http.R
 // len = ceil(bitlength/(2^6))+1
 const VersionLen = 12
 
-func (s *DiscoveryServer) getResourceVersion(nonce, key string, cache map[string]string) string {
+// nonceVersion returns the config version a nonce was generated for
+func nonceVersion(nonce string) string {
 	if len(nonce) < VersionLen {
 		return ""
 	}
-	configVersion := nonce[:VersionLen]
+	return nonce[:VersionLen]
+}
+
+func (s *DiscoveryServer) getResourceVersion(nonce, key string, cache map[string]string) string {
+	configVersion := nonceVersion(nonce)
+	if configVersion == "" {
+		return ""
+	}
 	result, ok := cache[configVersion]
 	if !ok {
 		lookupResult, err := s.Env.IstioConfigStore.GetResourceAtVersion(configVersion, key)
@@ -341,6 +398,119 @@ func (s *DiscoveryServer) getResourceVersion(nonce, key string, cache map[string
 	return result
 }
 
+// historyEnabled writes an error when the config history is disabled
+func (s *DiscoveryServer) historyEnabled(w http.ResponseWriter) bool {
+	if s.ConfigHistory == nil {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = fmt.Fprint(w, "Pilot config history is disabled. Please set the PILOT_ENABLE_CONFIG_HISTORY "+
+			"environment variable to true to enable.")
+		return false
+	}
+	return true
+}
+
+// configHistory lists the config versions kept in the history, oldest first.
+func (s *DiscoveryServer) configHistory(w http.ResponseWriter, _ *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	out, err := json.MarshalIndent(s.ConfigHistory.Versions(), "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal config history: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// configHistorySnapshot dumps the config of a version of the history as YAML.
+func (s *DiscoveryServer) configHistorySnapshot(w http.ResponseWriter, req *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	version := req.URL.Query().Get("version")
+	if version == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(w, "querystring parameter 'version' is required")
+		return
+	}
+	configs, err := s.ConfigHistory.Snapshot(version)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	var out strings.Builder
+	if err := history.WriteYAML(&out, s.ConfigHistory.Schemas(), configs); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to convert config version %s to YAML: %v", version, err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/yaml")
+	_, _ = fmt.Fprint(w, out.String())
+}
+
+// configHistoryDiff lists the config changes between two versions of the history.
+func (s *DiscoveryServer) configHistoryDiff(w http.ResponseWriter, req *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	from, to := req.URL.Query().Get("from"), req.URL.Query().Get("to")
+	if from == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(w, "querystring parameter 'from' is required")
+		return
+	}
+	if to == "" {
+		to = s.ConfigHistory.Latest()
+	}
+	changes, err := s.ConfigHistory.Diff(from, to)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	if changes == nil {
+		changes = []history.Change{}
+	}
+	out, err := json.MarshalIndent(changes, "", "    ")
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to marshal config changes: %v", err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
+
+// configHistoryRollback returns the YAML bundle restoring the config of a version of the history.
+func (s *DiscoveryServer) configHistoryRollback(w http.ResponseWriter, req *http.Request) {
+	if !s.historyEnabled(w) {
+		return
+	}
+	version := req.URL.Query().Get("version")
+	if version == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_, _ = fmt.Fprintf(w, "querystring parameter 'version' is required")
+		return
+	}
+	changes, err := s.ConfigHistory.Rollback(version)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = fmt.Fprint(w, err)
+		return
+	}
+	out, err := history.RollbackBundle(s.ConfigHistory.Schemas(), changes)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = fmt.Fprintf(w, "unable to build the rollback to config version %s: %v", version, err)
+		return
+	}
+	w.Header().Add("Content-Type", "application/yaml")
+	_, _ = w.Write(out)
+}
+
 // Config debugging.
 func (s *DiscoveryServer) configz(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
@@ -532,19 +702,35 @@ func (s *DiscoveryServer) configDump(conn *XdsConnection) (*adminapi.ConfigDump,
 	return configDump, nil
 }
 
-// InjectTemplateHandler dumps the injection template
+// InjectTemplateHandler dumps the injection templates by name, or the template selected by the
+// template parameter.
 // Replaces dumping the template at startup.
 func (s *DiscoveryServer) InjectTemplateHandler(webhook *inject.Webhook) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, req *http.Request) {
-		// TODO: we should split the inject template into smaller modules (separate one for dump core, etc),
-		// and allow pods to select which patches will be selected. When this happen, this should return
-		// all inject templates or take a param to select one.
 		if webhook == nil {
 			w.WriteHeader(404)
 			return
 		}
 
-		_, _ = w.Write([]byte(webhook.Config.Template))
+		templates := webhook.Templates()
+		if name := req.URL.Query().Get("template"); name != "" {
+			template, ok := templates[name]
+			if !ok {
+				w.WriteHeader(404)
+				_, _ = fmt.Fprintf(w, "unknown injection template %q", name)
+				return
+			}
+			_, _ = w.Write([]byte(template))
+			return
+		}
+		out, err := json.MarshalIndent(templates, "", "  ")
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = fmt.Fprintf(w, "unable to marshal the injection templates: %v", err)
+			return
+		}
+		w.Header().Add("Content-Type", "application/json")
+		_, _ = w.Write(out)
 	}
 }
 
@@ -77,6 +77,7 @@ func TestSyncz(t *testing.T) {
 
 		node, _ := model.ParseServiceNodeWithMetadata(sidecarID(app3Ip, "syncApp"), &model.NodeMetadata{})
 		verifySyncStatus(t, s.EnvoyXdsServer, node.ID, true, true)
+		verifyConfigVersions(t, s.EnvoyXdsServer, node.ID)
 	})
 	t.Run("sync status not set when Nackd", func(t *testing.T) {
 		s, tearDown := initLocalPilotTestEnv(t)
@@ -283,6 +284,37 @@ func getConfigDump(t *testing.T, s *v2.DiscoveryServer, proxyID string, wantCode
 	return got
 }
 
+func verifyConfigVersions(t *testing.T, s *v2.DiscoveryServer, nodeID string) {
+	req, err := http.NewRequest("GET", "/debug/config_versionz", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(s.ConfigVersionz).ServeHTTP(rr, req)
+	got := []v2.ConfigVersionStatus{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, ss := range getSyncStatus(t, s) {
+		if ss.ProxyID != nodeID {
+			continue
+		}
+		for _, cv := range got {
+			if cv.ProxyID != nodeID {
+				continue
+			}
+			if cv.ClusterAcked != ss.ClusterAcked[:v2.VersionLen] {
+				t.Errorf("wanted cluster config version %v got %v for %v", ss.ClusterAcked[:v2.VersionLen], cv.ClusterAcked, nodeID)
+			}
+			if cv.ListenerAcked != ss.ListenerAcked[:v2.VersionLen] {
+				t.Errorf("wanted listener config version %v got %v for %v", ss.ListenerAcked[:v2.VersionLen], cv.ListenerAcked, nodeID)
+			}
+			return
+		}
+	}
+	t.Errorf("node id %v not found", nodeID)
+}
+
 func TestDebugHandlers(t *testing.T) {
 	server, tearDown := initLocalPilotTestEnv(t)
 	defer tearDown()
@@ -24,6 +24,7 @@ import (
 	"go.uber.org/atomic"
 	"google.golang.org/grpc"
 
+	"istio.io/istio/pilot/pkg/config/history"
 	"istio.io/istio/pilot/pkg/features"
 	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/pkg/networking/core"
@@ -129,6 +130,9 @@ type DiscoveryServer struct {
 	adsClientsMutex sync.RWMutex
 
 	StatusReporter DistributionEventHandler
+
+	// ConfigHistory keeps track of the versions of the config store, nil when disabled.
+	ConfigHistory *history.History
 }
 
 // EndpointShards holds the set of endpoint shards of a service. Registries update
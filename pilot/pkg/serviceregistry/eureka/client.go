@@ -0,0 +1,140 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client for Eureka
+type Client interface {
+	// Applications registered on the Eureka server
+	Applications() (*Applications, error)
+}
+
+// Minimal client for Eureka server's REST APIs.
+// TODO: support multiple Eureka servers
+type client struct {
+	client http.Client
+	url    string
+}
+
+// Applications is the root of the response returned by the Eureka /apps endpoint.
+type Applications struct {
+	Applications []*Application `json:"application"`
+}
+
+// Application groups the instances registered under the same application name.
+type Application struct {
+	Name      string      `json:"name"`
+	Instances []*Instance `json:"instance"`
+}
+
+// Instance of an application registered with Eureka.
+type Instance struct { // nolint: maligned
+	InstanceID string            `json:"instanceId,omitempty"`
+	Hostname   string            `json:"hostName"`
+	App        string            `json:"app"`
+	IPAddress  string            `json:"ipAddr"`
+	Status     string            `json:"status"`
+	Port       *Port             `json:"port,omitempty"`
+	SecurePort *Port             `json:"securePort,omitempty"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+// Port of an instance. Eureka serializes ports as {"$": 8080, "@enabled": "true"}.
+type Port struct {
+	Port    int  `json:"$"`
+	Enabled bool `json:"@enabled,string"`
+}
+
+// UnmarshalJSON accepts the port number both as a JSON number and as a string, since
+// different Eureka server versions serialize it differently.
+func (p *Port) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Port    json.Number `json:"$"`
+		Enabled interface{} `json:"@enabled"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Port != "" {
+		port, err := raw.Port.Int64()
+		if err != nil {
+			return fmt.Errorf("invalid port %q: %v", raw.Port, err)
+		}
+		p.Port = int(port)
+	}
+	switch enabled := raw.Enabled.(type) {
+	case bool:
+		p.Enabled = enabled
+	case string:
+		p.Enabled = strings.EqualFold(enabled, "true")
+	}
+	return nil
+}
+
+const (
+	appsPath        = "/apps"
+	requestTimeout  = 30 * time.Second
+	acceptHeaderKey = "Accept"
+	acceptJSON      = "application/json"
+)
+
+type getApplications struct {
+	Applications Applications `json:"applications"`
+}
+
+// NewClient instantiates a new Eureka client. The url is the base path of the Eureka REST API,
+// typically http://<host>:8761/eureka.
+func NewClient(url string) Client {
+	return &client{
+		client: http.Client{Timeout: requestTimeout},
+		url:    strings.TrimSuffix(url, "/"),
+	}
+}
+
+func (c *client) Applications() (*Applications, error) {
+	req, err := http.NewRequest(http.MethodGet, c.url+appsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(acceptHeaderKey, acceptJSON)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint: errcheck
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("eureka server %s returned status %d: %s", c.url, resp.StatusCode, string(data))
+	}
+
+	var apps getApplications
+	if err = json.Unmarshal(data, &apps); err != nil {
+		return nil, err
+	}
+	return &apps.Applications, nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/spiffe"
+)
+
+var _ serviceregistry.Instance = &Controller{}
+
+// DefaultPollInterval is the default interval between two fetches of the Eureka registry
+const DefaultPollInterval = 2 * time.Second
+
+// Controller polls the Eureka registry and notifies handlers of the services and instances that changed.
+type Controller struct {
+	client    Client
+	interval  time.Duration
+	clusterID string
+
+	mutex        sync.RWMutex
+	services     map[host.Name]*model.Service
+	servicesList []*model.Service
+	// instances of each service, keyed by hostname then by endpoint address and port
+	instances map[host.Name]map[string]*model.ServiceInstance
+
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController creates a new Eureka controller polling the registry every interval.
+func NewController(client Client, interval time.Duration, clusterID string) *Controller {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	return &Controller{
+		client:    client,
+		interval:  interval,
+		clusterID: clusterID,
+		services:  make(map[host.Name]*model.Service),
+		instances: make(map[host.Name]map[string]*model.ServiceInstance),
+	}
+}
+
+func (c *Controller) Provider() serviceregistry.ProviderID {
+	return serviceregistry.Eureka
+}
+
+func (c *Controller) Cluster() string {
+	return c.clusterID
+}
+
+// Services list declarations of all services in the system
+func (c *Controller) Services() ([]*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.Service, len(c.servicesList))
+	copy(out, c.servicesList)
+	return out, nil
+}
+
+// GetService retrieves a service by host name if it exists
+func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.services[hostname], nil
+}
+
+// ManagementPorts retrieves set of health check ports by instance IP.
+// This does not apply to Eureka service registry, as Eureka does not
+// manage the service instances.
+func (c *Controller) ManagementPorts(addr string) model.PortList {
+	return nil
+}
+
+// WorkloadHealthCheckInfo retrieves set of health check info by instance IP.
+// This does not apply to Eureka service registry, as Eureka does not
+// manage the service instances.
+func (c *Controller) WorkloadHealthCheckInfo(addr string) model.ProbeList {
+	return nil
+}
+
+// InstancesByPort retrieves instances for a service that match
+// any of the supplied labels. All instances match an empty tag list.
+func (c *Controller) InstancesByPort(svc *model.Service, port int,
+	labels labels.Collection) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	instances, ok := c.instances[svc.Hostname]
+	if !ok {
+		return nil, fmt.Errorf("could not find instance of service: %s", svc.Hostname)
+	}
+	out := make([]*model.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if labels.HasSubsetOf(instance.Endpoint.Labels) && portMatch(instance, port) {
+			out = append(out, instance)
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+// returns true if an instance's port matches with any in the provided list
+func portMatch(instance *model.ServiceInstance, port int) bool {
+	return port == 0 || port == instance.ServicePort.Port
+}
+
+// GetProxyServiceInstances lists service instances co-located with a given proxy
+func (c *Controller) GetProxyServiceInstances(node *model.Proxy) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.ServiceInstance, 0)
+	for _, instances := range c.instances {
+		for _, instance := range instances {
+			if proxyHasAddress(node, instance.Endpoint.Address) {
+				out = append(out, instance)
+			}
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collection, error) {
+	instances, err := c.GetProxyServiceInstances(proxy)
+	if err != nil {
+		return nil, err
+	}
+	out := make(labels.Collection, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, instance.Endpoint.Labels)
+	}
+	return out, nil
+}
+
+func proxyHasAddress(node *model.Proxy, addr string) bool {
+	for _, ipAddress := range node.IPAddresses {
+		if ipAddress == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// GetIstioServiceAccounts returns the identities advertised by the instances of the service through
+// the istio.serviceaccount metadata. Eureka has no notion of service account, so when no instance
+// advertises one, all services are assumed to run as the default service account, as Consul does.
+func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	saSet := make(map[string]bool)
+	for _, instance := range c.instances[svc.Hostname] {
+		if instance.Endpoint.ServiceAccount == "" {
+			continue
+		}
+		for _, port := range ports {
+			if port == instance.ServicePort.Port {
+				saSet[instance.Endpoint.ServiceAccount] = true
+				break
+			}
+		}
+	}
+	if len(saSet) == 0 {
+		return []string{
+			spiffe.MustGenSpiffeURI("default", "default"),
+		}
+	}
+
+	out := make([]string, 0, len(saSet))
+	for sa := range saSet {
+		out = append(out, sa)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	return nil
+}
+
+// Run polls the Eureka registry until a signal is received
+func (c *Controller) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	c.update()
+	for {
+		select {
+		case <-ticker.C:
+			c.update()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// update fetches the Eureka registry, replaces the cached services and instances and notifies
+// handlers of the difference with the previous state.
+func (c *Controller) update() {
+	apps, err := c.client.Applications()
+	if err != nil {
+		log.Warnf("Could not fetch applications from Eureka: %v", err)
+		return
+	}
+
+	services := convertServices(apps.Applications)
+	instances := make(map[host.Name]map[string]*model.ServiceInstance, len(services))
+	for _, instance := range convertServiceInstances(services, apps.Applications) {
+		byEndpoint, ok := instances[instance.Service.Hostname]
+		if !ok {
+			byEndpoint = make(map[string]*model.ServiceInstance)
+			instances[instance.Service.Hostname] = byEndpoint
+		}
+		byEndpoint[instanceKey(instance)] = instance
+	}
+	servicesList := make([]*model.Service, 0, len(services))
+	for _, svc := range services {
+		servicesList = append(servicesList, svc)
+	}
+	sort.Slice(servicesList, func(i, j int) bool { return servicesList[i].Hostname < servicesList[j].Hostname })
+
+	c.mutex.Lock()
+	oldServices, oldInstances := c.services, c.instances
+	c.services, c.servicesList, c.instances = services, servicesList, instances
+	c.mutex.Unlock()
+
+	c.notify(oldServices, services, oldInstances, instances)
+}
+
+// notify sends service events before instance events for added and updated services,
+// and instance events before service events for deleted services.
+func (c *Controller) notify(oldServices, services map[host.Name]*model.Service,
+	oldInstances, instances map[host.Name]map[string]*model.ServiceInstance) {
+	for hostname, svc := range services {
+		if old, exists := oldServices[hostname]; !exists {
+			c.notifyService(svc, model.EventAdd)
+		} else if !reflect.DeepEqual(old, svc) {
+			c.notifyService(svc, model.EventUpdate)
+		}
+	}
+
+	for hostname, byEndpoint := range instances {
+		oldByEndpoint := oldInstances[hostname]
+		for key, instance := range byEndpoint {
+			if old, exists := oldByEndpoint[key]; !exists {
+				c.notifyInstance(instance, model.EventAdd)
+			} else if !reflect.DeepEqual(old.Endpoint, instance.Endpoint) ||
+				!reflect.DeepEqual(old.Service, instance.Service) {
+				c.notifyInstance(instance, model.EventUpdate)
+			}
+		}
+	}
+	for hostname, oldByEndpoint := range oldInstances {
+		byEndpoint := instances[hostname]
+		for key, old := range oldByEndpoint {
+			if _, exists := byEndpoint[key]; !exists {
+				c.notifyInstance(old, model.EventDelete)
+			}
+		}
+	}
+
+	for hostname, old := range oldServices {
+		if _, exists := services[hostname]; !exists {
+			c.notifyService(old, model.EventDelete)
+		}
+	}
+}
+
+func (c *Controller) notifyService(svc *model.Service, event model.Event) {
+	log.Debugf("Eureka service %s %s", svc.Hostname, event)
+	for _, f := range c.serviceHandlers {
+		f(svc, event)
+	}
+}
+
+func (c *Controller) notifyInstance(instance *model.ServiceInstance, event model.Event) {
+	log.Debugf("Eureka instance %s of %s %s", instanceKey(instance), instance.Service.Hostname, event)
+	for _, f := range c.instanceHandlers {
+		f(instance, event)
+	}
+}
+
+func instanceKey(instance *model.ServiceInstance) string {
+	return fmt.Sprintf("%s:%d", instance.Endpoint.Address, instance.Endpoint.EndpointPort)
+}
+
+func sortInstances(instances []*model.ServiceInstance) {
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Service.Hostname != instances[j].Service.Hostname {
+			return instances[i].Service.Hostname < instances[j].Service.Hostname
+		}
+		return instanceKey(instances[i]) < instanceKey(instances[j])
+	})
+}
@@ -0,0 +1,301 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const notifyThreshold = 10 * time.Second
+
+// mockServer is a minimal stand-in for the Eureka REST API. As for a full fetch from a real Eureka
+// server, the versions delta is fixed and the hash code only counts the instances by status.
+type mockServer struct {
+	server *httptest.Server
+	lock   sync.Mutex
+	apps   []*Application
+}
+
+// mockApplications is the root of the responses of the mock server
+type mockApplications struct {
+	VersionsDelta string         `json:"versions__delta"`
+	HashCode      string         `json:"apps__hashcode"`
+	Applications  []*Application `json:"application"`
+}
+
+func newServer() *mockServer {
+	m := &mockServer{
+		apps: []*Application{
+			{
+				Name: "PRODUCTPAGE",
+				Instances: []*Instance{
+					makeInstance("productpage", "10.0.0.1", 9080, "UP", map[string]string{"version": "v1"}),
+				},
+			},
+			{
+				Name: "REVIEWS",
+				Instances: []*Instance{
+					makeInstance("reviews", "10.0.0.2", 9080, "UP", map[string]string{"version": "v1"}),
+					makeInstance("reviews", "10.0.0.3", 9080, "UP", map[string]string{"version": "v2"}),
+					makeInstance("reviews", "10.0.0.4", 9080, "DOWN", map[string]string{"version": "v3"}),
+				},
+			},
+			{
+				Name: "RATINGS",
+				Instances: []*Instance{
+					makeInstance("ratings", "10.0.0.5", 9090, "UP", map[string]string{
+						"version":        "v1",
+						protocolMetadata: "grpc",
+						"@class":         "java.util.Collections$EmptyMap",
+					}),
+				},
+			},
+		},
+	}
+
+	m.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eureka/apps" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		m.lock.Lock()
+		data, _ := json.Marshal(map[string]mockApplications{"applications": {
+			VersionsDelta: "1",
+			HashCode:      hashCode(m.apps),
+			Applications:  m.apps,
+		}})
+		m.lock.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintln(w, string(data))
+	}))
+
+	return m
+}
+
+func makeInstance(app, ip string, port int, status string, metadata map[string]string) *Instance {
+	return &Instance{
+		InstanceID: fmt.Sprintf("%s:%s:%d", ip, app, port),
+		Hostname:   ip,
+		App:        app,
+		IPAddress:  ip,
+		Status:     status,
+		Port:       &Port{Port: port, Enabled: true},
+		SecurePort: &Port{Port: 443, Enabled: false},
+		Metadata:   metadata,
+	}
+}
+
+// hashCode counts the instances by status, as the apps__hashcode of Eureka, e.g. "DOWN_1_UP_3_"
+func hashCode(apps []*Application) string {
+	counts := map[string]int{}
+	for _, app := range apps {
+		for _, instance := range app.Instances {
+			counts[instance.Status]++
+		}
+	}
+	statuses := make([]string, 0, len(counts))
+	for status := range counts {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	var out strings.Builder
+	for _, status := range statuses {
+		fmt.Fprintf(&out, "%s_%d_", status, counts[status])
+	}
+	return out.String()
+}
+
+func (m *mockServer) update(f func()) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	f()
+}
+
+type event struct {
+	kind  string
+	name  string
+	event model.Event
+}
+
+func TestController(t *testing.T) {
+	ts := newServer()
+	defer ts.server.Close()
+
+	ctl := NewController(NewClient(ts.server.URL+"/eureka"), 10*time.Millisecond, "")
+	events := make(chan event, 100)
+	_ = ctl.AppendServiceHandler(func(svc *model.Service, e model.Event) {
+		events <- event{"service", string(svc.Hostname), e}
+	})
+	_ = ctl.AppendInstanceHandler(func(si *model.ServiceInstance, e model.Event) {
+		events <- event{"instance", instanceKey(si), e}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	expectEvents := func(t *testing.T, want ...event) {
+		t.Helper()
+		got := make(map[event]bool)
+		for range want {
+			select {
+			case e := <-events:
+				got[e] = true
+			case <-time.After(notifyThreshold):
+				t.Fatalf("got events %v, want %v", got, want)
+			}
+		}
+		for _, w := range want {
+			if !got[w] {
+				t.Fatalf("missing event %v, got %v", w, got)
+			}
+		}
+	}
+
+	expectEvents(t,
+		event{"service", "productpage.eureka", model.EventAdd},
+		event{"service", "reviews.eureka", model.EventAdd},
+		event{"service", "ratings.eureka", model.EventAdd},
+		event{"instance", "10.0.0.1:9080", model.EventAdd},
+		event{"instance", "10.0.0.2:9080", model.EventAdd},
+		event{"instance", "10.0.0.3:9080", model.EventAdd},
+		event{"instance", "10.0.0.5:9090", model.EventAdd},
+	)
+
+	services, err := ctl.Services()
+	if err != nil {
+		t.Fatalf("Services() encountered unexpected error: %v", err)
+	}
+	if len(services) != 3 {
+		t.Fatalf("Services() returned %d services, want 3", len(services))
+	}
+	services[0] = nil
+	if again, _ := ctl.Services(); again[0] == nil {
+		t.Fatalf("Services() returned the registry's internal slice")
+	}
+
+	ratings, _ := ctl.GetService("ratings.eureka")
+	if ratings == nil || len(ratings.Ports) != 1 || ratings.Ports[0].Protocol != protocol.GRPC {
+		t.Fatalf("GetService(ratings.eureka) => %v, want a single GRPC port", ratings)
+	}
+
+	reviews, _ := ctl.GetService("reviews.eureka")
+	instances, err := ctl.InstancesByPort(reviews, 9080, labels.Collection{{"version": "v2"}})
+	if err != nil {
+		t.Fatalf("InstancesByPort() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Endpoint.Address != "10.0.0.3" {
+		t.Fatalf("InstancesByPort() => %v, want the v2 instance", instances)
+	}
+
+	// Taking an instance out of service removes it and updates nothing else
+	ts.update(func() {
+		ts.apps[1].Instances[1].Status = "OUT_OF_SERVICE"
+	})
+	expectEvents(t, event{"instance", "10.0.0.3:9080", model.EventDelete})
+
+	// Relabeling an instance updates it
+	ts.update(func() {
+		ts.apps[0].Instances[0].Metadata = map[string]string{"version": "v2"}
+	})
+	expectEvents(t, event{"instance", "10.0.0.1:9080", model.EventUpdate})
+
+	// Replacing an instance by another one with the same status, which keeps the hash code, is detected
+	ts.update(func() {
+		ts.apps[1].Instances[0] = makeInstance("reviews", "10.0.0.6", 9080, "UP", map[string]string{"version": "v1"})
+	})
+	expectEvents(t,
+		event{"instance", "10.0.0.2:9080", model.EventDelete},
+		event{"instance", "10.0.0.6:9080", model.EventAdd},
+	)
+
+	// Removing an application deletes its instances and the service
+	ts.update(func() {
+		ts.apps = ts.apps[:2]
+	})
+	expectEvents(t,
+		event{"instance", "10.0.0.5:9090", model.EventDelete},
+		event{"service", "ratings.eureka", model.EventDelete},
+	)
+
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestGetProxyServiceInstances(t *testing.T) {
+	ts := newServer()
+	defer ts.server.Close()
+
+	ctl := NewController(NewClient(ts.server.URL+"/eureka"), time.Second, "")
+	ctl.update()
+
+	instances, err := ctl.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"10.0.0.2"}})
+	if err != nil {
+		t.Fatalf("GetProxyServiceInstances() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Service.Hostname != "reviews.eureka" {
+		t.Fatalf("GetProxyServiceInstances() => %v, want the reviews v1 instance", instances)
+	}
+
+	workloadLabels, err := ctl.GetProxyWorkloadLabels(&model.Proxy{IPAddresses: []string{"10.0.0.2"}})
+	if err != nil {
+		t.Fatalf("GetProxyWorkloadLabels() encountered unexpected error: %v", err)
+	}
+	if len(workloadLabels) != 1 || workloadLabels[0]["version"] != "v1" {
+		t.Fatalf("GetProxyWorkloadLabels() => %v, want version v1", workloadLabels)
+	}
+
+	// Instances in DOWN status are not proxies of any service
+	instances, _ = ctl.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"10.0.0.4"}})
+	if len(instances) != 0 {
+		t.Fatalf("GetProxyServiceInstances() => %v, want none", instances)
+	}
+}
+
+func TestGetIstioServiceAccounts(t *testing.T) {
+	ts := newServer()
+	defer ts.server.Close()
+	ts.apps[1].Instances[0].Metadata[serviceAccountMetadata] = "spiffe://cluster.local/ns/default/sa/reviews"
+
+	ctl := NewController(NewClient(ts.server.URL+"/eureka"), time.Second, "")
+	ctl.update()
+
+	reviews, _ := ctl.GetService("reviews.eureka")
+	sa := ctl.GetIstioServiceAccounts(reviews, []int{9080})
+	if len(sa) != 1 || sa[0] != "spiffe://cluster.local/ns/default/sa/reviews" {
+		t.Fatalf("GetIstioServiceAccounts() => %v, want the reviews service account", sa)
+	}
+
+	productpage, _ := ctl.GetService("productpage.eureka")
+	sa = ctl.GetIstioServiceAccounts(productpage, []int{9080})
+	if len(sa) != 1 {
+		t.Fatalf("GetIstioServiceAccounts() => %v, want the default service account", sa)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// statusUp is the only Eureka instance status that receives traffic
+	statusUp = "UP"
+
+	// metadataPrefix is reserved for Istio specific instance metadata, which is not exported as labels
+	metadataPrefix = "istio."
+	// protocolMetadata selects the protocol of the non-secure port. Defaults to HTTP.
+	protocolMetadata = "istio.protocol"
+	// localityMetadata sets the locality of the instance in region/zone/subzone form
+	localityMetadata = "istio.locality"
+	// externalMetadata marks the application as living outside of the mesh
+	externalMetadata = "istio.external"
+	// serviceAccountMetadata carries the SPIFFE identity of the instance
+	serviceAccountMetadata = "istio.serviceaccount"
+
+	// domainSuffix is appended to the lower-cased application name to build the service hostname
+	domainSuffix = "eureka"
+)
+
+// convertServices builds the Istio services for the given Eureka applications, keyed by hostname.
+// Applications without any instance in UP status are omitted.
+func convertServices(apps []*Application) map[host.Name]*model.Service {
+	services := make(map[host.Name]*model.Service)
+	for _, app := range apps {
+		ports := make(map[int]*model.Port)
+		meshExternal := false
+		for _, instance := range app.Instances {
+			if instance.Status != statusUp {
+				continue
+			}
+			for _, port := range convertPorts(instance) {
+				if svcPort, exists := ports[port.Port]; exists && svcPort.Protocol != port.Protocol {
+					log.Warnf("Eureka application %v has two instances on same port %v but different protocols (%v, %v)",
+						app.Name, port.Port, svcPort.Protocol, port.Protocol)
+					continue
+				}
+				ports[port.Port] = port
+			}
+			if instance.Metadata[externalMetadata] != "" {
+				meshExternal = true
+			}
+		}
+		if len(ports) == 0 {
+			continue
+		}
+
+		svcPorts := make(model.PortList, 0, len(ports))
+		for _, port := range ports {
+			svcPorts = append(svcPorts, port)
+		}
+		sort.Slice(svcPorts, func(i, j int) bool { return svcPorts[i].Port < svcPorts[j].Port })
+
+		resolution := model.ClientSideLB
+		if meshExternal {
+			resolution = model.Passthrough
+		}
+
+		hostname := serviceHostname(app.Name)
+		services[hostname] = &model.Service{
+			Hostname:     hostname,
+			Address:      "0.0.0.0",
+			Ports:        svcPorts,
+			MeshExternal: meshExternal,
+			Resolution:   resolution,
+			Attributes: model.ServiceAttributes{
+				ServiceRegistry: string(serviceregistry.Eureka),
+				Name:            string(hostname),
+				Namespace:       model.IstioDefaultConfigNamespace,
+			},
+		}
+	}
+	return services
+}
+
+// convertServiceInstances builds the service instances of the UP Eureka instances of the given services.
+func convertServiceInstances(services map[host.Name]*model.Service, apps []*Application) []*model.ServiceInstance {
+	out := make([]*model.ServiceInstance, 0)
+	for _, app := range apps {
+		service := services[serviceHostname(app.Name)]
+		if service == nil {
+			continue
+		}
+		for _, instance := range app.Instances {
+			if instance.Status != statusUp {
+				continue
+			}
+			instanceLabels := convertLabels(instance.Metadata)
+			for _, port := range convertPorts(instance) {
+				svcPort, exists := service.Ports.GetByPort(port.Port)
+				if !exists {
+					continue
+				}
+				out = append(out, &model.ServiceInstance{
+					Endpoint: &model.IstioEndpoint{
+						Address:         instance.IPAddress,
+						UID:             instance.InstanceID,
+						ServiceAccount:  instance.Metadata[serviceAccountMetadata],
+						EndpointPort:    uint32(port.Port),
+						ServicePortName: svcPort.Name,
+						Locality: model.Locality{
+							Label: instance.Metadata[localityMetadata],
+						},
+						Labels:  instanceLabels,
+						TLSMode: model.GetTLSModeFromEndpointLabels(instanceLabels),
+					},
+					ServicePort: svcPort,
+					Service:     service,
+				})
+			}
+		}
+	}
+	return out
+}
+
+// convertPorts returns the enabled ports of an instance. The non-secure port takes its protocol from the
+// istio.protocol metadata, while the secure port is always HTTPS.
+func convertPorts(instance *Instance) model.PortList {
+	out := make(model.PortList, 0, 2)
+	if instance.Port != nil && instance.Port.Enabled && instance.Port.Port > 0 {
+		out = append(out, convertPort(instance.Port.Port, instance.Metadata[protocolMetadata]))
+	}
+	if instance.SecurePort != nil && instance.SecurePort.Enabled && instance.SecurePort.Port > 0 {
+		out = append(out, convertPort(instance.SecurePort.Port, string(protocol.HTTPS)))
+	}
+	return out
+}
+
+func convertPort(port int, name string) *model.Port {
+	p := convertProtocol(name)
+	return &model.Port{
+		Name:     fmt.Sprintf("%s-%d", strings.ToLower(string(p)), port),
+		Port:     port,
+		Protocol: p,
+	}
+}
+
+func convertProtocol(name string) protocol.Instance {
+	if name == "" {
+		return protocol.HTTP
+	}
+	p := protocol.Parse(name)
+	if p == protocol.Unsupported {
+		log.Warnf("unsupported protocol value: %s", name)
+		return protocol.TCP
+	}
+	return p
+}
+
+// convertLabels turns instance metadata into labels. Keys reserved for Istio and the XML/JSON
+// serialization artifacts Eureka adds (such as "@class") are ignored.
+func convertLabels(metadata map[string]string) labels.Instance {
+	out := make(labels.Instance, len(metadata))
+	for k, v := range metadata {
+		if strings.HasPrefix(k, metadataPrefix) || strings.HasPrefix(k, "@") {
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// serviceHostname produces FQDN for a Eureka application
+func serviceHostname(app string) host.Name {
+	return host.Name(fmt.Sprintf("%s.%s", strings.ToLower(app), domainSuffix))
+}
@@ -0,0 +1,140 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eureka
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+func TestConvertProtocol(t *testing.T) {
+	protocols := []struct {
+		name string
+		out  protocol.Instance
+	}{
+		{"", protocol.HTTP},
+		{"http", protocol.HTTP},
+		{"http2", protocol.HTTP2},
+		{"grpc", protocol.GRPC},
+		{"tcp", protocol.TCP},
+		{"bogus", protocol.TCP},
+	}
+	for _, tt := range protocols {
+		if out := convertProtocol(tt.name); out != tt.out {
+			t.Errorf("convertProtocol(%q) => %q, want %q", tt.name, out, tt.out)
+		}
+	}
+}
+
+func TestConvertLabels(t *testing.T) {
+	metadata := map[string]string{
+		"version":        "v1",
+		"@class":         "java.util.Collections$EmptyMap",
+		protocolMetadata: "grpc",
+	}
+	want := labels.Instance{"version": "v1"}
+	if got := convertLabels(metadata); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertLabels(%v) => %v, want %v", metadata, got, want)
+	}
+}
+
+func TestConvertServices(t *testing.T) {
+	apps := []*Application{
+		{
+			Name: "FOO",
+			Instances: []*Instance{
+				{
+					IPAddress:  "10.0.0.1",
+					Status:     "UP",
+					Port:       &Port{Port: 8080, Enabled: true},
+					SecurePort: &Port{Port: 8443, Enabled: true},
+					Metadata:   map[string]string{localityMetadata: "us-east/us-east-1a", "version": "v1"},
+				},
+				{
+					IPAddress: "10.0.0.2",
+					Status:    "STARTING",
+					Port:      &Port{Port: 9090, Enabled: true},
+				},
+			},
+		},
+		{
+			Name: "BAR",
+			Instances: []*Instance{
+				{
+					IPAddress: "10.0.0.3",
+					Status:    "DOWN",
+					Port:      &Port{Port: 8080, Enabled: true},
+				},
+			},
+		},
+	}
+
+	services := convertServices(apps)
+	if len(services) != 1 {
+		t.Fatalf("convertServices() => %d services, want 1 since BAR has no instance UP", len(services))
+	}
+	foo := services["foo.eureka"]
+	if foo == nil {
+		t.Fatalf("convertServices() => %v, missing foo.eureka", services)
+	}
+	wantPorts := model.PortList{
+		{Name: "http-8080", Port: 8080, Protocol: protocol.HTTP},
+		{Name: "https-8443", Port: 8443, Protocol: protocol.HTTPS},
+	}
+	if !reflect.DeepEqual(foo.Ports, wantPorts) {
+		t.Errorf("convertServices() ports => %v, want %v", foo.Ports, wantPorts)
+	}
+
+	instances := convertServiceInstances(services, apps)
+	if len(instances) != 2 {
+		t.Fatalf("convertServiceInstances() => %d instances, want 2", len(instances))
+	}
+	for _, instance := range instances {
+		if instance.Endpoint.Address != "10.0.0.1" {
+			t.Errorf("convertServiceInstances() returned instance %v which is not UP", instance.Endpoint.Address)
+		}
+		if instance.Endpoint.Locality.Label != "us-east/us-east-1a" {
+			t.Errorf("convertServiceInstances() locality => %q, want us-east/us-east-1a", instance.Endpoint.Locality.Label)
+		}
+		if instance.Endpoint.ServicePortName != instance.ServicePort.Name {
+			t.Errorf("convertServiceInstances() port name => %q, want %q", instance.Endpoint.ServicePortName, instance.ServicePort.Name)
+		}
+	}
+}
+
+func TestPortUnmarshal(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Port
+	}{
+		{`{"$": 8080, "@enabled": "true"}`, Port{Port: 8080, Enabled: true}},
+		{`{"$": "8080", "@enabled": "false"}`, Port{Port: 8080}},
+		{`{"$": 8080, "@enabled": true}`, Port{Port: 8080, Enabled: true}},
+	}
+	for _, c := range cases {
+		var got Port
+		if err := json.Unmarshal([]byte(c.in), &got); err != nil {
+			t.Fatalf("Unmarshal(%s) encountered unexpected error: %v", c.in, err)
+		}
+		if got != c.want {
+			t.Errorf("Unmarshal(%s) => %v, want %v", c.in, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"istio.io/pkg/appsignals"
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/spiffe"
+)
+
+var _ serviceregistry.Instance = &Controller{}
+
+// osSignalSource is the source of the signals received by the process rather than triggered by a file
+const osSignalSource = "os"
+
+// Controller serves the services and endpoints declared in the YAML files of a directory. Like the
+// config Monitor, it reloads the files whenever they change and notifies handlers of the difference.
+type Controller struct {
+	root      string
+	snapshot  *FileSnapshot
+	clusterID string
+
+	mutex    sync.RWMutex
+	registry *registry
+
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController creates a new file registry controller for the files under root.
+func NewController(root string, clusterID string) *Controller {
+	return &Controller{
+		root:      root,
+		snapshot:  NewFileSnapshot(root),
+		clusterID: clusterID,
+		registry:  newRegistry(),
+	}
+}
+
+func (c *Controller) Provider() serviceregistry.ProviderID {
+	return serviceregistry.File
+}
+
+func (c *Controller) Cluster() string {
+	return c.clusterID
+}
+
+// Services list declarations of all services in the system
+func (c *Controller) Services() ([]*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.Service, 0, len(c.registry.services))
+	for _, svc := range c.registry.services {
+		out = append(out, svc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	return out, nil
+}
+
+// GetService retrieves a service by host name if it exists
+func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.registry.services[hostname], nil
+}
+
+// ManagementPorts retrieves set of health check ports by instance IP.
+// This does not apply to the file registry, which does not manage the
+// service instances.
+func (c *Controller) ManagementPorts(addr string) model.PortList {
+	return nil
+}
+
+// WorkloadHealthCheckInfo retrieves set of health check info by instance IP.
+// This does not apply to the file registry, which does not manage the
+// service instances.
+func (c *Controller) WorkloadHealthCheckInfo(addr string) model.ProbeList {
+	return nil
+}
+
+// InstancesByPort retrieves instances for a service that match
+// any of the supplied labels. All instances match an empty tag list.
+func (c *Controller) InstancesByPort(svc *model.Service, port int,
+	labels labels.Collection) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	instances, ok := c.registry.instances[svc.Hostname]
+	if !ok {
+		return nil, fmt.Errorf("could not find instance of service: %s", svc.Hostname)
+	}
+	out := make([]*model.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if labels.HasSubsetOf(instance.Endpoint.Labels) && (port == 0 || port == instance.ServicePort.Port) {
+			out = append(out, instance)
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+// GetProxyServiceInstances lists service instances co-located with a given proxy
+func (c *Controller) GetProxyServiceInstances(node *model.Proxy) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.ServiceInstance, 0)
+	for _, instances := range c.registry.instances {
+		for _, instance := range instances {
+			for _, ipAddress := range node.IPAddresses {
+				if ipAddress == instance.Endpoint.Address {
+					out = append(out, instance)
+					break
+				}
+			}
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collection, error) {
+	instances, err := c.GetProxyServiceInstances(proxy)
+	if err != nil {
+		return nil, err
+	}
+	out := make(labels.Collection, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, instance.Endpoint.Labels)
+	}
+	return out, nil
+}
+
+// GetIstioServiceAccounts returns the service accounts declared by the endpoints of the service,
+// or the default service account when none is declared.
+func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	saSet := make(map[string]bool)
+	for _, instance := range c.registry.instances[svc.Hostname] {
+		if instance.Endpoint.ServiceAccount == "" {
+			continue
+		}
+		for _, port := range ports {
+			if port == instance.ServicePort.Port {
+				saSet[instance.Endpoint.ServiceAccount] = true
+				break
+			}
+		}
+	}
+	if len(saSet) == 0 {
+		return []string{
+			spiffe.MustGenSpiffeURI(svc.Attributes.Namespace, "default"),
+		}
+	}
+
+	out := make([]string, 0, len(saSet))
+	for sa := range saSet {
+		out = append(out, sa)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	return nil
+}
+
+// Run loads the files and reloads them on change until a signal is received
+func (c *Controller) Run(stop <-chan struct{}) {
+	c.checkAndUpdate()
+
+	ch := make(chan appsignals.Signal, 1)
+	appsignals.Watch(ch)
+	// The shutdown channels of the watches of the directories, which are updated on every reload
+	watches := map[string]chan os.Signal{}
+	c.watchDirectories(watches)
+	for {
+		select {
+		case trigger := <-ch:
+			if c.triggeredBy(trigger) {
+				log.Infof("Reloading service registry files in response to: %v", trigger.Source)
+				c.checkAndUpdate()
+				c.watchDirectories(watches)
+			}
+		case <-stop:
+			for _, shut := range watches {
+				shut <- syscall.SIGTERM
+			}
+			return
+		}
+	}
+}
+
+// watchDirectories watches the root and its subdirectories, whose files are read too, and stops
+// watching the directories removed.
+func (c *Controller) watchDirectories(watches map[string]chan os.Signal) {
+	dirs := map[string]bool{}
+	err := filepath.Walk(c.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs[path] = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Warnf("Failed to list the service registry directories under %s: %v", c.root, err)
+	}
+
+	for dir := range dirs {
+		if _, ok := watches[dir]; ok {
+			continue
+		}
+		shut := make(chan os.Signal, 1)
+		if err := appsignals.FileTrigger(dir, syscall.SIGUSR1, shut); err != nil {
+			log.Errorf("Unable to setup FileTrigger for %s: %v", dir, err)
+			continue
+		}
+		watches[dir] = shut
+	}
+	for dir, shut := range watches {
+		if !dirs[dir] {
+			shut <- syscall.SIGTERM
+			delete(watches, dir)
+		}
+	}
+}
+
+// triggeredBy returns whether the signal asks for the files to be reloaded. Signals are broadcast to
+// all the watchers of the process, so the ones triggered for the files of other watchers are ignored,
+// while SIGUSR1 sent to the process still reloads everything.
+func (c *Controller) triggeredBy(trigger appsignals.Signal) bool {
+	if trigger.Signal != syscall.SIGUSR1 {
+		return false
+	}
+	root := filepath.Clean(c.root)
+	source := filepath.Clean(trigger.Source)
+	return trigger.Source == osSignalSource || source == root ||
+		strings.HasPrefix(source, root+string(filepath.Separator))
+}
+
+// checkAndUpdate reads the files, replaces the registry and notifies handlers of the difference
+// with the previous state. Invalid documents are logged and skipped, so that a typo in one file
+// does not take down the services declared in the others.
+func (c *Controller) checkAndUpdate() {
+	docs, err := c.snapshot.ReadFiles()
+	if docs == nil && err != nil {
+		log.Warnf("Failed to read service registry files under %s: %v", c.root, err)
+		return
+	}
+	if err != nil {
+		log.Errorf("Invalid service registry files under %s: %v", c.root, err)
+	}
+
+	r, err := convertDocuments(docs)
+	if err != nil {
+		log.Errorf("Invalid service registry files under %s: %v", c.root, err)
+	}
+
+	c.mutex.Lock()
+	old := c.registry
+	c.registry = r
+	c.mutex.Unlock()
+
+	c.notify(old, r)
+}
+
+// notify sends service events before instance events for added and updated services,
+// and instance events before service events for deleted services.
+func (c *Controller) notify(old, r *registry) {
+	for hostname, svc := range r.services {
+		if oldSvc, exists := old.services[hostname]; !exists {
+			c.notifyService(svc, model.EventAdd)
+		} else if !reflect.DeepEqual(oldSvc, svc) {
+			c.notifyService(svc, model.EventUpdate)
+		}
+	}
+
+	for hostname, byEndpoint := range r.instances {
+		oldByEndpoint := old.instances[hostname]
+		for key, instance := range byEndpoint {
+			if oldInstance, exists := oldByEndpoint[key]; !exists {
+				c.notifyInstance(instance, model.EventAdd)
+			} else if !reflect.DeepEqual(oldInstance.Endpoint, instance.Endpoint) ||
+				!reflect.DeepEqual(oldInstance.Service, instance.Service) {
+				c.notifyInstance(instance, model.EventUpdate)
+			}
+		}
+	}
+	for hostname, oldByEndpoint := range old.instances {
+		byEndpoint := r.instances[hostname]
+		for key, oldInstance := range oldByEndpoint {
+			if _, exists := byEndpoint[key]; !exists {
+				c.notifyInstance(oldInstance, model.EventDelete)
+			}
+		}
+	}
+
+	for hostname, oldSvc := range old.services {
+		if _, exists := r.services[hostname]; !exists {
+			c.notifyService(oldSvc, model.EventDelete)
+		}
+	}
+}
+
+func (c *Controller) notifyService(svc *model.Service, event model.Event) {
+	log.Debugf("File registry service %s %s", svc.Hostname, event)
+	for _, f := range c.serviceHandlers {
+		f(svc, event)
+	}
+}
+
+func (c *Controller) notifyInstance(instance *model.ServiceInstance, event model.Event) {
+	log.Debugf("File registry endpoint %s of %s %s", instanceKey(instance), instance.Service.Hostname, event)
+	for _, f := range c.instanceHandlers {
+		f(instance, event)
+	}
+}
+
+func sortInstances(instances []*model.ServiceInstance) {
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Service.Hostname != instances[j].Service.Hostname {
+			return instances[i].Service.Hostname < instances[j].Service.Hostname
+		}
+		return instanceKey(instances[i]) < instanceKey(instances[j])
+	})
+}
@@ -0,0 +1,216 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"istio.io/pkg/appsignals"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+)
+
+const notifyThreshold = 10 * time.Second
+
+type event struct {
+	kind  string
+	name  string
+	event model.Event
+}
+
+func newTestController(t *testing.T) (*Controller, string, chan event) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "file-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctl := NewController(dir, "")
+	events := make(chan event, 100)
+	_ = ctl.AppendServiceHandler(func(svc *model.Service, e model.Event) {
+		events <- event{"service", string(svc.Hostname), e}
+	})
+	_ = ctl.AppendInstanceHandler(func(si *model.ServiceInstance, e model.Event) {
+		events <- event{"instance", instanceKey(si), e}
+	})
+	return ctl, dir, events
+}
+
+func expectEvents(t *testing.T, events chan event, want ...event) {
+	t.Helper()
+	got := make(map[event]bool)
+	for range want {
+		select {
+		case e := <-events:
+			got[e] = true
+		case <-time.After(notifyThreshold):
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Fatalf("missing event %v, got %v", w, got)
+		}
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %v", e)
+	default:
+	}
+}
+
+func TestCheckAndUpdate(t *testing.T) {
+	ctl, dir, events := newTestController(t)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "reviews.yaml", reviewsService)
+	writeFile(t, dir, "vms.yaml", reviewsEndpoints)
+	ctl.checkAndUpdate()
+	expectEvents(t, events,
+		event{"service", "reviews.edge.local", model.EventAdd},
+		event{"instance", "10.0.0.1:9080", model.EventAdd},
+		event{"instance", "10.0.0.2:19080", model.EventAdd},
+	)
+
+	svc, _ := ctl.GetService("reviews.edge.local")
+	if svc == nil || svc.Attributes.Namespace != "edge" || svc.Attributes.ServiceRegistry != "File" {
+		t.Fatalf("GetService() => %+v", svc)
+	}
+	instances, err := ctl.InstancesByPort(svc, 9080, labels.Collection{{"version": "v2"}})
+	if err != nil {
+		t.Fatalf("InstancesByPort() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Endpoint.Address != "10.0.0.2" || instances[0].Endpoint.EndpointPort != 19080 {
+		t.Fatalf("InstancesByPort() => %v, want the v2 VM", instances)
+	}
+
+	// Nothing changed
+	ctl.checkAndUpdate()
+	expectEvents(t, events)
+
+	// Relabeling an endpoint only updates that endpoint
+	writeFile(t, dir, "vms.yaml", strings.Replace(reviewsEndpoints, "version: v2", "version: v3", 1))
+	ctl.checkAndUpdate()
+	expectEvents(t, events, event{"instance", "10.0.0.2:19080", model.EventUpdate})
+
+	// An invalid document is skipped, which removes its endpoints, without affecting the service
+	writeFile(t, dir, "vms.yaml", strings.Replace(reviewsEndpoints, "address: 10.0.0.2", "address: vm-2", 1))
+	ctl.checkAndUpdate()
+	expectEvents(t, events, event{"instance", "10.0.0.2:19080", model.EventDelete})
+
+	// Adding a port updates the service and adds instances
+	writeFile(t, dir, "reviews.yaml", strings.Replace(reviewsService, "  endpoints:",
+		"  - name: grpc\n    number: 9090\n    protocol: GRPC\n  endpoints:", 1))
+	ctl.checkAndUpdate()
+	expectEvents(t, events,
+		event{"service", "reviews.edge.local", model.EventUpdate},
+		event{"instance", "10.0.0.1:9080", model.EventUpdate},
+		event{"instance", "10.0.0.1:9090", model.EventAdd},
+	)
+
+	// Removing the files deletes everything
+	_ = os.Remove(filepath.Join(dir, "reviews.yaml"))
+	_ = os.Remove(filepath.Join(dir, "vms.yaml"))
+	ctl.checkAndUpdate()
+	expectEvents(t, events,
+		event{"instance", "10.0.0.1:9080", model.EventDelete},
+		event{"instance", "10.0.0.1:9090", model.EventDelete},
+		event{"service", "reviews.edge.local", model.EventDelete},
+	)
+}
+
+func TestRunWatchesFiles(t *testing.T) {
+	ctl, dir, events := newTestController(t)
+	defer os.RemoveAll(dir)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	// Wait for the initial, empty, load to be done before changing files
+	time.Sleep(100 * time.Millisecond)
+	writeFile(t, dir, "reviews.yaml", reviewsService)
+	expectEvents(t, events,
+		event{"service", "reviews.edge.local", model.EventAdd},
+		event{"instance", "10.0.0.1:9080", model.EventAdd},
+	)
+}
+
+func TestRunWatchesSubdirectories(t *testing.T) {
+	ctl, dir, events := newTestController(t)
+	defer os.RemoveAll(dir)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	// Wait for the initial load, then for the reload adding the watch of the new directory
+	time.Sleep(100 * time.Millisecond)
+	if err := os.Mkdir(filepath.Join(dir, "services"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	writeFile(t, filepath.Join(dir, "services"), "reviews.yaml", reviewsService)
+	expectEvents(t, events,
+		event{"service", "reviews.edge.local", model.EventAdd},
+		event{"instance", "10.0.0.1:9080", model.EventAdd},
+	)
+}
+
+func TestTriggeredBy(t *testing.T) {
+	ctl := NewController("/etc/istio/registry", "")
+	cases := []struct {
+		trigger appsignals.Signal
+		want    bool
+	}{
+		{appsignals.Signal{Source: "/etc/istio/registry", Signal: syscall.SIGUSR1}, true},
+		{appsignals.Signal{Source: "/etc/istio/registry/vms", Signal: syscall.SIGUSR1}, true},
+		{appsignals.Signal{Source: "/etc/istio/registry-backup", Signal: syscall.SIGUSR1}, false},
+		{appsignals.Signal{Source: "os", Signal: syscall.SIGUSR1}, true},
+		{appsignals.Signal{Source: "/etc/istio/config", Signal: syscall.SIGUSR1}, false},
+		{appsignals.Signal{Source: "/etc/istio/registry", Signal: syscall.SIGTERM}, false},
+	}
+	for _, c := range cases {
+		if got := ctl.triggeredBy(c.trigger); got != c.want {
+			t.Errorf("triggeredBy(%v) => %v, want %v", c.trigger, got, c.want)
+		}
+	}
+}
+
+func TestGetProxyServiceInstances(t *testing.T) {
+	ctl, dir, _ := newTestController(t)
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "reviews.yaml", reviewsService)
+	ctl.checkAndUpdate()
+
+	instances, err := ctl.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"10.0.0.1"}})
+	if err != nil {
+		t.Fatalf("GetProxyServiceInstances() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Service.Hostname != "reviews.edge.local" {
+		t.Fatalf("GetProxyServiceInstances() => %v, want the reviews endpoint", instances)
+	}
+	svc, _ := ctl.GetService("reviews.edge.local")
+	if sa := ctl.GetIstioServiceAccounts(svc, []int{9080}); len(sa) != 1 || !strings.HasSuffix(sa[0], "/ns/edge/sa/default") {
+		t.Fatalf("GetIstioServiceAccounts() => %v, want the default service account of edge", sa)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+// registry is the state of the services and instances built from a snapshot of the files
+type registry struct {
+	services map[host.Name]*model.Service
+	// instances of each service, keyed by hostname then by endpoint address and port
+	instances map[host.Name]map[string]*model.ServiceInstance
+}
+
+func newRegistry() *registry {
+	return &registry{
+		services:  make(map[host.Name]*model.Service),
+		instances: make(map[host.Name]map[string]*model.ServiceInstance),
+	}
+}
+
+// convertDocuments builds the registry from validated documents. Documents conflicting with others,
+// such as two services with the same hostname or endpoints of an unknown service, are skipped and
+// reported in the returned error.
+func convertDocuments(docs []*Document) (*registry, error) {
+	r := newRegistry()
+	var errs error
+
+	// Services are keyed by namespace and name for the Endpoints documents to refer to them
+	byName := make(map[string]*model.Service)
+	origins := make(map[host.Name]Origin)
+	for _, doc := range docs {
+		if doc.Service == nil {
+			continue
+		}
+		svc := convertService(doc)
+		if origin, exists := origins[svc.Hostname]; exists {
+			errs = multierror.Append(errs, fmt.Errorf("%v: %s %s/%s: hostname %s is already declared at %v",
+				doc.Origin, doc.Kind, doc.Metadata.Namespace, doc.Metadata.Name, svc.Hostname, origin))
+			continue
+		}
+		origins[svc.Hostname] = doc.Origin
+		r.services[svc.Hostname] = svc
+		r.instances[svc.Hostname] = make(map[string]*model.ServiceInstance)
+		byName[doc.Metadata.Namespace+"/"+doc.Metadata.Name] = svc
+		if err := r.addEndpoints(svc, doc.Service.Endpoints); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%v: %s %s/%s: %v", doc.Origin, doc.Kind,
+				doc.Metadata.Namespace, doc.Metadata.Name, err))
+		}
+	}
+
+	for _, doc := range docs {
+		if doc.Endpoints == nil {
+			continue
+		}
+		svc, exists := byName[doc.Metadata.Namespace+"/"+doc.Endpoints.Service]
+		var err error
+		if !exists {
+			err = fmt.Errorf("spec.service: service %s/%s is not declared", doc.Metadata.Namespace, doc.Endpoints.Service)
+		} else {
+			err = r.addEndpoints(svc, doc.Endpoints.Endpoints)
+		}
+		if err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%v: %s %s/%s: %v", doc.Origin, doc.Kind,
+				doc.Metadata.Namespace, doc.Metadata.Name, err))
+		}
+	}
+
+	return r, errs
+}
+
+func convertService(doc *Document) *model.Service {
+	ports := make(model.PortList, 0, len(doc.Service.Ports))
+	for _, port := range doc.Service.Ports {
+		ports = append(ports, &model.Port{
+			Name:     port.Name,
+			Port:     port.Number,
+			Protocol: convertProtocol(port.Protocol),
+		})
+	}
+
+	address := doc.Service.Address
+	if address == "" {
+		address = "0.0.0.0"
+	}
+	resolution := model.ClientSideLB
+	if doc.Service.MeshExternal && len(doc.Service.Endpoints) == 0 {
+		resolution = model.Passthrough
+	}
+
+	hostname := host.Name(doc.Service.Hostname)
+	return &model.Service{
+		Hostname:     hostname,
+		Address:      address,
+		Ports:        ports,
+		MeshExternal: doc.Service.MeshExternal,
+		Resolution:   resolution,
+		Attributes: model.ServiceAttributes{
+			ServiceRegistry: string(serviceregistry.File),
+			Name:            doc.Metadata.Name,
+			Namespace:       doc.Metadata.Namespace,
+		},
+	}
+}
+
+// addEndpoints adds an instance for each endpoint and port of the service. An endpoint declared
+// twice is an error; the first declaration is kept.
+func (r *registry) addEndpoints(svc *model.Service, endpoints []*EndpointSpec) error {
+	var errs error
+	for i, ep := range endpoints {
+		epLabels := labels.Instance(ep.Labels)
+		for name := range ep.Ports {
+			if _, exists := svc.Ports.Get(name); !exists {
+				errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d].ports: unknown service port %q", i, name))
+			}
+		}
+		for _, port := range svc.Ports {
+			if _, exists := ep.Ports[port.Name]; !exists && len(ep.Ports) > 0 {
+				continue
+			}
+			number := port.Port
+			if n, exists := ep.Ports[port.Name]; exists {
+				number = n
+			}
+			instance := &model.ServiceInstance{
+				Endpoint: &model.IstioEndpoint{
+					Address:         ep.Address,
+					EndpointPort:    uint32(number),
+					ServicePortName: port.Name,
+					Network:         ep.Network,
+					Locality: model.Locality{
+						Label: ep.Locality,
+					},
+					Labels:         epLabels,
+					ServiceAccount: ep.ServiceAccount,
+					LbWeight:       ep.Weight,
+					TLSMode:        model.GetTLSModeFromEndpointLabels(epLabels),
+				},
+				ServicePort: port,
+				Service:     svc,
+			}
+			key := instanceKey(instance)
+			if _, exists := r.instances[svc.Hostname][key]; exists {
+				errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d]: endpoint %s of %s is declared twice",
+					i, key, svc.Hostname))
+				continue
+			}
+			r.instances[svc.Hostname][key] = instance
+		}
+	}
+	return errs
+}
+
+// convertProtocol returns the protocol of a port, TCP when not set
+func convertProtocol(name string) protocol.Instance {
+	if name == "" {
+		return protocol.TCP
+	}
+	return protocol.Parse(name)
+}
+
+func instanceKey(instance *model.ServiceInstance) string {
+	return fmt.Sprintf("%s:%d", instance.Endpoint.Address, instance.Endpoint.EndpointPort)
+}
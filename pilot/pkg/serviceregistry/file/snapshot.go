@@ -0,0 +1,282 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/go-multierror"
+	"sigs.k8s.io/yaml"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+	"istio.io/istio/pkg/config/validation"
+)
+
+var (
+	supportedExtensions = map[string]bool{
+		".yaml": true,
+		".yml":  true,
+	}
+)
+
+// FileSnapshot holds a reference to a file directory that contains registry documents.
+type FileSnapshot struct {
+	root string
+}
+
+// NewFileSnapshot returns a snapshotter of the registry documents under root.
+func NewFileSnapshot(root string) *FileSnapshot {
+	return &FileSnapshot{
+		root: root,
+	}
+}
+
+// ReadFiles parses and validates the files in the root directory and returns the valid documents
+// sorted by key. Invalid documents are skipped and reported in the returned error, each with the
+// file and line where the document starts.
+func (f *FileSnapshot) ReadFiles() ([]*Document, error) {
+	var result []*Document
+	var errs error
+
+	err := filepath.Walk(f.root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if !supportedExtensions[filepath.Ext(path)] || (info.Mode()&os.ModeType) != 0 {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		docs, err := ParseDocuments(path, data)
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+		result = append(result, docs...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The same document declared twice is ambiguous; keep the first one in file order.
+	sort.SliceStable(result, func(i, j int) bool { return result[i].key() < result[j].key() })
+	deduped := make([]*Document, 0, len(result))
+	for _, doc := range result {
+		if n := len(deduped); n > 0 && deduped[n-1].key() == doc.key() {
+			errs = multierror.Append(errs, fmt.Errorf("%v: %s %s/%s is already declared at %v",
+				doc.Origin, doc.Kind, doc.Metadata.Namespace, doc.Metadata.Name, deduped[n-1].Origin))
+			continue
+		}
+		deduped = append(deduped, doc)
+	}
+	return deduped, errs
+}
+
+// rawDocument is used to peek at the kind of a document before decoding its spec
+type rawDocument struct {
+	APIVersion string          `json:"apiVersion"`
+	Kind       string          `json:"kind"`
+	Metadata   Metadata        `json:"metadata"`
+	Spec       json.RawMessage `json:"spec"`
+}
+
+// ParseDocuments parses and validates the documents of a registry file.
+func ParseDocuments(filename string, data []byte) ([]*Document, error) {
+	var docs []*Document
+	var errs error
+
+	for _, chunk := range splitDocuments(data) {
+		origin := Origin{Filename: filename, Line: chunk.line}
+		doc, err := parseDocument(chunk.data, origin)
+		if err != nil {
+			errs = multierror.Append(errs, prefixErrors(origin.String(), err))
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	return docs, errs
+}
+
+type chunk struct {
+	data []byte
+	// line is the first line of the document which is neither blank nor a comment, starting at 1
+	line int
+}
+
+// splitDocuments splits a multi-document YAML file on "---" separators, skipping empty documents.
+func splitDocuments(data []byte) []chunk {
+	var chunks []chunk
+	var current bytes.Buffer
+	start := 0
+	flush := func() {
+		if start > 0 {
+			chunks = append(chunks, chunk{data: append([]byte(nil), current.Bytes()...), line: start})
+		}
+		current.Reset()
+		start = 0
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if strings.TrimRightFunc(text, unicode.IsSpace) == "---" {
+			flush()
+			continue
+		}
+		trimmed := strings.TrimSpace(text)
+		if start == 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			start = line
+		}
+		current.WriteString(text)
+		current.WriteByte('\n')
+	}
+	flush()
+	return chunks
+}
+
+func parseDocument(chunk []byte, origin Origin) (*Document, error) {
+	var raw rawDocument
+	if err := yaml.Unmarshal(chunk, &raw); err != nil {
+		return nil, err
+	}
+	if raw.APIVersion != APIVersion {
+		return nil, fmt.Errorf("unsupported apiVersion %q, expected %q", raw.APIVersion, APIVersion)
+	}
+	if raw.Metadata.Name == "" {
+		return nil, fmt.Errorf("%s has no metadata.name", raw.Kind)
+	}
+	if raw.Metadata.Namespace == "" {
+		raw.Metadata.Namespace = model.IstioDefaultConfigNamespace
+	}
+
+	doc := &Document{
+		APIVersion: raw.APIVersion,
+		Kind:       raw.Kind,
+		Metadata:   raw.Metadata,
+		Origin:     origin,
+	}
+	var err error
+	switch raw.Kind {
+	case ServiceKind:
+		doc.Service = &ServiceSpec{}
+		if err = yaml.UnmarshalStrict(raw.Spec, doc.Service); err == nil {
+			err = validateService(doc.Service)
+		}
+	case EndpointsKind:
+		doc.Endpoints = &EndpointsSpec{}
+		if err = yaml.UnmarshalStrict(raw.Spec, doc.Endpoints); err == nil {
+			err = validateEndpoints(doc.Endpoints)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q, expected %s or %s", raw.Kind, ServiceKind, EndpointsKind)
+	}
+	if err != nil {
+		return nil, prefixErrors(fmt.Sprintf("%s %s/%s", doc.Kind, doc.Metadata.Namespace, doc.Metadata.Name), err)
+	}
+	return doc, nil
+}
+
+// prefixErrors prefixes each of the errors wrapped in err, so that they read well once flattened
+func prefixErrors(prefix string, err error) error {
+	merr, ok := err.(*multierror.Error)
+	if !ok {
+		return fmt.Errorf("%s: %v", prefix, err)
+	}
+	var errs error
+	for _, e := range merr.Errors {
+		errs = multierror.Append(errs, fmt.Errorf("%s: %v", prefix, e))
+	}
+	return errs
+}
+
+func validateService(spec *ServiceSpec) (errs error) {
+	if err := validation.ValidateFQDN(spec.Hostname); err != nil {
+		errs = multierror.Append(errs, fmt.Errorf("spec.hostname: %v", err))
+	}
+	if spec.Address != "" {
+		if err := validation.ValidateIPAddress(spec.Address); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("spec.address: %v", err))
+		}
+	}
+	if len(spec.Ports) == 0 {
+		errs = multierror.Append(errs, fmt.Errorf("spec.ports: at least one port is required"))
+	}
+	names := make(map[string]bool)
+	numbers := make(map[int]bool)
+	for i, port := range spec.Ports {
+		if err := validation.ValidatePortName(port.Name); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("spec.ports[%d].name: %v", i, err))
+		} else if names[port.Name] {
+			errs = multierror.Append(errs, fmt.Errorf("spec.ports[%d].name: duplicate port name %q", i, port.Name))
+		}
+		names[port.Name] = true
+		if err := validation.ValidatePort(port.Number); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("spec.ports[%d].number: %v", i, err))
+		} else if numbers[port.Number] {
+			errs = multierror.Append(errs, fmt.Errorf("spec.ports[%d].number: duplicate port number %d", i, port.Number))
+		}
+		numbers[port.Number] = true
+		if port.Protocol != "" && protocol.Parse(port.Protocol) == protocol.Unsupported {
+			errs = multierror.Append(errs, fmt.Errorf("spec.ports[%d].protocol: unsupported protocol %q", i, port.Protocol))
+		}
+	}
+	return multierror.Append(errs, validateEndpointList(spec.Endpoints, names)).ErrorOrNil()
+}
+
+func validateEndpoints(spec *EndpointsSpec) error {
+	var errs error
+	if spec.Service == "" {
+		errs = multierror.Append(errs, fmt.Errorf("spec.service: the name of the service is required"))
+	}
+	if len(spec.Endpoints) == 0 {
+		errs = multierror.Append(errs, fmt.Errorf("spec.endpoints: at least one endpoint is required"))
+	}
+	// Port names are checked against the service once all documents are known
+	return multierror.Append(errs, validateEndpointList(spec.Endpoints, nil)).ErrorOrNil()
+}
+
+// validateEndpointList validates endpoints. When portNames is not nil, endpoint ports must use one of them.
+func validateEndpointList(endpoints []*EndpointSpec, portNames map[string]bool) (errs error) {
+	for i, ep := range endpoints {
+		if err := validation.ValidateIPAddress(ep.Address); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d].address: %v", i, err))
+		}
+		for name, number := range ep.Ports {
+			if portNames != nil && !portNames[name] {
+				errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d].ports: unknown service port %q", i, name))
+			}
+			if err := validation.ValidatePort(number); err != nil {
+				errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d].ports[%s]: %v", i, name, err))
+			}
+		}
+		if err := labels.Instance(ep.Labels).Validate(); err != nil {
+			errs = multierror.Append(errs, fmt.Errorf("spec.endpoints[%d].labels: %v", i, err))
+		}
+	}
+	return
+}
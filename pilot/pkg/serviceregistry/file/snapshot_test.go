@@ -0,0 +1,148 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const reviewsService = `apiVersion: registry.istio.io/v1alpha1
+kind: Service
+metadata:
+  name: reviews
+  namespace: edge
+spec:
+  hostname: reviews.edge.local
+  ports:
+  - name: http
+    number: 9080
+    protocol: HTTP
+  endpoints:
+  - address: 10.0.0.1
+    labels:
+      version: v1
+`
+
+const reviewsEndpoints = `apiVersion: registry.istio.io/v1alpha1
+kind: Endpoints
+metadata:
+  name: reviews-vms
+  namespace: edge
+spec:
+  service: reviews
+  endpoints:
+  - address: 10.0.0.2
+    ports:
+      http: 19080
+    labels:
+      version: v2
+`
+
+func TestParseDocuments(t *testing.T) {
+	docs, err := ParseDocuments("edge.yaml", []byte(reviewsService+"---\n"+reviewsEndpoints))
+	if err != nil {
+		t.Fatalf("ParseDocuments() encountered unexpected error: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("ParseDocuments() => %d documents, want 2", len(docs))
+	}
+	if docs[0].Service == nil || docs[0].Service.Hostname != "reviews.edge.local" || docs[0].Origin.Line != 1 {
+		t.Errorf("ParseDocuments() first document => %+v", docs[0])
+	}
+	if docs[1].Endpoints == nil || docs[1].Endpoints.Service != "reviews" || docs[1].Origin.Line != 17 {
+		t.Errorf("ParseDocuments() second document => %+v at %v", docs[1], docs[1].Origin)
+	}
+}
+
+func TestParseDocumentsErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		doc  string
+		want []string
+	}{
+		{
+			name: "unknown kind",
+			doc:  strings.Replace(reviewsService, "kind: Service", "kind: Deployment", 1),
+			want: []string{"bad.yaml:3:", `unsupported kind "Deployment"`},
+		},
+		{
+			name: "unknown field",
+			doc:  strings.Replace(reviewsService, "hostname:", "host:", 1),
+			want: []string{"bad.yaml:3:", "Service edge/reviews", `unknown field "host"`},
+		},
+		{
+			name: "invalid values",
+			doc: strings.NewReplacer("number: 9080", "number: 90800", "protocol: HTTP", "protocol: FOO",
+				"address: 10.0.0.1", "address: vm1").Replace(reviewsService),
+			want: []string{
+				"bad.yaml:3:",
+				"spec.ports[0].number",
+				"spec.ports[0].protocol",
+				"spec.endpoints[0].address",
+			},
+		},
+		{
+			name: "unknown endpoint port",
+			doc:  strings.Replace(reviewsService, "    labels:", "    ports:\n      grpc: 9090\n    labels:", 1),
+			want: []string{"bad.yaml:3:", `spec.endpoints[0].ports: unknown service port "grpc"`},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Prefix with a comment so that the document starts on line 3
+			docs, err := ParseDocuments("bad.yaml", []byte("# comment\n\n"+c.doc))
+			if err == nil {
+				t.Fatalf("ParseDocuments() => %v, want error", docs)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("ParseDocuments() error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "file-registry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "a.yaml", reviewsService)
+	writeFile(t, dir, "b.yml", reviewsEndpoints+"---\n"+reviewsService)
+	writeFile(t, dir, "c.txt", "not a registry file")
+
+	docs, err := NewFileSnapshot(dir).ReadFiles()
+	if len(docs) != 2 {
+		t.Fatalf("ReadFiles() => %d documents, want 2", len(docs))
+	}
+	if err == nil || !strings.Contains(err.Error(), "b.yml:15: Service edge/reviews is already declared at "+
+		filepath.Join(dir, "a.yaml")+":1") {
+		t.Fatalf("ReadFiles() error => %v, want duplicate declaration", err)
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"fmt"
+)
+
+const (
+	// APIVersion of the documents understood by the file registry
+	APIVersion = "registry.istio.io/v1alpha1"
+	// ServiceKind declares a service and, optionally, its endpoints
+	ServiceKind = "Service"
+	// EndpointsKind declares additional endpoints of a service declared elsewhere
+	EndpointsKind = "Endpoints"
+)
+
+// Document is a single YAML document of a registry file, for example:
+//
+//	apiVersion: registry.istio.io/v1alpha1
+//	kind: Service
+//	metadata:
+//	  name: reviews
+//	  namespace: edge
+//	spec:
+//	  hostname: reviews.edge.local
+//	  ports:
+//	  - name: http
+//	    number: 9080
+//	    protocol: HTTP
+//	  endpoints:
+//	  - address: 10.0.0.1
+//	    labels:
+//	      version: v1
+type Document struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Metadata   Metadata `json:"metadata"`
+	// Spec of a Service document
+	Service *ServiceSpec `json:"-"`
+	// Spec of an Endpoints document
+	Endpoints *EndpointsSpec `json:"-"`
+
+	// Origin of the document
+	Origin Origin `json:"-"`
+}
+
+// Metadata identifies a document. Names are unique per kind and namespace.
+type Metadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ServiceSpec declares a service
+type ServiceSpec struct {
+	// Hostname of the service, which must be a fully qualified domain name
+	Hostname string `json:"hostname"`
+	// Address is the optional virtual IP of the service
+	Address string `json:"address,omitempty"`
+	// Ports of the service
+	Ports []*PortSpec `json:"ports"`
+	// MeshExternal marks services that are not part of the mesh
+	MeshExternal bool `json:"meshExternal,omitempty"`
+	// Endpoints of the service
+	Endpoints []*EndpointSpec `json:"endpoints,omitempty"`
+}
+
+// PortSpec declares a service port
+type PortSpec struct {
+	Name     string `json:"name"`
+	Number   int    `json:"number"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// EndpointsSpec declares endpoints of a service declared in another document
+type EndpointsSpec struct {
+	// Service is the name of the Service document, in the same namespace
+	Service   string          `json:"service"`
+	Endpoints []*EndpointSpec `json:"endpoints"`
+}
+
+// EndpointSpec declares an endpoint of a service
+type EndpointSpec struct {
+	// Address is the IP address of the endpoint
+	Address string `json:"address"`
+	// Ports maps service port names to endpoint ports. Ports not listed use the service port number.
+	Ports map[string]int `json:"ports,omitempty"`
+	// Labels of the endpoint, used by subsets
+	Labels map[string]string `json:"labels,omitempty"`
+	// Locality of the endpoint in region/zone/subzone form
+	Locality string `json:"locality,omitempty"`
+	// Network of the endpoint
+	Network string `json:"network,omitempty"`
+	// ServiceAccount is the SPIFFE identity of the endpoint
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Weight of the endpoint for load balancing
+	Weight uint32 `json:"weight,omitempty"`
+}
+
+// Origin is the position of a document in the registry files
+type Origin struct {
+	Filename string
+	Line     int
+}
+
+func (o Origin) String() string {
+	return fmt.Sprintf("%s:%d", o.Filename, o.Line)
+}
+
+// key identifies the document across snapshots
+func (d *Document) key() string {
+	return d.Kind + "/" + d.Metadata.Namespace + "/" + d.Metadata.Name
+}
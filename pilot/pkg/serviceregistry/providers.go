@@ -24,6 +24,12 @@ const (
 	Kubernetes ProviderID = "Kubernetes"
 	// Consul is a service registry backed by Consul
 	Consul ProviderID = "Consul"
+	// Eureka is a service registry backed by Eureka
+	Eureka ProviderID = "Eureka"
+	// Zookeeper is a service registry backed by Dubbo providers published in ZooKeeper
+	Zookeeper ProviderID = "Zookeeper"
+	// File is a service registry backed by YAML files
+	File ProviderID = "File"
 	// MCP is a service registry backed by MCP ServiceEntries
 	MCP ProviderID = "MCP"
 	// External is a service registry for externally provided ServiceEntries
@@ -0,0 +1,397 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-zookeeper/zk"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/spiffe"
+)
+
+var _ serviceregistry.Instance = &Controller{}
+
+const (
+	// DefaultRoot is the znode under which Dubbo publishes its interfaces
+	DefaultRoot = "/dubbo"
+
+	providersNode  = "providers"
+	sessionTimeout = 10 * time.Second
+	retryInterval  = 2 * time.Second
+)
+
+// conn is the subset of the ZooKeeper client used by the registry. It is satisfied by *zk.Conn.
+type conn interface {
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+	ExistsW(path string) (bool, *zk.Stat, <-chan zk.Event, error)
+	Close()
+}
+
+// Controller watches the Dubbo providers published in ZooKeeper under <root>/<interface>/providers
+// and notifies handlers of the services and instances that changed.
+type Controller struct {
+	conn      conn
+	root      string
+	clusterID string
+
+	mutex    sync.RWMutex
+	services map[host.Name]*model.Service
+	// instances of each service, keyed by hostname then by endpoint address and port
+	instances map[host.Name]map[string]*model.ServiceInstance
+
+	serviceHandlers  []func(*model.Service, model.Event)
+	instanceHandlers []func(*model.ServiceInstance, model.Event)
+}
+
+// NewController connects to the ZooKeeper ensemble and creates a new Dubbo registry controller.
+func NewController(servers []string, root string, clusterID string) (*Controller, error) {
+	c, _, err := zk.Connect(servers, sessionTimeout, zk.WithLogInfo(false))
+	if err != nil {
+		return nil, err
+	}
+	return newController(c, root, clusterID), nil
+}
+
+func newController(c conn, root string, clusterID string) *Controller {
+	if root == "" {
+		root = DefaultRoot
+	}
+	return &Controller{
+		conn:      c,
+		root:      root,
+		clusterID: clusterID,
+		services:  make(map[host.Name]*model.Service),
+		instances: make(map[host.Name]map[string]*model.ServiceInstance),
+	}
+}
+
+func (c *Controller) Provider() serviceregistry.ProviderID {
+	return serviceregistry.Zookeeper
+}
+
+func (c *Controller) Cluster() string {
+	return c.clusterID
+}
+
+// Services list declarations of all services in the system
+func (c *Controller) Services() ([]*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.Service, 0, len(c.services))
+	for _, svc := range c.services {
+		out = append(out, svc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Hostname < out[j].Hostname })
+	return out, nil
+}
+
+// GetService retrieves a service by host name if it exists
+func (c *Controller) GetService(hostname host.Name) (*model.Service, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.services[hostname], nil
+}
+
+// ManagementPorts retrieves set of health check ports by instance IP.
+// This does not apply to ZooKeeper service registry, as ZooKeeper does not
+// manage the service instances.
+func (c *Controller) ManagementPorts(addr string) model.PortList {
+	return nil
+}
+
+// WorkloadHealthCheckInfo retrieves set of health check info by instance IP.
+// This does not apply to ZooKeeper service registry, as ZooKeeper does not
+// manage the service instances.
+func (c *Controller) WorkloadHealthCheckInfo(addr string) model.ProbeList {
+	return nil
+}
+
+// InstancesByPort retrieves instances for a service that match
+// any of the supplied labels. All instances match an empty tag list.
+func (c *Controller) InstancesByPort(svc *model.Service, port int,
+	labels labels.Collection) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	instances, ok := c.instances[svc.Hostname]
+	if !ok {
+		return nil, fmt.Errorf("could not find instance of service: %s", svc.Hostname)
+	}
+	out := make([]*model.ServiceInstance, 0, len(instances))
+	for _, instance := range instances {
+		if labels.HasSubsetOf(instance.Endpoint.Labels) && (port == 0 || port == instance.ServicePort.Port) {
+			out = append(out, instance)
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+// GetProxyServiceInstances lists service instances co-located with a given proxy
+func (c *Controller) GetProxyServiceInstances(node *model.Proxy) ([]*model.ServiceInstance, error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	out := make([]*model.ServiceInstance, 0)
+	for _, instances := range c.instances {
+		for _, instance := range instances {
+			for _, ipAddress := range node.IPAddresses {
+				if ipAddress == instance.Endpoint.Address {
+					out = append(out, instance)
+					break
+				}
+			}
+		}
+	}
+	sortInstances(out)
+	return out, nil
+}
+
+func (c *Controller) GetProxyWorkloadLabels(proxy *model.Proxy) (labels.Collection, error) {
+	instances, err := c.GetProxyServiceInstances(proxy)
+	if err != nil {
+		return nil, err
+	}
+	out := make(labels.Collection, 0, len(instances))
+	for _, instance := range instances {
+		out = append(out, instance.Endpoint.Labels)
+	}
+	return out, nil
+}
+
+// GetIstioServiceAccounts implements model.ServiceAccounts operation.
+// Dubbo has no notion of service account, so as for Consul all the services
+// are assumed to run as the default service account.
+func (c *Controller) GetIstioServiceAccounts(svc *model.Service, ports []int) []string {
+	return []string{
+		spiffe.MustGenSpiffeURI("default", "default"),
+	}
+}
+
+// AppendServiceHandler implements a service catalog operation
+func (c *Controller) AppendServiceHandler(f func(*model.Service, model.Event)) error {
+	c.serviceHandlers = append(c.serviceHandlers, f)
+	return nil
+}
+
+// AppendInstanceHandler implements a service catalog operation
+func (c *Controller) AppendInstanceHandler(f func(*model.ServiceInstance, model.Event)) error {
+	c.instanceHandlers = append(c.instanceHandlers, f)
+	return nil
+}
+
+// Run watches the Dubbo interfaces until a signal is received
+func (c *Controller) Run(stop <-chan struct{}) {
+	defer c.conn.Close()
+
+	// channels closed when an interface is removed, keyed by interface
+	watches := make(map[string]chan struct{})
+	for {
+		interfaces, events, err := c.children(c.root)
+		if err != nil {
+			log.Warnf("Could not watch Dubbo interfaces under %s: %v", c.root, err)
+			if !sleep(retryInterval, stop) {
+				return
+			}
+			continue
+		}
+
+		current := make(map[string]bool, len(interfaces))
+		for _, iface := range interfaces {
+			current[iface] = true
+			if _, exists := watches[iface]; !exists {
+				removed := make(chan struct{})
+				watches[iface] = removed
+				go c.watchProviders(iface, removed, stop)
+			}
+		}
+		for iface, removed := range watches {
+			if !current[iface] {
+				close(removed)
+				delete(watches, iface)
+			}
+		}
+
+		select {
+		case <-events:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// watchProviders keeps the service of a Dubbo interface in sync with its providers until the
+// interface is removed, in which case the service is deleted, or a signal is received.
+func (c *Controller) watchProviders(iface string, removed <-chan struct{}, stop <-chan struct{}) {
+	providersPath := path.Join(c.root, iface, providersNode)
+	for {
+		znodes, events, err := c.children(providersPath)
+		if err != nil {
+			log.Warnf("Could not watch Dubbo providers under %s: %v", providersPath, err)
+			select {
+			case <-time.After(retryInterval):
+				continue
+			case <-removed:
+				c.updateService(iface, nil)
+				return
+			case <-stop:
+				return
+			}
+		}
+
+		providers := make([]*provider, 0, len(znodes))
+		for _, znode := range znodes {
+			p, err := parseProvider(znode)
+			if err != nil {
+				log.Warnf("Ignoring Dubbo provider of %s: %v", iface, err)
+				continue
+			}
+			if p.iface != iface {
+				log.Warnf("Ignoring Dubbo provider of %s published for interface %s", iface, p.iface)
+				continue
+			}
+			providers = append(providers, p)
+		}
+		c.updateService(iface, providers)
+
+		select {
+		case <-events:
+		case <-removed:
+			c.updateService(iface, nil)
+			return
+		case <-stop:
+			return
+		}
+	}
+}
+
+// children lists the children of a znode and watches it for changes. When the znode does not exist,
+// an empty list is returned and the znode is watched for creation instead.
+func (c *Controller) children(p string) ([]string, <-chan zk.Event, error) {
+	children, _, events, err := c.conn.ChildrenW(p)
+	if err != zk.ErrNoNode {
+		return children, events, err
+	}
+	exists, _, events, err := c.conn.ExistsW(p)
+	if err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		// Created in between the two calls, make the caller list it again right away
+		ch := make(chan zk.Event, 1)
+		ch <- zk.Event{Type: zk.EventNodeCreated, Path: p}
+		return nil, ch, nil
+	}
+	return nil, events, nil
+}
+
+// updateService replaces the service and instances of a Dubbo interface and notifies handlers
+// of the difference with the previous state.
+func (c *Controller) updateService(iface string, providers []*provider) {
+	hostname := serviceHostname(iface)
+	svc := convertService(iface, providers)
+	instances := make(map[string]*model.ServiceInstance)
+	if svc != nil {
+		for _, p := range providers {
+			if instance := convertInstance(svc, p); instance != nil {
+				instances[instanceKey(instance)] = instance
+			}
+		}
+	}
+
+	c.mutex.Lock()
+	oldSvc, oldInstances := c.services[hostname], c.instances[hostname]
+	if svc == nil {
+		delete(c.services, hostname)
+		delete(c.instances, hostname)
+	} else {
+		c.services[hostname] = svc
+		c.instances[hostname] = instances
+	}
+	c.mutex.Unlock()
+
+	if svc != nil {
+		if oldSvc == nil {
+			c.notifyService(svc, model.EventAdd)
+		} else if !reflect.DeepEqual(oldSvc, svc) {
+			c.notifyService(svc, model.EventUpdate)
+		}
+	}
+	for key, instance := range instances {
+		if old, exists := oldInstances[key]; !exists {
+			c.notifyInstance(instance, model.EventAdd)
+		} else if !reflect.DeepEqual(old.Endpoint, instance.Endpoint) || !reflect.DeepEqual(old.Service, instance.Service) {
+			c.notifyInstance(instance, model.EventUpdate)
+		}
+	}
+	for key, old := range oldInstances {
+		if _, exists := instances[key]; !exists {
+			c.notifyInstance(old, model.EventDelete)
+		}
+	}
+	if svc == nil && oldSvc != nil {
+		c.notifyService(oldSvc, model.EventDelete)
+	}
+}
+
+func (c *Controller) notifyService(svc *model.Service, event model.Event) {
+	log.Debugf("Dubbo service %s %s", svc.Hostname, event)
+	for _, f := range c.serviceHandlers {
+		f(svc, event)
+	}
+}
+
+func (c *Controller) notifyInstance(instance *model.ServiceInstance, event model.Event) {
+	log.Debugf("Dubbo provider %s of %s %s", instanceKey(instance), instance.Service.Hostname, event)
+	for _, f := range c.instanceHandlers {
+		f(instance, event)
+	}
+}
+
+func instanceKey(instance *model.ServiceInstance) string {
+	return fmt.Sprintf("%s:%d", instance.Endpoint.Address, instance.Endpoint.EndpointPort)
+}
+
+func sortInstances(instances []*model.ServiceInstance) {
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Service.Hostname != instances[j].Service.Hostname {
+			return instances[i].Service.Hostname < instances[j].Service.Hostname
+		}
+		return instanceKey(instances[i]) < instanceKey(instances[j])
+	})
+}
+
+// sleep waits for the duration and returns false if stopped in the meantime
+func sleep(d time.Duration, stop <-chan struct{}) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-stop:
+		return false
+	}
+}
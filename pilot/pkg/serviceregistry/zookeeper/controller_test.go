@@ -0,0 +1,213 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"net/url"
+	"path"
+	"testing"
+	"time"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const notifyThreshold = 10 * time.Second
+
+func providerPath(iface, providerURL string) string {
+	return path.Join(DefaultRoot, iface, providersNode, url.QueryEscape(providerURL))
+}
+
+type event struct {
+	kind  string
+	name  string
+	event model.Event
+}
+
+func newTestController(t *testing.T, server *testServer) *Controller {
+	t.Helper()
+	ctl, err := NewController([]string{server.addr()}, "", "")
+	if err != nil {
+		t.Fatalf("NewController() encountered unexpected error: %v", err)
+	}
+	return ctl
+}
+
+func expectEvents(t *testing.T, events <-chan event, want ...event) {
+	t.Helper()
+	got := make(map[event]bool)
+	for range want {
+		select {
+		case e := <-events:
+			got[e] = true
+		case <-time.After(notifyThreshold):
+			t.Fatalf("got events %v, want %v", got, want)
+		}
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Fatalf("missing event %v, got %v", w, got)
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	server := newTestServer(t)
+	defer server.close()
+	server.create(providerPath("com.foo.DemoService",
+		"dubbo://10.0.0.1:20880/com.foo.DemoService?interface=com.foo.DemoService&version=1.0.0&methods=sayHello"))
+	server.create(providerPath("com.foo.DemoService",
+		"dubbo://10.0.0.2:20880/com.foo.DemoService?interface=com.foo.DemoService&version=2.0.0&methods=sayHello"))
+
+	ctl := newTestController(t, server)
+	events := make(chan event, 100)
+	_ = ctl.AppendServiceHandler(func(svc *model.Service, e model.Event) {
+		events <- event{"service", string(svc.Hostname), e}
+	})
+	_ = ctl.AppendInstanceHandler(func(si *model.ServiceInstance, e model.Event) {
+		events <- event{"instance", instanceKey(si), e}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	expectEvents(t, events,
+		event{"service", "com.foo.demoservice.dubbo", model.EventAdd},
+		event{"instance", "10.0.0.1:20880", model.EventAdd},
+		event{"instance", "10.0.0.2:20880", model.EventAdd},
+	)
+
+	svc, _ := ctl.GetService("com.foo.demoservice.dubbo")
+	if svc == nil || len(svc.Ports) != 1 || svc.Ports[0].Protocol != protocol.TCP {
+		t.Fatalf("GetService() => %v, want a single TCP port", svc)
+	}
+	instances, err := ctl.InstancesByPort(svc, 20880, labels.Collection{{"version": "2.0.0"}})
+	if err != nil {
+		t.Fatalf("InstancesByPort() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Endpoint.Address != "10.0.0.2" {
+		t.Fatalf("InstancesByPort() => %v, want the 2.0.0 provider", instances)
+	}
+
+	// A new interface is discovered
+	server.create(providerPath("com.foo.GreetService",
+		"grpc://10.0.0.3:50051/com.foo.GreetService?interface=com.foo.GreetService"))
+	expectEvents(t, events,
+		event{"service", "com.foo.greetservice.dubbo", model.EventAdd},
+		event{"instance", "10.0.0.3:50051", model.EventAdd},
+	)
+
+	// A provider going away removes its instance only
+	server.delete(providerPath("com.foo.DemoService",
+		"dubbo://10.0.0.1:20880/com.foo.DemoService?interface=com.foo.DemoService&version=1.0.0&methods=sayHello"))
+	expectEvents(t, events, event{"instance", "10.0.0.1:20880", model.EventDelete})
+
+	// Removing the last provider deletes the service
+	server.delete(providerPath("com.foo.GreetService",
+		"grpc://10.0.0.3:50051/com.foo.GreetService?interface=com.foo.GreetService"))
+	expectEvents(t, events,
+		event{"instance", "10.0.0.3:50051", model.EventDelete},
+		event{"service", "com.foo.greetservice.dubbo", model.EventDelete},
+	)
+
+	// Removing the interface deletes the service
+	server.delete(path.Join(DefaultRoot, "com.foo.DemoService"))
+	expectEvents(t, events,
+		event{"instance", "10.0.0.2:20880", model.EventDelete},
+		event{"service", "com.foo.demoservice.dubbo", model.EventDelete},
+	)
+
+	services, _ := ctl.Services()
+	if len(services) != 0 {
+		t.Fatalf("Services() => %v, want none", services)
+	}
+}
+
+func TestControllerWaitsForRoot(t *testing.T) {
+	server := newTestServer(t)
+	defer server.close()
+	ctl := newTestController(t, server)
+	events := make(chan event, 10)
+	_ = ctl.AppendServiceHandler(func(svc *model.Service, e model.Event) {
+		events <- event{"service", string(svc.Hostname), e}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+
+	server.create(providerPath("com.foo.DemoService", "dubbo://10.0.0.1:20880/com.foo.DemoService?enabled=true"))
+	select {
+	case e := <-events:
+		if e != (event{"service", "com.foo.demoservice.dubbo", model.EventAdd}) {
+			t.Fatalf("unexpected event %v", e)
+		}
+	case <-time.After(notifyThreshold):
+		t.Fatal("timed out waiting for the service to be added")
+	}
+}
+
+func TestControllerReconnects(t *testing.T) {
+	server := newTestServer(t)
+	defer server.close()
+	server.create(providerPath("com.foo.DemoService", "dubbo://10.0.0.1:20880/com.foo.DemoService"))
+
+	ctl := newTestController(t, server)
+	events := make(chan event, 10)
+	_ = ctl.AppendInstanceHandler(func(si *model.ServiceInstance, e model.Event) {
+		events <- event{"instance", instanceKey(si), e}
+	})
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go ctl.Run(stop)
+	expectEvents(t, events, event{"instance", "10.0.0.1:20880", model.EventAdd})
+
+	// Providers changing while the client is disconnected are caught up with once the session
+	// is resumed and its watches are set again
+	server.setDown(true)
+	server.create(providerPath("com.foo.DemoService", "dubbo://10.0.0.2:20880/com.foo.DemoService"))
+	server.delete(providerPath("com.foo.DemoService", "dubbo://10.0.0.1:20880/com.foo.DemoService"))
+	server.setDown(false)
+	expectEvents(t, events,
+		event{"instance", "10.0.0.2:20880", model.EventAdd},
+		event{"instance", "10.0.0.1:20880", model.EventDelete},
+	)
+
+	// Watches keep firing on the resumed session
+	server.create(providerPath("com.foo.DemoService", "dubbo://10.0.0.3:20880/com.foo.DemoService"))
+	expectEvents(t, events, event{"instance", "10.0.0.3:20880", model.EventAdd})
+}
+
+func TestGetProxyServiceInstances(t *testing.T) {
+	ctl := newController(nil, "", "")
+	p1, _ := parseProvider(url.QueryEscape("dubbo://10.0.0.1:20880/com.foo.DemoService?version=1.0.0"))
+	p2, _ := parseProvider(url.QueryEscape("dubbo://10.0.0.2:20880/com.foo.DemoService?version=2.0.0"))
+	ctl.updateService("com.foo.DemoService", []*provider{p1, p2})
+
+	instances, err := ctl.GetProxyServiceInstances(&model.Proxy{IPAddresses: []string{"10.0.0.2"}})
+	if err != nil {
+		t.Fatalf("GetProxyServiceInstances() encountered unexpected error: %v", err)
+	}
+	if len(instances) != 1 || instances[0].Endpoint.Labels["version"] != "2.0.0" {
+		t.Fatalf("GetProxyServiceInstances() => %v, want the 2.0.0 provider", instances)
+	}
+	workloadLabels, _ := ctl.GetProxyWorkloadLabels(&model.Proxy{IPAddresses: []string{"10.0.0.2"}})
+	if len(workloadLabels) != 1 || workloadLabels[0]["version"] != "2.0.0" {
+		t.Fatalf("GetProxyWorkloadLabels() => %v, want version 2.0.0", workloadLabels)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"istio.io/pkg/log"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pilot/pkg/serviceregistry"
+	"istio.io/istio/pkg/config/host"
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+const (
+	// domainSuffix is appended to the lower-cased Dubbo interface name to build the service hostname
+	domainSuffix = "dubbo"
+
+	// Provider URL parameters with a special meaning
+	interfaceParam = "interface"
+	enabledParam   = "enabled"
+	localityParam  = "istio.locality"
+)
+
+// labelParams are the provider URL parameters exported as endpoint labels. Other parameters
+// (methods, timestamp, pid, ...) change on every restart or are too long to be useful selectors.
+var labelParams = []string{"application", "version", "group", "revision", "side", "dubbo", "release"}
+
+// provider is a Dubbo provider published under /dubbo/<interface>/providers
+type provider struct {
+	scheme   string
+	address  string
+	port     int
+	iface    string
+	enabled  bool
+	locality string
+	labels   labels.Instance
+}
+
+// parseProvider parses a provider znode name, which is an URL-escaped provider URL such as
+// dubbo%3A%2F%2F10.0.0.1%3A20880%2Fcom.foo.DemoService%3Fversion%3D1.0.0%26...
+func parseProvider(znode string) (*provider, error) {
+	raw, err := url.QueryUnescape(znode)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider znode %q: %v", znode, err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider URL %q: %v", raw, err)
+	}
+	if u.Scheme == "" {
+		return nil, fmt.Errorf("provider URL %q has no protocol", raw)
+	}
+
+	address, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid provider address in %q: %v", raw, err)
+	}
+	if net.ParseIP(address) == nil {
+		return nil, fmt.Errorf("provider address %q in %q is not an IP address", address, raw)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("invalid provider port %q in %q", portStr, raw)
+	}
+
+	params := u.Query()
+	iface := params.Get(interfaceParam)
+	if iface == "" {
+		iface = strings.TrimPrefix(u.Path, "/")
+	}
+	if iface == "" {
+		return nil, fmt.Errorf("provider URL %q has no interface", raw)
+	}
+
+	providerLabels := make(labels.Instance)
+	for _, k := range labelParams {
+		if v := params.Get(k); v != "" {
+			providerLabels[k] = v
+		}
+	}
+
+	return &provider{
+		scheme:   u.Scheme,
+		address:  address,
+		port:     port,
+		iface:    iface,
+		enabled:  params.Get(enabledParam) != "false",
+		locality: params.Get(localityParam),
+		labels:   providerLabels,
+	}, nil
+}
+
+// convertService builds the service of a Dubbo interface from its providers. It returns nil when
+// none of the providers is enabled.
+func convertService(iface string, providers []*provider) *model.Service {
+	ports := make(map[int]*model.Port)
+	for _, p := range providers {
+		if !p.enabled {
+			continue
+		}
+		port := convertPort(p.port, p.scheme)
+		if svcPort, exists := ports[port.Port]; exists && svcPort.Protocol != port.Protocol {
+			log.Warnf("Dubbo interface %v has two providers on same port %v but different protocols (%v, %v)",
+				iface, port.Port, svcPort.Protocol, port.Protocol)
+			continue
+		}
+		ports[port.Port] = port
+	}
+	if len(ports) == 0 {
+		return nil
+	}
+
+	svcPorts := make(model.PortList, 0, len(ports))
+	for _, port := range ports {
+		svcPorts = append(svcPorts, port)
+	}
+	sort.Slice(svcPorts, func(i, j int) bool { return svcPorts[i].Port < svcPorts[j].Port })
+
+	hostname := serviceHostname(iface)
+	return &model.Service{
+		Hostname:   hostname,
+		Address:    "0.0.0.0",
+		Ports:      svcPorts,
+		Resolution: model.ClientSideLB,
+		Attributes: model.ServiceAttributes{
+			ServiceRegistry: string(serviceregistry.Zookeeper),
+			Name:            iface,
+			Namespace:       model.IstioDefaultConfigNamespace,
+		},
+	}
+}
+
+// convertInstance builds the service instance of an enabled provider of the service.
+func convertInstance(svc *model.Service, p *provider) *model.ServiceInstance {
+	svcPort, exists := svc.Ports.GetByPort(p.port)
+	if !exists || !p.enabled {
+		return nil
+	}
+	return &model.ServiceInstance{
+		Endpoint: &model.IstioEndpoint{
+			Address:         p.address,
+			EndpointPort:    uint32(p.port),
+			ServicePortName: svcPort.Name,
+			Locality: model.Locality{
+				Label: p.locality,
+			},
+			Labels:  p.labels,
+			TLSMode: model.GetTLSModeFromEndpointLabels(p.labels),
+		},
+		ServicePort: svcPort,
+		Service:     svc,
+	}
+}
+
+func convertPort(port int, scheme string) *model.Port {
+	p := convertProtocol(scheme)
+	return &model.Port{
+		Name:     fmt.Sprintf("%s-%d", strings.ToLower(scheme), port),
+		Port:     port,
+		Protocol: p,
+	}
+}
+
+// convertProtocol maps a Dubbo protocol to the Istio protocol. Dubbo's own binary protocols
+// are handled as opaque TCP.
+func convertProtocol(scheme string) protocol.Instance {
+	switch strings.ToLower(scheme) {
+	case "rest", "http", "webservice":
+		return protocol.HTTP
+	case "grpc":
+		return protocol.GRPC
+	}
+	p := protocol.Parse(scheme)
+	if p == protocol.Unsupported {
+		return protocol.TCP
+	}
+	return p
+}
+
+// serviceHostname produces FQDN for a Dubbo interface
+func serviceHostname(iface string) host.Name {
+	return host.Name(fmt.Sprintf("%s.%s", strings.ToLower(iface), domainSuffix))
+}
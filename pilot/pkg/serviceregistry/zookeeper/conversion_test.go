@@ -0,0 +1,130 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"istio.io/istio/pkg/config/labels"
+	"istio.io/istio/pkg/config/protocol"
+)
+
+func TestParseProvider(t *testing.T) {
+	cases := []struct {
+		name string
+		url  string
+		want *provider
+	}{
+		{
+			name: "dubbo provider",
+			url: "dubbo://10.0.0.1:20880/com.foo.DemoService?anyhost=true&application=demo-provider" +
+				"&interface=com.foo.DemoService&methods=sayHello&side=provider&version=1.0.0&timestamp=1583000000000",
+			want: &provider{
+				scheme:  "dubbo",
+				address: "10.0.0.1",
+				port:    20880,
+				iface:   "com.foo.DemoService",
+				enabled: true,
+				labels: labels.Instance{
+					"application": "demo-provider",
+					"side":        "provider",
+					"version":     "1.0.0",
+				},
+			},
+		},
+		{
+			name: "interface from path and locality",
+			url:  "rest://10.0.0.2:8080/com.foo.RestService?enabled=false&istio.locality=us-east/us-east-1a",
+			want: &provider{
+				scheme:   "rest",
+				address:  "10.0.0.2",
+				port:     8080,
+				iface:    "com.foo.RestService",
+				locality: "us-east/us-east-1a",
+				labels:   labels.Instance{},
+			},
+		},
+		{
+			name: "hostname instead of IP",
+			url:  "dubbo://provider.local:20880/com.foo.DemoService",
+		},
+		{
+			name: "missing port",
+			url:  "dubbo://10.0.0.1/com.foo.DemoService",
+		},
+		{
+			name: "missing interface",
+			url:  "dubbo://10.0.0.1:20880",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseProvider(url.QueryEscape(c.url))
+			if c.want == nil {
+				if err == nil {
+					t.Fatalf("parseProvider(%q) => %v, want error", c.url, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProvider(%q) encountered unexpected error: %v", c.url, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("parseProvider(%q) => %+v, want %+v", c.url, got, c.want)
+			}
+		})
+	}
+}
+
+func TestConvertProtocol(t *testing.T) {
+	protocols := []struct {
+		scheme string
+		out    protocol.Instance
+	}{
+		{"dubbo", protocol.TCP},
+		{"hessian", protocol.TCP},
+		{"rest", protocol.HTTP},
+		{"http", protocol.HTTP},
+		{"grpc", protocol.GRPC},
+		{"redis", protocol.Redis},
+	}
+	for _, tt := range protocols {
+		if out := convertProtocol(tt.scheme); out != tt.out {
+			t.Errorf("convertProtocol(%q) => %q, want %q", tt.scheme, out, tt.out)
+		}
+	}
+}
+
+func TestConvertService(t *testing.T) {
+	p1, _ := parseProvider(url.QueryEscape("dubbo://10.0.0.1:20880/com.foo.DemoService"))
+	p2, _ := parseProvider(url.QueryEscape("rest://10.0.0.1:8080/com.foo.DemoService"))
+	disabled, _ := parseProvider(url.QueryEscape("dubbo://10.0.0.2:20881/com.foo.DemoService?enabled=false"))
+
+	svc := convertService("com.foo.DemoService", []*provider{p1, p2, disabled})
+	if svc.Hostname != "com.foo.demoservice.dubbo" {
+		t.Errorf("convertService() hostname => %v, want com.foo.demoservice.dubbo", svc.Hostname)
+	}
+	if len(svc.Ports) != 2 || svc.Ports[0].Name != "rest-8080" || svc.Ports[1].Name != "dubbo-20880" {
+		t.Errorf("convertService() ports => %v, want rest-8080 and dubbo-20880", svc.Ports)
+	}
+	if instance := convertInstance(svc, disabled); instance != nil {
+		t.Errorf("convertInstance() => %v for a disabled provider, want nil", instance)
+	}
+	if svc := convertService("com.foo.DemoService", []*provider{disabled}); svc != nil {
+		t.Errorf("convertService() => %v without enabled providers, want nil", svc)
+	}
+}
@@ -0,0 +1,457 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zookeeper
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/go-zookeeper/zk"
+)
+
+// ZooKeeper wire protocol constants used by the test server
+const (
+	opExists       = 3
+	opPing         = 11
+	opGetChildren2 = 12
+	opClose        = -11
+	opSetWatches   = 101
+
+	xidWatcherEvent = -1
+	xidPing         = -2
+
+	errUnimplemented = -6
+	errNoNode        = -101
+
+	// keeper state of watcher events, SyncConnected in the Java server
+	stateSyncConnected = 3
+)
+
+// testServer is an in-process ZooKeeper server for the real go-zookeeper client. It implements
+// sessions, pings, exists and getChildren2 with watches, and the re-registration of watches by
+// clients reconnecting to their session, which is all the registry relies on.
+type testServer struct {
+	listener net.Listener
+
+	mutex    sync.Mutex
+	zxid     int64
+	nodes    map[string]*znode
+	sessions map[int64]bool
+	conns    map[*serverConn]bool
+	// when down, connections are refused while sessions are kept
+	down bool
+}
+
+type znode struct {
+	czxid    int64
+	pzxid    int64
+	cversion int32
+	children map[string]bool
+}
+
+// serverConn is a client connection and the watches it registered
+type serverConn struct {
+	net.Conn
+	childWatches map[string]bool
+	existWatches map[string]bool
+	dataWatches  map[string]bool
+}
+
+func newTestServer(t *testing.T) *testServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testServer{
+		listener: l,
+		nodes:    map[string]*znode{"/": {children: make(map[string]bool)}},
+		sessions: make(map[int64]bool),
+		conns:    make(map[*serverConn]bool),
+	}
+	go s.serve()
+	return s
+}
+
+func (s *testServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *testServer) close() {
+	_ = s.listener.Close()
+	s.disconnect()
+}
+
+// disconnect closes the connections of all clients, which reconnect to their session
+func (s *testServer) disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.conns {
+		_ = c.Close()
+		delete(s.conns, c)
+	}
+}
+
+// setDown refuses connections while down is true
+func (s *testServer) setDown(down bool) {
+	s.mutex.Lock()
+	s.down = down
+	s.mutex.Unlock()
+	if down {
+		s.disconnect()
+	}
+}
+
+func (s *testServer) serve() {
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(&serverConn{
+			Conn:         c,
+			childWatches: make(map[string]bool),
+			existWatches: make(map[string]bool),
+			dataWatches:  make(map[string]bool),
+		})
+	}
+}
+
+func (s *testServer) handle(c *serverConn) {
+	defer func() {
+		s.mutex.Lock()
+		delete(s.conns, c)
+		s.mutex.Unlock()
+		_ = c.Close()
+	}()
+
+	packet, err := readPacket(c)
+	if err != nil {
+		return
+	}
+	d := &decoder{buf: packet}
+	d.int32() // protocol version
+	d.int64() // last zxid seen
+	timeout := d.int32()
+	sessionID := d.int64()
+
+	s.mutex.Lock()
+	if s.down {
+		s.mutex.Unlock()
+		return
+	}
+	if !s.sessions[sessionID] {
+		sessionID = int64(len(s.sessions) + 1)
+		s.sessions[sessionID] = true
+	}
+	s.conns[c] = true
+	e := &encoder{}
+	e.int32(0)
+	e.int32(timeout)
+	e.int64(sessionID)
+	e.bytes(make([]byte, 16))
+	s.write(c, e)
+	s.mutex.Unlock()
+
+	for {
+		packet, err := readPacket(c)
+		if err != nil {
+			return
+		}
+		d := &decoder{buf: packet}
+		xid, op := d.int32(), d.int32()
+
+		s.mutex.Lock()
+		switch op {
+		case opPing:
+			s.write(c, s.header(xidPing, 0))
+		case opExists:
+			p, watch := d.string(), d.bool()
+			n, ok := s.nodes[p]
+			if !ok {
+				if watch {
+					c.existWatches[p] = true
+				}
+				s.write(c, s.header(xid, errNoNode))
+				break
+			}
+			if watch {
+				c.dataWatches[p] = true
+			}
+			e := s.header(xid, 0)
+			e.stat(n)
+			s.write(c, e)
+		case opGetChildren2:
+			p, watch := d.string(), d.bool()
+			n, ok := s.nodes[p]
+			if !ok {
+				s.write(c, s.header(xid, errNoNode))
+				break
+			}
+			if watch {
+				c.childWatches[p] = true
+			}
+			children := make([]string, 0, len(n.children))
+			for child := range n.children {
+				children = append(children, child)
+			}
+			sort.Strings(children)
+			e := s.header(xid, 0)
+			e.int32(int32(len(children)))
+			for _, child := range children {
+				e.string(child)
+			}
+			e.stat(n)
+			s.write(c, e)
+		case opSetWatches:
+			relativeZxid := d.int64()
+			dataWatches, existWatches, childWatches := d.strings(), d.strings(), d.strings()
+			s.write(c, s.header(xid, 0))
+			s.setWatches(c, relativeZxid, dataWatches, existWatches, childWatches)
+		case opClose:
+			s.write(c, s.header(xid, 0))
+			s.mutex.Unlock()
+			return
+		default:
+			s.write(c, s.header(xid, errUnimplemented))
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// setWatches re-registers the watches of a reconnecting client, and triggers right away the ones
+// for changes which happened after the last zxid seen by the client.
+func (s *testServer) setWatches(c *serverConn, relativeZxid int64, dataWatches, existWatches, childWatches []string) {
+	for _, p := range dataWatches {
+		if _, ok := s.nodes[p]; !ok {
+			s.notify(c, zk.EventNodeDeleted, p)
+			continue
+		}
+		c.dataWatches[p] = true
+	}
+	for _, p := range existWatches {
+		if _, ok := s.nodes[p]; ok {
+			s.notify(c, zk.EventNodeCreated, p)
+			continue
+		}
+		c.existWatches[p] = true
+	}
+	for _, p := range childWatches {
+		n, ok := s.nodes[p]
+		switch {
+		case !ok:
+			s.notify(c, zk.EventNodeDeleted, p)
+		case n.pzxid > relativeZxid:
+			s.notify(c, zk.EventNodeChildrenChanged, p)
+		default:
+			c.childWatches[p] = true
+		}
+	}
+}
+
+// create creates the znode and its missing parents, triggering the watches
+func (s *testServer) create(p string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.createLocked(p)
+}
+
+func (s *testServer) createLocked(p string) {
+	if _, ok := s.nodes[p]; ok {
+		return
+	}
+	parent := path.Dir(p)
+	s.createLocked(parent)
+
+	s.zxid++
+	s.nodes[p] = &znode{czxid: s.zxid, pzxid: s.zxid, children: make(map[string]bool)}
+	n := s.nodes[parent]
+	n.children[path.Base(p)] = true
+	n.pzxid = s.zxid
+	n.cversion++
+	s.trigger(p, zk.EventNodeCreated)
+	s.trigger(parent, zk.EventNodeChildrenChanged)
+}
+
+// delete deletes the znode and its children, triggering the watches
+func (s *testServer) delete(p string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deleteLocked(p)
+}
+
+func (s *testServer) deleteLocked(p string) {
+	n, ok := s.nodes[p]
+	if !ok {
+		return
+	}
+	for child := range n.children {
+		s.deleteLocked(path.Join(p, child))
+	}
+
+	s.zxid++
+	delete(s.nodes, p)
+	parent := s.nodes[path.Dir(p)]
+	delete(parent.children, path.Base(p))
+	parent.pzxid = s.zxid
+	parent.cversion++
+	s.trigger(p, zk.EventNodeDeleted)
+	s.trigger(path.Dir(p), zk.EventNodeChildrenChanged)
+}
+
+// trigger notifies the connections watching the znode of the event, and removes their watches
+func (s *testServer) trigger(p string, event zk.EventType) {
+	for c := range s.conns {
+		var watched bool
+		switch event {
+		case zk.EventNodeCreated:
+			watched = c.existWatches[p]
+			delete(c.existWatches, p)
+		case zk.EventNodeDeleted:
+			watched = c.dataWatches[p] || c.childWatches[p]
+			delete(c.dataWatches, p)
+			delete(c.childWatches, p)
+		case zk.EventNodeChildrenChanged:
+			watched = c.childWatches[p]
+			delete(c.childWatches, p)
+		}
+		if watched {
+			s.notify(c, event, p)
+		}
+	}
+}
+
+func (s *testServer) notify(c *serverConn, event zk.EventType, p string) {
+	e := s.header(xidWatcherEvent, 0)
+	e.int32(int32(event))
+	e.int32(stateSyncConnected)
+	e.string(p)
+	s.write(c, e)
+}
+
+func (s *testServer) header(xid int32, err int32) *encoder {
+	e := &encoder{}
+	e.int32(xid)
+	e.int64(s.zxid)
+	e.int32(err)
+	return e
+}
+
+// write sends a packet to the client. A failure closes the connection, which is then handled by
+// the reading side.
+func (s *testServer) write(c *serverConn, e *encoder) {
+	buf := make([]byte, 4, 4+e.Len())
+	binary.BigEndian.PutUint32(buf, uint32(e.Len()))
+	if _, err := c.Write(append(buf, e.Bytes()...)); err != nil {
+		_ = c.Close()
+	}
+}
+
+func readPacket(r io.Reader) ([]byte, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+// encoder writes the jute encoding of ZooKeeper packets
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) int32(v int32) {
+	_ = binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) int64(v int64) {
+	_ = binary.Write(e, binary.BigEndian, v)
+}
+
+func (e *encoder) bytes(v []byte) {
+	e.int32(int32(len(v)))
+	e.Write(v)
+}
+
+func (e *encoder) string(v string) {
+	e.bytes([]byte(v))
+}
+
+func (e *encoder) stat(n *znode) {
+	e.int64(n.czxid)    // czxid
+	e.int64(n.czxid)    // mzxid
+	e.int64(0)          // ctime
+	e.int64(0)          // mtime
+	e.int32(0)          // version
+	e.int32(n.cversion) // cversion
+	e.int32(0)          // aversion
+	e.int64(0)          // ephemeral owner
+	e.int32(0)          // data length
+	e.int32(int32(len(n.children)))
+	e.int64(n.pzxid)
+}
+
+// decoder reads the jute encoding of ZooKeeper packets. Malformed packets decode as zero values.
+type decoder struct {
+	buf []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || len(d.buf) < n {
+		d.err = errors.New("short packet")
+		return make([]byte, n)
+	}
+	out := d.buf[:n]
+	d.buf = d.buf[n:]
+	return out
+}
+
+func (d *decoder) int32() int32 {
+	return int32(binary.BigEndian.Uint32(d.next(4)))
+}
+
+func (d *decoder) int64() int64 {
+	return int64(binary.BigEndian.Uint64(d.next(8)))
+}
+
+func (d *decoder) bool() bool {
+	return d.next(1)[0] != 0
+}
+
+func (d *decoder) string() string {
+	n := d.int32()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *decoder) strings() []string {
+	n := d.int32()
+	out := make([]string, 0)
+	for i := int32(0); i < n && d.err == nil; i++ {
+		out = append(out, d.string())
+	}
+	return out
+}
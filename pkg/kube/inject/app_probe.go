@@ -19,7 +19,10 @@ package inject
 import (
 	"encoding/json"
 	"fmt"
+	"net"
+	"path"
 	"strconv"
+	"strings"
 
 	"istio.io/api/annotation"
 	"istio.io/istio/pilot/cmd/pilot-agent/status"
@@ -54,9 +57,27 @@ func FindSidecar(containers []corev1.Container) *corev1.Container {
 	return nil
 }
 
+// grpcHealthProbeCommand is the command of the exec probes performing gRPC health checks, which pilot agent
+// performs natively when the probes are rewritten.
+const grpcHealthProbeCommand = "grpc_health_probe"
+
 // convertAppProber returns an overwritten `Probe` for pilot agent to take over.
 func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev1.Probe {
-	if probe == nil || probe.HTTPGet == nil {
+	if probe == nil {
+		return nil
+	}
+	if probe.TCPSocket != nil || grpcHealthProbeAction(probe.Exec) != nil {
+		// TCP and gRPC probes are turned into HTTP probes of pilot agent.
+		p := probe.DeepCopy()
+		p.TCPSocket = nil
+		p.Exec = nil
+		p.HTTPGet = &corev1.HTTPGetAction{
+			Path: newURL,
+			Port: intstr.FromInt(statusPort),
+		}
+		return p
+	}
+	if probe.HTTPGet == nil {
 		return nil
 	}
 	p := probe.DeepCopy()
@@ -77,15 +98,24 @@ func convertAppProber(probe *corev1.Probe, newURL string, statusPort int) *corev
 func DumpAppProbers(podspec *corev1.PodSpec) string {
 	out := status.KubeAppProbers{}
 	updateNamedPort := func(p *status.Prober, portMap map[string]int32) *status.Prober {
-		if p == nil || p.HTTPGet == nil {
+		if p == nil {
 			return nil
 		}
-		if p.HTTPGet.Port.Type == intstr.String {
-			port, exists := portMap[p.HTTPGet.Port.StrVal]
+		var probePort *intstr.IntOrString
+		switch {
+		case p.HTTPGet != nil:
+			probePort = &p.HTTPGet.Port
+		case p.TCPSocket != nil:
+			probePort = &p.TCPSocket.Port
+		default:
+			return p
+		}
+		if probePort.Type == intstr.String {
+			port, exists := portMap[probePort.StrVal]
 			if !exists {
 				return nil
 			}
-			p.HTTPGet.Port = intstr.FromInt(int(port))
+			*probePort = intstr.FromInt(int(port))
 		}
 		return p
 	}
@@ -209,12 +239,71 @@ func kubeProbeToInternalProber(probe *corev1.Probe) *status.Prober {
 		return nil
 	}
 
-	if probe.HTTPGet == nil {
-		return nil
+	if probe.HTTPGet != nil {
+		return &status.Prober{
+			HTTPGet:        probe.HTTPGet,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
+	}
+
+	if probe.TCPSocket != nil {
+		return &status.Prober{
+			TCPSocket:      probe.TCPSocket.DeepCopy(),
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
+	}
+
+	if grpc := grpcHealthProbeAction(probe.Exec); grpc != nil {
+		return &status.Prober{
+			GRPC:           grpc,
+			TimeoutSeconds: probe.TimeoutSeconds,
+		}
 	}
 
-	return &status.Prober{
-		HTTPGet:        probe.HTTPGet,
-		TimeoutSeconds: probe.TimeoutSeconds,
+	return nil
+}
+
+// grpcHealthProbeAction returns the gRPC health check of an exec probe running grpc_health_probe against a
+// local port, or nil for other exec probes and for options pilot agent doesn't support, such as TLS.
+func grpcHealthProbeAction(exec *corev1.ExecAction) *status.GRPCAction {
+	if exec == nil || len(exec.Command) == 0 || path.Base(exec.Command[0]) != grpcHealthProbeCommand {
+		return nil
+	}
+	action := &status.GRPCAction{}
+	args := exec.Command[1:]
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			return nil
+		}
+		name := strings.TrimLeft(args[i], "-")
+		var value string
+		if eq := strings.Index(name, "="); eq >= 0 {
+			name, value = name[:eq], name[eq+1:]
+		} else if i+1 < len(args) {
+			i++
+			value = args[i]
+		} else {
+			return nil
+		}
+		switch name {
+		case "addr":
+			host, port, err := net.SplitHostPort(value)
+			if err != nil || (host != "" && host != "localhost" && host != "127.0.0.1") {
+				return nil
+			}
+			if action.Port, err = strconv.Atoi(port); err != nil {
+				return nil
+			}
+		case "service":
+			action.Service = value
+		case "connect-timeout", "rpc-timeout", "user-agent":
+			// The timeout of the probe applies instead.
+		default:
+			return nil
+		}
+	}
+	if action.Port == 0 {
+		return nil
 	}
+	return action
 }
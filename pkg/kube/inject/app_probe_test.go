@@ -14,9 +14,11 @@
 package inject
 
 import (
+	"reflect"
 	"testing"
 
 	"istio.io/api/annotation"
+	"istio.io/istio/pilot/cmd/pilot-agent/status"
 
 	corev1 "k8s.io/api/core/v1"
 )
@@ -109,3 +111,26 @@ func TestShouldRewriteAppHTTPProbers(t *testing.T) {
 		}
 	}
 }
+
+func TestGRPCHealthProbeAction(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		command []string
+		want    *status.GRPCAction
+	}{
+		{"addr", []string{"grpc_health_probe", "-addr=:5000"}, &status.GRPCAction{Port: 5000}},
+		{"separate-values", []string{"/bin/grpc_health_probe", "--addr", "localhost:5000", "-service", "hello"},
+			&status.GRPCAction{Port: 5000, Service: "hello"}},
+		{"timeouts", []string{"grpc_health_probe", "-addr=127.0.0.1:5000", "-connect-timeout=2s", "-rpc-timeout=2s"},
+			&status.GRPCAction{Port: 5000}},
+		{"no-addr", []string{"grpc_health_probe", "-service=hello"}, nil},
+		{"remote-addr", []string{"grpc_health_probe", "-addr=example.com:5000"}, nil},
+		{"tls", []string{"grpc_health_probe", "-addr=:5000", "-tls"}, nil},
+		{"other-command", []string{"cat", "/tmp/healthy"}, nil},
+	} {
+		got := grpcHealthProbeAction(&corev1.ExecAction{Command: tc.command})
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("[%v] failed, want %v, got %v", tc.name, tc.want, got)
+		}
+	}
+}
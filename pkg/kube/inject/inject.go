@@ -178,6 +178,13 @@ type Config struct {
 	// expansion over the `SidecarTemplateData`.
 	Template string `json:"template"`
 
+	// Templates are additional named templates, which pods select with the InjectTemplatesAnnotation. The
+	// Template can be selected under the SidecarTemplateName, unless overridden here.
+	Templates map[string]string `json:"templates,omitempty"`
+
+	// DefaultTemplates are the templates of the pods which don't select any, the Template if empty.
+	DefaultTemplates []string `json:"defaultTemplates,omitempty"`
+
 	// NeverInjectSelector: Refuses the injection on pods whose labels match this selector.
 	// It's an array of label selectors, that will be OR'ed, meaning we will iterate
 	// over it and stop at the first match
@@ -441,8 +448,8 @@ func flippedContains(needle, haystack string) bool {
 // ProxyConfigAnnotation determines the mesh config overrides for a workloadTODO move this to API
 var ProxyConfigAnnotation = "istio.io/proxyConfig"
 
-// InjectionData renders sidecarTemplate with valuesConfig.
-func InjectionData(sidecarTemplate, valuesConfig, version string, typeMetadata *metav1.TypeMeta, deploymentMetadata *metav1.ObjectMeta, spec *corev1.PodSpec,
+// InjectionData renders the templates of injectConfig selected by the pod with valuesConfig, and composes them.
+func InjectionData(injectConfig *Config, valuesConfig, version string, typeMetadata *metav1.TypeMeta, deploymentMetadata *metav1.ObjectMeta, spec *corev1.PodSpec,
 	metadata *metav1.ObjectMeta, meshConfig *meshconfig.MeshConfig) (
 	*SidecarInjectionSpec, string, error) {
 
@@ -523,17 +530,27 @@ func InjectionData(sidecarTemplate, valuesConfig, version string, typeMetadata *
 		return bbuf.String()
 	}
 
-	bbuf, err := parseTemplate(sidecarTemplate, funcMap, data)
+	templates, err := injectConfig.SelectTemplates(metadata)
 	if err != nil {
 		return nil, "", err
 	}
-
 	var sic SidecarInjectionSpec
-	if err := yaml.Unmarshal(bbuf.Bytes(), &sic); err != nil {
-		// This usually means an invalid injector template; we can't check
-		// the template itself because it is merely a string.
-		log.Warnf("Failed to unmarshal template %v\n %s", err, bbuf.String())
-		return nil, "", multierror.Prefix(err, "failed parsing generated injected YAML (check Istio sidecar injector configuration):")
+	for _, sidecarTemplate := range templates {
+		bbuf, err := parseTemplate(sidecarTemplate, funcMap, data)
+		if err != nil {
+			return nil, "", err
+		}
+
+		var spec SidecarInjectionSpec
+		if err := yaml.Unmarshal(bbuf.Bytes(), &spec); err != nil {
+			// This usually means an invalid injector template; we can't check
+			// the template itself because it is merely a string.
+			log.Warnf("Failed to unmarshal template %v\n %s", err, bbuf.String())
+			return nil, "", multierror.Prefix(err, "failed parsing generated injected YAML (check Istio sidecar injector configuration):")
+		}
+		if err := mergeInjectionSpec(&sic, &spec); err != nil {
+			return nil, "", err
+		}
 	}
 
 	// set sidecar --concurrency
@@ -577,7 +594,7 @@ func parseTemplate(tmplStr string, funcMap map[string]interface{}, data SidecarT
 
 // IntoResourceFile injects the istio proxy into the specified
 // kubernetes YAML file.
-func IntoResourceFile(sidecarTemplate string, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in io.Reader, out io.Writer) error {
+func IntoResourceFile(injectConfig *Config, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in io.Reader, out io.Writer) error {
 	reader := yamlDecoder.NewYAMLReader(bufio.NewReaderSize(in, 4096))
 	for {
 		raw, err := reader.Read()
@@ -595,7 +612,7 @@ func IntoResourceFile(sidecarTemplate string, valuesConfig string, revision stri
 
 		var updated []byte
 		if err == nil {
-			outObject, err := IntoObject(sidecarTemplate, valuesConfig, revision, meshconfig, obj) // nolint: vetshadow
+			outObject, err := IntoObject(injectConfig, valuesConfig, revision, meshconfig, obj) // nolint: vetshadow
 			if err != nil {
 				return err
 			}
@@ -636,7 +653,7 @@ func FromRawToObject(raw []byte) (runtime.Object, error) {
 }
 
 // IntoObject convert the incoming resources into Injected resources
-func IntoObject(sidecarTemplate string, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in runtime.Object) (interface{}, error) {
+func IntoObject(injectConfig *Config, valuesConfig string, revision string, meshconfig *meshconfig.MeshConfig, in runtime.Object) (interface{}, error) {
 	out := in.DeepCopyObject()
 
 	var deploymentMetadata *metav1.ObjectMeta
@@ -657,7 +674,7 @@ func IntoObject(sidecarTemplate string, valuesConfig string, revision string, me
 				return nil, err
 			}
 
-			r, err := IntoObject(sidecarTemplate, valuesConfig, revision, meshconfig, obj) // nolint: vetshadow
+			r, err := IntoObject(injectConfig, valuesConfig, revision, meshconfig, obj) // nolint: vetshadow
 			if err != nil {
 				return nil, err
 			}
@@ -739,9 +756,9 @@ func IntoObject(sidecarTemplate string, valuesConfig string, revision string, me
 	}
 
 	spec, status, err := InjectionData(
-		sidecarTemplate,
+		injectConfig,
 		valuesConfig,
-		sidecarTemplateVersionHash(sidecarTemplate),
+		sidecarTemplateVersionHash(injectConfig),
 		typeMeta,
 		deploymentMetadata,
 		podSpec,
@@ -1018,7 +1035,7 @@ type SidecarInjectionStatus struct {
 
 // helper function to generate a template version identifier from a
 // hash of the un-executed template contents.
-func sidecarTemplateVersionHash(in string) string {
+func templateVersionHash(in string) string {
 	hash := sha256.Sum256([]byte(in))
 	return hex.EncodeToString(hash[:])
 }
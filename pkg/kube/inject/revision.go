@@ -0,0 +1,185 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/api/admissionregistration/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/constants"
+)
+
+const (
+	// DefaultRevision is the value of the istio.io/rev label of the resources of the default revision.
+	DefaultRevision = "default"
+
+	// WebhookName is the name of the webhook injecting the pods of the namespaces with injection enabled.
+	WebhookName = "sidecar-injector.istio.io"
+	// NamespaceWebhookName is the name of the webhook injecting the pods of the namespaces labeled with a revision.
+	NamespaceWebhookName = "namespace.sidecar-injector.istio.io"
+	// ObjectWebhookName is the name of the webhook injecting the pods labeled with a revision.
+	ObjectWebhookName = "object.sidecar-injector.istio.io"
+
+	injectorConfigMapName = "istio-sidecar-injector"
+	injectionLabel        = "istio-injection"
+)
+
+// RevisionConfig is the injection configuration of a control plane revision.
+type RevisionConfig struct {
+	Config       *Config
+	ValuesConfig string
+	// DiscoveryAddress is the address of the discovery server of the revision, which replaces the one of
+	// the mesh config. Empty for the revision of the injector.
+	DiscoveryAddress string
+}
+
+// RevisionLabelValue returns the value of the istio.io/rev label of the resources of the revision.
+func RevisionLabelValue(revision string) string {
+	if revision == "" {
+		return DefaultRevision
+	}
+	return revision
+}
+
+// RevisionFromLabel returns the revision of the istio.io/rev label value.
+func RevisionFromLabel(value string) string {
+	if value == DefaultRevision {
+		return ""
+	}
+	return value
+}
+
+func revisionSuffix(revision string) string {
+	if revision == "" {
+		return ""
+	}
+	return "-" + revision
+}
+
+// InjectorConfigMapName returns the name of the config map holding the injection configuration of the revision.
+func InjectorConfigMapName(revision string) string {
+	return injectorConfigMapName + revisionSuffix(revision)
+}
+
+// WebhookConfigName returns the name of the MutatingWebhookConfiguration of the revision installed in the
+// namespace.
+func WebhookConfigName(revision, namespace string) string {
+	name := injectorConfigMapName + revisionSuffix(revision)
+	if namespace != constants.IstioSystemNamespace {
+		name += "-" + namespace
+	}
+	return name
+}
+
+// ServiceName returns the name of the istiod service of the revision.
+func ServiceName(revision string) string {
+	return "istiod" + revisionSuffix(revision)
+}
+
+// DiscoveryAddress returns the discovery address of the istiod of the revision installed in the namespace.
+func DiscoveryAddress(revision, namespace string) string {
+	return fmt.Sprintf("%s.%s.svc:15012", ServiceName(revision), namespace)
+}
+
+// RevisionConfigFromConfigMap parses the injector config map of a revision installed in the namespace, and
+// returns the revision and its configuration.
+func RevisionConfigFromConfigMap(cm *corev1.ConfigMap) (string, *RevisionConfig, error) {
+	value, ok := cm.Labels[model.RevisionLabel]
+	if !ok {
+		return "", nil, fmt.Errorf("config map %s.%s has no %s label", cm.Name, cm.Namespace, model.RevisionLabel)
+	}
+	revision := RevisionFromLabel(value)
+	var config Config
+	if err := yaml.Unmarshal([]byte(cm.Data["config"]), &config); err != nil {
+		return "", nil, fmt.Errorf("invalid injection config of revision %q: %v", value, err)
+	}
+	return revision, &RevisionConfig{
+		Config:           &config,
+		ValuesConfig:     cm.Data["values"],
+		DiscoveryAddress: DiscoveryAddress(revision, cm.Namespace),
+	}, nil
+}
+
+// WebhookConfiguration returns the MutatingWebhookConfiguration sending the pods of the revision to the
+// injector of the revision installed in the namespace. The pods of the default revision are those of the
+// namespaces with injection enabled. The pods of the other revisions are those labeled with the revision, or
+// of a namespace labeled with the revision, the label of the pod taking precedence.
+func WebhookConfiguration(revision, namespace string, caBundle []byte) *v1beta1.MutatingWebhookConfiguration {
+	path := "/inject"
+	failurePolicy := v1beta1.Fail
+	webhook := func(name string, namespaceSelector, objectSelector *metav1.LabelSelector) v1beta1.MutatingWebhook {
+		return v1beta1.MutatingWebhook{
+			Name: name,
+			ClientConfig: v1beta1.WebhookClientConfig{
+				Service: &v1beta1.ServiceReference{
+					Name:      ServiceName(revision),
+					Namespace: namespace,
+					Path:      &path,
+				},
+				CABundle: caBundle,
+			},
+			Rules: []v1beta1.RuleWithOperations{{
+				Operations: []v1beta1.OperationType{v1beta1.Create},
+				Rule: v1beta1.Rule{
+					APIGroups:   []string{""},
+					APIVersions: []string{"v1"},
+					Resources:   []string{"pods"},
+				},
+			}},
+			FailurePolicy:     &failurePolicy,
+			NamespaceSelector: namespaceSelector,
+			ObjectSelector:    objectSelector,
+		}
+	}
+
+	config := &v1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: WebhookConfigName(revision, namespace),
+			Labels: map[string]string{
+				model.RevisionLabel: RevisionLabelValue(revision),
+				"app":               "sidecar-injector",
+			},
+		},
+	}
+	if revision == "" {
+		config.Webhooks = []v1beta1.MutatingWebhook{
+			webhook(WebhookName, &metav1.LabelSelector{MatchLabels: map[string]string{injectionLabel: "enabled"}}, nil),
+		}
+		return config
+	}
+	config.Webhooks = []v1beta1.MutatingWebhook{
+		webhook(NamespaceWebhookName,
+			&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: injectionLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+				{Key: model.RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{revision}},
+			}},
+			&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: model.RevisionLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+			}}),
+		webhook(ObjectWebhookName,
+			&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: injectionLabel, Operator: metav1.LabelSelectorOpDoesNotExist},
+			}},
+			&metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+				{Key: model.RevisionLabel, Operator: metav1.LabelSelectorOpIn, Values: []string{revision}},
+			}}),
+	}
+	return config
+}
@@ -0,0 +1,100 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestRevisionConfigFromConfigMap(t *testing.T) {
+	cases := []struct {
+		name          string
+		labels        map[string]string
+		config        string
+		wantRevision  string
+		wantDiscovery string
+		wantErr       bool
+	}{
+		{
+			name:          "default",
+			labels:        map[string]string{"istio.io/rev": "default"},
+			config:        "policy: enabled\ntemplate: foo",
+			wantRevision:  "",
+			wantDiscovery: "istiod.istio-control.svc:15012",
+		},
+		{
+			name:          "canary",
+			labels:        map[string]string{"istio.io/rev": "canary"},
+			config:        "policy: disabled\ntemplate: foo",
+			wantRevision:  "canary",
+			wantDiscovery: "istiod-canary.istio-control.svc:15012",
+		},
+		{
+			name:    "no revision",
+			config:  "policy: enabled\ntemplate: foo",
+			wantErr: true,
+		},
+		{
+			name:    "invalid config",
+			labels:  map[string]string{"istio.io/rev": "canary"},
+			config:  "policy: [",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "istio-sidecar-injector", Namespace: "istio-control", Labels: c.labels},
+				Data:       map[string]string{"config": c.config, "values": "{}"},
+			}
+			revision, config, err := RevisionConfigFromConfigMap(cm)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if revision != c.wantRevision {
+				t.Errorf("got revision %q, want %q", revision, c.wantRevision)
+			}
+			if config.DiscoveryAddress != c.wantDiscovery {
+				t.Errorf("got discovery address %q, want %q", config.DiscoveryAddress, c.wantDiscovery)
+			}
+			if config.Config.Template != "foo" || config.ValuesConfig != "{}" {
+				t.Errorf("got config %+v, values %q", config.Config, config.ValuesConfig)
+			}
+		})
+	}
+}
+
+func TestWebhookConfigName(t *testing.T) {
+	cases := []struct {
+		revision  string
+		namespace string
+		want      string
+	}{
+		{"", "istio-system", "istio-sidecar-injector"},
+		{"canary", "istio-system", "istio-sidecar-injector-canary"},
+		{"canary", "istio-control", "istio-sidecar-injector-canary-istio-control"},
+	}
+	for _, c := range cases {
+		if got := WebhookConfigName(c.revision, c.namespace); got != c.want {
+			t.Errorf("WebhookConfigName(%q, %q) = %q, want %q", c.revision, c.namespace, got, c.want)
+		}
+	}
+}
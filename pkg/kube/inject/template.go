@@ -0,0 +1,164 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// SidecarTemplateName is the name under which the Template of the Config can be selected.
+const SidecarTemplateName = "sidecar"
+
+// InjectTemplatesAnnotation selects the comma separated list of templates a pod is injected with, which are
+// composed in order. TODO move this to API
+var InjectTemplatesAnnotation = "inject.istio.io/templates"
+
+// NamedTemplates returns the templates of the config by name, including the sidecar template.
+func (c *Config) NamedTemplates() map[string]string {
+	templates := map[string]string{SidecarTemplateName: c.Template}
+	for name, template := range c.Templates {
+		templates[name] = template
+	}
+	return templates
+}
+
+// SelectTemplates returns the templates selected by the annotation of the pod, or else the default templates
+// of the config, in order.
+func (c *Config) SelectTemplates(metadata *metav1.ObjectMeta) ([]string, error) {
+	names := c.DefaultTemplates
+	if value, ok := metadata.GetAnnotations()[InjectTemplatesAnnotation]; ok {
+		names = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		names = []string{SidecarTemplateName}
+	}
+
+	templates := c.NamedTemplates()
+	selected := make([]string, 0, len(names))
+	for _, name := range names {
+		template, ok := templates[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown injection template %q", name)
+		}
+		selected = append(selected, template)
+	}
+	return selected, nil
+}
+
+// sidecarTemplateVersionHash returns the version of the templates of the config. It is the hash of the sidecar
+// template when there are no named templates.
+func sidecarTemplateVersionHash(config *Config) string {
+	in := config.Template
+	names := make([]string, 0, len(config.Templates))
+	for name := range config.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		in += "\n---\n" + name + "\n" + config.Templates[name]
+	}
+	return templateVersionHash(in)
+}
+
+// mergeInjectionSpec composes the spec rendered from a template with the spec rendered from the previous
+// templates. The containers named as a previous one are applied to it as strategic merge patches, so that a
+// template can customize the containers of another one, while the volumes and image pull secrets replace the
+// previous ones of the same name.
+func mergeInjectionSpec(into, spec *SidecarInjectionSpec) error {
+	for k, v := range spec.PodRedirectAnnot {
+		if into.PodRedirectAnnot == nil {
+			into.PodRedirectAnnot = map[string]string{}
+		}
+		into.PodRedirectAnnot[k] = v
+	}
+	into.RewriteAppHTTPProbe = into.RewriteAppHTTPProbe || spec.RewriteAppHTTPProbe
+
+	var err error
+	if into.InitContainers, err = mergeContainers(into.InitContainers, spec.InitContainers); err != nil {
+		return err
+	}
+	if into.Containers, err = mergeContainers(into.Containers, spec.Containers); err != nil {
+		return err
+	}
+
+volumes:
+	for _, volume := range spec.Volumes {
+		for i := range into.Volumes {
+			if into.Volumes[i].Name == volume.Name {
+				into.Volumes[i] = volume
+				continue volumes
+			}
+		}
+		into.Volumes = append(into.Volumes, volume)
+	}
+
+	if spec.DNSConfig != nil {
+		into.DNSConfig = spec.DNSConfig
+	}
+
+secrets:
+	for _, secret := range spec.ImagePullSecrets {
+		for _, s := range into.ImagePullSecrets {
+			if s.Name == secret.Name {
+				continue secrets
+			}
+		}
+		into.ImagePullSecrets = append(into.ImagePullSecrets, secret)
+	}
+	return nil
+}
+
+func mergeContainers(containers, patches []corev1.Container) ([]corev1.Container, error) {
+patches:
+	for _, patch := range patches {
+		for i := range containers {
+			if containers[i].Name != patch.Name {
+				continue
+			}
+			original, err := json.Marshal(containers[i])
+			if err != nil {
+				return nil, err
+			}
+			patchJSON, err := json.Marshal(patch)
+			if err != nil {
+				return nil, err
+			}
+			merged, err := strategicpatch.StrategicMergePatch(original, patchJSON, corev1.Container{})
+			if err != nil {
+				return nil, fmt.Errorf("failed to merge container %q: %v", patch.Name, err)
+			}
+			var container corev1.Container
+			if err := json.Unmarshal(merged, &container); err != nil {
+				return nil, err
+			}
+			containers[i] = container
+			continue patches
+		}
+		containers = append(containers, patch)
+	}
+	return containers, nil
+}
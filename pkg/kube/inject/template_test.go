@@ -0,0 +1,151 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inject
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"istio.io/istio/pkg/config/mesh"
+)
+
+var templatesConfig = &Config{
+	Policy: InjectionPolicyEnabled,
+	Template: `containers:
+- name: istio-proxy
+  image: proxy
+  args: ["proxy", "sidecar"]
+volumes:
+- name: istio-envoy
+  emptyDir: {}`,
+	Templates: map[string]string{
+		"gateway": `containers:
+- name: istio-proxy
+  args: ["proxy", "router"]
+  env:
+  - name: GATEWAY
+    value: "true"`,
+		"job": `containers:
+- name: istio-proxy
+  env:
+  - name: EXIT_ON_ZERO_ACTIVE_CONNECTIONS
+    value: "true"
+volumes:
+- name: istio-envoy
+  emptyDir:
+    medium: Memory
+- name: istio-job
+  emptyDir: {}`,
+	},
+}
+
+func TestSelectTemplates(t *testing.T) {
+	config := *templatesConfig
+	cases := []struct {
+		name             string
+		annotation       string
+		defaultTemplates []string
+		want             []string
+		wantErr          bool
+	}{
+		{
+			name: "default",
+			want: []string{config.Template},
+		},
+		{
+			name:             "default templates",
+			defaultTemplates: []string{"sidecar", "job"},
+			want:             []string{config.Template, config.Templates["job"]},
+		},
+		{
+			name:             "annotation",
+			annotation:       "sidecar, gateway",
+			defaultTemplates: []string{"job"},
+			want:             []string{config.Template, config.Templates["gateway"]},
+		},
+		{
+			name:       "unknown template",
+			annotation: "sidecar,unknown",
+			wantErr:    true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			config.DefaultTemplates = c.defaultTemplates
+			metadata := &metav1.ObjectMeta{}
+			if c.annotation != "" {
+				metadata.Annotations = map[string]string{InjectTemplatesAnnotation: c.annotation}
+			}
+			got, err := config.SelectTemplates(metadata)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("got error %v, want error %v", err, c.wantErr)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("got templates %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestInjectionDataTemplates(t *testing.T) {
+	m := mesh.DefaultMeshConfig()
+	metadata := &metav1.ObjectMeta{
+		Name:        "app",
+		Namespace:   "default",
+		Annotations: map[string]string{InjectTemplatesAnnotation: "sidecar,gateway,job"},
+	}
+	spec, status, err := InjectionData(templatesConfig, "{}", sidecarTemplateVersionHash(templatesConfig),
+		&metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"}, metadata, &corev1.PodSpec{}, metadata, &m)
+	if err != nil {
+		t.Fatalf("injection failed: %v", err)
+	}
+
+	wantContainers := []corev1.Container{{
+		Name:  "istio-proxy",
+		Image: "proxy",
+		Args:  []string{"proxy", "router"},
+		Env: []corev1.EnvVar{
+			{Name: "EXIT_ON_ZERO_ACTIVE_CONNECTIONS", Value: "true"},
+			{Name: "GATEWAY", Value: "true"},
+		},
+	}}
+	if !reflect.DeepEqual(spec.Containers, wantContainers) {
+		t.Errorf("got containers %+v, want %+v", spec.Containers, wantContainers)
+	}
+	wantVolumes := []corev1.Volume{
+		{Name: "istio-envoy", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{Medium: "Memory"}}},
+		{Name: "istio-job", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+	}
+	if !reflect.DeepEqual(spec.Volumes, wantVolumes) {
+		t.Errorf("got volumes %+v, want %+v", spec.Volumes, wantVolumes)
+	}
+	wantStatus := `{"version":"` + sidecarTemplateVersionHash(templatesConfig) +
+		`","initContainers":null,"containers":["istio-proxy"],"volumes":["istio-envoy","istio-job"],"imagePullSecrets":null}`
+	if status != wantStatus {
+		t.Errorf("got status %s, want %s", status, wantStatus)
+	}
+}
+
+func TestSidecarTemplateVersionHash(t *testing.T) {
+	if got, want := sidecarTemplateVersionHash(&Config{Template: "foo"}), templateVersionHash("foo"); got != want {
+		t.Errorf("got version %s of the sidecar template, want %s", got, want)
+	}
+	if sidecarTemplateVersionHash(templatesConfig) == templateVersionHash(templatesConfig.Template) {
+		t.Errorf("the version doesn't depend on the named templates")
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/ghodss/yaml"
+	"github.com/gogo/protobuf/proto"
 	"github.com/howeyc/fsnotify"
 
 	"istio.io/api/annotation"
@@ -83,6 +84,9 @@ type Webhook struct {
 	mon        *monitor
 	env        *model.Environment
 	revision   string
+	// revisions holds the injection configuration of the other revisions of the control plane, the pods
+	// labeled with one of them being injected with its configuration.
+	revisions map[string]*RevisionConfig
 }
 
 // env will be used for other things besides meshConfig - when webhook is running in Istiod it can take advantage
@@ -196,7 +200,7 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 
 	wh := &Webhook{
 		Config:                 sidecarConfig,
-		sidecarTemplateVersion: sidecarTemplateVersionHash(sidecarConfig.Template),
+		sidecarTemplateVersion: sidecarTemplateVersionHash(sidecarConfig),
 		meshConfig:             meshConfig,
 		configFile:             p.ConfigFile,
 		valuesFile:             p.ValuesFile,
@@ -210,6 +214,7 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 		cert:                   &pair,
 		env:                    p.Env,
 		revision:               p.Revision,
+		revisions:              map[string]*RevisionConfig{},
 	}
 
 	var mux *http.ServeMux
@@ -246,6 +251,71 @@ func NewWebhook(p WebhookParameters) (*Webhook, error) {
 	return wh, nil
 }
 
+// Templates returns the injection templates of the webhook by name.
+func (wh *Webhook) Templates() map[string]string {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	return wh.Config.NamedTemplates()
+}
+
+// UpdateRevision adds or updates the injection configuration of another revision of the control plane.
+func (wh *Webhook) UpdateRevision(revision string, config *RevisionConfig) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	wh.revisions[revision] = config
+}
+
+// DeleteRevision removes the injection configuration of another revision of the control plane.
+func (wh *Webhook) DeleteRevision(revision string) {
+	wh.mu.Lock()
+	defer wh.mu.Unlock()
+	delete(wh.revisions, revision)
+}
+
+// injectionConfig is the configuration a pod is injected with.
+type injectionConfig struct {
+	revision        string
+	config          *Config
+	valuesConfig    string
+	templateVersion string
+	meshConfig      *meshconfig.MeshConfig
+}
+
+// injectionConfig returns the configuration of the revision of the pod label, if known, or of the revision
+// of the webhook.
+func (wh *Webhook) injectionConfig(pod *corev1.Pod) injectionConfig {
+	wh.mu.RLock()
+	defer wh.mu.RUnlock()
+	ic := injectionConfig{
+		revision:        wh.revision,
+		config:          wh.Config,
+		valuesConfig:    wh.valuesConfig,
+		templateVersion: wh.sidecarTemplateVersion,
+		meshConfig:      wh.meshConfig,
+	}
+	label, ok := pod.Labels[model.RevisionLabel]
+	if !ok {
+		return ic
+	}
+	revision := RevisionFromLabel(label)
+	rc, ok := wh.revisions[revision]
+	if revision == wh.revision || !ok {
+		return ic
+	}
+	meshConfig := proto.Clone(wh.meshConfig).(*meshconfig.MeshConfig)
+	if meshConfig.DefaultConfig == nil {
+		meshConfig.DefaultConfig = &meshconfig.ProxyConfig{}
+	}
+	meshConfig.DefaultConfig.DiscoveryAddress = rc.DiscoveryAddress
+	return injectionConfig{
+		revision:        revision,
+		config:          rc.Config,
+		valuesConfig:    rc.ValuesConfig,
+		templateVersion: sidecarTemplateVersionHash(rc.Config),
+		meshConfig:      meshConfig,
+	}
+}
+
 // Run implements the webhook server
 func (wh *Webhook) Run(stop <-chan struct{}) {
 	if wh.server != nil {
@@ -280,7 +350,7 @@ func (wh *Webhook) Run(stop <-chan struct{}) {
 				break
 			}
 
-			version := sidecarTemplateVersionHash(sidecarConfig.Template)
+			version := sidecarTemplateVersionHash(sidecarConfig)
 			pair, err := tls.LoadX509KeyPair(wh.certFile, wh.keyFile)
 			if err != nil {
 				log.Errorf("reload cert error: %v", err)
@@ -741,7 +811,8 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 	log.Debugf("Object: %v", string(req.Object.Raw))
 	log.Debugf("OldObject: %v", string(req.OldObject.Raw))
 
-	if !injectRequired(ignoredNamespaces, wh.Config, &pod.Spec, &pod.ObjectMeta) {
+	ic := wh.injectionConfig(&pod)
+	if !injectRequired(ignoredNamespaces, ic.config, &pod.Spec, &pod.ObjectMeta) {
 		log.Infof("Skipping %s/%s due to policy check", pod.ObjectMeta.Namespace, podName)
 		totalSkippedInjections.Increment()
 		return &v1beta1.AdmissionResponse{
@@ -752,7 +823,7 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 	// due to bug https://github.com/kubernetes/kubernetes/issues/57923,
 	// k8s sa jwt token volume mount file is only accessible to root user, not istio-proxy(the user that istio proxy runs as).
 	// workaround by https://kubernetes.io/docs/tasks/configure-pod-container/security-context/#set-the-security-context-for-a-pod
-	if wh.meshConfig.SdsUdsPath != "" {
+	if ic.meshConfig.SdsUdsPath != "" {
 		var grp = int64(1337)
 		if pod.Spec.SecurityContext == nil {
 			pod.Spec.SecurityContext = &corev1.PodSecurityContext{
@@ -804,7 +875,7 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 		deployMeta.Name = pod.Name
 	}
 
-	spec, iStatus, err := InjectionData(wh.Config.Template, wh.valuesConfig, wh.sidecarTemplateVersion, typeMetadata, deployMeta, &pod.Spec, &pod.ObjectMeta, wh.meshConfig) // nolint: lll
+	spec, iStatus, err := InjectionData(ic.config, ic.valuesConfig, ic.templateVersion, typeMetadata, deployMeta, &pod.Spec, &pod.ObjectMeta, ic.meshConfig) // nolint: lll
 	if err != nil {
 		handleError(fmt.Sprintf("Injection data: err=%v spec=%v\n", err, iStatus))
 		return toAdmissionResponse(err)
@@ -813,11 +884,11 @@ func (wh *Webhook) inject(ar *v1beta1.AdmissionReview) *v1beta1.AdmissionRespons
 	annotations := map[string]string{annotation.SidecarStatus.Name: iStatus}
 
 	// Add all additional injected annotations
-	for k, v := range wh.Config.InjectedAnnotations {
+	for k, v := range ic.config.InjectedAnnotations {
 		annotations[k] = v
 	}
 
-	patchBytes, err := createPatch(&pod, injectionStatus(&pod), wh.revision, annotations, spec, deployMeta.Name, wh.meshConfig)
+	patchBytes, err := createPatch(&pod, injectionStatus(&pod), ic.revision, annotations, spec, deployMeta.Name, ic.meshConfig)
 	if err != nil {
 		handleError(fmt.Sprintf("AdmissionResponse: err=%v spec=%v\n", err, spec))
 		return toAdmissionResponse(err)
@@ -48,6 +48,7 @@ import (
 	"istio.io/istio/operator/pkg/tpath"
 	util2 "istio.io/istio/operator/pkg/util"
 	"istio.io/istio/operator/pkg/util/clog"
+	"istio.io/istio/pilot/pkg/model"
 	"istio.io/istio/pilot/test/util"
 	"istio.io/istio/pkg/config/mesh"
 	"istio.io/istio/pkg/mcp/testing/testcerts"
@@ -601,6 +602,11 @@ func TestWebhookInject(t *testing.T) {
 			wantFile:     "TestWebhookInject_http_probe_rewrite.patch",
 			templateFile: "TestWebhookInject_http_probe_rewrite_template.yaml",
 		},
+		{
+			inputFile:    "TestWebhookInject_tcp_grpc_probe_rewrite.yaml",
+			wantFile:     "TestWebhookInject_tcp_grpc_probe_rewrite.patch",
+			templateFile: "TestWebhookInject_http_probe_rewrite_template.yaml",
+		},
 		{
 			inputFile:    "TestWebhookInject_http_probe_nosidecar_rewrite.yaml",
 			wantFile:     "TestWebhookInject_http_probe_nosidecar_rewrite.patch",
@@ -675,6 +681,78 @@ func TestWebhookInject(t *testing.T) {
 	}
 }
 
+func TestWebhookInjectRevision(t *testing.T) {
+	template := func(image string) *Config {
+		return &Config{
+			Policy: InjectionPolicyEnabled,
+			Template: `containers:
+- name: istio-proxy
+  image: ` + image + `
+  args: ["{{ .ProxyConfig.DiscoveryAddress }}"]`,
+		}
+	}
+	wh, cleanup := createTestWebhook(t, template("example.com/proxy:latest"), "{}")
+	defer cleanup()
+	wh.UpdateRevision("canary", &RevisionConfig{
+		Config:           template("example.com/proxy:canary"),
+		ValuesConfig:     "{}",
+		DiscoveryAddress: DiscoveryAddress("canary", "istio-system"),
+	})
+
+	cases := []struct {
+		name     string
+		labels   map[string]string
+		want     []string
+		wantNone []string
+	}{
+		{
+			name:     "no revision",
+			want:     []string{"example.com/proxy:latest"},
+			wantNone: []string{"canary"},
+		},
+		{
+			name:   "revision",
+			labels: map[string]string{model.RevisionLabel: "canary"},
+			want: []string{
+				"example.com/proxy:canary",
+				"istiod-canary.istio-system.svc:15012",
+			},
+		},
+		{
+			name:     "unknown revision",
+			labels:   map[string]string{model.RevisionLabel: "unknown"},
+			want:     []string{"example.com/proxy:latest"},
+			wantNone: []string{"canary"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default", Labels: c.labels},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app"}}},
+			}
+			got := wh.inject(&v1beta1.AdmissionReview{
+				Request: &v1beta1.AdmissionRequest{
+					Object: runtime.RawExtension{Raw: convertToJSON(pod, t)},
+				},
+			})
+			if got.Result != nil {
+				t.Fatalf("injection failed: %v", got.Result.Message)
+			}
+			for _, want := range c.want {
+				if !strings.Contains(string(got.Patch), want) {
+					t.Errorf("got patch %s, want it to contain %q", got.Patch, want)
+				}
+			}
+			for _, want := range c.wantNone {
+				if strings.Contains(string(got.Patch), want) {
+					t.Errorf("got patch %s, want it not to contain %q", got.Patch, want)
+				}
+			}
+		})
+	}
+}
+
 // TestHelmInject tests the webhook injector with the installation configmap.yaml. It runs through many of the
 // same tests as TestIntoResourceFile in order to verify that the webhook performs the same way as the manual injector.
 func TestHelmInject(t *testing.T) {
@@ -860,6 +938,7 @@ func createTestWebhook(t testing.TB, config *Config, values string) (*Webhook, f
 		sidecarTemplateVersion: "unit-test-fake-version",
 		meshConfig:             &m,
 		valuesConfig:           values,
+		revisions:              map[string]*RevisionConfig{},
 	}, cleanup
 }
 
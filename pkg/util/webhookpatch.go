@@ -20,6 +20,7 @@ import (
 	"fmt"
 
 	"k8s.io/api/admissionregistration/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -64,3 +65,42 @@ func PatchMutatingWebhookConfig(client admissionregistrationv1beta1client.Mutati
 	}
 	return err
 }
+
+// ReconcileMutatingWebhookConfig updates the existing webhook config to hold the desired webhooks. The client
+// config of the existing desired webhooks is replaced, while their rules and selectors are kept so that they can be
+// customized. The missing desired webhooks are added, and the other webhooks are kept. The config is not created
+// if missing.
+func ReconcileMutatingWebhookConfig(client admissionregistrationv1beta1client.MutatingWebhookConfigurationInterface,
+	desired *v1beta1.MutatingWebhookConfiguration) error {
+	existing, err := client.Get(context.TODO(), desired.Name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+
+	updated := existing.DeepCopy()
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	for k, v := range desired.Labels {
+		updated.Labels[k] = v
+	}
+	for _, webhook := range desired.Webhooks {
+		found := false
+		for i, w := range updated.Webhooks {
+			if w.Name == webhook.Name {
+				updated.Webhooks[i].ClientConfig = webhook.ClientConfig
+				found = true
+				break
+			}
+		}
+		if !found {
+			updated.Webhooks = append(updated.Webhooks, webhook)
+		}
+	}
+
+	if apiequality.Semantic.DeepEqual(existing, updated) {
+		return nil
+	}
+	_, err = client.Update(context.TODO(), updated, metav1.UpdateOptions{})
+	return err
+}
@@ -16,11 +16,13 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"strings"
 	"testing"
 
 	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
@@ -108,3 +110,112 @@ func TestMutatingWebhookPatch(t *testing.T) {
 		})
 	}
 }
+
+func TestReconcileMutatingWebhookConfig(t *testing.T) {
+	failurePolicy := admissionregistrationv1beta1.Ignore
+	desired := &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "config1",
+			Labels: map[string]string{"istio.io/rev": "canary"},
+		},
+		Webhooks: []admissionregistrationv1beta1.MutatingWebhook{
+			{
+				Name:         "webhook1",
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{CABundle: []byte("fake CA")},
+			},
+			{
+				Name:         "webhook2",
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{CABundle: []byte("fake CA")},
+			},
+		},
+	}
+	ts := []struct {
+		name         string
+		configs      admissionregistrationv1beta1.MutatingWebhookConfigurationList
+		wantActions  []string
+		wantWebhooks []string
+	}{
+		{
+			"NotFound",
+			admissionregistrationv1beta1.MutatingWebhookConfigurationList{},
+			[]string{"get"},
+			nil,
+		},
+		{
+			"Updated",
+			admissionregistrationv1beta1.MutatingWebhookConfigurationList{
+				Items: []admissionregistrationv1beta1.MutatingWebhookConfiguration{
+					{
+						ObjectMeta: metav1.ObjectMeta{
+							Name: "config1",
+						},
+						Webhooks: []admissionregistrationv1beta1.MutatingWebhook{
+							{
+								Name:          "webhook1",
+								FailurePolicy: &failurePolicy,
+							},
+							{
+								Name: "custom",
+							},
+						},
+					},
+				},
+			},
+			[]string{"get", "update"},
+			[]string{"webhook1", "custom", "webhook2"},
+		},
+		{
+			"UpToDate",
+			admissionregistrationv1beta1.MutatingWebhookConfigurationList{
+				Items: []admissionregistrationv1beta1.MutatingWebhookConfiguration{*desired},
+			},
+			[]string{"get"},
+			[]string{"webhook1", "webhook2"},
+		},
+	}
+	for _, tc := range ts {
+		t.Run(tc.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(tc.configs.DeepCopyObject())
+			client.ClearActions()
+			webhookConfigs := client.AdmissionregistrationV1beta1().MutatingWebhookConfigurations()
+			err := ReconcileMutatingWebhookConfig(webhookConfigs, desired.DeepCopy())
+			var actions []string
+			for _, action := range client.Actions() {
+				actions = append(actions, action.GetVerb())
+			}
+			if strings.Join(actions, ",") != strings.Join(tc.wantActions, ",") {
+				t.Fatalf("Got actions %v, want %v", actions, tc.wantActions)
+			}
+			if tc.wantWebhooks == nil {
+				if !apierrors.IsNotFound(err) {
+					t.Fatalf("Got error %v, want not found", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			config, err := webhookConfigs.Get(context.TODO(), "config1", metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.Labels["istio.io/rev"] != "canary" {
+				t.Fatalf("Incorrect labels: %v", config.Labels)
+			}
+			var names []string
+			for _, w := range config.Webhooks {
+				names = append(names, w.Name)
+				if w.Name != "custom" && !bytes.Equal(w.ClientConfig.CABundle, []byte("fake CA")) {
+					t.Fatalf("Incorrect webhook: %v", w)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tc.wantWebhooks, ",") {
+				t.Fatalf("Got webhooks %v, want %v", names, tc.wantWebhooks)
+			}
+			if tc.name == "Updated" && config.Webhooks[0].FailurePolicy == nil {
+				t.Fatalf("The failure policy of the existing webhook is not kept")
+			}
+		})
+	}
+}
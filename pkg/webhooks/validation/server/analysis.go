@@ -0,0 +1,208 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/go-multierror"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/galley/pkg/config/source/kube/rt"
+	"istio.io/istio/pilot/pkg/model"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+)
+
+// Snapshot is a set of resources the incoming configuration is analyzed with.
+type Snapshot interface {
+	// Find the resource with the given name and collection. If not found, nil is returned.
+	Find(c collection.Name, name resource.FullName) *resource.Instance
+
+	// ForEach iterates over all the resources of the collection.
+	ForEach(c collection.Name, fn analysis.IteratorFn)
+}
+
+// AnalysisOptions configure the cross-resource validation, which runs analyzers on the incoming
+// configuration and the other resources of the cluster. Only the messages reported on the incoming
+// resource are considered, so that the existing problems of the cluster do not prevent changes.
+type AnalysisOptions struct {
+	// Analyzers are run on the incoming resources of their input collections.
+	Analyzers []analysis.Analyzer
+
+	// Snapshot returns the resources of the cluster, or nil while they are not known. The analysis
+	// is skipped in that case.
+	Snapshot func() Snapshot
+
+	// BlockingAnalyzers are the names of the analyzers whose messages of BlockingLevel or worse
+	// reject the incoming resources. The other messages are returned to the clients as warnings.
+	BlockingAnalyzers []string
+
+	// BlockingLevel is the minimum level of the messages rejecting the incoming resources. Warnings
+	// and errors reject them if unset.
+	BlockingLevel diag.Level
+}
+
+// String produces a stringified version of the options for debugging.
+func (o *AnalysisOptions) String() string {
+	names := make([]string, 0, len(o.Analyzers))
+	for _, a := range o.Analyzers {
+		names = append(names, a.Metadata().Name)
+	}
+	return fmt.Sprintf("analyzers=%v, blocking=%v, blockingLevel=%v", names, o.BlockingAnalyzers, o.blockingLevel())
+}
+
+func (o *AnalysisOptions) blockingLevel() diag.Level {
+	if o.BlockingLevel == (diag.Level{}) {
+		return diag.Warning
+	}
+	return o.BlockingLevel
+}
+
+func (o *AnalysisOptions) isBlocking(analyzer string) bool {
+	for _, name := range o.BlockingAnalyzers {
+		if name == analyzer {
+			return true
+		}
+	}
+	return false
+}
+
+// analyze runs the analyzers on the incoming configuration. The messages are returned as warnings,
+// except the ones of the blocking analyzers at the blocking level or worse, which are returned as an
+// error.
+func (o *AnalysisOptions) analyze(s collection.Schema, cfg *model.Config) ([]string, error) {
+	snapshot := o.Snapshot()
+	if snapshot == nil {
+		scope.Infof("skipping the analysis of %s %s/%s: the cluster resources are not synced yet",
+			cfg.Type, cfg.Namespace, cfg.Name)
+		return nil, nil
+	}
+
+	fullName := resource.NewFullName(resource.Namespace(cfg.Namespace), resource.LocalName(cfg.Name))
+	ctx := &admissionContext{
+		snapshot:   snapshot,
+		collection: s.Name(),
+		resource: &resource.Instance{
+			Metadata: resource.Metadata{
+				Schema:      s.Resource(),
+				FullName:    fullName,
+				CreateTime:  cfg.CreationTimestamp,
+				Version:     resource.Version(cfg.ResourceVersion),
+				Labels:      cfg.Labels,
+				Annotations: cfg.Annotations,
+			},
+			Message: cfg.Spec,
+			Origin: &rt.Origin{
+				Collection: s.Name(),
+				Kind:       s.Resource().Kind(),
+				FullName:   fullName,
+				Version:    resource.Version(cfg.ResourceVersion),
+			},
+		},
+	}
+
+	var warnings []string
+	var errs *multierror.Error
+	level := o.blockingLevel()
+	for _, a := range o.Analyzers {
+		if !isInput(a, s.Name()) {
+			continue
+		}
+
+		ctx.messages = nil
+		a.Analyze(ctx)
+		blocking := o.isBlocking(a.Metadata().Name)
+		for _, m := range ctx.messages.SortedDedupedCopy() {
+			if blocking && m.Type.Level().IsWorseThanOrEqualTo(level) {
+				errs = multierror.Append(errs, errors.New(m.String()))
+			} else {
+				warnings = append(warnings, m.String())
+			}
+		}
+	}
+	return warnings, errs.ErrorOrNil()
+}
+
+func isInput(a analysis.Analyzer, c collection.Name) bool {
+	for _, input := range a.Metadata().Inputs {
+		if input == c {
+			return true
+		}
+	}
+	return false
+}
+
+// admissionContext is the analysis context of an incoming resource. The resource replaces the one
+// with the same name in the snapshot, if any.
+type admissionContext struct {
+	snapshot   Snapshot
+	collection collection.Name
+	resource   *resource.Instance
+	messages   diag.Messages
+}
+
+var _ analysis.Context = &admissionContext{}
+
+// Report implements analysis.Context
+func (c *admissionContext) Report(col collection.Name, m diag.Message) {
+	if c.isIncoming(col, m.Resource) {
+		c.messages = append(c.messages, m)
+	}
+}
+
+// Find implements analysis.Context
+func (c *admissionContext) Find(col collection.Name, name resource.FullName) *resource.Instance {
+	if col == c.collection && name == c.resource.Metadata.FullName {
+		return c.resource
+	}
+	return c.snapshot.Find(col, name)
+}
+
+// Exists implements analysis.Context
+func (c *admissionContext) Exists(col collection.Name, name resource.FullName) bool {
+	return c.Find(col, name) != nil
+}
+
+// ForEach implements analysis.Context
+func (c *admissionContext) ForEach(col collection.Name, fn analysis.IteratorFn) {
+	if col != c.collection {
+		c.snapshot.ForEach(col, fn)
+		return
+	}
+
+	done := false
+	c.snapshot.ForEach(col, func(r *resource.Instance) bool {
+		if c.isIncoming(col, r) {
+			return true
+		}
+		done = !fn(r)
+		return !done
+	})
+	if !done {
+		fn(c.resource)
+	}
+}
+
+// Canceled implements analysis.Context
+func (c *admissionContext) Canceled() bool {
+	return false
+}
+
+func (c *admissionContext) isIncoming(col collection.Name, r *resource.Instance) bool {
+	return col == c.collection && r != nil && r.Metadata.FullName == c.resource.Metadata.FullName
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/onsi/gomega"
+	kubeApiAdmission "k8s.io/api/admission/v1beta1"
+	kubeApisMeta "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/analysis/diag"
+	"istio.io/istio/pkg/config/resource"
+	"istio.io/istio/pkg/config/schema/collection"
+	"istio.io/istio/pkg/config/schema/collections"
+	"istio.io/istio/pkg/test/config"
+)
+
+// duplicateKeyAnalyzer reports the mock configs using the key of another one, at the given level
+type duplicateKeyAnalyzer struct {
+	level diag.Level
+}
+
+func (a *duplicateKeyAnalyzer) Metadata() analysis.Metadata {
+	return analysis.Metadata{
+		Name:   "test.DuplicateKeyAnalyzer",
+		Inputs: collection.Names{collections.Mock.Name()},
+	}
+}
+
+func (a *duplicateKeyAnalyzer) Analyze(ctx analysis.Context) {
+	duplicateKey := diag.NewMessageType(a.level, "T0001", "The key %q is also used by %s")
+	owners := map[string]*resource.Instance{}
+	ctx.ForEach(collections.Mock.Name(), func(r *resource.Instance) bool {
+		key := r.Message.(*config.MockConfig).Key
+		if owner, ok := owners[key]; ok {
+			ctx.Report(collections.Mock.Name(), diag.NewMessage(duplicateKey, r, key, owner.Metadata.FullName))
+			ctx.Report(collections.Mock.Name(), diag.NewMessage(duplicateKey, owner, key, r.Metadata.FullName))
+		} else {
+			owners[key] = r
+		}
+		return true
+	})
+}
+
+type fakeSnapshot map[resource.FullName]*resource.Instance
+
+func (s fakeSnapshot) Find(c collection.Name, name resource.FullName) *resource.Instance {
+	if c != collections.Mock.Name() {
+		return nil
+	}
+	return s[name]
+}
+
+func (s fakeSnapshot) ForEach(c collection.Name, fn analysis.IteratorFn) {
+	if c != collections.Mock.Name() {
+		return
+	}
+	for _, r := range s {
+		if !fn(r) {
+			return
+		}
+	}
+}
+
+func mockResource(name, key string) *resource.Instance {
+	return &resource.Instance{
+		Metadata: resource.Metadata{FullName: resource.NewFullName("default", resource.LocalName(name))},
+		Message:  &config.MockConfig{Key: key},
+	}
+}
+
+func createAnalysisWebhook(t *testing.T, snapshot Snapshot, level, blockingLevel diag.Level, blocking ...string) *Webhook {
+	t.Helper()
+	wh, err := New(Options{
+		Schemas:        collections.Mocks,
+		MixerValidator: &fakeValidator{},
+		Mux:            http.NewServeMux(),
+		Analysis: &AnalysisOptions{
+			Analyzers:         []analysis.Analyzer{&duplicateKeyAnalyzer{level: level}},
+			Snapshot:          func() Snapshot { return snapshot },
+			BlockingAnalyzers: blocking,
+			BlockingLevel:     blockingLevel,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() failed: %v", err)
+	}
+	return wh
+}
+
+func TestAdmitPilotAnalysis(t *testing.T) {
+	request := &kubeApiAdmission.AdmissionRequest{
+		Kind:      kubeApisMeta.GroupVersionKind{Kind: collections.Mock.Resource().Kind()},
+		Namespace: "default",
+		Object:    runtime.RawExtension{Raw: makePilotConfig(t, 0, true, false)},
+		Operation: kubeApiAdmission.Create,
+	}
+	conflicting := fakeSnapshot{
+		// The previous version of the incoming resource is replaced
+		resource.NewFullName("default", "mock-config0"): mockResource("mock-config0", "key"),
+		resource.NewFullName("default", "other"):        mockResource("other", "key"),
+	}
+	unrelated := fakeSnapshot{
+		resource.NewFullName("default", "mock-config0"): mockResource("mock-config0", "key"),
+		resource.NewFullName("default", "first"):        mockResource("first", "other-key"),
+		resource.NewFullName("default", "second"):       mockResource("second", "other-key"),
+	}
+
+	cases := []struct {
+		name          string
+		snapshot      Snapshot
+		level         diag.Level
+		blocking      []string
+		blockingLevel diag.Level
+		wantAllowed   bool
+		wantWarnings  int
+	}{
+		{
+			name:        "not synced",
+			snapshot:    nil,
+			blocking:    []string{"test.DuplicateKeyAnalyzer"},
+			wantAllowed: true,
+		},
+		{
+			name:         "warning",
+			snapshot:     conflicting,
+			wantAllowed:  true,
+			wantWarnings: 1,
+		},
+		{
+			name:        "blocking",
+			snapshot:    conflicting,
+			blocking:    []string{"test.DuplicateKeyAnalyzer"},
+			wantAllowed: false,
+		},
+		{
+			name:        "blocking warning",
+			snapshot:    conflicting,
+			level:       diag.Warning,
+			blocking:    []string{"test.DuplicateKeyAnalyzer"},
+			wantAllowed: false,
+		},
+		{
+			name:          "warning below the blocking level",
+			snapshot:      conflicting,
+			level:         diag.Warning,
+			blocking:      []string{"test.DuplicateKeyAnalyzer"},
+			blockingLevel: diag.Error,
+			wantAllowed:   true,
+			wantWarnings:  1,
+		},
+		{
+			name:        "messages on other resources",
+			snapshot:    unrelated,
+			blocking:    []string{"test.DuplicateKeyAnalyzer"},
+			wantAllowed: true,
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(fmt.Sprintf("[%d] %s", i, c.name), func(t *testing.T) {
+			g := gomega.NewGomegaWithT(t)
+
+			if c.level == (diag.Level{}) {
+				c.level = diag.Error
+			}
+			got := createAnalysisWebhook(t, c.snapshot, c.level, c.blockingLevel, c.blocking...).admitPilot(request)
+			g.Expect(got.Allowed).To(gomega.Equal(c.wantAllowed))
+			g.Expect(got.Warnings).To(gomega.HaveLen(c.wantWarnings))
+			if !c.wantAllowed {
+				g.Expect(got.Result.Message).To(gomega.ContainSubstring(fmt.Sprintf(
+					`%v [T0001] (MockConfig mock-config0.default) The key "key" is also used by default/other`, c.level)))
+			}
+		})
+	}
+}
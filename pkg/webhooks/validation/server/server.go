@@ -97,6 +97,9 @@ type Options struct {
 
 	// Use an existing mux instead of creating our own.
 	Mux *http.ServeMux
+
+	// Analysis enables the cross-resource validation of the configuration. It is disabled when nil.
+	Analysis *AnalysisOptions
 }
 
 // String produces a stringified version of the arguments for debugging.
@@ -107,6 +110,9 @@ func (o Options) String() string {
 	_, _ = fmt.Fprintf(buf, "Port: %d\n", o.Port)
 	_, _ = fmt.Fprintf(buf, "CertFile: %s\n", o.CertFile)
 	_, _ = fmt.Fprintf(buf, "KeyFile: %s\n", o.KeyFile)
+	if o.Analysis != nil {
+		_, _ = fmt.Fprintf(buf, "Analysis: %s\n", o.Analysis)
+	}
 
 	return buf.String()
 }
@@ -134,6 +140,9 @@ type Webhook struct {
 	// mixer
 	validator store.BackendValidator
 
+	// cross-resource validation
+	analysis *AnalysisOptions
+
 	server   *http.Server
 	keyFile  string
 	certFile string
@@ -186,6 +195,7 @@ func New(p Options) (*Webhook, error) {
 		wh := &Webhook{
 			schemas:   p.Schemas,
 			validator: p.MixerValidator,
+			analysis:  p.Analysis,
 		}
 
 		p.Mux.HandleFunc("/validate", wh.serveValidate)
@@ -220,6 +230,7 @@ func New(p Options) (*Webhook, error) {
 		cert:           pair,
 		schemas:        p.Schemas,
 		validator:      p.MixerValidator,
+		analysis:       p.Analysis,
 	}
 
 	// mtls disabled because apiserver webhook cert usage is still TBD.
@@ -294,11 +305,32 @@ func (wh *Webhook) getCert(*tls.ClientHelloInfo) (*tls.Certificate, error) {
 	return wh.cert, nil
 }
 
-func toAdmissionResponse(err error) *kubeApiAdmission.AdmissionResponse {
-	return &kubeApiAdmission.AdmissionResponse{Result: &kubeApisMeta.Status{Message: err.Error()}}
+// admissionResponse adds the warnings returned to the clients by the Kubernetes 1.19 API servers
+// and later to the v1beta1 AdmissionResponse. Older API servers ignore them.
+type admissionResponse struct {
+	*kubeApiAdmission.AdmissionResponse
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// admissionReview is the AdmissionReview returned by the webhook
+type admissionReview struct {
+	Response *admissionResponse `json:"response,omitempty"`
 }
 
-type admitFunc func(*kubeApiAdmission.AdmissionRequest) *kubeApiAdmission.AdmissionResponse
+func toAdmissionResponse(err error) *admissionResponse {
+	return &admissionResponse{
+		AdmissionResponse: &kubeApiAdmission.AdmissionResponse{Result: &kubeApisMeta.Status{Message: err.Error()}},
+	}
+}
+
+func allowed(warnings ...string) *admissionResponse {
+	return &admissionResponse{
+		AdmissionResponse: &kubeApiAdmission.AdmissionResponse{Allowed: true},
+		Warnings:          warnings,
+	}
+}
+
+type admitFunc func(*kubeApiAdmission.AdmissionRequest) *admissionResponse
 
 func serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
 	var body []byte
@@ -321,7 +353,7 @@ func serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
 		return
 	}
 
-	var reviewResponse *kubeApiAdmission.AdmissionResponse
+	var reviewResponse *admissionResponse
 	ar := kubeApiAdmission.AdmissionReview{}
 	if _, _, err := deserializer.Decode(body, nil, &ar); err != nil {
 		reviewResponse = toAdmissionResponse(fmt.Errorf("could not decode body: %v", err))
@@ -329,7 +361,7 @@ func serve(w http.ResponseWriter, r *http.Request, admit admitFunc) {
 		reviewResponse = admit(ar.Request)
 	}
 
-	response := kubeApiAdmission.AdmissionReview{}
+	response := admissionReview{}
 	if reviewResponse != nil {
 		response.Response = reviewResponse
 		if ar.Request != nil {
@@ -365,7 +397,7 @@ func (wh *Webhook) serveValidate(w http.ResponseWriter, r *http.Request) {
 	serve(w, r, wh.validate)
 }
 
-func (wh *Webhook) validate(request *kubeApiAdmission.AdmissionRequest) *kubeApiAdmission.AdmissionResponse {
+func (wh *Webhook) validate(request *kubeApiAdmission.AdmissionRequest) *admissionResponse {
 	switch request.Kind.Kind {
 	case collections.IstioPolicyV1Beta1Rules.Resource().Kind(),
 		collections.IstioPolicyV1Beta1Attributemanifests.Resource().Kind(),
@@ -379,13 +411,13 @@ func (wh *Webhook) validate(request *kubeApiAdmission.AdmissionRequest) *kubeApi
 	}
 }
 
-func (wh *Webhook) admitPilot(request *kubeApiAdmission.AdmissionRequest) *kubeApiAdmission.AdmissionResponse {
+func (wh *Webhook) admitPilot(request *kubeApiAdmission.AdmissionRequest) *admissionResponse {
 	switch request.Operation {
 	case kubeApiAdmission.Create, kubeApiAdmission.Update:
 	default:
 		scope.Warnf("Unsupported webhook operation %v", request.Operation)
 		reportValidationFailed(request, reasonUnsupportedOperation)
-		return &kubeApiAdmission.AdmissionResponse{Allowed: true}
+		return allowed()
 	}
 
 	var obj crd.IstioKind
@@ -427,11 +459,26 @@ func (wh *Webhook) admitPilot(request *kubeApiAdmission.AdmissionRequest) *kubeA
 		return toAdmissionResponse(err)
 	}
 
+	var warnings []string
+	if wh.analysis != nil {
+		if out.Namespace == "" {
+			out.Namespace = request.Namespace
+		}
+		var err error
+		if warnings, err = wh.analysis.analyze(s, out); err != nil {
+			scope.Infof("configuration is rejected by the analysis: %v", err)
+			reportValidationFailed(request, reasonAnalysisError)
+			response := toAdmissionResponse(err)
+			response.Warnings = warnings
+			return response
+		}
+	}
+
 	reportValidationPass(request)
-	return &kubeApiAdmission.AdmissionResponse{Allowed: true}
+	return allowed(warnings...)
 }
 
-func (wh *Webhook) admitMixer(request *kubeApiAdmission.AdmissionRequest) *kubeApiAdmission.AdmissionResponse {
+func (wh *Webhook) admitMixer(request *kubeApiAdmission.AdmissionRequest) *admissionResponse {
 	ev := &store.BackendEvent{
 		Key: store.Key{
 			Namespace: request.Namespace,
@@ -474,7 +521,7 @@ func (wh *Webhook) admitMixer(request *kubeApiAdmission.AdmissionRequest) *kubeA
 	default:
 		scope.Warnf("Unsupported webhook operation %v", request.Operation)
 		reportValidationFailed(request, reasonUnsupportedOperation)
-		return &kubeApiAdmission.AdmissionResponse{Allowed: true}
+		return allowed()
 	}
 
 	// webhook skips deletions
@@ -486,7 +533,7 @@ func (wh *Webhook) admitMixer(request *kubeApiAdmission.AdmissionRequest) *kubeA
 	}
 
 	reportValidationPass(request)
-	return &kubeApiAdmission.AdmissionResponse{Allowed: true}
+	return allowed()
 }
 
 func checkFields(raw []byte, kind string, namespace string, name string) (string, error) {
@@ -509,8 +509,8 @@ func TestServe(t *testing.T) {
 			req.Header.Add("Content-Type", c.contentType)
 			w := httptest.NewRecorder()
 
-			serve(w, req, func(*kubeApiAdmission.AdmissionRequest) *kubeApiAdmission.AdmissionResponse {
-				return &kubeApiAdmission.AdmissionResponse{Allowed: c.allowedResponse}
+			serve(w, req, func(*kubeApiAdmission.AdmissionRequest) *admissionResponse {
+				return &admissionResponse{AdmissionResponse: &kubeApiAdmission.AdmissionResponse{Allowed: c.allowedResponse}}
 			})
 
 			res := w.Result()
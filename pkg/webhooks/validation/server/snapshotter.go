@@ -0,0 +1,110 @@
+// Copyright 2020 Istio Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"sync"
+
+	"istio.io/istio/galley/pkg/config/analysis"
+	"istio.io/istio/galley/pkg/config/meshcfg"
+	"istio.io/istio/galley/pkg/config/processing"
+	"istio.io/istio/galley/pkg/config/processing/snapshotter"
+	"istio.io/istio/galley/pkg/config/processor"
+	"istio.io/istio/galley/pkg/config/processor/transforms"
+	"istio.io/istio/galley/pkg/config/source/kube"
+	"istio.io/istio/galley/pkg/config/source/kube/apiserver"
+	"istio.io/istio/galley/pkg/config/util/kuberesource"
+	"istio.io/istio/pkg/config/event"
+	"istio.io/istio/pkg/config/schema"
+	"istio.io/istio/pkg/config/schema/snapshots"
+)
+
+// KubeSnapshotter maintains the snapshot of the cluster resources used by the cross-resource
+// validation, with a Galley processing pipeline watching the resources the analyzers need.
+type KubeSnapshotter struct {
+	runtime *processing.Runtime
+
+	mu       sync.RWMutex
+	snapshot *snapshotter.Snapshot
+}
+
+var _ snapshotter.Distributor = &KubeSnapshotter{}
+
+// NewKubeSnapshotter creates a snapshotter of the resources of the cluster used by the analyzers.
+// The mesh config is read from the meshConfigFile, and defaults when it is empty.
+func NewKubeSnapshotter(k kube.Interfaces, analyzers []analysis.Analyzer, meshConfigFile, domainSuffix string) (
+	*KubeSnapshotter, error) {
+	m := schema.MustGet()
+	transformProviders := transforms.Providers(m)
+
+	combined := analysis.Combine("validation", analyzers...)
+	kubeResources := kuberesource.DisableExcludedCollections(m.KubeCollections(), transformProviders,
+		combined.Metadata().Inputs, kuberesource.DefaultExcludedResourceKinds(), true)
+
+	var mesh event.Source
+	if meshConfigFile != "" {
+		fs, err := meshcfg.NewFS(meshConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		mesh = fs
+	} else {
+		inmemory := meshcfg.NewInmemory()
+		inmemory.Set(meshcfg.Default())
+		mesh = inmemory
+	}
+
+	s := &KubeSnapshotter{}
+	rt, err := processor.Initialize(processor.Settings{
+		Metadata:     m,
+		DomainSuffix: domainSuffix,
+		Source: event.CombineSources(mesh, apiserver.New(apiserver.Options{
+			Client:  k,
+			Schemas: kubeResources,
+		})),
+		TransformProviders: transformProviders,
+		Distributor:        s,
+		EnabledSnapshots:   []string{snapshots.LocalAnalysis},
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.runtime = rt
+	return s, nil
+}
+
+// Distribute implements snapshotter.Distributor
+func (s *KubeSnapshotter) Distribute(_ string, sn *snapshotter.Snapshot) {
+	s.mu.Lock()
+	s.snapshot = sn
+	s.mu.Unlock()
+}
+
+// Snapshot returns the last snapshot of the cluster resources, or nil until they are synced.
+func (s *KubeSnapshotter) Snapshot() Snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.snapshot == nil {
+		return nil
+	}
+	return s.snapshot
+}
+
+// Run watches the cluster resources until the stop channel is closed.
+func (s *KubeSnapshotter) Run(stop <-chan struct{}) {
+	s.runtime.Start()
+	<-stop
+	s.runtime.Stop()
+}